@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/database"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/routes"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const usage = `Usage: go run ./cmd/admin [-config dir] [-org slug] [-json] user <command> [flags] [args]
+
+Commands:
+  create -username name -email email [-role role] -password-stdin
+  set-role <user> <role>
+  reset-password -password-stdin <user>
+  list [-page n] [-page-size n] [-attr name=value]...
+  disable [-reason text] <user>
+
+A user is given by ID, username or email. Passwords are read from the first
+line of stdin.
+`
+
+var errUsage = errors.New("invalid usage")
+
+// CLI runs the user commands against a UserService, so they go through the
+// same checks, events and audit log as the API.
+type CLI struct {
+	users  services.UserService
+	stdin  io.Reader
+	stdout io.Writer
+	json   bool
+}
+
+// Usage: go run ./cmd/admin [-config .] [-org slug] [-json] user <command> ...
+func main() {
+	configPath := flag.String("config", ".", "directory of config.yaml")
+	organization := flag.String("org", "", "organization slug, defaults to the default organization")
+	jsonOutput := flag.Bool("json", false, "write JSON instead of text")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if err := run(*configPath, *organization, *jsonOutput, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "admin: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, "\n"+usage)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(configPath, organization string, jsonOutput bool, args []string) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	dbConn := &database.DbConnection{Config: cfg}
+	if err := dbConn.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	if organization != "" {
+		found, err := repository.NewPostgresOrganizationRepository(dbConn.DB).GetBySlug(ctx, organization)
+		if err != nil {
+			return fmt.Errorf("failed to find organization %q: %w", organization, err)
+		}
+		ctx = tenant.NewContext(ctx, tenant.Tenant{ID: found.ID, Slug: found.Slug})
+	} else {
+		ctx = tenant.NewContext(ctx, tenant.Tenant{ID: models.DefaultOrganizationID})
+	}
+
+	users, err := routes.NewUserService(dbConn.DB, cfg, repository.NewPostgresUserRepository(dbConn.DB), auth.NewTokenService(cfg))
+	if err != nil {
+		return err
+	}
+	cli := &CLI{users: users, stdin: os.Stdin, stdout: os.Stdout, json: jsonOutput}
+	return cli.Run(ctx, args)
+}
+
+// Run runs a command given as "user <command> [flags] [args]".
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if len(args) < 2 || args[0] != "user" {
+		return errUsage
+	}
+
+	command, args := args[1], args[2:]
+	switch command {
+	case "create":
+		return c.create(ctx, args)
+	case "set-role":
+		return c.setRole(ctx, args)
+	case "reset-password":
+		return c.resetPassword(ctx, args)
+	case "list":
+		return c.list(ctx, args)
+	case "disable":
+		return c.disable(ctx, args)
+	}
+	return fmt.Errorf("%w: unknown command %q", errUsage, command)
+}
+
+func (c *CLI) create(ctx context.Context, args []string) error {
+	flags := newFlagSet("create")
+	username := flags.String("username", "", "username")
+	email := flags.String("email", "", "email")
+	role := flags.String("role", models.RoleUser, "role")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from stdin")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	password, err := c.readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+	create := &models.UserCreate{Username: *username, Email: *email, Password: password, Role: *role}
+	if err := validator.New().Struct(create); err != nil {
+		return err
+	}
+
+	user, err := c.users.Create(ctx, create)
+	if err != nil {
+		return err
+	}
+	return c.writeUser(user)
+}
+
+func (c *CLI) setRole(ctx context.Context, args []string) error {
+	flags := newFlagSet("set-role")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 {
+		return errUsage
+	}
+
+	user, err := c.findUser(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if user, err = c.users.Update(ctx, user.ID, &models.UserUpdate{Role: flags.Arg(1)}); err != nil {
+		return err
+	}
+	return c.writeUser(user)
+}
+
+func (c *CLI) resetPassword(ctx context.Context, args []string) error {
+	flags := newFlagSet("reset-password")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from stdin")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	user, err := c.findUser(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	password, err := c.readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+	if user, err = c.users.ResetPassword(ctx, user.ID, password); err != nil {
+		return err
+	}
+	return c.writeUser(user)
+}
+
+func (c *CLI) list(ctx context.Context, args []string) error {
+	flags := newFlagSet("list")
+	page := flags.Int("page", 1, "page")
+	pageSize := flags.Int("page-size", 100, "users per page, at most 100")
+	filter := models.UserFilter{Attributes: map[string]string{}}
+	flags.Func("attr", "only users whose attribute has this value, as name=value", func(value string) error {
+		name, value, ok := strings.Cut(value, "=")
+		if !ok || name == "" {
+			return errUsage
+		}
+		filter.Attributes[name] = value
+		return nil
+	})
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	users, total, err := c.users.List(ctx, filter, *page, *pageSize)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return json.NewEncoder(c.stdout).Encode(map[string]interface{}{"users": users, "total": total})
+	}
+	return c.writeTable(users...)
+}
+
+func (c *CLI) disable(ctx context.Context, args []string) error {
+	flags := newFlagSet("disable")
+	reason := flags.String("reason", "", "reason, kept in the status history")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	user, err := c.findUser(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	update := &models.UserStatusUpdate{Status: models.UserStatusDisabled, Reason: *reason}
+	if user, err = c.users.SetStatus(ctx, user.ID, update); err != nil {
+		return err
+	}
+	return c.writeUser(user)
+}
+
+// findUser looks a user up by ID, email or username.
+func (c *CLI) findUser(ctx context.Context, ref string) (*models.User, error) {
+	var user *models.User
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = c.users.GetByID(ctx, id)
+	} else if strings.Contains(ref, "@") {
+		user, err = c.users.GetByEmail(ctx, ref)
+	} else {
+		user, err = c.users.GetByUsername(ctx, ref)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("user %q not found", ref)
+	}
+	return user, err
+}
+
+// readPassword reads the first line of stdin. Passwords are never taken as
+// arguments, which would leave them in the shell history and process list.
+func (c *CLI) readPassword(fromStdin bool) (string, error) {
+	if !fromStdin {
+		return "", fmt.Errorf("%w: pass the password on stdin with -password-stdin", errUsage)
+	}
+
+	line, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("the password on stdin is empty")
+	}
+	return password, nil
+}
+
+func (c *CLI) writeUser(user *models.User) error {
+	if c.json {
+		return json.NewEncoder(c.stdout).Encode(user)
+	}
+	return c.writeTable(user)
+}
+
+func (c *CLI) writeTable(users ...*models.User) error {
+	out := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "ID\tUSERNAME\tEMAIL\tROLE\tSTATUS")
+	for _, user := range users {
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n", user.ID, user.Username, user.Email, user.Role, user.Status)
+	}
+	return out.Flush()
+}
+
+func newFlagSet(command string) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/Romasmi/go-rest-api-template/internal/utils"
+)
+
+func TestCLI(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	service := services.NewUserService(users, auth.NewFakeTokenService(), services.WithRoles(repository.NewMemoryRoleRepository(users)))
+
+	run := func(stdin string, args ...string) (*models.User, error) {
+		var stdout bytes.Buffer
+		cli := &CLI{users: service, stdin: strings.NewReader(stdin), stdout: &stdout, json: true}
+		if err := cli.Run(ctx, args); err != nil {
+			return nil, err
+		}
+		var user models.User
+		if err := json.Unmarshal(stdout.Bytes(), &user); err != nil {
+			t.Fatalf("Error while decoding output %q: %v", stdout.String(), err)
+		}
+		return &user, nil
+	}
+
+	admin, err := run("password1\n", "user", "create", "-username", "root", "-email", "root@example.com", "-role", "admin", "-password-stdin")
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	if admin.Username != "root" || admin.Role != models.RoleAdmin || admin.Status != models.UserStatusActive {
+		t.Errorf("Wrong user: %+v", admin)
+	}
+	if _, err := run("", "user", "create", "-username", "bob", "-email", "bob@example.com"); !errors.Is(err, errUsage) {
+		t.Errorf("Expected a password to be required, actual: %v", err)
+	}
+
+	if _, err := run("password1\n", "user", "create", "-username", "alice", "-email", "alice@example.com", "-password-stdin"); err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	promoted, err := run("", "user", "set-role", "alice@example.com", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("Error while setting role: %v", err)
+	}
+	if promoted.Role != models.RoleAdmin {
+		t.Errorf("Wrong role, expected: %v, actual: %v", models.RoleAdmin, promoted.Role)
+	}
+	if _, err := run("", "user", "set-role", "alice", "ghost"); !errors.Is(err, services.ErrUnknownRole) {
+		t.Errorf("Expected ErrUnknownRole, actual: %v", err)
+	}
+
+	if _, err := run("new-password1", "user", "reset-password", "-password-stdin", promoted.ID.String()); err != nil {
+		t.Fatalf("Error while resetting password: %v", err)
+	}
+	if stored, _ := users.GetByID(ctx, promoted.ID); !utils.CheckPassword("new-password1", stored.PasswordHash) || stored.TokenVersion != 1 {
+		t.Errorf("Expected the new password and revoked tokens, actual token version: %v", stored.TokenVersion)
+	}
+
+	disabled, err := run("", "user", "disable", "-reason", "spam", "alice")
+	if err != nil {
+		t.Fatalf("Error while disabling user: %v", err)
+	}
+	if disabled.Status != models.UserStatusDisabled {
+		t.Errorf("Wrong status, expected: %v, actual: %v", models.UserStatusDisabled, disabled.Status)
+	}
+	if _, err := service.Login(ctx, &models.UserLogin{Username: "alice", Password: "new-password1"}); !errors.Is(err, services.ErrUserInactive) {
+		t.Errorf("Expected ErrUserInactive, actual: %v", err)
+	}
+	if _, err := run("", "user", "disable", "nobody"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a missing user to be reported, actual: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	cli := &CLI{users: service, stdout: &stdout}
+	if err := cli.Run(ctx, []string{"user", "list"}); err != nil {
+		t.Fatalf("Error while listing users: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[2], "alice") || !strings.Contains(lines[2], "disabled") {
+		t.Errorf("Wrong list: %q", stdout.String())
+	}
+
+	if err := cli.Run(ctx, []string{"user", "promote", "alice"}); !errors.Is(err, errUsage) {
+		t.Errorf("Expected errUsage for an unknown command, actual: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/Romasmi/go-rest-api-template/internal/application"
 )
@@ -14,6 +15,12 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+//
+// Run with the "worker" argument to process background jobs, outbox events
+// and webhooks without serving HTTP.
 func main() {
 	app := &application.App{}
 	err := app.InitApp("../../")
@@ -23,5 +30,10 @@ func main() {
 	}
 	defer app.OnStop()
 
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		app.RunWorker()
+		return
+	}
+
 	app.Run()
 }
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+var identifierPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type Entity struct {
+	Name       string // Product
+	Plural     string // Products
+	Var        string // product
+	PluralVar  string // products
+	Table      string // products
+	Module     string
+	Migration  string // 000002_create_products_table
+	PluralPath string // /products
+}
+
+type output struct {
+	template string
+	path     string
+}
+
+// Usage: go run ./cmd/scaffold -name product [-plural products] [-root .]
+func main() {
+	name := flag.String("name", "", "entity name in snake_case, e.g. product or order_item")
+	plural := flag.String("plural", "", "plural form of the name, defaults to an English guess")
+	root := flag.String("root", ".", "repository root")
+	flag.Parse()
+
+	files, err := Generate(*root, *name, *plural)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "scaffold: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, file := range files {
+		fmt.Println("created", file)
+	}
+	entity, _ := newEntity(*root, *name, *plural)
+	fmt.Printf("\nRegister the routes in internal/routes/routes.go:\n\n\tRegister%sRoutes(protected, db)\n", entity.Plural)
+}
+
+func Generate(root, name, plural string) ([]string, error) {
+	entity, err := newEntity(root, name, plural)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := []output{
+		{"model.go.tmpl", filepath.Join("internal", "models", name+".go")},
+		{"repository.go.tmpl", filepath.Join("internal", "repository", name+"_repository.go")},
+		{"service.go.tmpl", filepath.Join("internal", "services", name+"_service.go")},
+		{"handler.go.tmpl", filepath.Join("internal", "handlers", name+"_handler.go")},
+		{"routes.go.tmpl", filepath.Join("internal", "routes", entity.Table+"_routes.go")},
+		{"migration.up.sql.tmpl", filepath.Join("migrations", entity.Migration+".up.sql")},
+		{"migration.down.sql.tmpl", filepath.Join("migrations", entity.Migration+".down.sql")},
+	}
+
+	for _, out := range outputs {
+		if _, err := os.Stat(filepath.Join(root, out.path)); err == nil {
+			return nil, fmt.Errorf("%s already exists", out.path)
+		}
+	}
+
+	tmpl, err := template.ParseFS(templates, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	var created []string
+	for _, out := range outputs {
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, out.template, entity); err != nil {
+			return created, fmt.Errorf("failed to render %s: %w", out.template, err)
+		}
+
+		content := buf.Bytes()
+		if strings.HasSuffix(out.path, ".go") {
+			content, err = format.Source(content)
+			if err != nil {
+				return created, fmt.Errorf("failed to format %s: %w", out.path, err)
+			}
+		}
+
+		if err := os.WriteFile(filepath.Join(root, out.path), content, 0644); err != nil {
+			return created, err
+		}
+		created = append(created, out.path)
+	}
+
+	return created, nil
+}
+
+func newEntity(root, name, plural string) (*Entity, error) {
+	if !identifierPattern.MatchString(name) {
+		return nil, errors.New("-name must be snake_case, e.g. product or order_item")
+	}
+	if plural == "" {
+		plural = pluralize(name)
+	}
+	if !identifierPattern.MatchString(plural) {
+		return nil, errors.New("-plural must be snake_case")
+	}
+
+	module, err := modulePath(root)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := nextMigrationVersion(filepath.Join(root, "migrations"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Entity{
+		Name:       camel(name, true),
+		Plural:     camel(plural, true),
+		Var:        camel(name, false),
+		PluralVar:  camel(plural, false),
+		Table:      plural,
+		Module:     module,
+		Migration:  fmt.Sprintf("%06d_create_%s_table", version, plural),
+		PluralPath: "/" + strings.ReplaceAll(plural, "_", "-"),
+	}, nil
+}
+
+func modulePath(root string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "module ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "module ")), nil
+		}
+	}
+	return "", errors.New("go.mod has no module directive")
+}
+
+func nextMigrationVersion(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var versions []int
+	for _, entry := range entries {
+		prefix, _, ok := strings.Cut(entry.Name(), "_")
+		if !ok {
+			continue
+		}
+		if version, err := strconv.Atoi(prefix); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+
+	if len(versions) == 0 {
+		return 1, nil
+	}
+	return versions[len(versions)-1] + 1, nil
+}
+
+func pluralize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "y") && !strings.HasSuffix(name, "ay") && !strings.HasSuffix(name, "ey") && !strings.HasSuffix(name, "oy"):
+		return name[:len(name)-1] + "ies"
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	default:
+		return name + "s"
+	}
+}
+
+func camel(name string, exported bool) string {
+	var b strings.Builder
+	for i, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		runes := []rune(part)
+		if i > 0 || exported {
+			runes[0] = unicode.ToUpper(runes[0])
+		}
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
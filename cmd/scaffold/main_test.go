@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"internal/models", "internal/repository", "internal/services", "internal/handlers", "internal/routes", "migrations"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n\ngo 1.24.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "migrations", "000001_create_users_table.up.sql"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Generate(root, "category", "")
+	if err != nil {
+		t.Fatalf("Error while generating: %v", err)
+	}
+	if len(files) != 7 {
+		t.Errorf("Wrong number of generated files, expected: %v, actual: %v", 7, len(files))
+	}
+
+	migration, err := os.ReadFile(filepath.Join(root, "migrations", "000002_create_categories_table.up.sql"))
+	if err != nil {
+		t.Fatalf("Expected migration to be created: %v", err)
+	}
+	if !strings.Contains(string(migration), "CREATE TABLE IF NOT EXISTS categories") {
+		t.Errorf("Wrong migration content: %s", migration)
+	}
+
+	repo, err := os.ReadFile(filepath.Join(root, "internal", "repository", "category_repository.go"))
+	if err != nil {
+		t.Fatalf("Expected repository to be created: %v", err)
+	}
+	if !strings.Contains(string(repo), `"example.com/app/internal/models"`) {
+		t.Error("Expected repository to import models from the module path")
+	}
+
+	if _, err := Generate(root, "category", ""); err == nil {
+		t.Error("Expected existing files not to be overwritten")
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	cases := map[string]string{
+		"product":  "products",
+		"category": "categories",
+		"day":      "days",
+		"box":      "boxes",
+		"address":  "addresses",
+	}
+	for name, expected := range cases {
+		if actual := pluralize(name); actual != expected {
+			t.Errorf("Wrong plural for %v, expected: %v, actual: %v", name, expected, actual)
+		}
+	}
+}
@@ -2,18 +2,23 @@ package application
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
 	"github.com/Romasmi/go-rest-api-template/internal/config"
 	"github.com/Romasmi/go-rest-api-template/internal/database"
-	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/jobs"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
 	"github.com/Romasmi/go-rest-api-template/internal/routes"
 	ghandlers "github.com/gorilla/handlers"
 
@@ -24,28 +29,50 @@ type App struct {
 	config *config.Config
 	dbConn *database.DbConnection
 	router *mux.Router
+	tokens auth.TokenService
+	jobs   *jobs.Client
+	users  *repository.CachedUserRepository
 	logger *log.Logger
 }
 
+// NewApp builds an App around an already connected database, which lets tests
+// run the full router without going through InitApp.
+func NewApp(config *config.Config, dbConn *database.DbConnection) (*App, error) {
+	app := &App{
+		config: config,
+		dbConn: dbConn,
+		tokens: auth.NewTokenService(config),
+		jobs:   jobs.NewClient(repository.NewPostgresJobRepository(dbConn.DB), config.Jobs.MaxAttempts),
+		users: repository.NewCachedUserRepository(
+			repository.NewPostgresUserRepository(dbConn.DB), config.UsersCache.Size, config.UsersCache.TTL,
+		),
+		router: mux.NewRouter(),
+		logger: log.New(os.Stdout, "API: ", log.LstdFlags),
+	}
+	if err := routes.RegisterRoutes(app.router, app.dbConn.DB, app.config, app.tokens, app.users); err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
 func (app *App) InitApp(configPath string) error {
 	envConfig, err := config.LoadConfig(configPath)
 	if err != nil {
 		return fmt.Errorf("error loading config: %v\n", err)
 	}
-	app.config = envConfig
 
 	dbConn := &database.DbConnection{Config: envConfig}
 	err = dbConn.Connect()
 	if err != nil {
 		return fmt.Errorf("error connecting to DB: %v\n", err)
 	}
-	app.dbConn = dbConn
-	app.router = mux.NewRouter()
-	routes.RegisterRoutes(app.router, app.dbConn.DB, app.config)
-
-	authMiddleware.InitAuth(envConfig)
-
-	app.logger = log.New(os.Stdout, "API: ", log.LstdFlags)
+	created, err := NewApp(envConfig, dbConn)
+	if err != nil {
+		dbConn.Close()
+		return fmt.Errorf("error registering routes: %v\n", err)
+	}
+	*app = *created
 
 	if err := dbConn.RunMigrations("up"); err != nil {
 		app.logger.Fatalf("Failed to run migrations: %v", err)
@@ -54,14 +81,40 @@ func (app *App) InitApp(configPath string) error {
 	return nil
 }
 
+func (app *App) Router() *mux.Router {
+	return app.router
+}
+
+func (app *App) Jobs() *jobs.Client {
+	return app.jobs
+}
+
 func (app *App) OnStop() {
 	app.dbConn.Close()
 }
 
 func (app *App) Run() {
-	var handler http.Handler = app.router
-	handler = ghandlers.CombinedLoggingHandler(os.Stdout, handler)
-	handler = http.TimeoutHandler(handler, 60*time.Second, "Request timed out")
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	go app.users.Listen(workersCtx, app.dbConn.DB)
+	workersDone := &sync.WaitGroup{}
+	if !app.config.Jobs.SeparateWorker {
+		var err error
+		if workersDone, err = app.startWorkers(workersCtx); err != nil {
+			app.logger.Fatalf("Failed to start background workers: %v", err)
+		}
+	}
+
+	logged := ghandlers.CombinedLoggingHandler(os.Stdout, app.router)
+	timed := http.TimeoutHandler(logged, 60*time.Second, "Request timed out")
+	// TimeoutHandler buffers the whole response, so exports, which are
+	// streamed, bypass it.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/export") {
+			logged.ServeHTTP(w, r)
+			return
+		}
+		timed.ServeHTTP(w, r)
+	})
 
 	server := &http.Server{
 		Addr:         ":" + strconv.Itoa(int(app.config.Server.Port)),
@@ -73,7 +126,7 @@ func (app *App) Run() {
 
 	go func() {
 		err := server.ListenAndServe()
-		if err != nil {
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("Server error: %v", err)
 		}
 	}()
@@ -91,5 +144,9 @@ func (app *App) Run() {
 		app.logger.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	app.logger.Println("Draining background workers...")
+	stopWorkers()
+	workersDone.Wait()
+
 	app.logger.Println("Server gracefully stopped")
 }
@@ -0,0 +1,143 @@
+package application
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/events"
+	"github.com/Romasmi/go-rest-api-template/internal/jobs"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/routes"
+	"github.com/Romasmi/go-rest-api-template/internal/webhooks"
+)
+
+// PurgeOutboxArgs deletes outbox events delivered more than OlderThan ago.
+type PurgeOutboxArgs struct {
+	OlderThan time.Duration `json:"older_than"`
+}
+
+func (PurgeOutboxArgs) Kind() string {
+	return "outbox.purge"
+}
+
+// PurgeIdempotencyKeysArgs deletes the stored responses of expired
+// idempotency keys.
+type PurgeIdempotencyKeysArgs struct{}
+
+func (PurgeIdempotencyKeysArgs) Kind() string {
+	return "idempotency.purge"
+}
+
+// registerJobs adds the handlers and schedules of all background jobs.
+func (app *App) registerJobs(worker *jobs.Worker) error {
+	outbox := repository.NewPostgresOutboxRepository(app.dbConn.DB)
+	jobs.Register(worker, func(ctx context.Context, job *jobs.Job[PurgeOutboxArgs]) error {
+		deleted, err := outbox.DeleteDelivered(ctx, time.Now().Add(-job.Args.OlderThan))
+		if err != nil {
+			return err
+		}
+		app.logger.Printf("Purged %d delivered outbox events", deleted)
+		return nil
+	})
+
+	idempotency := repository.NewPostgresIdempotencyRepository(app.dbConn.DB)
+	jobs.Register(worker, func(ctx context.Context, job *jobs.Job[PurgeIdempotencyKeysArgs]) error {
+		deleted, err := idempotency.DeleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+		app.logger.Printf("Purged %d expired idempotency keys", deleted)
+		return nil
+	})
+
+	imports, err := routes.NewUserImportService(app.dbConn.DB, app.config, app.users, app.tokens)
+	if err != nil {
+		return err
+	}
+	jobs.Register(worker, imports.Run)
+
+	if err := worker.Schedule("outbox.purge", "@daily", PurgeOutboxArgs{OlderThan: 7 * 24 * time.Hour}); err != nil {
+		return err
+	}
+	return worker.Schedule("idempotency.purge", "@hourly", PurgeIdempotencyKeysArgs{})
+}
+
+// startWorkers runs the outbox dispatcher, the webhook worker and the job
+// worker in the background until ctx is cancelled. The returned WaitGroup is
+// done once all of them have stopped, including draining running jobs.
+func (app *App) startWorkers(ctx context.Context) (*sync.WaitGroup, error) {
+	var wg sync.WaitGroup
+
+	sink, err := events.NewSink(app.config.Events)
+	if err != nil {
+		return &wg, err
+	}
+
+	webhookService, err := routes.NewWebhookService(app.dbConn.DB, app.config)
+	if err != nil {
+		return &wg, err
+	}
+	webhookSecrets, err := routes.NewWebhookSecrets(app.config)
+	if err != nil {
+		return &wg, err
+	}
+
+	sinks := events.FanoutSink{webhookService}
+	if sink != nil {
+		sinks = append(sinks, sink)
+	}
+
+	jobWorker := jobs.NewWorker(repository.NewPostgresJobRepository(app.dbConn.DB), app.jobs, jobs.NewWorkerOptions(app.config.Jobs))
+	if err := app.registerJobs(jobWorker); err != nil {
+		return &wg, err
+	}
+
+	workers := []interface{ Run(ctx context.Context) }{
+		events.NewDispatcher(
+			repository.NewPostgresOutboxRepository(app.dbConn.DB),
+			sinks,
+			events.NewDispatcherOptions(app.config.Events),
+		),
+		webhooks.NewWorker(
+			repository.NewPostgresWebhookRepository(app.dbConn.DB),
+			webhookSecrets,
+			webhooks.NewWorkerOptions(app.config.Webhooks),
+		),
+		jobWorker,
+	}
+
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(worker interface{ Run(ctx context.Context) }) {
+			defer wg.Done()
+			worker.Run(ctx)
+		}(worker)
+	}
+	return &wg, nil
+}
+
+// RunWorker runs only the background workers, without the HTTP server, until
+// SIGINT or SIGTERM. It is used by the worker subcommand together with
+// jobs.separateWorker in the API's config.
+func (app *App) RunWorker() {
+	ctx, stop := context.WithCancel(context.Background())
+	done, err := app.startWorkers(ctx)
+	if err != nil {
+		app.logger.Fatalf("Failed to start background workers: %v", err)
+	}
+	app.logger.Println("Worker started")
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	app.logger.Println("Draining background workers...")
+	stop()
+	done.Wait()
+
+	app.logger.Println("Worker gracefully stopped")
+}
@@ -0,0 +1,17 @@
+package auth
+
+import "context"
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+func FromContext(ctx context.Context) Claims {
+	claims, _ := ctx.Value(contextKey{}).(Claims)
+	if claims == nil {
+		return Claims{}
+	}
+	return claims
+}
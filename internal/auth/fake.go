@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// FakeTokenService issues opaque tokens kept in memory. It is meant for tests
+// that need a TokenService without a signing secret.
+type FakeTokenService struct {
+	mu     sync.Mutex
+	next   int
+	tokens map[string]Claims
+}
+
+func NewFakeTokenService() *FakeTokenService {
+	return &FakeTokenService{
+		tokens: make(map[string]Claims),
+	}
+}
+
+func (s *FakeTokenService) Issue(claims Claims) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next++
+	token := "fake-token-" + strconv.Itoa(s.next)
+
+	stored := make(Claims, len(claims))
+	for k, v := range claims {
+		stored[k] = v
+	}
+	s.tokens[token] = stored
+
+	return token, nil
+}
+
+func (s *FakeTokenService) Verify(_ context.Context, token string) (Claims, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claims, ok := s.tokens[token]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func (s *FakeTokenService) Revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, token)
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+const DefaultTokenTTL = 24 * time.Hour
+
+type JWTService struct {
+	auth *jwtauth.JWTAuth
+	ttl  time.Duration
+}
+
+func NewJWTService(config *config.Config) *JWTService {
+	ttl := config.JWT.ExpirationTTL
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+
+	return &JWTService{
+		auth: jwtauth.New("HS256", []byte(config.JWT.Secret), nil),
+		ttl:  ttl,
+	}
+}
+
+func (s *JWTService) Issue(claims Claims) (string, error) {
+	payload := make(map[string]interface{}, len(claims)+1)
+	for k, v := range claims {
+		payload[k] = v
+	}
+	if _, ok := payload["exp"]; !ok {
+		payload["exp"] = time.Now().Add(s.ttl).Unix()
+	}
+
+	_, tokenString, err := s.auth.Encode(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func (s *JWTService) Verify(ctx context.Context, tokenString string) (Claims, error) {
+	token, err := jwtauth.VerifyToken(s.auth, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := token.AsMap(ctx)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
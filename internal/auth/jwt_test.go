@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+)
+
+func TestJWTServiceRoundTrip(t *testing.T) {
+	service := NewJWTService(&config.Config{JWT: config.JWTConfig{Secret: "first-secret"}})
+
+	token, err := service.Issue(UserClaims("42", "admin"))
+	if err != nil {
+		t.Fatalf("Error while issuing token: %v", err)
+	}
+
+	claims, err := service.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Error while verifying token: %v", err)
+	}
+
+	if claims["user_id"] != "42" {
+		t.Errorf("Wrong user_id claim, expected: %v, actual: %v", "42", claims["user_id"])
+	}
+	if claims["role"] != "admin" {
+		t.Errorf("Wrong role claim, expected: %v, actual: %v", "admin", claims["role"])
+	}
+}
+
+func TestJWTServiceRejectsForeignSecret(t *testing.T) {
+	first := NewJWTService(&config.Config{JWT: config.JWTConfig{Secret: "first-secret"}})
+	second := NewJWTService(&config.Config{JWT: config.JWTConfig{Secret: "second-secret"}})
+
+	token, err := first.Issue(UserClaims("42", "user"))
+	if err != nil {
+		t.Fatalf("Error while issuing token: %v", err)
+	}
+
+	if _, err := second.Verify(context.Background(), token); err == nil {
+		t.Error("Expected token signed with another secret to be rejected")
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+const (
+	ScopeAll        = "*"
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+	ScopeAPIKeys    = "api_keys"
+	ScopeAdmin      = "admin"
+)
+
+// HasScope reports whether claims allow scope. Claims without a scopes entry,
+// such as those of a login JWT, carry the user's full access.
+func HasScope(claims Claims, scope string) bool {
+	raw, ok := claims["scopes"]
+	if !ok {
+		return true
+	}
+
+	var scopes []string
+	switch v := raw.(type) {
+	case []string:
+		scopes = v
+	case []interface{}:
+		for _, s := range v {
+			if str, ok := s.(string); ok {
+				scopes = append(scopes, str)
+			}
+		}
+	}
+
+	for _, s := range scopes {
+		if s == scope || s == ScopeAll {
+			return true
+		}
+	}
+	return false
+}
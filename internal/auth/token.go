@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+)
+
+var ErrInvalidToken = errors.New("token is unauthorized")
+
+type Claims map[string]interface{}
+
+type TokenIssuer interface {
+	Issue(claims Claims) (string, error)
+}
+
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (Claims, error)
+}
+
+type TokenService interface {
+	TokenIssuer
+	TokenVerifier
+}
+
+func NewTokenService(config *config.Config) TokenService {
+	return NewJWTService(config)
+}
+
+func UserClaims(userID string, role string) Claims {
+	return Claims{
+		"user_id": userID,
+		"role":    role,
+	}
+}
+
+// ClaimOrganization holds the ID of the organization a token was issued in,
+// as a string. Tokens issued before multi-tenancy do not have it.
+const ClaimOrganization = "org_id"
+
+func WithOrganization(claims Claims, organizationID int) Claims {
+	claims[ClaimOrganization] = strconv.Itoa(organizationID)
+	return claims
+}
+
+func OrganizationID(claims Claims) (int, bool) {
+	value, _ := claims[ClaimOrganization].(string)
+	id, err := strconv.Atoi(value)
+	return id, err == nil
+}
+
+// ClaimTokenVersion holds the token version of the user the token was issued
+// to, as a string. Tokens without it are version 0.
+const ClaimTokenVersion = "token_version"
+
+func WithTokenVersion(claims Claims, version int) Claims {
+	claims[ClaimTokenVersion] = strconv.Itoa(version)
+	return claims
+}
+
+func TokenVersion(claims Claims) int {
+	value, _ := claims[ClaimTokenVersion].(string)
+	version, _ := strconv.Atoi(value)
+	return version
+}
+
+// ClaimSession holds the ID of the session a login token belongs to. Tokens
+// issued before sessions existed do not have it.
+const ClaimSession = "sid"
+
+func WithSession(claims Claims, sessionID string) Claims {
+	claims[ClaimSession] = sessionID
+	return claims
+}
+
+func SessionID(claims Claims) (string, bool) {
+	id, ok := claims[ClaimSession].(string)
+	return id, ok && id != ""
+}
+
+// ClaimPurpose marks tokens that only serve one step of a flow, such as the
+// second factor of a login. Authenticator rejects them.
+const ClaimPurpose = "purpose"
+
+const (
+	PurposeMFA             = "mfa"
+	PurposeMFAEnroll       = "mfa_enroll"
+	PurposePasskeyRegister = "passkey_register"
+	PurposePasskeyLogin    = "passkey_login"
+	PurposeEmailChange     = "email_change"
+)
+
+// MFAChallengeClaims identify a user who passed the first login factor. They
+// use mfa_user_id rather than user_id so they cannot act as the user.
+func MFAChallengeClaims(userID string, organizationID int, purpose string, ttl time.Duration) Claims {
+	return Claims{
+		"mfa_user_id":     userID,
+		ClaimOrganization: strconv.Itoa(organizationID),
+		ClaimPurpose:      purpose,
+		"exp":             time.Now().Add(ttl).Unix(),
+	}
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[string, int](2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if value, ok := c.Get("a"); !ok || value != 1 {
+		t.Errorf("Wrong value for a, expected: %v, actual: %v", 1, value)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Wrong length, expected: %v, actual: %v", 2, c.Len())
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	now := time.Now()
+	c := NewLRU[string, int](2, time.Minute)
+	c.now = func() time.Time { return now }
+	c.Set("a", 1)
+
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to be expired")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Wrong length, expected: %v, actual: %v", 0, c.Len())
+	}
+}
+
+func TestLRUDeleteFunc(t *testing.T) {
+	c := NewLRU[int, string](10, time.Minute)
+	for i := 0; i < 4; i++ {
+		c.Set(i, "v")
+	}
+	c.DeleteFunc(func(key int) bool { return key%2 == 0 })
+
+	if c.Len() != 2 {
+		t.Errorf("Wrong length, expected: %v, actual: %v", 2, c.Len())
+	}
+	if _, ok := c.Get(1); !ok {
+		t.Error("Expected 1 to be kept")
+	}
+}
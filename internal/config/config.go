@@ -10,9 +10,25 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig `mapstructure:"server"` // mapping in annotation is optional and by default is use property name as it is
-	Database DatabaseConfig
-	JWT      JWTConfig
+	Server      ServerConfig `mapstructure:"server"` // mapping in annotation is optional and by default is use property name as it is
+	Database    DatabaseConfig
+	JWT         JWTConfig
+	Events      EventsConfig
+	Webhooks    WebhooksConfig
+	Jobs        JobsConfig
+	Tenancy     TenancyConfig
+	OIDC        OIDCConfig
+	MFA         MFAConfig
+	WebAuthn    WebAuthnConfig
+	Password    PasswordConfig
+	Mail        MailConfig
+	Sessions    SessionsConfig
+	Idempotency IdempotencyConfig
+	UsersCache  UsersCacheConfig
+	Compression CompressionConfig
+	UserImport  UserImportConfig
+	Profile     ProfileConfig
+	Storage     StorageConfig
 }
 
 type ServerConfig struct {
@@ -20,6 +36,10 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	// TrustedProxies are the addresses and CIDR ranges of reverse proxies
+	// whose X-Forwarded-* headers are believed. Without any, the client IP is
+	// the address of the connection.
+	TrustedProxies []string
 }
 
 type DatabaseConfig struct {
@@ -35,6 +55,213 @@ type JWTConfig struct {
 	ExpirationTTL time.Duration
 }
 
+type EventsConfig struct {
+	// Sink is one of "stdout", "webhook" or "none". Webhook subscriptions
+	// receive events regardless of the sink.
+	Sink         string
+	WebhookURL   string
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+}
+
+type WebhooksConfig struct {
+	PollInterval time.Duration
+	MaxAttempts  int
+	Timeout      time.Duration
+	// SecretKey encrypts the signing secrets of subscriptions. It defaults to
+	// jwt.secret.
+	SecretKey string
+	// AllowPrivateNetworks lets webhooks reach loopback, link-local and
+	// private addresses, which is refused by default.
+	AllowPrivateNetworks bool
+}
+
+type JobsConfig struct {
+	// SeparateWorker stops the API process from running background work.
+	// It is then done by the worker subcommand.
+	SeparateWorker bool
+	Concurrency    int
+	PollInterval   time.Duration
+	MaxAttempts    int
+	DrainTimeout   time.Duration
+	// RescueAfter is how long a job may run before it is assumed that its
+	// worker died and it is made available again. Keep it above the longest
+	// job.
+	RescueAfter time.Duration
+	// Retention is how long completed and discarded jobs are kept.
+	Retention time.Duration
+}
+
+type TenancyConfig struct {
+	// BaseDomain enables tenant resolution from subdomains, e.g. requests to
+	// acme.example.com use the "acme" organization when it is "example.com".
+	BaseDomain string
+}
+
+type OIDCConfig struct {
+	// PublicURL is the externally visible base URL of the API. Callbacks go
+	// to <PublicURL>/api/v1/auth/oidc/<provider>/callback.
+	PublicURL string
+	// PostLoginRedirectURL, when set, receives the token in the URL fragment
+	// at the end of the flow instead of a JSON response.
+	PostLoginRedirectURL string
+	Providers            map[string]OIDCProviderConfig
+}
+
+type OIDCProviderConfig struct {
+	// Type is "oidc" (the default) for providers with OpenID Connect
+	// discovery at Issuer, or "github".
+	Type         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// LinkByEmail links the first sign-in to the existing user with the same
+	// verified email instead of creating a new user.
+	LinkByEmail bool
+}
+
+type MFAConfig struct {
+	// Issuer names the account in authenticator apps.
+	Issuer string
+	// RequiredRoles must enroll in two-factor authentication before they
+	// can sign in.
+	RequiredRoles []string
+	ChallengeTTL  time.Duration
+	// MaxFailures invalid codes in a row lock two-factor verification of a
+	// user for LockoutDuration.
+	MaxFailures     int
+	LockoutDuration time.Duration
+}
+
+type WebAuthnConfig struct {
+	// RPID is the domain passkeys are bound to, such as "example.com". They
+	// work on that domain and its subdomains.
+	RPID          string
+	RPDisplayName string
+	// RPOrigins are the origins of the web apps allowed to use passkeys,
+	// such as "https://app.example.com".
+	RPOrigins []string
+}
+
+type PasswordConfig struct {
+	// Algorithm is "bcrypt" (the default) or "argon2id". Passwords hashed
+	// differently are rehashed at the next successful login.
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Config
+	Policy     PasswordPolicyConfig
+}
+
+type Argon2Config struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+type PasswordPolicyConfig struct {
+	MinLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSymbol  bool
+	RejectUserInfo bool
+	// RejectCommon rejects passwords from the bundled list of common
+	// passwords.
+	RejectCommon bool
+	// BreachedRangesDir holds Have I Been Pwned range files named after the
+	// first five hex characters of the SHA-1 hash, as written by its
+	// downloader. Passwords found there are rejected.
+	BreachedRangesDir string
+}
+
+type MailConfig struct {
+	// SMTPAddr is the host:port of the SMTP server. Without it emails are
+	// written to the log.
+	SMTPAddr string
+	Username string
+	Password string
+	From     string
+	// ConfirmEmailURL is the page that confirms email changes. It gets the
+	// token as ?token=... When empty the email holds the bare token.
+	ConfirmEmailURL string
+}
+
+type SessionsConfig struct {
+	// CacheTTL is how long a replica trusts its last check of a session.
+	// Sessions revoked on another replica keep working for up to this long.
+	CacheTTL time.Duration
+}
+
+type IdempotencyConfig struct {
+	// TTL is how long responses are kept for retries with the same
+	// Idempotency-Key.
+	TTL time.Duration
+}
+
+type UsersCacheConfig struct {
+	// Size bounds the number of cached users.
+	Size int
+	// TTL bounds how stale a cached user can be should an invalidation be
+	// missed.
+	TTL time.Duration
+}
+
+type CompressionConfig struct {
+	// MinSize is the smallest response body, in bytes, that is compressed.
+	MinSize int
+}
+
+type UserImportConfig struct {
+	// MaxSize bounds the body of an import, in bytes.
+	MaxSize int64
+	// MaxSyncSize is the largest import, in bytes, run within the request.
+	// Larger ones run as a background job.
+	MaxSyncSize int64
+}
+
+type ProfileConfig struct {
+	// AttributesSchema is a file with the JSON Schema the attributes of users
+	// must match. Without one any JSON object is accepted.
+	AttributesSchema string
+	// AvatarMaxSize bounds uploaded avatars, in bytes.
+	AvatarMaxSize int64
+	// AvatarSize is the width and height, in pixels, avatars are scaled to.
+	AvatarSize int
+}
+
+type StorageConfig struct {
+	// Driver is "local" (the default) or "s3".
+	Driver string
+	// PublicURL is the base URL stored files are served from. Local files
+	// are served by the API under /files.
+	PublicURL string
+	Local     LocalStorageConfig
+	S3        S3StorageConfig
+}
+
+type LocalStorageConfig struct {
+	Dir string
+}
+
+// S3StorageConfig works with S3 and compatible services such as MinIO.
+type S3StorageConfig struct {
+	// Endpoint is the base URL of the service, such as
+	// "https://s3.eu-west-1.amazonaws.com".
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle puts the bucket in the path instead of the host name, which
+	// most S3-compatible services need.
+	PathStyle bool
+	// Timeout bounds each request to the service.
+	Timeout time.Duration
+}
+
 func bindEnvRecursive(v *viper.Viper, prefix string, val reflect.Value) error {
 	for i := 0; i < val.NumField(); i++ {
 		field := val.Type().Field(i)
@@ -0,0 +1,35 @@
+package events
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+)
+
+// NewSink builds the sink selected in config. It returns nil for "none".
+// NATS and Kafka sinks need a client and are wired up in code.
+func NewSink(config config.EventsConfig) (Sink, error) {
+	switch config.Sink {
+	case "", "stdout":
+		return NewStdoutSink(nil), nil
+	case "webhook":
+		if config.WebhookURL == "" {
+			return nil, fmt.Errorf("events.webhookUrl is required for the webhook sink")
+		}
+		return NewWebhookSink(config.WebhookURL, &http.Client{Timeout: 10 * time.Second}), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown events sink %q", config.Sink)
+	}
+}
+
+func NewDispatcherOptions(config config.EventsConfig) DispatcherOptions {
+	return DispatcherOptions{
+		PollInterval: config.PollInterval,
+		BatchSize:    config.BatchSize,
+		MaxAttempts:  config.MaxAttempts,
+	}
+}
@@ -0,0 +1,137 @@
+package events
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	defaultMaxAttempts  = 10
+	defaultMaxBackoff   = time.Hour
+	defaultLease        = 5 * time.Minute
+)
+
+type DispatcherOptions struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is the number of failed deliveries after which an event is
+	// marked failed and no longer retried. Later events with the same
+	// ordering key are then delivered.
+	MaxAttempts int
+	// Backoff returns the delay before the given attempt is retried.
+	Backoff func(attempts int) time.Duration
+	// Lease is how long claimed events are kept from other dispatchers.
+	// Events of a batch not attempted within it are left to be claimed again.
+	Lease time.Duration
+}
+
+// Dispatcher polls the outbox and hands due events to the sink.
+type Dispatcher struct {
+	outbox repository.OutboxRepository
+	sink   Sink
+	opts   DispatcherOptions
+	logger *log.Logger
+}
+
+func NewDispatcher(outbox repository.OutboxRepository, sink Sink, opts DispatcherOptions) *Dispatcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = ExponentialBackoff(time.Second, defaultMaxBackoff)
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = defaultLease
+	}
+
+	return &Dispatcher{
+		outbox: outbox,
+		sink:   sink,
+		opts:   opts,
+		logger: log.New(log.Writer(), "EVENTS: ", log.LstdFlags),
+	}
+}
+
+// Run dispatches events until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := d.DispatchOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Printf("failed to dispatch outbox events: %v", err)
+		}
+
+		// A full batch means there is probably more waiting.
+		if n == d.opts.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce delivers one batch of due events and returns how many were
+// claimed. Events are leased rather than locked, so no transaction is held
+// open while the sink is called.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	claimedAt := time.Now()
+	events, err := d.outbox.ClaimDue(ctx, d.opts.BatchSize, d.opts.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if time.Since(claimedAt) >= d.opts.Lease {
+			break
+		}
+
+		deliverErr := d.sink.Deliver(ctx, event.DomainEvent())
+		if deliverErr == nil {
+			err = d.outbox.MarkDelivered(ctx, event.ID)
+		} else if attempts := event.Attempts + 1; attempts >= d.opts.MaxAttempts {
+			d.logger.Printf("giving up on event %s (%s) after %d attempts: %v", event.EventID, event.EventType, attempts, deliverErr)
+			err = d.outbox.MarkFailed(ctx, event.ID, attempts, deliverErr.Error())
+		} else {
+			err = d.outbox.MarkRetry(ctx, event.ID, attempts, time.Now().Add(d.opts.Backoff(attempts)), deliverErr.Error())
+		}
+		if err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// ExponentialBackoff doubles the delay after every attempt, starting at base
+// and capped at max.
+func ExponentialBackoff(base, max time.Duration) func(attempts int) time.Duration {
+	return func(attempts int) time.Duration {
+		delay := base
+		for i := 1; i < attempts; i++ {
+			delay *= 2
+			if delay >= max {
+				return max
+			}
+		}
+		return delay
+	}
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/google/uuid"
+)
+
+func addEvent(t *testing.T, outbox repository.OutboxRepository, eventType, key string) {
+	t.Helper()
+	err := outbox.Add(context.Background(), &models.DomainEvent{
+		ID:          uuid.New(),
+		Type:        eventType,
+		OrderingKey: key,
+		OccurredAt:  time.Now(),
+		Payload:     []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("Error while adding event: %v", err)
+	}
+}
+
+func TestDispatcherKeepsOrderPerKey(t *testing.T) {
+	ctx := context.Background()
+	outbox := repository.NewMemoryOutboxRepository()
+	addEvent(t, outbox, "first", "a")
+	addEvent(t, outbox, "second", "a")
+	addEvent(t, outbox, "other", "b")
+
+	var delivered []string
+	sink := SinkFunc(func(ctx context.Context, event *models.DomainEvent) error {
+		delivered = append(delivered, event.Type)
+		return nil
+	})
+	d := NewDispatcher(outbox, sink, DispatcherOptions{})
+
+	if n, err := d.DispatchOnce(ctx); err != nil || n != 2 {
+		t.Fatalf("Wrong first batch, expected: %v, actual: %v (%v)", 2, n, err)
+	}
+	if n, err := d.DispatchOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("Wrong second batch, expected: %v, actual: %v (%v)", 1, n, err)
+	}
+
+	expected := []string{"first", "other", "second"}
+	for i := range expected {
+		if delivered[i] != expected[i] {
+			t.Errorf("Wrong delivery order, expected: %v, actual: %v", expected, delivered)
+			break
+		}
+	}
+}
+
+func TestDispatcherRetriesAndGivesUp(t *testing.T) {
+	ctx := context.Background()
+	outbox := repository.NewMemoryOutboxRepository()
+	addEvent(t, outbox, "broken", "a")
+	addEvent(t, outbox, "next", "a")
+
+	var delivered []string
+	sink := SinkFunc(func(ctx context.Context, event *models.DomainEvent) error {
+		if event.Type == "broken" {
+			return errors.New("sink unavailable")
+		}
+		delivered = append(delivered, event.Type)
+		return nil
+	})
+	d := NewDispatcher(outbox, sink, DispatcherOptions{
+		MaxAttempts: 2,
+		Backoff:     func(int) time.Duration { return 0 },
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := d.DispatchOnce(ctx); err != nil {
+			t.Fatalf("Error while dispatching: %v", err)
+		}
+	}
+
+	events := outbox.Events()
+	if events[0].Status != models.OutboxStatusFailed || events[0].Attempts != 2 {
+		t.Errorf("Wrong broken event state, expected: %v/%v, actual: %v/%v", models.OutboxStatusFailed, 2, events[0].Status, events[0].Attempts)
+	}
+	if events[0].LastError != "sink unavailable" {
+		t.Errorf("Wrong last error, expected: %v, actual: %v", "sink unavailable", events[0].LastError)
+	}
+	if len(delivered) != 1 || events[1].Status != models.OutboxStatusDelivered {
+		t.Errorf("Expected event after a failed one to be delivered, actual: %v", delivered)
+	}
+}
+
+func TestDispatcherLeasesClaimedEvents(t *testing.T) {
+	ctx := context.Background()
+	outbox := repository.NewMemoryOutboxRepository()
+	addEvent(t, outbox, "first", "a")
+
+	other := NewDispatcher(outbox, SinkFunc(func(context.Context, *models.DomainEvent) error { return nil }), DispatcherOptions{})
+	var claimedMeanwhile int
+	sink := SinkFunc(func(ctx context.Context, event *models.DomainEvent) error {
+		// Another dispatcher polling during the delivery finds nothing due.
+		claimedMeanwhile, _ = other.DispatchOnce(ctx)
+		return nil
+	})
+	if n, err := NewDispatcher(outbox, sink, DispatcherOptions{}).DispatchOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("Wrong batch, expected: %v, actual: %v (%v)", 1, n, err)
+	}
+	if claimedMeanwhile != 0 {
+		t.Errorf("Wrong number of events claimed during a delivery, expected: %v, actual: %v", 0, claimedMeanwhile)
+	}
+
+	// A dispatcher that stops after claiming leaves the event to be claimed
+	// again once the lease has run out.
+	addEvent(t, outbox, "second", "b")
+	if _, err := outbox.ClaimDue(ctx, 10, time.Millisecond); err != nil {
+		t.Fatalf("Error while claiming events: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if n, err := other.DispatchOnce(ctx); err != nil || n != 1 {
+		t.Errorf("Wrong batch after the lease ran out, expected: %v, actual: %v (%v)", 1, n, err)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 10*time.Second)
+	cases := map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 50: 10 * time.Second}
+	for attempts, expected := range cases {
+		if actual := backoff(attempts); actual != expected {
+			t.Errorf("Wrong backoff for attempt %d, expected: %v, actual: %v", attempts, expected, actual)
+		}
+	}
+}
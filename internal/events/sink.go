@@ -0,0 +1,165 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+// Sink delivers a domain event somewhere outside the service. Deliveries are
+// at least once, so implementations and their consumers should use the event
+// ID to deduplicate.
+type Sink interface {
+	Deliver(ctx context.Context, event *models.DomainEvent) error
+}
+
+type SinkFunc func(ctx context.Context, event *models.DomainEvent) error
+
+func (f SinkFunc) Deliver(ctx context.Context, event *models.DomainEvent) error {
+	return f(ctx, event)
+}
+
+// StdoutSink writes every event as a JSON line. It is the default sink and is
+// handy during development.
+type StdoutSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewStdoutSink(w io.Writer) *StdoutSink {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &StdoutSink{w: w}
+}
+
+func (s *StdoutSink) Deliver(ctx context.Context, event *models.DomainEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.NewEncoder(s.w).Encode(event)
+}
+
+// WebhookSink POSTs the event as JSON to a fixed URL. Any non-2xx response is
+// treated as a failed delivery.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebhookSink{
+		url:    url,
+		client: client,
+	}
+}
+
+func (s *WebhookSink) Deliver(ctx context.Context, event *models.DomainEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", event.ID.String())
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NATSPublisher is the subset of *nats.Conn (or a JetStream context) the NATS
+// sink needs, so the service does not depend on a particular client version.
+type NATSPublisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATSSink publishes every event to subjectPrefix + "." + event type.
+type NATSSink struct {
+	conn          NATSPublisher
+	subjectPrefix string
+}
+
+func NewNATSSink(conn NATSPublisher, subjectPrefix string) *NATSSink {
+	return &NATSSink{
+		conn:          conn,
+		subjectPrefix: subjectPrefix,
+	}
+}
+
+func (s *NATSSink) Deliver(ctx context.Context, event *models.DomainEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	subject := event.Type
+	if s.subjectPrefix != "" {
+		subject = s.subjectPrefix + "." + subject
+	}
+	return s.conn.Publish(subject, data)
+}
+
+// KafkaWriter is implemented by a thin adapter around the Kafka client in use.
+// The ordering key is passed as the message key so that events for the same
+// entity land on the same partition.
+type KafkaWriter interface {
+	WriteMessage(ctx context.Context, topic string, key, value []byte) error
+}
+
+type KafkaSink struct {
+	writer KafkaWriter
+	topic  string
+}
+
+func NewKafkaSink(writer KafkaWriter, topic string) *KafkaSink {
+	return &KafkaSink{
+		writer: writer,
+		topic:  topic,
+	}
+}
+
+func (s *KafkaSink) Deliver(ctx context.Context, event *models.DomainEvent) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	return s.writer.WriteMessage(ctx, s.topic, []byte(event.OrderingKey), value)
+}
+
+// FanoutSink delivers to every sink and fails if any of them fails. Since the
+// whole event is retried, sinks that already succeeded will see it again.
+type FanoutSink []Sink
+
+func (f FanoutSink) Deliver(ctx context.Context, event *models.DomainEvent) error {
+	var errs []error
+	for _, sink := range f {
+		if err := sink.Deliver(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type APIKeyHandler struct {
+	service  services.APIKeyService
+	validate *validator.Validate
+}
+
+func NewAPIKeyHandler(service services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// CreateAPIKey handles creating an API key
+// @Summary Create an API key
+// @Description Create an API key for the current user, or for a service account when service_account_role is set (admins only). The key is only returned once.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param key body models.APIKeyCreate true "API key data"
+// @Success 201 {object} models.APIKeyCreated
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var key models.APIKeyCreate
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(key); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
+		return
+	}
+
+	created, err := h.service.Create(r.Context(), &key)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExpiresAt) {
+			render.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrForbidden) {
+			render.Error(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	render.Render(w, r, http.StatusCreated, created)
+}
+
+// ListAPIKeys handles listing API keys
+// @Summary List API keys
+// @Description List the current user's API keys, or service account keys for admins
+// @Tags api-keys
+// @Produce json,text/csv
+// @Param service_accounts query bool false "List service account keys (admins only)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /api-keys [get]
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	serviceAccounts, _ := strconv.ParseBool(r.URL.Query().Get("service_accounts"))
+
+	keys, err := h.service.List(r.Context(), serviceAccounts)
+	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			render.Error(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+
+	render.List(w, r, http.StatusOK, map[string]interface{}{"api_keys": keys}, keys)
+}
+
+// RevokeAPIKey handles revoking an API key
+// @Summary Revoke an API key
+// @Description Revoke one of the current user's API keys; admins can revoke any key
+// @Tags api-keys
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	key, err := h.service.Revoke(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "API key not found")
+			return
+		}
+		if errors.Is(err, services.ErrForbidden) {
+			render.Error(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, key)
+}
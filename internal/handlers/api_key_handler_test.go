@@ -0,0 +1,31 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/testutil"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.Register("alice", "password1")
+
+	var created models.APIKeyCreated
+	server.Request(http.MethodPost, "/api-keys").WithToken(token).
+		JSON(map[string]interface{}{"name": "batch", "scopes": []string{"users:read"}}).
+		Do().
+		ExpectStatus(http.StatusCreated).
+		Decode(&created)
+
+	server.Request(http.MethodGet, "/users").Header("X-API-Key", created.Key).Do().ExpectStatus(http.StatusOK)
+	server.Request(http.MethodGet, "/users").Header("Authorization", "ApiKey "+created.Key).Do().ExpectStatus(http.StatusOK)
+	server.Request(http.MethodDelete, "/users/"+created.APIKey.Prefix).Header("X-API-Key", created.Key).Do().ExpectStatus(http.StatusForbidden)
+	server.Request(http.MethodGet, "/api-keys").Header("X-API-Key", created.Key).Do().ExpectStatus(http.StatusForbidden)
+
+	path := "/api-keys/" + strconv.FormatInt(created.APIKey.ID, 10)
+	server.Request(http.MethodDelete, path).WithToken(token).Do().ExpectStatus(http.StatusOK)
+	server.Request(http.MethodGet, "/users").Header("X-API-Key", created.Key).Do().ExpectStatus(http.StatusUnauthorized)
+}
@@ -0,0 +1,50 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/testutil"
+)
+
+func TestAuditEventsAreAdminOnly(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.Register("alice", "password1")
+
+	server.Request(http.MethodGet, "/audit-events").WithToken(token).Do().ExpectStatus(http.StatusForbidden)
+
+	var list struct {
+		Users []models.User `json:"users"`
+	}
+	server.Request(http.MethodGet, "/users").WithToken(token).Do().ExpectStatus(http.StatusOK).Decode(&list)
+	server.Request(http.MethodPut, "/users/"+list.Users[0].ID.String()).WithToken(token).
+		JSON(map[string]string{"role": "admin"}).
+		Do().
+		ExpectStatus(http.StatusForbidden)
+
+	server.Register("bob", "password1")
+	server.GrantRole("alice", "admin")
+	adminToken := server.Login("alice", "password1")
+	server.Request(http.MethodGet, "/users").WithToken(adminToken).Do().ExpectStatus(http.StatusOK).Decode(&list)
+	server.Request(http.MethodPut, "/users/"+list.Users[1].ID.String()).WithToken(adminToken).
+		JSON(map[string]string{"role": "support"}).
+		Do().
+		ExpectStatus(http.StatusOK)
+
+	var events struct {
+		Events []models.AuditEvent `json:"events"`
+		Total  int                 `json:"total"`
+	}
+	server.Request(http.MethodGet, "/audit-events?action=user.update").WithToken(adminToken).Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&events)
+	if events.Total != 1 || events.Events[0].Changes["role"].To != "support" {
+		t.Errorf("Wrong audit events: %+v", events)
+	}
+
+	resp := server.Request(http.MethodGet, "/audit-events/export").WithToken(adminToken).Do().ExpectStatus(http.StatusOK)
+	if resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("Wrong export content type: %v", resp.Header.Get("Content-Type"))
+	}
+}
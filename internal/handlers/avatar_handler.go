@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/Romasmi/go-rest-api-template/internal/images"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// DefaultAvatarMaxSize bounds uploaded avatars.
+	DefaultAvatarMaxSize = 5 << 20
+	// avatarField is the multipart form field of the image.
+	avatarField = "avatar"
+)
+
+type AvatarHandler struct {
+	service services.UserService
+	me      *MeHandler
+	maxSize int64
+}
+
+// NewAvatarHandler returns a handler for avatars of up to maxSize bytes, or
+// DefaultAvatarMaxSize when it is not positive.
+func NewAvatarHandler(service services.UserService, maxSize int64) *AvatarHandler {
+	if maxSize <= 0 {
+		maxSize = DefaultAvatarMaxSize
+	}
+	return &AvatarHandler{
+		service: service,
+		me:      NewMeHandler(service),
+		maxSize: maxSize,
+	}
+}
+
+// UploadMyAvatar handles changing the avatar of the current user
+// @Summary Upload the avatar of the current user
+// @Description Upload a JPEG, PNG, GIF or WebP image as the avatar field of a multipart form. It is cropped to a square and scaled down, and its URL is returned as avatar_url.
+// @Tags me
+// @Accept multipart/form-data
+// @Produce json
+// @Param avatar formData file true "Image"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /me/avatar [put]
+func (h *AvatarHandler) UploadMyAvatar(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.me.caller(w, r)
+	if !ok {
+		return
+	}
+	h.upload(w, r, user.ID)
+}
+
+// DeleteMyAvatar handles removing the avatar of the current user
+// @Summary Delete the avatar of the current user
+// @Description Remove the avatar and delete its image
+// @Tags me
+// @Produce json
+// @Success 200 {object} models.User
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /me/avatar [delete]
+func (h *AvatarHandler) DeleteMyAvatar(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.me.caller(w, r)
+	if !ok {
+		return
+	}
+	h.delete(w, r, user.ID)
+}
+
+// UploadUserAvatar handles changing the avatar of a user
+// @Summary Upload the avatar of a user
+// @Description Upload a JPEG, PNG, GIF or WebP image as the avatar field of a multipart form. It is cropped to a square and scaled down, and its URL is returned as avatar_url.
+// @Tags users
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "User ID (UUID)"
+// @Param avatar formData file true "Image"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/avatar [put]
+func (h *AvatarHandler) UploadUserAvatar(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	h.upload(w, r, id)
+}
+
+// DeleteUserAvatar handles removing the avatar of a user
+// @Summary Delete the avatar of a user
+// @Description Remove the avatar and delete its image
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID (UUID)"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/avatar [delete]
+func (h *AvatarHandler) DeleteUserAvatar(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	h.delete(w, r, id)
+}
+
+func (h *AvatarHandler) upload(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	image, ok := h.readImage(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.SetAvatar(r.Context(), id, image)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	render.Render(w, r, http.StatusOK, user)
+}
+
+func (h *AvatarHandler) delete(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	user, err := h.service.DeleteAvatar(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	render.Render(w, r, http.StatusOK, user)
+}
+
+// readImage reads the avatar field of the multipart form in the body, and
+// checks its size and type before it is decoded.
+func (h *AvatarHandler) readImage(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	// The limit leaves room for the rest of the form.
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxSize+64<<10)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Expected a multipart form")
+		return nil, false
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			if !tooLarge(w, r, err) {
+				render.Error(w, r, http.StatusBadRequest, "Expected an "+avatarField+" field with the image")
+			}
+			return nil, false
+		}
+		if part.FormName() != avatarField {
+			continue
+		}
+
+		image, err := io.ReadAll(io.LimitReader(part, h.maxSize+1))
+		if err != nil {
+			if !tooLarge(w, r, err) {
+				render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+			}
+			return nil, false
+		}
+		if int64(len(image)) > h.maxSize {
+			render.Error(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Avatars are limited to %d bytes", h.maxSize))
+			return nil, false
+		}
+		if !slices.Contains(images.Formats, http.DetectContentType(image)) {
+			render.Error(w, r, http.StatusUnsupportedMediaType, "Avatars must be JPEG, PNG, GIF or WebP images")
+			return nil, false
+		}
+		return image, true
+	}
+}
+
+func tooLarge(w http.ResponseWriter, r *http.Request, err error) bool {
+	var maxBytesError *http.MaxBytesError
+	if !errors.As(err, &maxBytesError) {
+		return false
+	}
+	render.Error(w, r, http.StatusRequestEntityTooLarge, "Request is too large")
+	return true
+}
+
+func (h *AvatarHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, images.ErrUnsupportedFormat):
+		render.Error(w, r, http.StatusUnsupportedMediaType, "Avatars must be JPEG, PNG, GIF or WebP images")
+	case errors.Is(err, images.ErrTooLarge):
+		render.Error(w, r, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, repository.ErrNotFound):
+		render.Error(w, r, http.StatusNotFound, "User not found")
+	default:
+		render.Error(w, r, http.StatusInternalServerError, "Failed to update avatar")
+	}
+}
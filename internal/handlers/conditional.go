@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+// userCacheControl lets clients keep user responses but makes them
+// revalidate on every use. They are private as they depend on the caller's
+// permissions.
+const userCacheControl = "private, no-cache"
+
+// notModified sets the cache validators of a response and, when the
+// conditional headers of the request match them, writes a 304 and reports
+// true. A zero lastModified is left out, for responses where it would miss
+// changes such as deletions.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	w.Header().Set("Cache-Control", userCacheControl)
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since.
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if !etagMatches(match, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// usersETag is a weak validator derived from the IDs and update times of
+// users, and from extra values that also shape the response.
+func usersETag(users []*models.User, extra ...interface{}) string {
+	hash := sha256.New()
+	fmt.Fprintln(hash, extra...)
+	for _, user := range users {
+		fmt.Fprintf(hash, "%s:%d\n", user.ID, user.UpdatedAt.UnixNano())
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
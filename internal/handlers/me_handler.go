@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/password"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/go-playground/validator/v10"
+)
+
+// MeHandler lets the authenticated user manage their own account.
+type MeHandler struct {
+	service  services.UserService
+	validate *validator.Validate
+}
+
+func NewMeHandler(service services.UserService) *MeHandler {
+	return &MeHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// Get handles fetching the current user
+// @Summary Get the current user
+// @Description Get the user the request is authenticated as
+// @Tags me
+// @Produce json
+// @Success 200 {object} models.User
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /me [get]
+func (h *MeHandler) Get(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.caller(w, r)
+	if !ok {
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, user)
+}
+
+// Update handles changing the profile of the current user
+// @Summary Update the current user
+// @Description Change the username, display name, locale or timezone of the current user. Roles and attributes cannot be changed here.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Param profile body models.ProfileUpdate true "Profile changes"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /me [patch]
+func (h *MeHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var profile models.ProfileUpdate
+	if !h.decode(w, r, &profile) {
+		return
+	}
+	user, ok := h.caller(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.service.UpdateProfile(r.Context(), user.ID, &profile)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, updated)
+}
+
+// ChangePassword handles changing the password of the current user
+// @Summary Change the password of the current user
+// @Description Change the password after checking the current one. All other sessions are signed out, and a new token for this one is returned.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Param password body models.PasswordChange true "Current and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /me/password [post]
+func (h *MeHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var change models.PasswordChange
+	if !h.decode(w, r, &change) {
+		return
+	}
+	user, ok := h.caller(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := h.service.ChangePassword(r.Context(), user.ID, &change)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, map[string]string{"token": token})
+}
+
+// ChangeEmail handles requesting an email change
+// @Summary Change the email of the current user
+// @Description Send a confirmation token to the new address. The email changes once it is confirmed at /auth/email/confirm.
+// @Tags me
+// @Accept json
+// @Param email body models.EmailChange true "New email"
+// @Success 202
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /me/email [post]
+func (h *MeHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	var change models.EmailChange
+	if !h.decode(w, r, &change) {
+		return
+	}
+	user, ok := h.caller(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RequestEmailChange(r.Context(), user.ID, change.Email); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmEmail handles confirming an email change
+// @Summary Confirm an email change
+// @Description Change the email of a user with the token sent to the new address
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param confirm body models.EmailChangeConfirm true "Confirmation token"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/email/confirm [post]
+func (h *MeHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	var confirm models.EmailChangeConfirm
+	if !h.decode(w, r, &confirm) {
+		return
+	}
+
+	user, err := h.service.ConfirmEmailChange(r.Context(), confirm.Token)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, user)
+}
+
+// Delete handles deleting the current user
+// @Summary Delete the current user
+// @Description Delete the account of the current user
+// @Tags me
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /me [delete]
+func (h *MeHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.caller(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), user.ID); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// caller is the user of the request. API keys of service accounts have none.
+func (h *MeHandler) caller(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID := middleware.GetUserIDFromToken(middleware.GetClaimsFromRequest(r))
+	user, err := h.service.GetByTokenUserID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			return nil, false
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to get user")
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *MeHandler) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+
+	if err := h.validate.Struct(v); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
+		return false
+	}
+	return true
+}
+
+func (h *MeHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	message := "Failed to update account"
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		status, message = http.StatusForbidden, "Roles cannot be changed through /me"
+	case errors.Is(err, services.ErrInvalidCredentials):
+		status, message = http.StatusForbidden, "Current password is incorrect"
+	case errors.Is(err, password.ErrWeakPassword), errors.Is(err, services.ErrInvalidEmailToken):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, services.ErrUserExists), errors.Is(err, repository.ErrConflict):
+		status, message = http.StatusConflict, services.ErrUserExists.Error()
+	case errors.Is(err, repository.ErrNotFound):
+		status, message = http.StatusNotFound, "User not found"
+	}
+
+	render.Error(w, r, status, message)
+}
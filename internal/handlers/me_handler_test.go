@@ -0,0 +1,65 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/testutil"
+)
+
+func TestMe(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.Register("alice", "password1")
+	server.Register("bob", "password1")
+
+	var me models.User
+	server.Request(http.MethodGet, "/me").WithToken(token).Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&me)
+	if me.Username != "alice" {
+		t.Errorf("Wrong username, expected: %v, actual: %v", "alice", me.Username)
+	}
+
+	server.Request(http.MethodPatch, "/me").WithToken(token).
+		JSON(map[string]string{"role": "admin"}).
+		Do().
+		ExpectStatus(http.StatusForbidden)
+	server.Request(http.MethodPatch, "/me").WithToken(token).
+		JSON(map[string]string{"username": "bob"}).
+		Do().
+		ExpectStatus(http.StatusConflict)
+	server.Request(http.MethodPatch, "/me").WithToken(token).
+		JSON(map[string]string{"username": "alice2"}).
+		Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&me)
+	if me.Username != "alice2" || me.Role != "user" {
+		t.Errorf("Wrong profile after update: %+v", me)
+	}
+
+	server.Request(http.MethodPost, "/me/password").WithToken(token).
+		JSON(map[string]string{"current_password": "wrong-password", "new_password": "new-password"}).
+		Do().
+		ExpectStatus(http.StatusForbidden)
+
+	var changed map[string]string
+	server.Request(http.MethodPost, "/me/password").WithToken(token).
+		JSON(map[string]string{"current_password": "password1", "new_password": "new-password"}).
+		Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&changed)
+
+	// The password change signs out every other session.
+	server.Request(http.MethodGet, "/me").WithToken(token).Do().ExpectStatus(http.StatusUnauthorized)
+	server.Request(http.MethodGet, "/me").WithToken(changed["token"]).Do().ExpectStatus(http.StatusOK)
+	server.Login("alice2", "new-password")
+
+	server.Request(http.MethodPost, "/me/email").WithToken(changed["token"]).
+		JSON(map[string]string{"email": "alice@example.org"}).
+		Do().
+		ExpectStatus(http.StatusAccepted)
+
+	server.Request(http.MethodDelete, "/me").WithToken(changed["token"]).Do().ExpectStatus(http.StatusNoContent)
+	server.Request(http.MethodGet, "/me").WithToken(changed["token"]).Do().ExpectStatus(http.StatusUnauthorized)
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/go-playground/validator/v10"
+)
+
+type MFAHandler struct {
+	users    services.UserService
+	mfa      services.MFAService
+	validate *validator.Validate
+}
+
+func NewMFAHandler(users services.UserService, mfa services.MFAService) *MFAHandler {
+	return &MFAHandler{
+		users:    users,
+		mfa:      mfa,
+		validate: validator.New(),
+	}
+}
+
+// Verify handles the second step of a login
+// @Summary Complete a login with a second factor
+// @Description Exchange the mfa_token returned by /auth/login and a TOTP or recovery code for a JWT token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param verify body models.MFAVerify true "MFA token and code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/mfa/verify [post]
+func (h *MFAHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var verify models.MFAVerify
+	if !h.decode(w, r, &verify) {
+		return
+	}
+
+	token, err := h.users.VerifyMFA(r.Context(), &verify)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, map[string]string{"token": token})
+}
+
+// EnrollWithToken handles enrollment required at login
+// @Summary Enroll in two-factor authentication during login
+// @Description Start TOTP enrollment with the mfa_token returned by /auth/login when mfa_enrollment_required is set
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param enroll body models.MFAEnroll true "MFA token"
+// @Success 200 {object} models.TOTPEnrollment
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/mfa/enroll [post]
+func (h *MFAHandler) EnrollWithToken(w http.ResponseWriter, r *http.Request) {
+	var enroll models.MFAEnroll
+	if !h.decode(w, r, &enroll) {
+		return
+	}
+
+	enrollment, err := h.users.EnrollMFA(r.Context(), enroll.MFAToken)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, enrollment)
+}
+
+// ConfirmWithToken handles confirming enrollment required at login
+// @Summary Confirm two-factor enrollment and complete the login
+// @Description Confirm TOTP enrollment with a code from the app. Returns a JWT token and the recovery codes, which are only shown once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param enroll body models.MFAEnroll true "MFA token and code"
+// @Success 200 {object} models.MFAEnrolled
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/mfa/enroll/confirm [post]
+func (h *MFAHandler) ConfirmWithToken(w http.ResponseWriter, r *http.Request) {
+	var enroll models.MFAEnroll
+	if !h.decode(w, r, &enroll) {
+		return
+	}
+
+	enrolled, err := h.users.ConfirmMFAEnrollment(r.Context(), enroll.MFAToken, enroll.Code)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, enrolled)
+}
+
+// Enroll handles starting TOTP enrollment
+// @Summary Enroll in two-factor authentication
+// @Description Generate a TOTP secret for the current user. It is enabled once confirmed with a code.
+// @Tags mfa
+// @Produce json
+// @Success 200 {object} models.TOTPEnrollment
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /mfa/totp [post]
+func (h *MFAHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.caller(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.mfa.Enroll(r.Context(), user)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, enrollment)
+}
+
+// Confirm handles confirming TOTP enrollment
+// @Summary Confirm two-factor enrollment
+// @Description Enable TOTP with a code from the app. The recovery codes are only shown once.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param code body models.MFACode true "TOTP code"
+// @Success 200 {object} models.RecoveryCodes
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /mfa/totp/confirm [post]
+func (h *MFAHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	var code models.MFACode
+	if !h.decode(w, r, &code) {
+		return
+	}
+	user, ok := h.caller(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.mfa.Confirm(r.Context(), user, code.Code)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, models.RecoveryCodes{RecoveryCodes: codes})
+}
+
+// Disable handles turning off TOTP
+// @Summary Disable two-factor authentication
+// @Description Disable TOTP and delete the recovery codes. Not allowed for roles that require two-factor authentication.
+// @Tags mfa
+// @Accept json
+// @Param code body models.MFACode true "TOTP code"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /mfa/totp [delete]
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	var code models.MFACode
+	if !h.decode(w, r, &code) {
+		return
+	}
+	user, ok := h.caller(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.mfa.Disable(r.Context(), user, code.Code); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes handles replacing recovery codes
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes of the current user
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param code body models.MFACode true "TOTP code"
+// @Success 200 {object} models.RecoveryCodes
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /mfa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var code models.MFACode
+	if !h.decode(w, r, &code) {
+		return
+	}
+	user, ok := h.caller(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.mfa.RegenerateRecoveryCodes(r.Context(), user, code.Code)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, models.RecoveryCodes{RecoveryCodes: codes})
+}
+
+func (h *MFAHandler) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+
+	if err := h.validate.Struct(v); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
+		return false
+	}
+	return true
+}
+
+// caller is the user of the request. API keys of service accounts have none.
+func (h *MFAHandler) caller(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, _ := auth.FromContext(r.Context())["user_id"].(string)
+	user, err := h.users.GetByTokenUserID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			return nil, false
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to get user")
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *MFAHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	message := "Failed to process two-factor request"
+	switch {
+	case errors.Is(err, services.ErrInvalidMFAToken), errors.Is(err, services.ErrInvalidMFACode):
+		status, message = http.StatusUnauthorized, err.Error()
+	case errors.Is(err, services.ErrMFALocked):
+		status, message = http.StatusTooManyRequests, err.Error()
+	case errors.Is(err, services.ErrMFANotEnabled):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, services.ErrMFAEnabled):
+		status, message = http.StatusConflict, err.Error()
+	case errors.Is(err, services.ErrMFARequired), errors.Is(err, services.ErrUserInactive):
+		status, message = http.StatusForbidden, err.Error()
+	}
+
+	render.Error(w, r, status, message)
+}
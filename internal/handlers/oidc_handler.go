@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/oidc"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+)
+
+const oidcCookiePath = "/api/v1/auth/oidc/"
+
+type OIDCHandler struct {
+	service services.OIDCService
+	// postLoginRedirectURL receives the token in its fragment when set.
+	postLoginRedirectURL string
+	secureCookie         bool
+}
+
+func NewOIDCHandler(service services.OIDCService, publicURL, postLoginRedirectURL string) *OIDCHandler {
+	return &OIDCHandler{
+		service:              service,
+		postLoginRedirectURL: postLoginRedirectURL,
+		secureCookie:         strings.HasPrefix(publicURL, "https://"),
+	}
+}
+
+// Start handles starting a sign-in with an identity provider
+// @Summary Sign in with an identity provider
+// @Description Redirect the browser to the provider. The flow ends at the callback, which returns a token.
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Success 302
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/oidc/{provider}/start [get]
+func (h *OIDCHandler) Start(w http.ResponseWriter, r *http.Request) {
+	authURL, state, err := h.service.Start(r.Context(), mux.Vars(r)["provider"], false)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.setStateCookie(w, state, 600)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Link handles starting the linking of an identity to the current user
+// @Summary Link an identity provider account
+// @Description Start a flow that links the provider account to the current user. The browser must be sent to authorization_url with the cookie set by this response.
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/oidc/{provider}/link [post]
+func (h *OIDCHandler) Link(w http.ResponseWriter, r *http.Request) {
+	authURL, state, err := h.service.Start(r.Context(), mux.Vars(r)["provider"], true)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.setStateCookie(w, state, 600)
+	render.Render(w, r, http.StatusOK, map[string]string{"authorization_url": authURL})
+}
+
+// Callback handles the redirect back from an identity provider
+// @Summary Complete sign-in with an identity provider
+// @Description Exchange the authorization code and respond like /auth/login, or redirect to the configured post-login URL with the response fields in the fragment
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Success 200 {object} models.LoginResult
+// @Success 302
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		render.Error(w, r, http.StatusBadRequest, providerError)
+		return
+	}
+
+	var state string
+	if cookie, err := r.Cookie(oidc.StateCookie); err == nil {
+		state = cookie.Value
+	}
+	h.setStateCookie(w, "", -1)
+
+	result, err := h.service.Callback(r.Context(), mux.Vars(r)["provider"], state, query.Get("state"), query.Get("code"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	if h.postLoginRedirectURL != "" {
+		http.Redirect(w, r, h.postLoginRedirectURL+"#"+loginFragment(result), http.StatusFound)
+		return
+	}
+	render.Render(w, r, http.StatusOK, result)
+}
+
+// ListIdentities handles listing the identities of the current user
+// @Summary List linked identities
+// @Description List the identity provider accounts linked to the current user
+// @Tags auth
+// @Produce json,text/csv
+// @Success 200 {array} models.UserIdentity
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /identities [get]
+func (h *OIDCHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	identities, err := h.service.ListIdentities(r.Context())
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.List(w, r, http.StatusOK, identities, identities)
+}
+
+// UnlinkIdentity handles removing an identity of the current user
+// @Summary Unlink an identity
+// @Description Remove an identity provider account from the current user
+// @Tags auth
+// @Param id path int true "Identity ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /identities/{id} [delete]
+func (h *OIDCHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid identity ID")
+		return
+	}
+
+	if err := h.service.Unlink(r.Context(), id); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OIDCHandler) setStateCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidc.StateCookie,
+		Value:    value,
+		Path:     oidcCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (h *OIDCHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	message := "Failed to sign in"
+	switch {
+	case errors.Is(err, oidc.ErrUnknownProvider), errors.Is(err, repository.ErrNotFound):
+		status, message = http.StatusNotFound, err.Error()
+	case errors.Is(err, services.ErrOIDCStateInvalid), errors.Is(err, services.ErrIdentityNoEmail),
+		errors.Is(err, oidc.ErrInvalidIDToken):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, services.ErrIdentityLinked), errors.Is(err, services.ErrUserExists):
+		status, message = http.StatusConflict, err.Error()
+	case errors.Is(err, services.ErrForbidden):
+		status, message = http.StatusForbidden, http.StatusText(http.StatusForbidden)
+	case errors.Is(err, services.ErrUserInactive):
+		status, message = http.StatusForbidden, err.Error()
+	}
+
+	render.Error(w, r, status, message)
+}
+
+// loginFragment encodes the fields of result that are set.
+func loginFragment(result *models.LoginResult) string {
+	values := url.Values{}
+	if result.Token != "" {
+		values.Set("token", result.Token)
+	}
+	if result.MFAToken != "" {
+		values.Set("mfa_token", result.MFAToken)
+		values.Set("mfa_required", strconv.FormatBool(result.MFARequired))
+		values.Set("mfa_enrollment_required", strconv.FormatBool(result.MFAEnrollmentRequired))
+	}
+	return values.Encode()
+}
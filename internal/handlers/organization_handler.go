@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/password"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/go-playground/validator/v10"
+)
+
+type OrganizationHandler struct {
+	service  services.OrganizationService
+	validate *validator.Validate
+}
+
+func NewOrganizationHandler(service services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// CreateOrganization handles provisioning an organization
+// @Summary Create an organization
+// @Description Create an organization and its first admin user. Only available in the default organization.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param organization body models.OrganizationCreate true "Organization"
+// @Success 201 {object} models.Organization
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations [post]
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var organization models.OrganizationCreate
+	if err := json.NewDecoder(r.Body).Decode(&organization); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(organization); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
+		return
+	}
+
+	created, err := h.service.Create(r.Context(), &organization)
+	if err != nil {
+		if errors.Is(err, services.ErrOrganizationExists) {
+			render.Error(w, r, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, password.ErrWeakPassword) {
+			render.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to create organization")
+		return
+	}
+
+	render.Render(w, r, http.StatusCreated, created)
+}
+
+// ListOrganizations handles listing organizations
+// @Summary List organizations
+// @Description List all organizations. Only available in the default organization.
+// @Tags organizations
+// @Produce json,text/csv
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations [get]
+func (h *OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	organizations, err := h.service.List(r.Context())
+	if err != nil {
+		render.Error(w, r, http.StatusInternalServerError, "Failed to list organizations")
+		return
+	}
+
+	render.List(w, r, http.StatusOK, map[string]interface{}{"organizations": organizations}, organizations)
+}
+
+// GetCurrentOrganization handles getting the organization of the request
+// @Summary Get the current organization
+// @Description Get the organization the request is scoped to
+// @Tags organizations
+// @Produce json
+// @Success 200 {object} models.Organization
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organization [get]
+func (h *OrganizationHandler) GetCurrentOrganization(w http.ResponseWriter, r *http.Request) {
+	organization, err := h.service.Current(r.Context())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "Organization not found")
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to get organization")
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, organization)
+}
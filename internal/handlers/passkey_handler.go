@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type PasskeyHandler struct {
+	service  services.PasskeyService
+	validate *validator.Validate
+}
+
+func NewPasskeyHandler(service services.PasskeyService) *PasskeyHandler {
+	return &PasskeyHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// BeginRegistration handles starting to add a passkey
+// @Summary Begin passkey registration
+// @Description Get the options for navigator.credentials.create() and a session to finish the registration with
+// @Tags passkeys
+// @Produce json
+// @Success 200 {object} models.PasskeyCeremony
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /passkeys/register/begin [post]
+func (h *PasskeyHandler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	ceremony, err := h.service.BeginRegistration(r.Context())
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, ceremony)
+}
+
+// FinishRegistration handles storing a new passkey
+// @Summary Finish passkey registration
+// @Description Verify the credential created by the browser and store it as a passkey of the current user
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Param passkey body models.PasskeyFinish true "Session and credential"
+// @Success 201 {object} models.Passkey
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /passkeys/register/finish [post]
+func (h *PasskeyHandler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	var finish models.PasskeyFinish
+	if !h.decode(w, r, &finish) {
+		return
+	}
+
+	passkey, err := h.service.FinishRegistration(r.Context(), &finish)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusCreated, passkey)
+}
+
+// List handles listing the passkeys of the current user
+// @Summary List passkeys
+// @Description List the passkeys of the current user
+// @Tags passkeys
+// @Produce json,text/csv
+// @Success 200 {array} models.Passkey
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /passkeys [get]
+func (h *PasskeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	passkeys, err := h.service.List(r.Context())
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.List(w, r, http.StatusOK, passkeys, passkeys)
+}
+
+// Delete handles removing a passkey
+// @Summary Delete a passkey
+// @Description Remove a passkey of the current user
+// @Tags passkeys
+// @Param id path int true "Passkey ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /passkeys/{id} [delete]
+func (h *PasskeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid passkey ID")
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BeginLogin handles starting a passkey login
+// @Summary Begin passkey login
+// @Description Get the options for navigator.credentials.get() and a session to finish the login with. Without a username any discoverable passkey can be used.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param login body models.PasskeyLoginBegin false "Username"
+// @Success 200 {object} models.PasskeyCeremony
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/passkey/begin [post]
+func (h *PasskeyHandler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	var begin models.PasskeyLoginBegin
+	if r.ContentLength != 0 && !h.decode(w, r, &begin) {
+		return
+	}
+
+	ceremony, err := h.service.BeginLogin(r.Context(), begin.Username)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, ceremony)
+}
+
+// FinishLogin handles completing a passkey login
+// @Summary Finish passkey login
+// @Description Verify the assertion returned by the browser and get a JWT token, or an MFA challenge like /auth/login
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param login body models.PasskeyFinish true "Session and credential"
+// @Success 200 {object} models.LoginResult
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/passkey/finish [post]
+func (h *PasskeyHandler) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	var finish models.PasskeyFinish
+	if !h.decode(w, r, &finish) {
+		return
+	}
+
+	result, err := h.service.FinishLogin(r.Context(), &finish)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, result)
+}
+
+func (h *PasskeyHandler) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+
+	if err := h.validate.Struct(v); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
+		return false
+	}
+	return true
+}
+
+func (h *PasskeyHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	message := "Failed to process passkey"
+	switch {
+	case errors.Is(err, services.ErrPasskeysDisabled), errors.Is(err, repository.ErrNotFound):
+		status, message = http.StatusNotFound, err.Error()
+	case errors.Is(err, services.ErrInvalidPasskeySession), errors.Is(err, services.ErrPasskeyFailed):
+		status, message = http.StatusUnauthorized, services.ErrPasskeyFailed.Error()
+	case errors.Is(err, repository.ErrConflict):
+		status, message = http.StatusConflict, "Passkey already registered"
+	case errors.Is(err, services.ErrForbidden):
+		status, message = http.StatusForbidden, http.StatusText(http.StatusForbidden)
+	case errors.Is(err, services.ErrUserInactive):
+		status, message = http.StatusForbidden, err.Error()
+	}
+
+	render.Error(w, r, status, message)
+}
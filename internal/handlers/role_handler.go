@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type RoleHandler struct {
+	service  services.RoleService
+	validate *validator.Validate
+}
+
+func NewRoleHandler(service services.RoleService) *RoleHandler {
+	return &RoleHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// ListPermissions handles listing the known permissions
+// @Summary List permissions
+// @Description List the permissions that can be granted to roles
+// @Tags roles
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /permissions [get]
+func (h *RoleHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	permissions, err := h.service.ListPermissions(r.Context())
+	if err != nil {
+		render.Error(w, r, http.StatusInternalServerError, "Failed to list permissions")
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, map[string]interface{}{"permissions": permissions})
+}
+
+// ListRoles handles listing roles
+// @Summary List roles
+// @Description List roles together with their permissions
+// @Tags roles
+// @Produce json,text/csv
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /roles [get]
+func (h *RoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.service.ListRoles(r.Context())
+	if err != nil {
+		render.Error(w, r, http.StatusInternalServerError, "Failed to list roles")
+		return
+	}
+
+	render.List(w, r, http.StatusOK, map[string]interface{}{"roles": roles}, roles)
+}
+
+// CreateRole handles creating a role
+// @Summary Create a role
+// @Description Create a role with a set of permissions
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param role body models.RoleCreate true "Role"
+// @Success 201 {object} models.Role
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /roles [post]
+func (h *RoleHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var role models.RoleCreate
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(role); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
+		return
+	}
+
+	created, err := h.service.CreateRole(r.Context(), &role)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownPermission) {
+			render.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			render.Error(w, r, http.StatusConflict, "Role already exists")
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to create role")
+		return
+	}
+
+	render.Render(w, r, http.StatusCreated, created)
+}
+
+// UpdateRole handles updating a role
+// @Summary Update a role
+// @Description Update the description of a role or replace its permissions
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param name path string true "Role name"
+// @Param role body models.RoleUpdate true "Role update data"
+// @Success 200 {object} models.Role
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /roles/{name} [put]
+func (h *RoleHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	var role models.RoleUpdate
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(role); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
+		return
+	}
+
+	updated, err := h.service.UpdateRole(r.Context(), mux.Vars(r)["name"], &role)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownPermission) {
+			render.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "Role not found")
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to update role")
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, updated)
+}
+
+// DeleteRole handles deleting a role
+// @Summary Delete a role
+// @Description Delete a role that is not builtin and not assigned to any user
+// @Tags roles
+// @Produce json
+// @Param name path string true "Role name"
+// @Success 204 {object} nil
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /roles/{name} [delete]
+func (h *RoleHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	err := h.service.DeleteRole(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "Role not found")
+			return
+		}
+		if errors.Is(err, services.ErrBuiltinRole) {
+			render.Error(w, r, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, repository.ErrInUse) {
+			render.Error(w, r, http.StatusConflict, "Role is assigned to users")
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to delete role")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserRoles handles listing the roles of a user
+// @Summary Get user roles
+// @Description List the roles assigned to a user
+// @Tags roles
+// @Produce json
+// @Param id path string true "User ID (UUID)"
+// @Success 200 {object} models.UserRolesUpdate
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/roles [get]
+func (h *RoleHandler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	roles, err := h.service.GetUserRoles(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "User not found")
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to get user roles")
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, models.UserRolesUpdate{Roles: roles})
+}
+
+// SetUserRoles handles replacing the roles of a user
+// @Summary Set user roles
+// @Description Replace the roles of a user. The first role becomes the primary role.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID (UUID)"
+// @Param roles body models.UserRolesUpdate true "Roles"
+// @Success 200 {object} models.UserRolesUpdate
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/roles [put]
+func (h *RoleHandler) SetUserRoles(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var update models.UserRolesUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(update); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
+		return
+	}
+
+	roles, err := h.service.SetUserRoles(r.Context(), id, update.Roles)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownRole) {
+			render.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "User not found")
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to set user roles")
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, models.UserRolesUpdate{Roles: roles})
+}
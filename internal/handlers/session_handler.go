@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type SessionHandler struct {
+	users    services.UserService
+	sessions services.SessionService
+}
+
+func NewSessionHandler(users services.UserService, sessions services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		users:    users,
+		sessions: sessions,
+	}
+}
+
+// ListMine handles listing the sessions of the current user
+// @Summary List my sessions
+// @Description List where the current user is signed in. The session of the request is marked as current.
+// @Tags me
+// @Produce json
+// @Success 200 {array} models.Session
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /me/sessions [get]
+func (h *SessionHandler) ListMine(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromToken(middleware.GetClaimsFromRequest(r))
+	user, err := h.users.GetByTokenUserID(r.Context(), userID)
+	if err != nil {
+		h.writeUserError(w, r, err, http.StatusForbidden)
+		return
+	}
+
+	h.list(w, r, user)
+}
+
+// RevokeMine handles signing the current user out of a session
+// @Summary Revoke one of my sessions
+// @Description Sign the current user out of a session. Its tokens stop working.
+// @Tags me
+// @Param id path string true "Session ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /me/sessions/{id} [delete]
+func (h *SessionHandler) RevokeMine(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromToken(middleware.GetClaimsFromRequest(r))
+	user, err := h.users.GetByTokenUserID(r.Context(), userID)
+	if err != nil {
+		h.writeUserError(w, r, err, http.StatusForbidden)
+		return
+	}
+
+	h.revoke(w, r, user, mux.Vars(r)["id"])
+}
+
+// ListForUser handles listing the sessions of a user
+// @Summary List the sessions of a user
+// @Description List where a user is signed in
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {array} models.Session
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/sessions [get]
+func (h *SessionHandler) ListForUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+
+	h.list(w, r, user)
+}
+
+// RevokeForUser handles signing a user out of a session
+// @Summary Revoke a session of a user
+// @Description Sign a user out of a session. Its tokens stop working.
+// @Tags users
+// @Param id path string true "User ID"
+// @Param session_id path string true "Session ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/sessions/{session_id} [delete]
+func (h *SessionHandler) RevokeForUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+
+	h.revoke(w, r, user, mux.Vars(r)["session_id"])
+}
+
+func (h *SessionHandler) list(w http.ResponseWriter, r *http.Request, user *models.User) {
+	sessions, err := h.sessions.List(r.Context(), user)
+	if err != nil {
+		render.Error(w, r, http.StatusInternalServerError, "Failed to list sessions")
+		return
+	}
+
+	render.List(w, r, http.StatusOK, sessions, sessions)
+}
+
+func (h *SessionHandler) revoke(w http.ResponseWriter, r *http.Request, user *models.User, sessionID string) {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	if err := h.sessions.Revoke(r.Context(), user, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "Session not found")
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// user is the user of the {id} path variable.
+func (h *SessionHandler) user(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid user ID")
+		return nil, false
+	}
+
+	user, err := h.users.GetByID(r.Context(), id)
+	if err != nil {
+		h.writeUserError(w, r, err, http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+// writeUserError reports a failed user lookup, with notFound as the status
+// for a missing user.
+func (h *SessionHandler) writeUserError(w http.ResponseWriter, r *http.Request, err error, notFound int) {
+	if errors.Is(err, repository.ErrNotFound) {
+		render.Error(w, r, notFound, http.StatusText(notFound))
+		return
+	}
+	render.Error(w, r, http.StatusInternalServerError, "Failed to get user")
+}
@@ -3,22 +3,33 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/password"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
 	"github.com/Romasmi/go-rest-api-template/internal/repository"
 	"github.com/Romasmi/go-rest-api-template/internal/services"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
+const (
+	attributeFilterPrefix = "attr."
+	maxAttributeFilters   = 10
+)
+
 type UserHandler struct {
-	service  *services.UserService
+	service  services.UserService
 	validate *validator.Validate
 }
 
-func NewUserHandler(service *services.UserService) *UserHandler {
+func NewUserHandler(service services.UserService) *UserHandler {
 	return &UserHandler{
 		service:  service,
 		validate: validator.New(),
@@ -40,72 +51,74 @@ func NewUserHandler(service *services.UserService) *UserHandler {
 func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var user models.UserCreate
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if err := h.validate.Struct(user); err != nil {
 		validationErrors := err.(validator.ValidationErrors)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": validationErrors.Error()})
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
 		return
 	}
 
 	token, err := h.service.Register(r.Context(), &user)
 	if err != nil {
-		if err.Error() == "username or email already exists" {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		if errors.Is(err, services.ErrUserExists) {
+			render.Error(w, r, http.StatusConflict, err.Error())
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to register user"})
+		if errors.Is(err, password.ErrWeakPassword) {
+			render.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to register user")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	render.Render(w, r, http.StatusCreated, map[string]string{"token": token})
 }
 
 // Login handles user login
 // @Summary Login a user
-// @Description Login a user and return a JWT token
+// @Description Login a user and return a JWT token. When two-factor authentication is enabled or required, an mfa_token for /auth/mfa/verify or /auth/mfa/enroll is returned instead.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param user body models.UserLogin true "User login data"
-// @Success 200 {object} map[string]string
+// @Success 200 {object} models.LoginResult
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /auth/login [post]
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var login models.UserLogin
 	if err := json.NewDecoder(r.Body).Decode(&login); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if err := h.validate.Struct(login); err != nil {
 		validationErrors := err.(validator.ValidationErrors)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": validationErrors.Error()})
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
 		return
 	}
 
-	token, err := h.service.Login(r.Context(), &login)
+	result, err := h.service.Login(r.Context(), &login)
 	if err != nil {
-		if err.Error() == "invalid username or password" {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			render.Error(w, r, http.StatusUnauthorized, err.Error())
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to login"})
+		if errors.Is(err, services.ErrUserInactive) {
+			render.Error(w, r, http.StatusForbidden, err.Error())
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to login")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	render.Render(w, r, http.StatusOK, result)
 }
 
 // GetUser handles getting a user by ID
@@ -114,8 +127,11 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param id path int true "User ID"
+// @Param id path string true "User ID (UUID)"
+// @Param If-None-Match header string false "ETag of a cached response"
+// @Param If-Modified-Since header string false "Last-Modified of a cached response"
 // @Success 200 {object} models.User
+// @Success 304
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -124,35 +140,39 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
-	id, err := strconv.Atoi(idStr)
+	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		render.Error(w, r, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
 	user, err := h.service.GetByID(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
-			http.Error(w, "User not found", http.StatusNotFound)
+			render.Error(w, r, http.StatusNotFound, "User not found")
 			return
 		}
-		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		render.Error(w, r, http.StatusInternalServerError, "Failed to get user")
 		return
 	}
 
-	json.NewEncoder(w).Encode(user)
+	if notModified(w, r, usersETag([]*models.User{user}), user.UpdatedAt) {
+		return
+	}
+	render.Render(w, r, http.StatusOK, user)
 }
 
 // UpdateUser handles updating a user
 // @Summary Update a user
-// @Description Update a user
+// @Description Update a user. Attributes replace the current ones and must match the configured JSON Schema.
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param id path int true "User ID"
+// @Param id path string true "User ID (UUID)"
 // @Param user body models.UserUpdate true "User update data"
 // @Success 200 {object} models.User
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -161,40 +181,47 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
-	id, err := strconv.Atoi(idStr)
+	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		render.Error(w, r, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
 	var user models.UserUpdate
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if err := h.validate.Struct(user); err != nil {
 		validationErrors := err.(validator.ValidationErrors)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": validationErrors.Error()})
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
 		return
 	}
 
 	updatedUser, err := h.service.Update(r.Context(), id, &user)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
-			http.Error(w, "User not found", http.StatusNotFound)
+			render.Error(w, r, http.StatusNotFound, "User not found")
 			return
 		}
 		if errors.Is(err, repository.ErrConflict) {
-			http.Error(w, "Username or email already exists", http.StatusConflict)
+			render.Error(w, r, http.StatusConflict, "Username or email already exists")
+			return
+		}
+		if errors.Is(err, services.ErrUnknownRole) || errors.Is(err, password.ErrWeakPassword) || errors.Is(err, services.ErrInvalidAttributes) {
+			render.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrForbidden) {
+			render.Error(w, r, http.StatusForbidden, "Changing roles requires the roles:manage permission")
 			return
 		}
-		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		render.Error(w, r, http.StatusInternalServerError, "Failed to update user")
 		return
 	}
 
-	json.NewEncoder(w).Encode(updatedUser)
+	render.Render(w, r, http.StatusOK, updatedUser)
 }
 
 // DeleteUser handles deleting a user
@@ -203,7 +230,7 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param id path int true "User ID"
+// @Param id path string true "User ID (UUID)"
 // @Success 204 {object} nil
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
@@ -213,19 +240,19 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
-	id, err := strconv.Atoi(idStr)
+	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		render.Error(w, r, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
 	err = h.service.Delete(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
-			http.Error(w, "User not found", http.StatusNotFound)
+			render.Error(w, r, http.StatusNotFound, "User not found")
 			return
 		}
-		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		render.Error(w, r, http.StatusInternalServerError, "Failed to delete user")
 		return
 	}
 
@@ -234,13 +261,16 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 // ListUsers handles listing users
 // @Summary List users
-// @Description List users with pagination
+// @Description List users with pagination. Parameters named attr.<name> only list users whose attribute <name> equals the value, as a string or as the number or boolean it spells, e.g. attr.department=sales.
 // @Tags users
 // @Accept json
-// @Produce json
+// @Produce json,text/csv
 // @Param page query int false "Page number"
 // @Param page_size query int false "Page size"
+// @Param If-None-Match header string false "ETag of a cached response"
 // @Success 200 {object} map[string]interface{}
+// @Success 304
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /users [get]
@@ -265,9 +295,33 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	users, count, err := h.service.List(r.Context(), page, pageSize)
+	filter := models.UserFilter{}
+	for name, values := range r.URL.Query() {
+		attribute, ok := strings.CutPrefix(name, attributeFilterPrefix)
+		if !ok {
+			continue
+		}
+		if attribute == "" || len(values) != 1 {
+			render.Error(w, r, http.StatusBadRequest, "Invalid "+name+" parameter")
+			return
+		}
+		if filter.Attributes == nil {
+			filter.Attributes = map[string]string{}
+		}
+		filter.Attributes[attribute] = values[0]
+	}
+	if len(filter.Attributes) > maxAttributeFilters {
+		render.Error(w, r, http.StatusBadRequest, fmt.Sprintf("At most %d attribute filters are allowed", maxAttributeFilters))
+		return
+	}
+
+	users, count, err := h.service.List(r.Context(), filter, page, pageSize)
 	if err != nil {
-		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		render.Error(w, r, http.StatusInternalServerError, "Failed to list users")
+		return
+	}
+
+	if notModified(w, r, usersETag(users, page, pageSize, count, r.URL.RawQuery), time.Time{}) {
 		return
 	}
 
@@ -285,5 +339,88 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 		"has_prev":    hasPrev,
 	}
 
-	json.NewEncoder(w).Encode(response)
+	render.List(w, r, http.StatusOK, response, users)
+}
+
+// SetUserStatus handles changing the status of a user
+// @Summary Set the status of a user
+// @Description Activate, suspend until a time, disable or mark a user as pending verification. Users who are no longer active are signed out everywhere and cannot sign in. The change and its reason are kept in the status history.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID (UUID)"
+// @Param status body models.UserStatusUpdate true "New status"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/status [put]
+func (h *UserHandler) SetUserStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var update models.UserStatusUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := h.validate.Struct(update); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
+		return
+	}
+
+	user, err := h.service.SetStatus(r.Context(), id, &update)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			render.Error(w, r, http.StatusNotFound, "User not found")
+		case errors.Is(err, services.ErrInvalidStatusChange):
+			render.Error(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrForbidden):
+			render.Error(w, r, http.StatusForbidden, "Admins cannot change their own status")
+		default:
+			render.Error(w, r, http.StatusInternalServerError, "Failed to set user status")
+		}
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, user)
+}
+
+// GetUserStatusHistory handles listing the status changes of a user
+// @Summary Get the status history of a user
+// @Description List the status changes of a user with their reasons, newest first
+// @Tags users
+// @Produce json,text/csv
+// @Param id path string true "User ID (UUID)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/status-history [get]
+func (h *UserHandler) GetUserStatusHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	history, err := h.service.StatusHistory(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "User not found")
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to get status history")
+		return
+	}
+
+	render.List(w, r, http.StatusOK, map[string]interface{}{"history": history}, history)
 }
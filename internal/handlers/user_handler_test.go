@@ -0,0 +1,279 @@
+package handlers_test
+
+import (
+	"bytes"
+	stdimage "image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/testutil"
+)
+
+func TestAuthFlow(t *testing.T) {
+	server := testutil.NewServer(t)
+
+	token := server.Register("alice", "password1")
+	if token == "" {
+		t.Fatal("Expected register to return a token")
+	}
+
+	server.Request(http.MethodPost, "/auth/register").
+		JSON(map[string]string{"username": "alice", "email": "other@example.com", "password": "password1"}).
+		Do().
+		ExpectStatus(http.StatusConflict)
+
+	server.Request(http.MethodPost, "/auth/login").
+		JSON(map[string]string{"username": "alice", "password": "wrong-password"}).
+		Do().
+		ExpectStatus(http.StatusUnauthorized)
+
+	server.Request(http.MethodPost, "/auth/register").
+		JSON(map[string]string{"username": "bo", "email": "not-an-email", "password": "short"}).
+		Do().
+		ExpectStatus(http.StatusBadRequest)
+
+	server.Login("alice", "password1")
+}
+
+func TestUsersRequireAuthentication(t *testing.T) {
+	server := testutil.NewServer(t)
+
+	server.Request(http.MethodGet, "/users").Do().ExpectStatus(http.StatusUnauthorized)
+	server.Request(http.MethodGet, "/users").WithToken("garbage").Do().ExpectStatus(http.StatusUnauthorized)
+}
+
+func TestUserCRUD(t *testing.T) {
+	server := testutil.NewServer(t)
+	server.Register("alice", "password1")
+	server.Register("bob", "password1")
+	server.GrantRole("alice", "admin")
+	token := server.Login("alice", "password1")
+
+	var list struct {
+		Users []models.User `json:"users"`
+		Total int           `json:"total"`
+	}
+	server.Request(http.MethodGet, "/users?page_size=1").WithToken(token).Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&list)
+	if list.Total != 2 || len(list.Users) != 1 {
+		t.Fatalf("Wrong list response: %+v", list)
+	}
+
+	path := "/users/" + list.Users[0].ID.String()
+
+	var user models.User
+	server.Request(http.MethodGet, path).WithToken(token).Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&user)
+	if user.Username != "alice" {
+		t.Errorf("Wrong username, expected: %v, actual: %v", "alice", user.Username)
+	}
+
+	server.Request(http.MethodPut, path).WithToken(token).
+		JSON(map[string]string{"email": "bob@example.com"}).
+		Do().
+		ExpectStatus(http.StatusConflict)
+
+	server.Request(http.MethodPut, path).WithToken(token).
+		JSON(map[string]string{"email": "alice@example.org"}).
+		Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&user)
+	if user.Email != "alice@example.org" {
+		t.Errorf("Wrong email, expected: %v, actual: %v", "alice@example.org", user.Email)
+	}
+
+	server.Request(http.MethodDelete, path).WithToken(token).Do().ExpectStatus(http.StatusNoContent)
+	server.Request(http.MethodGet, path).WithToken(token).Do().ExpectStatus(http.StatusNotFound)
+	server.Request(http.MethodGet, "/users/abc").WithToken(token).Do().ExpectStatus(http.StatusBadRequest)
+	server.Request(http.MethodGet, "/users/1").WithToken(token).Do().ExpectStatus(http.StatusBadRequest)
+}
+
+func TestUserConditionalGet(t *testing.T) {
+	server := testutil.NewServer(t)
+	server.Register("alice", "password1")
+	server.GrantRole("alice", "admin")
+	token := server.Login("alice", "password1")
+
+	var list struct {
+		Users []models.User `json:"users"`
+	}
+	listed := server.Request(http.MethodGet, "/users").WithToken(token).Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&list)
+	server.Request(http.MethodGet, "/users").WithToken(token).
+		Header("If-None-Match", listed.Header.Get("ETag")).
+		Do().
+		ExpectStatus(http.StatusNotModified)
+
+	path := "/users/" + list.Users[0].ID.String()
+	got := server.Request(http.MethodGet, path).WithToken(token).Do().ExpectStatus(http.StatusOK)
+	etag := got.Header.Get("ETag")
+	if etag == "" || got.Header.Get("Last-Modified") == "" {
+		t.Fatalf("Expected ETag and Last-Modified, actual: %v", got.Header)
+	}
+	server.Request(http.MethodGet, path).WithToken(token).Header("If-None-Match", etag).Do().
+		ExpectStatus(http.StatusNotModified)
+	server.Request(http.MethodGet, path).WithToken(token).Header("If-Modified-Since", got.Header.Get("Last-Modified")).Do().
+		ExpectStatus(http.StatusNotModified)
+
+	server.Request(http.MethodPut, path).WithToken(token).
+		JSON(map[string]string{"email": "alice@example.org"}).
+		Do().
+		ExpectStatus(http.StatusOK)
+	server.Request(http.MethodGet, path).WithToken(token).Header("If-None-Match", etag).Do().
+		ExpectStatus(http.StatusOK)
+	server.Request(http.MethodGet, "/users").WithToken(token).
+		Header("If-None-Match", listed.Header.Get("ETag")).
+		Do().
+		ExpectStatus(http.StatusOK)
+}
+
+func TestUsersCannotChangeOtherUsers(t *testing.T) {
+	server := testutil.NewServer(t)
+	aliceToken := server.Register("alice", "password1")
+	bobToken := server.Register("bob", "password1")
+
+	var bob models.User
+	server.Request(http.MethodGet, "/me").WithToken(bobToken).Do().ExpectStatus(http.StatusOK).Decode(&bob)
+	path := "/users/" + bob.ID.String()
+
+	server.Request(http.MethodGet, path).WithToken(aliceToken).Do().ExpectStatus(http.StatusOK)
+	server.Request(http.MethodPut, path).WithToken(aliceToken).
+		JSON(map[string]string{"email": "alice@example.org"}).
+		Do().
+		ExpectStatus(http.StatusForbidden)
+	server.Request(http.MethodDelete, path).WithToken(aliceToken).Do().ExpectStatus(http.StatusForbidden)
+	server.Request(http.MethodDelete, path+"/avatar").WithToken(aliceToken).Do().ExpectStatus(http.StatusForbidden)
+
+	server.Request(http.MethodGet, "/me").WithToken(bobToken).Do().ExpectStatus(http.StatusOK)
+}
+
+func TestUserStatus(t *testing.T) {
+	server := testutil.NewServer(t)
+	server.Register("alice", "password1")
+	bobToken := server.Register("bob", "password1")
+	server.GrantRole("alice", "admin")
+	adminToken := server.Login("alice", "password1")
+
+	var bob models.User
+	server.Request(http.MethodGet, "/me").WithToken(bobToken).Do().ExpectStatus(http.StatusOK).Decode(&bob)
+	path := "/users/" + bob.ID.String()
+
+	server.Request(http.MethodPut, path+"/status").WithToken(bobToken).
+		JSON(map[string]string{"status": "disabled"}).
+		Do().
+		ExpectStatus(http.StatusForbidden)
+	server.Request(http.MethodPut, path+"/status").WithToken(adminToken).
+		JSON(map[string]string{"status": "suspended"}).
+		Do().
+		ExpectStatus(http.StatusBadRequest)
+	server.Request(http.MethodPut, path+"/status").WithToken(adminToken).
+		JSON(map[string]string{"status": "banned"}).
+		Do().
+		ExpectStatus(http.StatusBadRequest)
+
+	server.Request(http.MethodPut, path+"/status").WithToken(adminToken).
+		JSON(map[string]string{"status": "disabled", "reason": "spam"}).
+		Do().
+		ExpectStatus(http.StatusOK)
+	server.Request(http.MethodGet, "/me").WithToken(bobToken).Do().ExpectStatus(http.StatusUnauthorized)
+	server.Request(http.MethodPost, "/auth/login").
+		JSON(map[string]string{"username": "bob", "password": "password1"}).
+		Do().
+		ExpectStatus(http.StatusForbidden)
+
+	server.Request(http.MethodPut, path+"/status").WithToken(adminToken).
+		JSON(map[string]string{"status": "active"}).
+		Do().
+		ExpectStatus(http.StatusOK)
+	server.Login("bob", "password1")
+
+	var history struct {
+		History []models.UserStatusChange `json:"history"`
+	}
+	server.Request(http.MethodGet, path+"/status-history").WithToken(adminToken).Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&history)
+	if len(history.History) != 2 || history.History[1].Reason != "spam" || history.History[0].Status != models.UserStatusActive {
+		t.Errorf("Wrong history: %+v", history.History)
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	server := testutil.NewServer(t)
+	server.Register("alice", "password1")
+	bobToken := server.Register("bob", "password1")
+	server.GrantRole("alice", "admin")
+	adminToken := server.Login("alice", "password1")
+
+	var bob models.User
+	server.Request(http.MethodPatch, "/me").WithToken(bobToken).
+		JSON(map[string]string{"display_name": "Bob", "locale": "en-GB", "timezone": "Europe/London"}).
+		Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&bob)
+	if bob.DisplayName != "Bob" || bob.Locale != "en-GB" || bob.Timezone != "Europe/London" {
+		t.Errorf("Wrong profile: %+v", bob)
+	}
+	server.Request(http.MethodPatch, "/me").WithToken(bobToken).
+		JSON(map[string]string{"timezone": "Mars/Olympus"}).
+		Do().
+		ExpectStatus(http.StatusBadRequest)
+
+	server.Request(http.MethodPut, "/users/"+bob.ID.String()).WithToken(adminToken).
+		JSON(map[string]interface{}{"attributes": map[string]interface{}{"department": "sales", "level": 2}}).
+		Do().
+		ExpectStatus(http.StatusOK)
+	var list struct {
+		Users []models.User `json:"users"`
+	}
+	server.Request(http.MethodGet, "/users?attr.department=sales&attr.level=2").WithToken(adminToken).Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&list)
+	if len(list.Users) != 1 || list.Users[0].ID != bob.ID {
+		t.Errorf("Wrong filtered users: %+v", list.Users)
+	}
+	server.Request(http.MethodGet, "/users?attr.=sales").WithToken(adminToken).Do().ExpectStatus(http.StatusBadRequest)
+
+	var image bytes.Buffer
+	png.Encode(&image, stdimage.NewRGBA(stdimage.Rect(0, 0, 300, 300)))
+	body, contentType := avatarForm(t, image.Bytes())
+	server.Request(http.MethodPut, "/me/avatar").WithToken(bobToken).Body(body, contentType).Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&bob)
+	if !strings.HasPrefix(bob.AvatarURL, "/files/avatars/"+bob.ID.String()+"/") {
+		t.Fatalf("Wrong avatar URL: %v", bob.AvatarURL)
+	}
+	resp, err := server.Client().Get(server.URL + bob.AvatarURL)
+	if err != nil {
+		t.Fatalf("Error while downloading avatar: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("Wrong avatar response: %v, %v", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	body, contentType = avatarForm(t, []byte("<svg></svg>"))
+	server.Request(http.MethodPut, "/me/avatar").WithToken(bobToken).Body(body, contentType).Do().
+		ExpectStatus(http.StatusUnsupportedMediaType)
+	server.Request(http.MethodDelete, "/users/"+bob.ID.String()+"/avatar").WithToken(adminToken).Do().
+		ExpectStatus(http.StatusOK)
+}
+
+func avatarForm(t *testing.T, image []byte) ([]byte, string) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("avatar", "avatar.png")
+	if err != nil {
+		t.Fatalf("Error while building form: %v", err)
+	}
+	part.Write(image)
+	form.Close()
+	return body.Bytes(), form.FormDataContentType()
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// DefaultUserImportMaxSize bounds the body of an import.
+	DefaultUserImportMaxSize = 50 << 20
+	// DefaultUserImportMaxSyncSize is the largest import run within the
+	// request. Larger ones run in the background.
+	DefaultUserImportMaxSyncSize = 1 << 20
+
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+type UserImportHandler struct {
+	imports     services.UserImportService
+	users       services.UserService
+	maxSize     int64
+	maxSyncSize int64
+}
+
+// NewUserImportHandler returns a handler for imports of up to maxSize bytes,
+// running those above maxSyncSize in the background. Zero sizes use the
+// defaults.
+func NewUserImportHandler(imports services.UserImportService, users services.UserService, maxSize, maxSyncSize int64) *UserImportHandler {
+	if maxSize <= 0 {
+		maxSize = DefaultUserImportMaxSize
+	}
+	if maxSyncSize <= 0 {
+		maxSyncSize = DefaultUserImportMaxSyncSize
+	}
+	return &UserImportHandler{
+		imports:     imports,
+		users:       users,
+		maxSize:     maxSize,
+		maxSyncSize: maxSyncSize,
+	}
+}
+
+// ImportUsers handles importing users in bulk
+// @Summary Import users
+// @Description Create users from a CSV file with a header row or from newline-delimited JSON. In the upsert mode users are matched by email and updated. Rows that fail are reported and skipped; the others are written in one transaction. Large files, or any with async=true, are imported in the background and answered with 202 and the import to poll.
+// @Tags users
+// @Accept text/csv,application/x-ndjson
+// @Produce json
+// @Param mode query string false "create (default) or upsert"
+// @Param dry_run query bool false "Check every row without writing anything"
+// @Param async query bool false "Import in the background"
+// @Success 200 {object} models.UserImportReport
+// @Success 202 {object} models.UserImport
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/import [post]
+func (h *UserImportHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	opts := &models.UserImportOptions{Mode: r.URL.Query().Get("mode")}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		opts.Format = models.UserImportFormatCSV
+	case ContentTypeNDJSON, "application/ndjson":
+		opts.Format = models.UserImportFormatNDJSON
+	default:
+		render.Error(w, r, http.StatusUnsupportedMediaType, "Content-Type must be text/csv or application/x-ndjson")
+		return
+	}
+
+	var err error
+	if opts.DryRun, err = parseBoolParam(r, "dry_run"); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid dry_run parameter")
+		return
+	}
+	async, err := parseBoolParam(r, "async")
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid async parameter")
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxSize))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			render.Error(w, r, http.StatusRequestEntityTooLarge, "Import is too large")
+			return
+		}
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if async || int64(len(data)) > h.maxSyncSize {
+		userImport, err := h.imports.Start(r.Context(), opts, data)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Location", "/api/v1/users/imports/"+userImport.ID.String())
+		render.Render(w, r, http.StatusAccepted, userImport)
+		return
+	}
+
+	report, err := h.imports.Import(r.Context(), opts, bytes.NewReader(data))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	render.Render(w, r, http.StatusOK, report)
+}
+
+// GetUserImport handles getting a background import
+// @Summary Get a user import
+// @Description Get the status of an import running in the background, and its report once it has finished
+// @Tags users
+// @Produce json
+// @Param id path string true "Import ID"
+// @Success 200 {object} models.UserImport
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/imports/{id} [get]
+func (h *UserImportHandler) GetUserImport(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+
+	userImport, err := h.imports.Get(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	render.Render(w, r, http.StatusOK, userImport)
+}
+
+// ExportUsers handles exporting users
+// @Summary Export users
+// @Description Stream all users as newline-delimited JSON, or as CSV that can be imported again
+// @Tags users
+// @Produce application/x-ndjson,text/csv
+// @Success 200 {string} string
+// @Failure 403 {object} map[string]string
+// @Failure 406 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/export [get]
+func (h *UserImportHandler) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	offered := []string{ContentTypeNDJSON, render.ContentTypeCSV}
+	w.Header().Add("Vary", "Accept")
+	contentType, ok := render.Negotiate(r.Header.Get("Accept"), offered)
+	if !ok {
+		render.NotAcceptable(w, offered)
+		return
+	}
+
+	var encode func(user *models.User) error
+	var flush func() error
+	extension := "ndjson"
+	if contentType == render.ContentTypeCSV {
+		encoder := render.NewCSVEncoder(w, models.User{})
+		encode = func(user *models.User) error { return encoder.Encode(user) }
+		flush = encoder.Flush
+		extension = "csv"
+		contentType += "; charset=utf-8"
+	} else {
+		encoder := json.NewEncoder(w)
+		encode = func(user *models.User) error { return encoder.Encode(user) }
+		flush = func() error { return nil }
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="users.`+extension+`"`)
+	// An export takes as long as it takes, so the server's write timeout is
+	// lifted for it.
+	controller := http.NewResponseController(w)
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	written := 0
+	err := h.users.Export(r.Context(), func(user *models.User) error {
+		if err := encode(user); err != nil {
+			return err
+		}
+		written++
+		if written%100 == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			_ = controller.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if written == 0 {
+			render.Error(w, r, http.StatusInternalServerError, "Failed to export users")
+			return
+		}
+		// The status has been sent, so the connection is aborted for the
+		// client to see that the export is incomplete.
+		panic(http.ErrAbortHandler)
+	}
+	if err := flush(); err != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func (h *UserImportHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidImport):
+		render.Error(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrForbidden):
+		render.Error(w, r, http.StatusForbidden, "Forbidden")
+	case errors.Is(err, repository.ErrNotFound):
+		render.Error(w, r, http.StatusNotFound, "Import not found")
+	default:
+		render.Error(w, r, http.StatusInternalServerError, "Failed to import users")
+	}
+}
+
+func parseBoolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
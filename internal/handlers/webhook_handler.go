@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type WebhookHandler struct {
+	service  services.WebhookService
+	validate *validator.Validate
+}
+
+func NewWebhookHandler(service services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// CreateWebhook handles registering a webhook subscription
+// @Summary Create a webhook subscription
+// @Description Register a URL to receive signed callbacks for the given event types ("*" for all)
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param subscription body models.WebhookSubscriptionCreate true "Webhook subscription"
+// @Success 201 {object} models.WebhookSubscription
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var subscription models.WebhookSubscriptionCreate
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(subscription); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		render.Error(w, r, http.StatusBadRequest, validationErrors.Error())
+		return
+	}
+
+	created, err := h.service.CreateSubscription(r.Context(), &subscription)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidWebhookURL) {
+			render.Error(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+
+	render.Render(w, r, http.StatusCreated, created)
+}
+
+// ListWebhooks handles listing webhook subscriptions
+// @Summary List webhook subscriptions
+// @Description List all webhook subscriptions
+// @Tags webhooks
+// @Produce json,text/csv
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /webhooks [get]
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.service.ListSubscriptions(r.Context())
+	if err != nil {
+		render.Error(w, r, http.StatusInternalServerError, "Failed to list webhooks")
+		return
+	}
+
+	render.List(w, r, http.StatusOK, map[string]interface{}{"webhooks": subscriptions}, subscriptions)
+}
+
+// GetWebhook handles getting a webhook subscription by ID
+// @Summary Get a webhook subscription
+// @Description Get a webhook subscription by ID
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} models.WebhookSubscription
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	subscription, err := h.service.GetSubscription(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to get webhook")
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, subscription)
+}
+
+// DeleteWebhook handles deleting a webhook subscription
+// @Summary Delete a webhook subscription
+// @Description Delete a webhook subscription together with its delivery log
+// @Tags webhooks
+// @Param id path int true "Webhook ID"
+// @Success 204 {object} nil
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	if err := h.service.DeleteSubscription(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries handles listing the webhook delivery log
+// @Summary List webhook deliveries
+// @Description List webhook deliveries, newest first, with pagination and filters
+// @Tags webhooks
+// @Produce json,text/csv
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Param subscription_id query int false "Webhook ID"
+// @Param status query string false "pending, succeeded or dead"
+// @Param event_type query string false "Event type, e.g. user.updated"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /webhook-deliveries [get]
+func (h *WebhookHandler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.WebhookDeliveryFilter{
+		Status:    query.Get("status"),
+		EventType: query.Get("event_type"),
+	}
+	if value := query.Get("subscription_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			render.Error(w, r, http.StatusBadRequest, "Invalid subscription_id")
+			return
+		}
+		filter.SubscriptionID = id
+	}
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	pageSize, _ := strconv.Atoi(query.Get("page_size"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	deliveries, count, err := h.service.ListDeliveries(r.Context(), filter, page, pageSize)
+	if err != nil {
+		render.Error(w, r, http.StatusInternalServerError, "Failed to list webhook deliveries")
+		return
+	}
+
+	totalPages := (count + pageSize - 1) / pageSize
+
+	response := map[string]interface{}{
+		"deliveries":  deliveries,
+		"total":       count,
+		"page":        page,
+		"page_size":   pageSize,
+		"total_pages": totalPages,
+		"has_next":    page < totalPages,
+		"has_prev":    page > 1,
+	}
+
+	render.List(w, r, http.StatusOK, response, deliveries)
+}
+
+// GetWebhookDelivery handles getting a webhook delivery by ID
+// @Summary Get a webhook delivery
+// @Description Get a webhook delivery including its payload and last response
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Delivery ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /webhook-deliveries/{id} [get]
+func (h *WebhookHandler) GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	delivery, err := h.service.GetDelivery(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "Delivery not found")
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to get delivery")
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, delivery)
+}
+
+// ReplayWebhookDelivery handles replaying a webhook delivery
+// @Summary Replay a webhook delivery
+// @Description Send a succeeded or dead delivery again, with a fresh set of attempts
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Delivery ID"
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /webhook-deliveries/{id}/replay [post]
+func (h *WebhookHandler) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		render.Error(w, r, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	delivery, err := h.service.Replay(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusNotFound, "Delivery not found")
+			return
+		}
+		if errors.Is(err, services.ErrDeliveryPending) {
+			render.Error(w, r, http.StatusConflict, err.Error())
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, "Failed to replay delivery")
+		return
+	}
+
+	render.Render(w, r, http.StatusAccepted, delivery)
+}
@@ -0,0 +1,69 @@
+// Package images prepares uploaded pictures for display.
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxPixels bounds the decoded size of an image, which can be far larger
+// than its file. Pictures are only ever shown small, so a few times the
+// largest avatar is plenty.
+const maxPixels = 4096 * 4096
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrTooLarge          = errors.New("image dimensions are too large")
+)
+
+// Formats are the content types Square accepts.
+var Formats = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// Square crops the image in data to its centre square and scales it down to
+// size by size pixels. The image is encoded again, which drops metadata such
+// as EXIF locations: JPEGs stay JPEGs and the other formats become PNGs, to
+// keep transparency. It returns the new image and its content type.
+func Square(data []byte, size int) ([]byte, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedFormat
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, "", ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	offset := image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2)
+	crop := image.Rect(0, 0, side, side).Add(bounds.Min).Add(offset)
+	// Small images are not scaled up.
+	size = min(size, side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, "", fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), "image/png", nil
+}
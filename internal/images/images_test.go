@@ -0,0 +1,77 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestSquare(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 200; y++ {
+			// Red on the left and right, blue in the middle, which is what
+			// the crop keeps.
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 100 && x < 300 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	for _, tt := range []struct {
+		encode      func(*bytes.Buffer) error
+		contentType string
+	}{
+		{encode: func(buf *bytes.Buffer) error { return png.Encode(buf, src) }, contentType: "image/png"},
+		{encode: func(buf *bytes.Buffer) error { return jpeg.Encode(buf, src, nil) }, contentType: "image/jpeg"},
+	} {
+		var buf bytes.Buffer
+		if err := tt.encode(&buf); err != nil {
+			t.Fatalf("Error while encoding image: %v", err)
+		}
+
+		data, contentType, err := Square(buf.Bytes(), 64)
+		if err != nil {
+			t.Fatalf("Error while resizing image: %v", err)
+		}
+		if contentType != tt.contentType {
+			t.Errorf("Wrong content type, expected: %v, actual: %v", tt.contentType, contentType)
+		}
+		resized, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Error while decoding resized image: %v", err)
+		}
+		if resized.Bounds() != image.Rect(0, 0, 64, 64) {
+			t.Errorf("Wrong bounds, expected: 64x64, actual: %v", resized.Bounds())
+		}
+		if r, _, b, _ := resized.At(0, 32).RGBA(); r > b {
+			t.Errorf("Expected the centre of the image to be kept, actual: %v", resized.At(0, 32))
+		}
+	}
+
+	var small bytes.Buffer
+	png.Encode(&small, image.NewRGBA(image.Rect(0, 0, 10, 20)))
+	if data, _, err := Square(small.Bytes(), 64); err != nil {
+		t.Errorf("Error while resizing small image: %v", err)
+	} else if config, _, _ := image.DecodeConfig(bytes.NewReader(data)); config.Width != 10 || config.Height != 10 {
+		t.Errorf("Expected small images not to be scaled up, actual: %vx%v", config.Width, config.Height)
+	}
+
+	// A large blank PNG compresses to a small file, which is why the
+	// dimensions are checked before decoding.
+	var large bytes.Buffer
+	png.Encode(&large, image.NewGray(image.Rect(0, 0, 4097, 4096)))
+	if _, _, err := Square(large.Bytes(), 64); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, actual: %v", err)
+	}
+
+	if _, _, err := Square([]byte("<svg></svg>"), 64); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, actual: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 7, 30, 0, time.UTC)
+	cases := map[string]time.Time{
+		"*/15 * * * *":   time.Date(2024, time.January, 31, 10, 15, 0, 0, time.UTC),
+		"0 3 * * *":      time.Date(2024, time.February, 1, 3, 0, 0, 0, time.UTC),
+		"30 9 * * 1-5":   time.Date(2024, time.February, 1, 9, 30, 0, 0, time.UTC),
+		"0 0 1,15 * *":   time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":     time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"0 12 13 * 5":    time.Date(2024, time.February, 2, 12, 0, 0, 0, time.UTC),
+		"@hourly":        time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC),
+		"@weekly":        time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC),
+		"@every 90s":     time.Date(2024, time.January, 31, 10, 9, 0, 0, time.UTC),
+		"0 0 * * 7":      time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC),
+		"5-10/5 8 * * *": time.Date(2024, time.February, 1, 8, 5, 0, 0, time.UTC),
+	}
+
+	for spec, expected := range cases {
+		schedule, err := ParseCron(spec)
+		if err != nil {
+			t.Errorf("Error while parsing %q: %v", spec, err)
+			continue
+		}
+		if actual := schedule.Next(from); !actual.Equal(expected) {
+			t.Errorf("Wrong next run for %q, expected: %v, actual: %v", spec, expected, actual)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "@every 1ms"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
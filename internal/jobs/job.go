@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+)
+
+const defaultMaxAttempts = 10
+
+// JobArgs is implemented by the argument struct of every job type. Kind must
+// work on the zero value since it is used to route stored jobs to handlers.
+type JobArgs interface {
+	Kind() string
+}
+
+// Job is what a typed handler receives.
+type Job[T JobArgs] struct {
+	ID          int64
+	Attempt     int
+	MaxAttempts int
+	Args        T
+}
+
+type Handler[T JobArgs] func(ctx context.Context, job *Job[T]) error
+
+type InsertOptions struct {
+	// UniqueKey prevents inserting a job while another one with the same key
+	// is waiting or running.
+	UniqueKey   string
+	RunAt       time.Time
+	MaxAttempts int
+}
+
+// Client enqueues jobs. Inserting with a ctx from TxManager.WithinTx stores
+// the job in that transaction.
+type Client struct {
+	repo        repository.JobRepository
+	maxAttempts int
+}
+
+func NewClient(repo repository.JobRepository, maxAttempts int) *Client {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	return &Client{
+		repo:        repo,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Insert enqueues a job. If opts.UniqueKey is taken, the existing job is
+// returned instead.
+func (c *Client) Insert(ctx context.Context, args JobArgs, opts *InsertOptions) (*models.Job, error) {
+	if opts == nil {
+		opts = &InsertOptions{}
+	}
+
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s job args: %w", args.Kind(), err)
+	}
+
+	job := &models.JobInsert{
+		Kind:        args.Kind(),
+		Args:        encoded,
+		UniqueKey:   opts.UniqueKey,
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       opts.RunAt,
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = c.maxAttempts
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now().UTC()
+	}
+
+	inserted, _, err := c.repo.Insert(ctx, job)
+	return inserted, err
+}
+
+type cancelError struct {
+	err error
+}
+
+func (e *cancelError) Error() string {
+	return e.err.Error()
+}
+
+func (e *cancelError) Unwrap() error {
+	return e.err
+}
+
+// Cancel wraps an error returned by a handler so that the job is discarded
+// right away instead of being retried.
+func Cancel(err error) error {
+	return &cancelError{err: err}
+}
+
+func isCancel(err error) bool {
+	var c *cancelError
+	return errors.As(err, &c)
+}
@@ -0,0 +1,272 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/events"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+)
+
+const (
+	defaultConcurrency  = 10
+	defaultPollInterval = time.Second
+	defaultDrainTimeout = 30 * time.Second
+	defaultRescueAfter  = 30 * time.Minute
+	defaultRetention    = 7 * 24 * time.Hour
+	maintenanceInterval = time.Minute
+)
+
+type WorkerOptions struct {
+	Concurrency  int
+	PollInterval time.Duration
+	// DrainTimeout is how long Run waits for running jobs after ctx is
+	// cancelled before cancelling their contexts too.
+	DrainTimeout time.Duration
+	// RescueAfter is how long a job may stay running before it is assumed
+	// that its worker died and it is made available again.
+	RescueAfter time.Duration
+	// Retention is how long completed and discarded jobs are kept.
+	Retention time.Duration
+	Backoff   func(attempts int) time.Duration
+}
+
+func NewWorkerOptions(config config.JobsConfig) WorkerOptions {
+	return WorkerOptions{
+		Concurrency:  config.Concurrency,
+		PollInterval: config.PollInterval,
+		DrainTimeout: config.DrainTimeout,
+		RescueAfter:  config.RescueAfter,
+		Retention:    config.Retention,
+	}
+}
+
+type handlerFunc func(ctx context.Context, job *models.Job) error
+
+type scheduledJob struct {
+	name     string
+	schedule Schedule
+	args     JobArgs
+}
+
+// Worker runs registered job handlers for jobs stored by a Client.
+type Worker struct {
+	repo      repository.JobRepository
+	client    *Client
+	handlers  map[string]handlerFunc
+	schedules []scheduledJob
+	opts      WorkerOptions
+	logger    *log.Logger
+}
+
+func NewWorker(repo repository.JobRepository, client *Client, opts WorkerOptions) *Worker {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = defaultDrainTimeout
+	}
+	if opts.RescueAfter <= 0 {
+		opts.RescueAfter = defaultRescueAfter
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultRetention
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = events.ExponentialBackoff(5*time.Second, 6*time.Hour)
+	}
+
+	return &Worker{
+		repo:     repo,
+		client:   client,
+		handlers: make(map[string]handlerFunc),
+		opts:     opts,
+		logger:   log.New(log.Writer(), "JOBS: ", log.LstdFlags),
+	}
+}
+
+// Register adds the handler for jobs with args of type T.
+func Register[T JobArgs](w *Worker, handler Handler[T]) {
+	var zero T
+	w.handlers[zero.Kind()] = func(ctx context.Context, job *models.Job) error {
+		var args T
+		if err := json.Unmarshal(job.Args, &args); err != nil {
+			return Cancel(fmt.Errorf("failed to decode %s job args: %w", job.Kind, err))
+		}
+		return handler(ctx, &Job[T]{
+			ID:          job.ID,
+			Attempt:     job.Attempts,
+			MaxAttempts: job.MaxAttempts,
+			Args:        args,
+		})
+	}
+}
+
+// Schedule enqueues args every time spec (see ParseCron) comes due. Each
+// run uses the unique key "schedule:<name>", so a run is skipped while the
+// previous one is still waiting or running. With several workers only one
+// of them enqueues a given run.
+func (w *Worker) Schedule(name, spec string, args JobArgs) error {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	w.schedules = append(w.schedules, scheduledJob{name: name, schedule: schedule, args: args})
+	return nil
+}
+
+// Run works jobs until ctx is cancelled, then drains the running ones.
+func (w *Worker) Run(ctx context.Context) {
+	// Job contexts outlive ctx so that running jobs can finish while draining.
+	jobsCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	kinds := make([]string, 0, len(w.handlers))
+	for kind := range w.handlers {
+		kinds = append(kinds, kind)
+	}
+
+	slots := make(chan struct{}, w.opts.Concurrency)
+	var running sync.WaitGroup
+
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+	var lastMaintenance time.Time
+
+	for ctx.Err() == nil {
+		if time.Since(lastMaintenance) >= maintenanceInterval {
+			w.maintain(ctx)
+			lastMaintenance = time.Now()
+		}
+		w.enqueueScheduled(ctx)
+
+		if free := w.opts.Concurrency - len(slots); free > 0 && len(kinds) > 0 {
+			jobs, err := w.repo.Claim(ctx, kinds, time.Now().UTC(), free)
+			if err != nil && ctx.Err() == nil {
+				w.logger.Printf("failed to claim jobs: %v", err)
+			}
+			for _, job := range jobs {
+				slots <- struct{}{}
+				running.Add(1)
+				go func(job *models.Job) {
+					defer func() {
+						<-slots
+						running.Done()
+					}()
+					w.work(jobsCtx, job)
+				}(job)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(w.opts.DrainTimeout):
+		w.logger.Printf("cancelling jobs still running after %v", w.opts.DrainTimeout)
+		cancelJobs()
+		<-drained
+	}
+}
+
+// WorkOnce claims and runs due jobs one by one until none are left. It is
+// meant for tests and one-off commands.
+func (w *Worker) WorkOnce(ctx context.Context) (int, error) {
+	kinds := make([]string, 0, len(w.handlers))
+	for kind := range w.handlers {
+		kinds = append(kinds, kind)
+	}
+
+	worked := 0
+	for {
+		jobs, err := w.repo.Claim(ctx, kinds, time.Now().UTC(), 1)
+		if err != nil || len(jobs) == 0 {
+			return worked, err
+		}
+		w.work(ctx, jobs[0])
+		worked++
+	}
+}
+
+func (w *Worker) work(ctx context.Context, job *models.Job) {
+	err := w.call(ctx, job)
+
+	// The outcome must be stored even when ctx was cancelled mid-job.
+	storeCtx := context.WithoutCancel(ctx)
+	now := time.Now().UTC()
+	switch {
+	case err == nil:
+		err = w.repo.Complete(storeCtx, job.ID, now)
+	case isCancel(err) || job.Attempts >= job.MaxAttempts:
+		w.logger.Printf("discarding %s job %d after %d attempts: %v", job.Kind, job.ID, job.Attempts, err)
+		err = w.repo.Discard(storeCtx, job.ID, now, err.Error())
+	default:
+		err = w.repo.Retry(storeCtx, job.ID, now.Add(w.opts.Backoff(job.Attempts)), err.Error())
+	}
+	if err != nil {
+		w.logger.Printf("failed to store result of %s job %d: %v", job.Kind, job.ID, err)
+	}
+}
+
+func (w *Worker) call(ctx context.Context, job *models.Job) (err error) {
+	handler, ok := w.handlers[job.Kind]
+	if !ok {
+		return Cancel(fmt.Errorf("no handler registered for %s jobs", job.Kind))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s job panicked: %v", job.Kind, r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+func (w *Worker) enqueueScheduled(ctx context.Context) {
+	now := time.Now().UTC()
+	for _, s := range w.schedules {
+		due, err := w.repo.DueSchedule(ctx, s.name, now, s.schedule.Next(now))
+		if err != nil {
+			w.logger.Printf("failed to check schedule %s: %v", s.name, err)
+			continue
+		}
+		if !due {
+			continue
+		}
+		if _, err := w.client.Insert(ctx, s.args, &InsertOptions{UniqueKey: "schedule:" + s.name}); err != nil {
+			w.logger.Printf("failed to enqueue scheduled job %s: %v", s.name, err)
+		}
+	}
+}
+
+func (w *Worker) maintain(ctx context.Context) {
+	now := time.Now().UTC()
+	if n, err := w.repo.Rescue(ctx, now.Add(-w.opts.RescueAfter)); err != nil {
+		w.logger.Printf("failed to rescue stuck jobs: %v", err)
+	} else if n > 0 {
+		w.logger.Printf("rescued %d stuck jobs", n)
+	}
+	if _, err := w.repo.DeleteFinished(ctx, now.Add(-w.opts.Retention)); err != nil {
+		w.logger.Printf("failed to delete finished jobs: %v", err)
+	}
+}
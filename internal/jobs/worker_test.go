@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+)
+
+type emailArgs struct {
+	To string `json:"to"`
+}
+
+func (emailArgs) Kind() string {
+	return "email"
+}
+
+func TestWorkerRunsTypedJobs(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryJobRepository()
+	client := NewClient(repo, 3)
+	worker := NewWorker(repo, client, WorkerOptions{Backoff: func(int) time.Duration { return 0 }})
+
+	var sent []string
+	Register(worker, func(ctx context.Context, job *Job[emailArgs]) error {
+		if job.Args.To == "flaky@example.com" && job.Attempt < 2 {
+			return errors.New("smtp unavailable")
+		}
+		if job.Args.To == "invalid" {
+			return Cancel(errors.New("invalid address"))
+		}
+		sent = append(sent, job.Args.To)
+		return nil
+	})
+
+	ok, _ := client.Insert(ctx, emailArgs{To: "alice@example.com"}, nil)
+	flaky, _ := client.Insert(ctx, emailArgs{To: "flaky@example.com"}, nil)
+	invalid, _ := client.Insert(ctx, emailArgs{To: "invalid"}, nil)
+
+	if _, err := worker.WorkOnce(ctx); err != nil {
+		t.Fatalf("Error while working jobs: %v", err)
+	}
+
+	if len(sent) != 2 {
+		t.Errorf("Wrong sent emails, expected: %v, actual: %v", 2, sent)
+	}
+
+	expected := map[int64]string{ok.ID: models.JobStatusCompleted, flaky.ID: models.JobStatusCompleted, invalid.ID: models.JobStatusDiscarded}
+	for id, status := range expected {
+		job, _ := repo.Get(ctx, id)
+		if job.Status != status {
+			t.Errorf("Wrong status of job %d, expected: %v, actual: %v", id, status, job.Status)
+		}
+	}
+
+	job, _ := repo.Get(ctx, flaky.ID)
+	if job.Attempts != 2 || job.LastError != "smtp unavailable" {
+		t.Errorf("Wrong retried job, expected: %v attempts, actual: %+v", 2, job)
+	}
+}
+
+func TestClientUniqueKey(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryJobRepository()
+	client := NewClient(repo, 0)
+
+	first, _ := client.Insert(ctx, emailArgs{To: "a"}, &InsertOptions{UniqueKey: "welcome:a"})
+	second, _ := client.Insert(ctx, emailArgs{To: "a"}, &InsertOptions{UniqueKey: "welcome:a"})
+	if first.ID != second.ID {
+		t.Errorf("Expected duplicate insert to return job %v, actual: %v", first.ID, second.ID)
+	}
+
+	repo.Complete(ctx, first.ID, time.Now())
+	third, _ := client.Insert(ctx, emailArgs{To: "a"}, &InsertOptions{UniqueKey: "welcome:a"})
+	if third.ID == first.ID {
+		t.Errorf("Expected a new job once the previous one finished")
+	}
+}
+
+func TestWorkerDrainsRunningJobs(t *testing.T) {
+	repo := repository.NewMemoryJobRepository()
+	client := NewClient(repo, 0)
+	worker := NewWorker(repo, client, WorkerOptions{PollInterval: 10 * time.Millisecond, DrainTimeout: time.Second})
+
+	started := make(chan struct{})
+	var finished atomic.Bool
+	Register(worker, func(ctx context.Context, job *Job[emailArgs]) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+		return nil
+	})
+	job, _ := client.Insert(context.Background(), emailArgs{To: "a"}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(done)
+	}()
+
+	<-started
+	cancel()
+	<-done
+
+	if !finished.Load() {
+		t.Errorf("Expected running job to finish before Run returned")
+	}
+	if stored, _ := repo.Get(context.Background(), job.ID); stored.Status != models.JobStatusCompleted {
+		t.Errorf("Wrong status, expected: %v, actual: %v", models.JobStatusCompleted, stored.Status)
+	}
+}
+
+func TestScheduleEnqueuesOncePerRun(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryJobRepository()
+	client := NewClient(repo, 0)
+	worker := NewWorker(repo, client, WorkerOptions{})
+	if err := worker.Schedule("digest", "@every 1s", emailArgs{To: "team"}); err != nil {
+		t.Fatalf("Error while scheduling: %v", err)
+	}
+
+	worker.enqueueScheduled(ctx)
+	time.Sleep(1100 * time.Millisecond)
+	worker.enqueueScheduled(ctx)
+	worker.enqueueScheduled(ctx)
+
+	jobs, _ := repo.Claim(ctx, []string{"email"}, time.Now().UTC(), 10)
+	if len(jobs) != 1 {
+		t.Errorf("Wrong number of scheduled jobs, expected: %v, actual: %v", 1, len(jobs))
+	}
+}
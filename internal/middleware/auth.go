@@ -1,48 +1,68 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
-	"time"
 
-	"github.com/Romasmi/go-rest-api-template/internal/config"
-	"github.com/go-chi/jwtauth/v5"
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
 )
 
-var TokenAuth *jwtauth.JWTAuth
+const APIKeyHeader = "X-API-Key"
 
-func InitAuth(config *config.Config) {
-	TokenAuth = jwtauth.New("HS256", []byte(config.JWT.Secret), nil)
-}
-
-func Authenticator(next http.Handler) http.Handler {
-	return jwtauth.Verifier(TokenAuth)(
-		jwtauth.Authenticator(next),
-	)
-}
+// Authenticator accepts a bearer JWT checked by verifier or, when apiKeys is
+// not nil, an API key sent in the X-API-Key header or as
+// "Authorization: ApiKey <key>". Either way the claims end up in the request
+// context.
+func Authenticator(verifier auth.TokenVerifier, apiKeys auth.TokenVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenVerifier := verifier
+			token := ExtractBearerToken(r)
+			if key := ExtractAPIKey(r); key != "" {
+				tokenVerifier, token = apiKeys, key
+			}
+			if token == "" || tokenVerifier == nil {
+				render.Error(w, r, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+				return
+			}
 
-func GenerateJWT(userID string, role string) (string, error) {
-	expiration := time.Now().Add(24 * time.Hour)
+			// Verifier errors can carry details of the keys and claims, so
+			// clients only learn that the token was refused.
+			claims, err := tokenVerifier.Verify(r.Context(), token)
+			if err != nil {
+				render.Error(w, r, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+				return
+			}
+			if _, ok := claims[auth.ClaimPurpose]; ok {
+				render.Error(w, r, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+				return
+			}
 
-	claims := map[string]interface{}{
-		"user_id": userID,
-		"role":    role,
-		"exp":     expiration.Unix(),
+			next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), claims)))
+		})
 	}
-
-	_, tokenString, err := TokenAuth.Encode(claims)
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
 }
 
 func ExtractBearerToken(r *http.Request) string {
 	bearerToken := r.Header.Get("Authorization")
 
 	strArr := strings.Split(bearerToken, " ")
-	if len(strArr) == 2 {
+	if len(strArr) == 2 && !strings.EqualFold(strArr[0], "ApiKey") {
+		return strArr[1]
+	}
+
+	return ""
+}
+
+func ExtractAPIKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+
+	strArr := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(strArr) == 2 && strings.EqualFold(strArr[0], "ApiKey") {
 		return strArr[1]
 	}
 
@@ -64,6 +84,60 @@ func GetRoleFromToken(claims map[string]interface{}) string {
 }
 
 func GetClaimsFromRequest(r *http.Request) map[string]interface{} {
-	_, claims, _ := jwtauth.FromContext(r.Context())
-	return claims
+	return auth.FromContext(r.Context())
+}
+
+// RequireRole rejects requests whose token role is not one of roles. It must
+// run after Authenticator.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := GetRoleFromToken(GetClaimsFromRequest(r))
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			render.Error(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		})
+	}
+}
+
+// RequireScope rejects requests authenticated with an API key that lacks
+// scope. It must run after Authenticator.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.HasScope(auth.FromContext(r.Context()), scope) {
+				render.Error(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PermissionChecker decides whether the caller behind claims has permission.
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, claims auth.Claims, permission string) (bool, error)
+}
+
+// RequirePermission rejects requests whose caller lacks permission. It must
+// run after Authenticator.
+func RequirePermission(checker PermissionChecker, permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := checker.HasPermission(r.Context(), auth.FromContext(r.Context()), permission)
+			if err != nil {
+				render.Error(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return
+			}
+			if !allowed {
+				render.Error(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+)
+
+type failingVerifier struct{}
+
+func (failingVerifier) Verify(context.Context, string) (auth.Claims, error) {
+	return nil, errors.New(`"exp" not satisfied: key kid-1`)
+}
+
+func TestAuthenticatorHidesVerifierErrors(t *testing.T) {
+	handler := Authenticator(failingVerifier{}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the request to be refused")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Wrong status, expected: %v, actual: %v", http.StatusUnauthorized, rec.Code)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "kid-1") || !strings.Contains(body, `"error":"Unauthorized"`) {
+		t.Errorf("Wrong body: %s", body)
+	}
+}
+
+type denyingChecker struct{}
+
+func (denyingChecker) HasPermission(context.Context, auth.Claims, string) (bool, error) {
+	return false, nil
+}
+
+func TestAuthorizationErrorsAreJSON(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the request to be refused")
+	})
+
+	for name, handler := range map[string]http.Handler{
+		"RequireRole":       RequireRole("admin")(next),
+		"RequirePermission": RequirePermission(denyingChecker{}, "users:write")(next),
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Wrong status of %s, expected: %v, actual: %v", name, http.StatusForbidden, rec.Code)
+		}
+		if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Wrong content type of %s, expected: %v, actual: %v", name, "application/json", contentType)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const DefaultCompressionMinSize = 1024
+
+var (
+	gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+	zstdWriters = sync.Pool{New: func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+)
+
+// Compress compresses responses with zstd or gzip, whichever the
+// Accept-Encoding header prefers. Responses smaller than minSize are sent as
+// they are, since compressing them saves too little.
+func Compress(minSize int) func(http.Handler) http.Handler {
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			accept := r.Header.Get("Accept-Encoding")
+			encoding, ok := render.Negotiate(accept, []string{"zstd", "gzip", "identity"})
+			if accept == "" || !ok || encoding == "identity" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize, status: http.StatusOK}
+			defer cw.Close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// compressWriter buffers the start of a response until it knows whether it
+// reaches minSize, and then either compresses it or passes it through.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	minSize     int
+	status      int
+	wroteHeader bool
+	buf         []byte
+	encoder     io.WriteCloser
+	passThrough bool
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = status
+	w.wroteHeader = true
+	// Responses without a body, and ones that are already encoded, are
+	// passed through.
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		w.Header().Get("Content-Encoding") != "" {
+		w.startPassThrough()
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case w.passThrough:
+		return w.ResponseWriter.Write(b)
+	case w.encoder != nil:
+		return w.encoder.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize {
+		if err := w.startEncoding(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what has been written so far, compressed when it is already
+// large enough.
+func (w *compressWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.encoder == nil && !w.passThrough {
+		if len(w.buf) >= w.minSize {
+			w.startEncoding()
+		} else {
+			w.startPassThrough()
+			w.ResponseWriter.Write(w.buf)
+			w.buf = nil
+		}
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close finishes the response.
+func (w *compressWriter) Close() error {
+	switch {
+	case w.encoder != nil:
+		err := w.encoder.Close()
+		w.release()
+		return err
+	case !w.passThrough && (w.wroteHeader || len(w.buf) > 0):
+		w.flushPassThrough()
+	}
+	return nil
+}
+
+func (w *compressWriter) startEncoding() error {
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", w.encoding)
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		// The compressed bytes differ, so a strong validator must too.
+		header.Set("ETag", `W/`+etag)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	switch w.encoding {
+	case "zstd":
+		encoder := zstdWriters.Get().(*zstd.Encoder)
+		encoder.Reset(w.ResponseWriter)
+		w.encoder = encoder
+	default:
+		encoder := gzipWriters.Get().(*gzip.Writer)
+		encoder.Reset(w.ResponseWriter)
+		w.encoder = encoder
+	}
+
+	buf := w.buf
+	w.buf = nil
+	_, err := w.encoder.Write(buf)
+	return err
+}
+
+func (w *compressWriter) release() {
+	switch encoder := w.encoder.(type) {
+	case *zstd.Encoder:
+		encoder.Reset(nil)
+		zstdWriters.Put(encoder)
+	case *gzip.Writer:
+		encoder.Reset(nil)
+		gzipWriters.Put(encoder)
+	}
+	w.encoder = nil
+}
+
+func (w *compressWriter) startPassThrough() {
+	w.passThrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// flushPassThrough sends a complete response that is too small to compress.
+func (w *compressWriter) flushPassThrough() {
+	if w.Header().Get("Content-Length") == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+	}
+	w.startPassThrough()
+	w.ResponseWriter.Write(w.buf)
+	w.buf = nil
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"username":"user"},`, 100)
+	handler := Compress(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Write([]byte(`{"ok":true}`))
+		case "/not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(large))
+		}
+	}))
+
+	send := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		encoding       string
+	}{
+		{name: "gzip", path: "/large", acceptEncoding: "gzip", encoding: "gzip"},
+		{name: "zstd preferred", path: "/large", acceptEncoding: "gzip, zstd", encoding: "zstd"},
+		{name: "quality", path: "/large", acceptEncoding: "zstd;q=0.5, gzip", encoding: "gzip"},
+		{name: "no header", path: "/large", acceptEncoding: "", encoding: ""},
+		{name: "identity only", path: "/large", acceptEncoding: "br", encoding: ""},
+		{name: "below minimum size", path: "/small", acceptEncoding: "gzip", encoding: ""},
+		{name: "not modified", path: "/not-modified", acceptEncoding: "gzip", encoding: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := send(tt.path, tt.acceptEncoding)
+			if encoding := rec.Header().Get("Content-Encoding"); encoding != tt.encoding {
+				t.Fatalf("Wrong Content-Encoding, expected: %q, actual: %q", tt.encoding, encoding)
+			}
+			if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Errorf("Wrong Vary, expected: %v, actual: %v", "Accept-Encoding", vary)
+			}
+
+			var body io.Reader = rec.Body
+			switch tt.encoding {
+			case "gzip":
+				reader, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("Error while reading gzip body: %v", err)
+				}
+				body = reader
+			case "zstd":
+				reader, err := zstd.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("Error while reading zstd body: %v", err)
+				}
+				defer reader.Close()
+				body = reader
+			}
+			decoded, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("Error while decoding body: %v", err)
+			}
+
+			switch tt.path {
+			case "/large":
+				if string(decoded) != large {
+					t.Errorf("Wrong body, expected %v bytes, actual: %v bytes", len(large), len(decoded))
+				}
+				etag := `"v1"`
+				if tt.encoding != "" {
+					etag = `W/"v1"`
+				}
+				if rec.Header().Get("ETag") != etag {
+					t.Errorf("Wrong ETag, expected: %v, actual: %v", etag, rec.Header().Get("ETag"))
+				}
+			case "/small":
+				if rec.Header().Get("Content-Length") != "11" {
+					t.Errorf("Wrong Content-Length, expected: %v, actual: %v", 11, rec.Header().Get("Content-Length"))
+				}
+			case "/not-modified":
+				if rec.Code != http.StatusNotModified || len(decoded) != 0 {
+					t.Errorf("Wrong response, expected: %v with no body, actual: %v with %q", http.StatusNotModified, rec.Code, decoded)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/requestinfo"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	DefaultIdempotencyKeyTTL = 24 * time.Hour
+	// DefaultIdempotentBodySize bounds the bodies of requests with an
+	// Idempotency-Key, which are read in full to fingerprint them.
+	DefaultIdempotentBodySize = 1 << 20
+	maxIdempotencyKeyLength   = 255
+	// idempotencyReservation is how long a key stays in flight when its
+	// request never finishes, for example because the process died.
+	idempotencyReservation = 5 * time.Minute
+)
+
+// Idempotency makes POST requests with an Idempotency-Key header safe to
+// retry. The first response for a key is stored for ttl and sent again for
+// retries with the same key, method, path and body. Reusing the key for a
+// different request is refused with 422. Retries sent while the first
+// request is still running get 409. Responses with a 5xx status are not
+// stored, so the request can be retried. Keys belong to the caller, or to
+// the client IP on public routes, so it must run after Authenticator on
+// protected routes. Bodies over maxBodySize bytes are refused with 413, so
+// it must be at least the limit of any route behind it.
+func Idempotency(repo repository.IdempotencyRepository, ttl time.Duration, maxBodySize int64) func(http.Handler) http.Handler {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyKeyTTL
+	}
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultIdempotentBodySize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				render.Error(w, r, http.StatusBadRequest, "Idempotency-Key is too long")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+			if err != nil {
+				var maxBytesError *http.MaxBytesError
+				if errors.As(err, &maxBytesError) {
+					render.Error(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+					return
+				}
+				render.Error(w, r, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := requestFingerprint(r, body)
+			scope := idempotencyScope(r)
+
+			stored, reserved, err := repo.Reserve(r.Context(), scope, key, fingerprint, time.Now().Add(idempotencyReservation))
+			if err != nil {
+				render.Error(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return
+			}
+			if !reserved {
+				switch {
+				case stored != nil && stored.Fingerprint != fingerprint:
+					render.Error(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was used for a different request")
+				case stored == nil || stored.Pending():
+					w.Header().Set("Retry-After", "1")
+					render.Error(w, r, http.StatusConflict, "A request with this Idempotency-Key is in progress")
+				default:
+					replay(w, stored)
+				}
+				return
+			}
+
+			saved := false
+			defer func() {
+				if !saved {
+					if err := repo.Release(context.WithoutCancel(r.Context()), scope, key); err != nil {
+						log.Printf("failed to release idempotency key %q: %v", key, err)
+					}
+				}
+			}()
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			if recorder.status >= http.StatusInternalServerError {
+				return
+			}
+
+			// The response has been sent, so a failure to store it only
+			// means that a retry runs the request again.
+			err = repo.Save(context.WithoutCancel(r.Context()), &models.IdempotentResponse{
+				Scope:       scope,
+				Key:         key,
+				Fingerprint: fingerprint,
+				StatusCode:  recorder.status,
+				Headers:     recorder.Header().Clone(),
+				Body:        recorder.body.Bytes(),
+				ExpiresAt:   time.Now().Add(ttl),
+			})
+			if err != nil {
+				log.Printf("failed to store response for idempotency key %q: %v", key, err)
+				return
+			}
+			saved = true
+		})
+	}
+}
+
+// idempotencyScope returns the caller keys belong to. Service accounts are
+// told apart by API key and anonymous callers by IP, so they cannot see each
+// other's responses.
+func idempotencyScope(r *http.Request) string {
+	claims := auth.FromContext(r.Context())
+	if userID := GetUserIDFromToken(claims); userID != "" {
+		return userID
+	}
+	if prefix, ok := claims["api_key"].(string); ok {
+		return "key:" + prefix
+	}
+	ip := requestinfo.FromContext(r.Context()).IP
+	if ip == "" {
+		ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	return "ip:" + ip
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, stored *models.IdempotentResponse) {
+	for name, values := range stored.Headers {
+		// The request ID and CORS headers belong to the current request.
+		if name == RequestIDHeader || strings.HasPrefix(name, "Access-Control-") {
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.Header().Set("Content-Length", strconv.Itoa(len(stored.Body)))
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.Body)
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
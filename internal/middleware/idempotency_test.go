@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+)
+
+func TestIdempotency(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{}, 1)
+	finish := make(chan struct{})
+	handler := Idempotency(repository.NewMemoryIdempotencyRepository(), time.Hour, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			started <- struct{}{}
+			<-finish
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	}))
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Duplicates sent while the first request runs are refused.
+	var wg sync.WaitGroup
+	var first *httptest.ResponseRecorder
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		first = send("key-1", `{"name":"a"}`)
+	}()
+	<-started
+	if rec := send("key-1", `{"name":"a"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Wrong status for a key in flight, expected: %v, actual: %v", http.StatusConflict, rec.Code)
+	}
+	close(finish)
+	wg.Wait()
+
+	if first.Code != http.StatusCreated || first.Body.String() != `{"id":1}` {
+		t.Errorf("Wrong first response, expected: %v %v, actual: %v %v", http.StatusCreated, `{"id":1}`, first.Code, first.Body.String())
+	}
+	retry := send("key-1", `{"name":"a"}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != `{"id":1}` || retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("Wrong replayed response, expected: %v %v, actual: %v %v", http.StatusCreated, `{"id":1}`, retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Wrong replayed content type, expected: %v, actual: %v", "application/json", retry.Header().Get("Content-Type"))
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Wrong number of handler calls, expected: %v, actual: %v", 1, calls.Load())
+	}
+
+	if rec := send("key-1", `{"name":"b"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Wrong status for reused key, expected: %v, actual: %v", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if rec := send("key-2", `{"name":"b"}`); rec.Code != http.StatusCreated || rec.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("Expected new key to run the request, actual: %v", rec.Code)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Wrong number of handler calls, expected: %v, actual: %v", 2, calls.Load())
+	}
+}
+
+func TestIdempotencySkipsServerErrors(t *testing.T) {
+	calls := 0
+	handler := Idempotency(repository.NewMemoryIdempotencyRepository(), time.Hour, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 2 {
+		t.Errorf("Wrong number of handler calls, expected: %v, actual: %v", 2, calls)
+	}
+}
+
+func TestIdempotencyScopesAnonymousKeysByIP(t *testing.T) {
+	calls := 0
+	handler := Idempotency(repository.NewMemoryIdempotencyRepository(), time.Hour, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	for _, remoteAddr := range []string{"203.0.113.1:1234", "203.0.113.2:1234", "203.0.113.1:5678"} {
+		req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(`{}`))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 2 {
+		t.Errorf("Wrong number of handler calls, expected: %v, actual: %v", 2, calls)
+	}
+}
+
+func TestIdempotencyBodySize(t *testing.T) {
+	handler := Idempotency(repository.NewMemoryIdempotencyRepository(), time.Hour, 8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	for body, status := range map[string]int{`{"a":1}`: http.StatusCreated, `{"a":"long"}`: http.StatusRequestEntityTooLarge} {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, body)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("Wrong status for %s, expected: %v, actual: %v", body, status, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	ghandlers "github.com/gorilla/handlers"
+)
+
+// ParseTrustedProxies parses the addresses and CIDR ranges of trusted
+// reverse proxies.
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if addr, err := netip.ParseAddr(value); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ProxyHeaders applies the X-Forwarded-For, X-Forwarded-Proto and
+// X-Forwarded-Host headers of requests sent by one of trusted. The client IP
+// is the last address in X-Forwarded-For that is not a trusted proxy, since
+// the ones before it can be made up by the client. Headers of requests from
+// anyone else are ignored.
+func ProxyHeaders(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, prefix := range trusted {
+			if prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			remote, err := netip.ParseAddrPort(r.RemoteAddr)
+			if err != nil || !isTrusted(remote.Addr()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := remote.Addr()
+			forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+			for i := len(forwarded) - 1; i >= 0 && isTrusted(client); i-- {
+				addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+				if err != nil {
+					break
+				}
+				client = addr
+			}
+
+			ghandlers.ProxyHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.RemoteAddr = net.JoinHostPort(client.Unmap().String(), "0")
+				next.ServeHTTP(w, r)
+			})).ServeHTTP(w, r)
+		})
+	}
+}
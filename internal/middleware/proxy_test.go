@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/requestinfo"
+)
+
+func TestProxyHeaders(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("Error while parsing trusted proxies: %v", err)
+	}
+	if _, err := ParseTrustedProxies([]string{"proxy.local"}); err == nil {
+		t.Error("Expected an invalid proxy to be refused")
+	}
+
+	var ip, scheme string
+	handler := ProxyHeaders(proxies)(RequestInfo(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = requestinfo.FromContext(r.Context()).IP
+		scheme = r.URL.Scheme
+	})))
+
+	for _, tt := range []struct {
+		remoteAddr string
+		forwarded  []string
+		expectedIP string
+		scheme     string
+	}{
+		{remoteAddr: "203.0.113.9:4000", forwarded: []string{"1.2.3.4"}, expectedIP: "203.0.113.9"},
+		{remoteAddr: "10.1.2.3:4000", forwarded: []string{"1.2.3.4"}, expectedIP: "1.2.3.4", scheme: "https"},
+		{remoteAddr: "10.1.2.3:4000", forwarded: []string{"6.6.6.6, 1.2.3.4, 192.168.1.1"}, expectedIP: "1.2.3.4", scheme: "https"},
+		{remoteAddr: "10.1.2.3:4000", forwarded: []string{"6.6.6.6", "1.2.3.4"}, expectedIP: "1.2.3.4", scheme: "https"},
+		{remoteAddr: "10.1.2.3:4000", forwarded: []string{"not-an-ip"}, expectedIP: "10.1.2.3", scheme: "https"},
+		{remoteAddr: "10.1.2.3:4000", expectedIP: "10.1.2.3", scheme: "https"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		for _, value := range tt.forwarded {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		req.Header.Set("X-Forwarded-Proto", "https")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if ip != tt.expectedIP || scheme != tt.scheme {
+			t.Errorf("Wrong client for %v and %v, expected: %v %q, actual: %v %q", tt.remoteAddr, tt.forwarded, tt.expectedIP, tt.scheme, ip, scheme)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/requestinfo"
+	"github.com/google/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestInfo stores the client IP, user agent and request ID in the request
+// context. A request ID is generated when the client did not send one.
+func RequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			ip = host
+		}
+
+		ctx := requestinfo.NewContext(r.Context(), requestinfo.Info{
+			IP:        ip,
+			UserAgent: r.UserAgent(),
+			RequestID: requestID,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
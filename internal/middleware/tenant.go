@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+)
+
+const TenantHeader = "X-Tenant"
+
+type TenantResolver interface {
+	GetByID(ctx context.Context, id int) (*models.Organization, error)
+	GetBySlug(ctx context.Context, slug string) (*models.Organization, error)
+}
+
+// Tenant scopes the request to the organization named by the X-Tenant header
+// or, when baseDomain is set, by the subdomain of the request host. Requests
+// that name no organization are left unscoped for TenantFromClaims or
+// DefaultTenant.
+func Tenant(resolver TenantResolver, baseDomain string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			slug := r.Header.Get(TenantHeader)
+			if slug == "" && baseDomain != "" {
+				slug = subdomain(r.Host, baseDomain)
+			}
+			if slug == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			organization, err := resolver.GetBySlug(r.Context(), slug)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					render.Error(w, r, http.StatusNotFound, "Unknown tenant")
+					return
+				}
+				render.Error(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(withTenant(r.Context(), organization)))
+		})
+	}
+}
+
+// TenantFromClaims scopes the request to the organization of its token, the
+// default one for tokens without the claim. A token used against another
+// organization than the one named by the request is rejected. It must run
+// after Authenticator.
+func TenantFromClaims(resolver TenantResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := auth.OrganizationID(auth.FromContext(r.Context()))
+			if !ok {
+				id = models.DefaultOrganizationID
+			}
+
+			if current, ok := tenant.FromContext(r.Context()); ok {
+				if current.ID != id {
+					render.Error(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			serveInOrganization(w, r, next, resolver, id)
+		})
+	}
+}
+
+// DefaultTenant scopes requests that name no organization to the default one.
+// It is meant for routes without authentication.
+func DefaultTenant(resolver TenantResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := tenant.FromContext(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			serveInOrganization(w, r, next, resolver, models.DefaultOrganizationID)
+		})
+	}
+}
+
+// RequireTenant rejects requests scoped to another organization than id. It
+// must run after TenantFromClaims.
+func RequireTenant(id int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if current, ok := tenant.FromContext(r.Context()); !ok || current.ID != id {
+				render.Error(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func serveInOrganization(w http.ResponseWriter, r *http.Request, next http.Handler, resolver TenantResolver, id int) {
+	organization, err := resolver.GetByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.Error(w, r, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		render.Error(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+
+	next.ServeHTTP(w, r.WithContext(withTenant(r.Context(), organization)))
+}
+
+func withTenant(ctx context.Context, organization *models.Organization) context.Context {
+	return tenant.NewContext(ctx, tenant.Tenant{ID: organization.ID, Slug: organization.Slug})
+}
+
+// subdomain returns "acme" for host "acme.example.com:8080" and base domain
+// "example.com", and "" when host is not a direct subdomain of it.
+func subdomain(host, baseDomain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	sub, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(baseDomain))
+	if !ok || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
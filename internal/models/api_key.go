@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+type APIKey struct {
+	ID             int64      `json:"id" db:"id"`
+	UserID         *int       `json:"-" db:"user_id"`
+	OrganizationID int        `json:"-" db:"organization_id"`
+	Name           string     `json:"name" db:"name"`
+	Prefix         string     `json:"prefix" db:"prefix"`
+	SecretHash     string     `json:"-" db:"secret_hash"`
+	Role           string     `json:"role,omitempty" db:"role"`
+	Scopes         []string   `json:"scopes" db:"scopes"`
+	ExpiresAt      *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt     *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt      *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ServiceAccount reports whether the key acts on its own rather than on
+// behalf of a user.
+func (k *APIKey) ServiceAccount() bool {
+	return k.UserID == nil
+}
+
+type APIKeyCreate struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=* users:read users:write api_keys admin"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	// ServiceAccountRole creates a key that is not tied to a user and acts
+	// with this role. Only admins may set it.
+	ServiceAccountRole string `json:"service_account_role" validate:"omitempty,oneof=admin user"`
+}
+
+// APIKeyCreated is returned once, when the key is created. The full key is
+// not stored and cannot be shown again.
+type APIKeyCreated struct {
+	APIKey *APIKey `json:"api_key"`
+	Key    string  `json:"key"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AuditActionUserRegister       = "user.register"
+	AuditActionUserCreate         = "user.create"
+	AuditActionUserUpdate         = "user.update"
+	AuditActionUserDelete         = "user.delete"
+	AuditActionUserLogin          = "user.login"
+	AuditActionAPIKeyCreate       = "api_key.create"
+	AuditActionAPIKeyRevoke       = "api_key.revoke"
+	AuditActionRoleCreate         = "role.create"
+	AuditActionRoleUpdate         = "role.update"
+	AuditActionRoleDelete         = "role.delete"
+	AuditActionUserRoles          = "user.roles"
+	AuditActionOrganizationCreate = "organization.create"
+)
+
+// Redacted replaces secret values in audit changes.
+const Redacted = "[REDACTED]"
+
+type AuditEvent struct {
+	ID             int64                  `json:"id" db:"id"`
+	OrganizationID int                    `json:"-" db:"organization_id"`
+	OccurredAt     time.Time              `json:"occurred_at" db:"occurred_at"`
+	ActorID        string                 `json:"actor_id" db:"actor_id"`
+	ActorRole      string                 `json:"actor_role" db:"actor_role"`
+	Action         string                 `json:"action" db:"action"`
+	TargetType     string                 `json:"target_type" db:"target_type"`
+	TargetID       string                 `json:"target_id" db:"target_id"`
+	Success        bool                   `json:"success" db:"success"`
+	Changes        map[string]AuditChange `json:"changes,omitempty" db:"changes"`
+	IP             string                 `json:"ip" db:"ip"`
+	UserAgent      string                 `json:"user_agent" db:"user_agent"`
+	RequestID      string                 `json:"request_id" db:"request_id"`
+}
+
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+type AuditEventFilter struct {
+	ActorID  string
+	Action   string
+	TargetID string
+	From     time.Time
+	To       time.Time
+}
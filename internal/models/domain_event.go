@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventUserRegistered   = "user.registered"
+	EventUserUpdated      = "user.updated"
+	EventUserEmailChanged = "user.email_changed"
+	EventUserDeleted      = "user.deleted"
+)
+
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusDelivered = "delivered"
+	OutboxStatusFailed    = "failed"
+)
+
+// DomainEvent is what gets delivered to event sinks. Events sharing an
+// OrderingKey are delivered in the order they were written.
+type DomainEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	Type        string          `json:"type"`
+	OrderingKey string          `json:"ordering_key"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+type UserEventPayload struct {
+	// Organization is the slug of the tenant the change was made in. It is
+	// empty for changes made outside a request.
+	Organization string                 `json:"organization,omitempty"`
+	User         *User                  `json:"user"`
+	Changes      map[string]AuditChange `json:"changes,omitempty"`
+}
+
+type OutboxEvent struct {
+	ID            int64           `json:"id" db:"id"`
+	EventID       uuid.UUID       `json:"event_id" db:"event_id"`
+	EventType     string          `json:"event_type" db:"event_type"`
+	OrderingKey   string          `json:"ordering_key" db:"ordering_key"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	Status        string          `json:"status" db:"status"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	LastError     string          `json:"last_error" db:"last_error"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at" db:"delivered_at"`
+}
+
+func (e *OutboxEvent) DomainEvent() *DomainEvent {
+	return &DomainEvent{
+		ID:          e.EventID,
+		Type:        e.EventType,
+		OrderingKey: e.OrderingKey,
+		OccurredAt:  e.CreatedAt,
+		Payload:     e.Payload,
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// IdempotentResponse is the stored response to a request sent with an
+// Idempotency-Key header. Retries with the same key get it again.
+type IdempotentResponse struct {
+	OrganizationID int                 `db:"organization_id"`
+	Scope          string              `db:"scope"`
+	Key            string              `db:"key"`
+	Fingerprint    string              `db:"fingerprint"`
+	StatusCode     int                 `db:"status_code"`
+	Headers        map[string][]string `db:"headers"`
+	Body           []byte              `db:"body"`
+	CreatedAt      time.Time           `db:"created_at"`
+	ExpiresAt      time.Time           `db:"expires_at"`
+}
+
+// Pending reports whether the request of the key is still running, so there
+// is no response yet.
+func (r *IdempotentResponse) Pending() bool {
+	return r.StatusCode == 0
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	JobStatusAvailable = "available"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusDiscarded = "discarded"
+)
+
+type Job struct {
+	ID          int64           `json:"id" db:"id"`
+	Kind        string          `json:"kind" db:"kind"`
+	Args        json.RawMessage `json:"args" db:"args"`
+	UniqueKey   *string         `json:"unique_key" db:"unique_key"`
+	Status      string          `json:"status" db:"status"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	MaxAttempts int             `json:"max_attempts" db:"max_attempts"`
+	RunAt       time.Time       `json:"run_at" db:"run_at"`
+	LockedAt    *time.Time      `json:"locked_at" db:"locked_at"`
+	FinishedAt  *time.Time      `json:"finished_at" db:"finished_at"`
+	LastError   string          `json:"last_error" db:"last_error"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+type JobInsert struct {
+	Kind        string
+	Args        json.RawMessage
+	UniqueKey   string
+	MaxAttempts int
+	RunAt       time.Time
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AuditActionMFAEnable        = "mfa.enable"
+	AuditActionMFADisable       = "mfa.disable"
+	AuditActionMFARecoveryCodes = "mfa.recovery_codes"
+)
+
+// UserTOTP is the authenticator app enrolled by a user. It only protects
+// logins once ConfirmedAt is set.
+type UserTOTP struct {
+	UserID      int        `db:"user_id"`
+	Secret      string     `db:"secret"`
+	ConfirmedAt *time.Time `db:"confirmed_at"`
+	// LastUsedStep is the time step of the last accepted code, so a code
+	// cannot be used twice.
+	LastUsedStep int64 `db:"last_used_step"`
+	// FailedAttempts counts invalid codes since the last accepted one. When
+	// it reaches the limit, codes are refused until LockedUntil.
+	FailedAttempts int        `db:"failed_attempts"`
+	LockedUntil    *time.Time `db:"locked_until"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+}
+
+// TOTPEnrollment is shown once so the user can add the secret to an
+// authenticator app, usually by scanning URI as a QR code.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// LoginResult holds either a token, or an MFA token to complete the login
+// with /auth/mfa/verify or, when enrollment is required, /auth/mfa/enroll.
+type LoginResult struct {
+	Token                 string `json:"token,omitempty"`
+	MFARequired           bool   `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string `json:"mfa_token,omitempty"`
+}
+
+type MFAVerify struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFAEnroll struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code"`
+}
+
+type MFACode struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAEnrolled completes a login that required enrollment.
+type MFAEnrolled struct {
+	Token         string   `json:"token"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
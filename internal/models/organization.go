@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultOrganizationID is the organization created by the migrations. It
+// owns every user that existed before multi-tenancy, serves requests that do
+// not name a tenant and is the only one allowed to manage cross-tenant
+// resources.
+const DefaultOrganizationID = 1
+
+type Organization struct {
+	ID        int       `json:"-" db:"id"`
+	Slug      string    `json:"slug" db:"slug"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type OrganizationCreate struct {
+	Slug string `json:"slug" validate:"required,min=2,max=63,hostname_rfc1123,excludesall=."`
+	Name string `json:"name" validate:"required,max=255"`
+	// Admin is the first user of the organization. It gets the admin role.
+	Admin UserCreate `json:"admin" validate:"required"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionPasskeyRegister = "passkey.register"
+	AuditActionPasskeyDelete   = "passkey.delete"
+)
+
+// Passkey is a WebAuthn credential a user can log in with instead of a
+// password.
+type Passkey struct {
+	ID              int64      `json:"id" db:"id"`
+	OrganizationID  int        `json:"-" db:"organization_id"`
+	UserID          int        `json:"-" db:"user_id"`
+	Name            string     `json:"name" db:"name"`
+	CredentialID    []byte     `json:"credential_id" db:"credential_id"`
+	PublicKey       []byte     `json:"-" db:"public_key"`
+	AttestationType string     `json:"-" db:"attestation_type"`
+	AAGUID          []byte     `json:"-" db:"aaguid"`
+	SignCount       int64      `json:"-" db:"sign_count"`
+	Transports      []string   `json:"transports" db:"transports"`
+	BackupEligible  bool       `json:"backup_eligible" db:"backup_eligible"`
+	BackupState     bool       `json:"backup_state" db:"backup_state"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+type PasskeyLoginBegin struct {
+	// Username limits the login to the passkeys of one user. Without it any
+	// discoverable passkey of the organization can be used.
+	Username string `json:"username"`
+}
+
+// PasskeyCeremony holds the options for navigator.credentials.create() or
+// get(), and the session to send back with the result.
+type PasskeyCeremony struct {
+	Options interface{} `json:"options"`
+	Session string      `json:"session"`
+}
+
+// PasskeyFinish carries the PublicKeyCredential returned by the browser, as
+// serialized by its toJSON() method.
+type PasskeyFinish struct {
+	Session    string          `json:"session" validate:"required"`
+	Name       string          `json:"name" validate:"omitempty,max=100"`
+	Credential json.RawMessage `json:"credential" validate:"required" swaggertype:"object"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	PermissionUsersRead      = "users:read"
+	PermissionUsersWrite     = "users:write"
+	PermissionUsersDelete    = "users:delete"
+	PermissionUsersExport    = "users:export"
+	PermissionRolesManage    = "roles:manage"
+	PermissionAuditRead      = "audit:read"
+	PermissionWebhooksManage = "webhooks:manage"
+	PermissionSessionsManage = "sessions:manage"
+	// PermissionOrganizationsManage only has an effect in the default
+	// organization.
+	PermissionOrganizationsManage = "organizations:manage"
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+type Permission struct {
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"description"`
+}
+
+type Role struct {
+	ID          int       `json:"-" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Builtin     bool      `json:"builtin" db:"builtin"`
+	Permissions []string  `json:"permissions" db:"permissions"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type RoleCreate struct {
+	Name        string   `json:"name" validate:"required,min=2,max=50,excludesall= /"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type RoleUpdate struct {
+	Description *string  `json:"description" validate:"omitempty,max=255"`
+	Permissions []string `json:"permissions" validate:"omitempty,dive,required"`
+}
+
+type UserRolesUpdate struct {
+	// The first role becomes the user's primary role, the one shown in
+	// User.Role and in token claims.
+	Roles []string `json:"roles" validate:"required,min=1,dive,required"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const AuditActionSessionRevoke = "session.revoke"
+
+// Session is a login of a user on one device. Every login token belongs to
+// one, and stops working once it is revoked.
+type Session struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	OrganizationID int        `json:"-" db:"organization_id"`
+	UserID         int        `json:"-" db:"user_id"`
+	UserAgent      string     `json:"user_agent" db:"user_agent"`
+	IP             string     `json:"ip" db:"ip"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	LastSeenAt     time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	ExpiresAt      time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt      *time.Time `json:"-" db:"revoked_at"`
+	// Current marks the session of the request.
+	Current bool `json:"current" db:"-"`
+}
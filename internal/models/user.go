@@ -2,33 +2,143 @@ package models
 
 import (
 	"time"
+
+	"github.com/google/uuid"
+)
+
+// Users who are not active cannot sign in, and their tokens are revoked when
+// they leave the active status.
+const (
+	UserStatusActive = "active"
+	// UserStatusSuspended users are active again once SuspendedUntil has
+	// passed.
+	UserStatusSuspended           = "suspended"
+	UserStatusDisabled            = "disabled"
+	UserStatusPendingVerification = "pending_verification"
+
+	AuditActionUserStatus = "user.status"
 )
 
 type User struct {
-	ID           int       `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`
-	Role         string    `json:"role"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	InternalID int       `json:"-" db:"id"`
+	ID         uuid.UUID `json:"id" db:"public_id"`
+	// OrganizationID is the tenant the user is a member of. Usernames and
+	// emails are unique per organization.
+	OrganizationID int    `json:"-" db:"organization_id"`
+	Username       string `json:"username" db:"username"`
+	Email          string `json:"email" db:"email"`
+	PasswordHash   string `json:"-" db:"password_hash"`
+	Role           string `json:"role" db:"role"`
+	Status         string `json:"status" db:"status"`
+	// SuspendedUntil is only set for suspended users.
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty" db:"suspended_until"`
+	DisplayName    string     `json:"display_name" db:"display_name"`
+	Locale         string     `json:"locale" db:"locale"`
+	Timezone       string     `json:"timezone" db:"timezone"`
+	// Attributes are app-specific values, checked against the configured
+	// JSON Schema.
+	Attributes map[string]interface{} `json:"attributes" db:"attributes"`
+	// AvatarKey is the storage key of the avatar, which is downloaded from
+	// AvatarURL.
+	AvatarKey string `json:"-" db:"avatar_key"`
+	AvatarURL string `json:"avatar_url,omitempty" db:"avatar_url"`
+	// TokenVersion is copied into issued tokens. Tokens of an older version
+	// are rejected.
+	TokenVersion int       `json:"-" db:"token_version"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// StatusAt is the status of the user at t, when a suspension may have ended.
+func (u *User) StatusAt(t time.Time) string {
+	if u.Status == UserStatusSuspended && u.SuspendedUntil != nil && !t.Before(*u.SuspendedUntil) {
+		return UserStatusActive
+	}
+	if u.Status == "" {
+		return UserStatusActive
+	}
+	return u.Status
+}
+
+// UserStatusUpdate changes the status of a user. SuspendedUntil is required
+// for, and only allowed with, the suspended status.
+type UserStatusUpdate struct {
+	Status         string     `json:"status" validate:"required,oneof=active suspended disabled pending_verification"`
+	SuspendedUntil *time.Time `json:"suspended_until"`
+	Reason         string     `json:"reason" validate:"max=500"`
+}
+
+// UserStatusChange is an entry of the status history of a user.
+type UserStatusChange struct {
+	ID             int64      `json:"id" db:"id"`
+	OrganizationID int        `json:"-" db:"organization_id"`
+	UserID         int        `json:"-" db:"user_id"`
+	Status         string     `json:"status" db:"status"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty" db:"suspended_until"`
+	Reason         string     `json:"reason" db:"reason"`
+	// ChangedBy is the ID of the user who made the change, empty for the
+	// admin CLI.
+	ChangedBy string    `json:"changed_by" db:"changed_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
 type UserCreate struct {
 	Username string `json:"username" validate:"required,min=3,max=100"`
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=8"`
-	Role     string `json:"role" validate:"omitempty,oneof=admin user"`
+	Role     string `json:"role" validate:"omitempty,max=50"`
+	// PasswordHash is stored instead of a hash of Password when set.
+	PasswordHash string `json:"-"`
 }
 
+// UserUpdate leaves empty and nil fields unchanged. Attributes replace all
+// current ones, so {} clears them.
 type UserUpdate struct {
-	Username string `json:"username" validate:"omitempty,min=3,max=100"`
-	Email    string `json:"email" validate:"omitempty,email"`
-	Password string `json:"password" validate:"omitempty,min=8"`
-	Role     string `json:"role" validate:"omitempty,oneof=admin user"`
+	Username     string `json:"username" validate:"omitempty,min=3,max=100"`
+	Email        string `json:"email" validate:"omitempty,email"`
+	Password     string `json:"password" validate:"omitempty,min=8"`
+	Role         string `json:"role" validate:"omitempty,max=50"`
+	PasswordHash string `json:"-"`
+	UserProfile
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// UserProfile holds the profile fields. Unlike the other fields of updates
+// they can be cleared, with an empty string, so nil leaves them unchanged.
+type UserProfile struct {
+	DisplayName *string `json:"display_name" validate:"omitempty,max=100"`
+	Locale      *string `json:"locale" validate:"omitempty,max=35,len=0|bcp47_language_tag"`
+	Timezone    *string `json:"timezone" validate:"omitempty,max=64,len=0|timezone"`
+}
+
+// UserFilter narrows lists of users. Attributes match users whose attribute
+// of that name, as text, equals the value.
+type UserFilter struct {
+	Attributes map[string]string
 }
 
 type UserLogin struct {
 	Username string `json:"username" validate:"required"`
 	Password string `json:"password" validate:"required"`
 }
+
+// ProfileUpdate is what users may change about themselves through /me.
+type ProfileUpdate struct {
+	Username string `json:"username" validate:"omitempty,min=3,max=100"`
+	UserProfile
+	// Role is only read to refuse role changes.
+	Role string `json:"role,omitempty" swaggerignore:"true"`
+}
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+}
+
+type EmailChange struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type EmailChangeConfirm struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+const AuditActionIdentityLink = "identity.link"
+
+// UserIdentity links a user to an account at an external identity provider.
+type UserIdentity struct {
+	ID             int64      `json:"id" db:"id"`
+	OrganizationID int        `json:"-" db:"organization_id"`
+	UserID         int        `json:"-" db:"user_id"`
+	Provider       string     `json:"provider" db:"provider"`
+	Subject        string     `json:"subject" db:"subject"`
+	Email          string     `json:"email" db:"email"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	LastLoginAt    *time.Time `json:"last_login_at" db:"last_login_at"`
+}
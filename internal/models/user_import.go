@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UserImportFormatCSV    = "csv"
+	UserImportFormatNDJSON = "ndjson"
+
+	// UserImportModeCreate reports rows whose username or email is taken.
+	UserImportModeCreate = "create"
+	// UserImportModeUpsert updates the users matched by email and creates
+	// the others.
+	UserImportModeUpsert = "upsert"
+
+	UserImportStatusPending   = "pending"
+	UserImportStatusRunning   = "running"
+	UserImportStatusCompleted = "completed"
+	UserImportStatusFailed    = "failed"
+
+	AuditActionUserImport = "user.import"
+)
+
+// UserImportRow is a user read from an import file. Role may be left empty:
+// new users then get the default role and existing ones keep theirs.
+// Password may only be left empty for existing users.
+type UserImportRow struct {
+	// Row is the line of the file the user was read from.
+	Row      int    `json:"-"`
+	Username string `json:"username" validate:"required,min=3,max=100"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"omitempty,min=8"`
+	Role     string `json:"role" validate:"omitempty,max=50"`
+	// PasswordHash is what is stored for Password.
+	PasswordHash string `json:"-"`
+}
+
+type UserImportRowError struct {
+	Row   int    `json:"row"`
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+type UserImportOptions struct {
+	Format string `json:"format" db:"format"`
+	Mode   string `json:"mode" db:"mode"`
+	// DryRun checks every row, against the database too, without writing
+	// anything.
+	DryRun bool `json:"dry_run" db:"dry_run"`
+}
+
+// UserImportReport is the outcome of an import. Rows that failed are
+// skipped, the others are written together.
+type UserImportReport struct {
+	Total     int                  `json:"total" db:"total_rows"`
+	Created   int                  `json:"created" db:"created_rows"`
+	Updated   int                  `json:"updated" db:"updated_rows"`
+	Unchanged int                  `json:"unchanged" db:"unchanged_rows"`
+	Failed    int                  `json:"failed" db:"failed_rows"`
+	Errors    []UserImportRowError `json:"errors" db:"errors"`
+}
+
+// UserImport is an import run by a background job.
+type UserImport struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	OrganizationID int       `json:"-" db:"organization_id"`
+	UserImportOptions
+	Status string `json:"status" db:"status"`
+	UserImportReport
+	// Error is why the import failed as a whole.
+	Error string `json:"error,omitempty" db:"error"`
+	// The job acts as the user who started the import.
+	CreatedBy     string     `json:"created_by" db:"created_by"`
+	CreatedByRole string     `json:"-" db:"created_by_role"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+	FinishedAt    *time.Time `json:"finished_at" db:"finished_at"`
+}
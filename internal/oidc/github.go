@@ -0,0 +1,80 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+)
+
+// GitHubProvider signs users in with GitHub, which offers OAuth2 but not
+// OpenID Connect. The identity comes from the REST API instead of an ID token.
+type GitHubProvider struct {
+	name   string
+	config config.OIDCProviderConfig
+	client *http.Client
+
+	// Endpoints can be pointed at a GitHub Enterprise server or a test server.
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+}
+
+func NewGitHubProvider(name string, cfg config.OIDCProviderConfig, client *http.Client) *GitHubProvider {
+	return &GitHubProvider{
+		name:     name,
+		config:   cfg,
+		client:   client,
+		AuthURL:  "https://github.com/login/oauth/authorize",
+		TokenURL: "https://github.com/login/oauth/access_token",
+		APIURL:   "https://api.github.com",
+	}
+}
+
+func (p *GitHubProvider) AuthCodeURL(ctx context.Context, redirectURL, state, nonce, codeChallenge string) (string, error) {
+	return authCodeURL(p.AuthURL, p.config, []string{"read:user", "user:email"}, redirectURL, state, codeChallenge, nil)
+}
+
+func (p *GitHubProvider) Exchange(ctx context.Context, redirectURL, code, codeVerifier, nonce string) (*Identity, error) {
+	token, err := exchangeCode(ctx, p.client, p.TokenURL, p.config, redirectURL, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, p.client, p.APIURL+"/user", token.AccessToken, &user); err != nil {
+		return nil, fmt.Errorf("failed to get github user: %w", err)
+	}
+	if user.ID == 0 {
+		return nil, fmt.Errorf("failed to get github user: empty response")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, p.client, p.APIURL+"/user/emails", token.AccessToken, &emails); err != nil {
+		return nil, fmt.Errorf("failed to get github emails: %w", err)
+	}
+
+	identity := &Identity{
+		Provider:          p.name,
+		Subject:           strconv.FormatInt(user.ID, 10),
+		Name:              user.Name,
+		PreferredUsername: user.Login,
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+	return identity, nil
+}
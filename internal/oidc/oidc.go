@@ -0,0 +1,160 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// jwksMinRefreshInterval is how often provider keys are fetched at most,
+// unless a token is signed with a key that is not known yet.
+const jwksMinRefreshInterval = 15 * time.Minute
+
+// OIDCProvider is an OpenID Connect provider found through discovery at its
+// issuer, such as Google or a corporate identity provider.
+type OIDCProvider struct {
+	name   string
+	config config.OIDCProviderConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      *jwk.Cache
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func NewOIDCProvider(name string, cfg config.OIDCProviderConfig, client *http.Client) *OIDCProvider {
+	return &OIDCProvider{
+		name:   name,
+		config: cfg,
+		client: client,
+		keys:   jwk.NewCache(context.Background()),
+	}
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, redirectURL, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return authCodeURL(discovery.AuthorizationEndpoint, p.config, []string{"openid", "email", "profile"},
+		redirectURL, state, codeChallenge, url.Values{"nonce": {nonce}})
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, redirectURL, code, codeVerifier, nonce string) (*Identity, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := exchangeCode(ctx, p.client, discovery.TokenEndpoint, p.config, redirectURL, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: missing from token response", ErrInvalidIDToken)
+	}
+
+	keys, err := p.keySet(ctx, discovery.JWKSURI, token.IDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch provider keys: %w", err)
+	}
+
+	idToken, err := jwt.Parse([]byte(token.IDToken),
+		jwt.WithKeySet(keys, jws.WithInferAlgorithmFromKey(true)),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithAcceptableSkew(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	claims := idToken.PrivateClaims()
+	if claimString(claims, "nonce") != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	verified, _ := claims["email_verified"].(bool)
+	return &Identity{
+		Provider:          p.name,
+		Subject:           idToken.Subject(),
+		Email:             claimString(claims, "email"),
+		EmailVerified:     verified,
+		Name:              claimString(claims, "name"),
+		PreferredUsername: claimString(claims, "preferred_username"),
+	}, nil
+}
+
+// discover fetches the discovery document once and keeps it.
+func (p *OIDCProvider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery discoveryDocument
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, p.client, wellKnown, "", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to discover %s: %w", p.name, err)
+	}
+	if discovery.Issuer != strings.TrimSuffix(p.config.Issuer, "/") && discovery.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("failed to discover %s: issuer mismatch %q", p.name, discovery.Issuer)
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// keySet returns the cached keys of the provider, fetching them again when
+// idToken is signed with a key they do not have yet, as after a rotation.
+func (p *OIDCProvider) keySet(ctx context.Context, jwksURI, idToken string) (jwk.Set, error) {
+	p.mu.Lock()
+	if !p.keys.IsRegistered(jwksURI) {
+		err := p.keys.Register(jwksURI, jwk.WithHTTPClient(p.client), jwk.WithMinRefreshInterval(jwksMinRefreshInterval))
+		if err != nil {
+			p.mu.Unlock()
+			return nil, err
+		}
+	}
+	p.mu.Unlock()
+
+	keys, err := p.keys.Get(ctx, jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	if message, err := jws.Parse([]byte(idToken)); err == nil && len(message.Signatures()) > 0 {
+		if kid := message.Signatures()[0].ProtectedHeaders().KeyID(); kid != "" {
+			if _, ok := keys.LookupKeyID(kid); !ok {
+				return p.keys.Refresh(ctx, jwksURI)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func claimString(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
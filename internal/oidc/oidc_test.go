@@ -0,0 +1,102 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/oidc"
+	"github.com/Romasmi/go-rest-api-template/internal/oidc/oidctest"
+)
+
+func TestOIDCProviderExchange(t *testing.T) {
+	fake := oidctest.NewProvider(t)
+	fake.SignInAs(oidctest.User{Subject: "42", Email: "bob@example.com", EmailVerified: true, Name: "Bob"})
+	provider := oidc.NewOIDCProvider("test", config.OIDCProviderConfig{
+		Issuer: fake.URL, ClientID: oidctest.ClientID, ClientSecret: oidctest.ClientSecret,
+	}, http.DefaultClient)
+
+	ctx := context.Background()
+	state, err := oidc.NewState("test", time.Minute)
+	if err != nil {
+		t.Fatalf("Error while creating state: %v", err)
+	}
+	redirectURL := "http://api.example.com/callback"
+
+	authURL, err := provider.AuthCodeURL(ctx, redirectURL, state.State, state.Nonce, state.CodeChallenge())
+	if err != nil {
+		t.Fatalf("Error while building auth URL: %v", err)
+	}
+	callback, err := fake.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Error while authorizing: %v", err)
+	}
+	if callback.Get("state") != state.State {
+		t.Errorf("Wrong state, expected: %v, actual: %v", state.State, callback.Get("state"))
+	}
+
+	if _, err := provider.Exchange(ctx, redirectURL, callback.Get("code"), "wrong-verifier", state.Nonce); err == nil {
+		t.Error("Expected exchange with wrong PKCE verifier to fail")
+	}
+
+	callback, _ = fake.Authorize(authURL)
+	if _, err := provider.Exchange(ctx, redirectURL, callback.Get("code"), state.CodeVerifier, "other"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("Expected ErrInvalidIDToken for wrong nonce, actual: %v", err)
+	}
+
+	callback, _ = fake.Authorize(authURL)
+	identity, err := provider.Exchange(ctx, redirectURL, callback.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		t.Fatalf("Error while exchanging code: %v", err)
+	}
+	expected := oidc.Identity{Provider: "test", Subject: "42", Email: "bob@example.com", EmailVerified: true, Name: "Bob"}
+	if *identity != expected {
+		t.Errorf("Wrong identity, expected: %+v, actual: %+v", expected, *identity)
+	}
+	if fake.KeyRequests() != 1 {
+		t.Errorf("Wrong number of key requests, expected: %v, actual: %v", 1, fake.KeyRequests())
+	}
+
+	// Tokens signed with a new key fetch the keys again.
+	fake.RotateKey(t, "test-key-2")
+	callback, _ = fake.Authorize(authURL)
+	if _, err := provider.Exchange(ctx, redirectURL, callback.Get("code"), state.CodeVerifier, state.Nonce); err != nil {
+		t.Fatalf("Error while exchanging code after key rotation: %v", err)
+	}
+	if fake.KeyRequests() != 2 {
+		t.Errorf("Wrong number of key requests, expected: %v, actual: %v", 2, fake.KeyRequests())
+	}
+}
+
+func TestStateSeal(t *testing.T) {
+	state, err := oidc.NewState("test", time.Minute)
+	if err != nil {
+		t.Fatalf("Error while creating state: %v", err)
+	}
+	state.OrganizationID = 2
+
+	sealed, err := oidc.Seal([]byte("key"), state)
+	if err != nil {
+		t.Fatalf("Error while sealing state: %v", err)
+	}
+	opened, err := oidc.Open([]byte("key"), sealed)
+	if err != nil {
+		t.Fatalf("Error while opening state: %v", err)
+	}
+	if *opened != *state {
+		t.Errorf("Wrong state, expected: %+v, actual: %+v", *state, *opened)
+	}
+
+	if _, err := oidc.Open([]byte("other"), sealed); !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("Expected ErrInvalidState for wrong key, actual: %v", err)
+	}
+
+	state.ExpiresAt = time.Now().Add(-time.Second).Unix()
+	expired, _ := oidc.Seal([]byte("key"), state)
+	if _, err := oidc.Open([]byte("key"), expired); !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("Expected ErrInvalidState for expired state, actual: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+)
+
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// Identity is the account a user signed in with at an identity provider.
+type Identity struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// Provider runs the provider side of the authorization code flow with PKCE.
+type Provider interface {
+	AuthCodeURL(ctx context.Context, redirectURL, state, nonce, codeChallenge string) (string, error)
+	// Exchange trades an authorization code for the identity of the user.
+	// nonce must match the one sent with AuthCodeURL.
+	Exchange(ctx context.Context, redirectURL, code, codeVerifier, nonce string) (*Identity, error)
+}
+
+// NewProviders builds the providers configured in cfg, keyed by name.
+func NewProviders(cfg config.OIDCConfig) (map[string]Provider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	providers := make(map[string]Provider, len(cfg.Providers))
+	for name, providerConfig := range cfg.Providers {
+		switch providerConfig.Type {
+		case "", "oidc":
+			if providerConfig.Issuer == "" {
+				return nil, fmt.Errorf("oidc provider %q has no issuer", name)
+			}
+			providers[name] = NewOIDCProvider(name, providerConfig, client)
+		case "github":
+			providers[name] = NewGitHubProvider(name, providerConfig, client)
+		default:
+			return nil, fmt.Errorf("oidc provider %q has unknown type %q", name, providerConfig.Type)
+		}
+	}
+	return providers, nil
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// exchangeCode posts the authorization code and PKCE verifier to tokenURL.
+func exchangeCode(ctx context.Context, client *http.Client, tokenURL string, cfg config.OIDCProviderConfig, redirectURL, code, codeVerifier string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {cfg.ClientID},
+		"client_secret": {cfg.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token tokenResponse
+	if err := doJSON(client, req, &token); err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("failed to exchange code: %s: %s", token.Error, token.Description)
+	}
+	return &token, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return doJSON(client, req, v)
+}
+
+func doJSON(client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("%s returned %d", req.URL, resp.StatusCode)
+	}
+	return json.Unmarshal(body, v)
+}
+
+func authCodeURL(endpoint string, cfg config.OIDCProviderConfig, defaultScopes []string, redirectURL, state, codeChallenge string, extra url.Values) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", cfg.ClientID)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	for key, values := range extra {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
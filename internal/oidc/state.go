@@ -0,0 +1,110 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StateCookie holds the sealed State between the start of a flow and its
+// callback.
+const StateCookie = "oidc_state"
+
+var ErrInvalidState = errors.New("invalid or expired oidc state")
+
+// State is what the callback needs to finish a flow started by the same
+// browser.
+type State struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	// The callback runs in the organization the flow was started in.
+	OrganizationID   int    `json:"organization_id"`
+	OrganizationSlug string `json:"organization_slug"`
+	// LinkUserID is set when a signed-in user links another identity.
+	LinkUserID string `json:"link_user_id,omitempty"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
+
+// NewState generates the random values of a flow that expires after ttl.
+func NewState(provider string, ttl time.Duration) (*State, error) {
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+
+	return &State{
+		Provider:     provider,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(ttl).Unix(),
+	}, nil
+}
+
+// CodeChallenge is the S256 PKCE challenge for the code verifier.
+func (s *State) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(s.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Seal encodes the state and signs it with key so it can be kept in a cookie.
+func Seal(key []byte, s *State) (string, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode oidc state: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + sign(key, encoded), nil
+}
+
+// Open checks the signature and expiry of a sealed state.
+func Open(key []byte, sealed string) (*State, error) {
+	encoded, signature, ok := strings.Cut(sealed, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(key, encoded))) {
+		return nil, ErrInvalidState
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	var s State
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return nil, ErrInvalidState
+	}
+	if time.Now().Unix() > s.ExpiresAt {
+		return nil, ErrInvalidState
+	}
+	return &s, nil
+}
+
+func sign(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("oidc-state."))
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate oidc state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,124 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHasher(t *testing.T) {
+	argon2Config := config.PasswordConfig{Algorithm: AlgorithmArgon2id, Argon2: config.Argon2Config{Memory: 1024, Iterations: 1, Parallelism: 1}}
+	bcryptHasher, err := NewHasher(config.PasswordConfig{BcryptCost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatalf("Error while creating hasher: %v", err)
+	}
+	argon2Hasher, err := NewHasher(argon2Config)
+	if err != nil {
+		t.Fatalf("Error while creating hasher: %v", err)
+	}
+
+	bcryptHash, _ := bcryptHasher.Hash("correct horse")
+	argon2Hash, err := argon2Hasher.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Error while hashing: %v", err)
+	}
+	if !strings.HasPrefix(argon2Hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Wrong argon2id hash format: %v", argon2Hash)
+	}
+
+	// Either hasher verifies both algorithms.
+	for _, hasher := range []*Hasher{bcryptHasher, argon2Hasher} {
+		for _, hash := range []string{bcryptHash, argon2Hash} {
+			if !hasher.Verify("correct horse", hash) {
+				t.Errorf("Expected %v to verify", hash)
+			}
+			if hasher.Verify("wrong horse", hash) {
+				t.Errorf("Expected wrong password not to verify against %v", hash)
+			}
+		}
+	}
+	if argon2Hasher.Verify("", "$argon2id$v=19$m=1024,t=0,p=0$c2FsdA$a2V5") {
+		t.Error("Expected malformed hash not to verify")
+	}
+
+	if bcryptHasher.NeedsRehash(bcryptHash) || !bcryptHasher.NeedsRehash(argon2Hash) {
+		t.Error("Wrong NeedsRehash for bcrypt hasher")
+	}
+	if argon2Hasher.NeedsRehash(argon2Hash) || !argon2Hasher.NeedsRehash(bcryptHash) {
+		t.Error("Wrong NeedsRehash for argon2id hasher")
+	}
+	argon2Config.Argon2.Iterations = 2
+	stronger, _ := NewHasher(argon2Config)
+	if !stronger.NeedsRehash(argon2Hash) {
+		t.Error("Expected hash with fewer iterations to need a rehash")
+	}
+
+	if _, err := NewHasher(config.PasswordConfig{Algorithm: "md5"}); err == nil {
+		t.Error("Expected unknown algorithm to be rejected")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy := NewPolicy(config.PasswordPolicyConfig{
+		MinLength:      10,
+		RequireUpper:   true,
+		RequireDigit:   true,
+		RejectUserInfo: true,
+		RejectCommon:   true,
+	})
+
+	tests := []struct {
+		password   string
+		violations []string
+	}{
+		{"Tr0ub4dor&3x", nil},
+		{"short1A", []string{"must be at least 10 characters"}},
+		{"alllowercase", []string{"must contain an uppercase letter", "must contain a digit"}},
+		{"MyAlice2024!", []string{"must not contain the username or email"}},
+		{"Xalice.smithX1", []string{"must not contain the username or email"}},
+		{"Password123", []string{"is too common"}},
+	}
+	for _, test := range tests {
+		err := policy.Check(test.password, "alice", "alice.smith@example.com")
+		var policyErr *PolicyError
+		if test.violations == nil {
+			if err != nil {
+				t.Errorf("Expected %q to be accepted, actual: %v", test.password, err)
+			}
+			continue
+		}
+		if !errors.As(err, &policyErr) || !errors.Is(err, ErrWeakPassword) {
+			t.Errorf("Expected PolicyError for %q, actual: %v", test.password, err)
+			continue
+		}
+		if !reflect.DeepEqual(policyErr.Violations, test.violations) {
+			t.Errorf("Wrong violations for %q, expected: %v, actual: %v", test.password, test.violations, policyErr.Violations)
+		}
+	}
+}
+
+func TestPolicyBreachedRanges(t *testing.T) {
+	dir := t.TempDir()
+	sum := sha1.Sum([]byte("breached password"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	rangeFile := "0123456789ABCDEF0123456789ABCDEF012:3\r\n" + hash[5:] + ":42\r\n"
+	if err := os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(rangeFile), 0o600); err != nil {
+		t.Fatalf("Error while writing range file: %v", err)
+	}
+	policy := NewPolicy(config.PasswordPolicyConfig{BreachedRangesDir: dir})
+
+	if err := policy.Check("breached password", "", ""); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("Expected breached password to be rejected, actual: %v", err)
+	}
+	if err := policy.Check("unbreached password", "", ""); err != nil {
+		t.Errorf("Expected password without range file to be accepted, actual: %v", err)
+	}
+}
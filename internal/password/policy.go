@@ -0,0 +1,156 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+)
+
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = func() map[string]struct{} {
+	passwords := make(map[string]struct{})
+	for _, password := range strings.Fields(commonPasswordList) {
+		passwords[strings.ToLower(password)] = struct{}{}
+	}
+	return passwords
+}()
+
+// PolicyError lists every rule a password broke.
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return ErrWeakPassword.Error() + ": " + strings.Join(e.Violations, ", ")
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+type Policy struct {
+	cfg config.PasswordPolicyConfig
+}
+
+func NewPolicy(cfg config.PasswordPolicyConfig) *Policy {
+	return &Policy{cfg: cfg}
+}
+
+// Check returns a *PolicyError when password breaks the policy for the user
+// with username and email.
+func (p *Policy) Check(password, username, email string) error {
+	var violations []string
+	if len([]rune(password)) < p.cfg.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.cfg.MinLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.cfg.RequireUpper && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.cfg.RequireLower && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if p.cfg.RejectUserInfo && containsUserInfo(password, username, email) {
+		violations = append(violations, "must not contain the username or email")
+	}
+	if p.cfg.RejectCommon {
+		if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+			violations = append(violations, "is too common")
+		}
+	}
+	if p.cfg.BreachedRangesDir != "" {
+		breached, err := p.breached(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, "appeared in a data breach")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// breached looks the password up by k-anonymity: only the range file for the
+// first five characters of its SHA-1 hash is read. A missing range file
+// counts as not breached.
+func (p *Policy) breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], []byte(hash[5:])
+
+	var (
+		data []byte
+		err  error
+	)
+	for _, name := range []string{prefix, prefix + ".txt"} {
+		if data, err = os.ReadFile(filepath.Join(p.cfg.BreachedRangesDir, name)); !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read breached password range: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := bytes.Cut(bytes.TrimSpace(scanner.Bytes()), []byte(":"))
+		if bytes.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// containsUserInfo matches case-insensitively, ignoring names and email local
+// parts shorter than three characters.
+func containsUserInfo(password, username, email string) bool {
+	password = strings.ToLower(password)
+	local, _, _ := strings.Cut(email, "@")
+	for _, info := range []string{username, local} {
+		if info = strings.ToLower(info); len(info) >= 3 && strings.Contains(password, info) {
+			return true
+		}
+	}
+	return false
+}
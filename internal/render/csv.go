@@ -0,0 +1,136 @@
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeCSV writes rows, a slice of structs or of pointers to them, as CSV.
+func writeCSV(w io.Writer, rows interface{}) error {
+	value := reflect.ValueOf(rows)
+	encoder := NewCSVEncoder(w, reflect.Zero(value.Type().Elem()).Interface())
+	for i := 0; i < value.Len(); i++ {
+		if err := encoder.Encode(value.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return encoder.Flush()
+}
+
+// CSVEncoder writes structs of one type as CSV rows, for responses that are
+// streamed rather than built as a list. Columns are named and skipped by the
+// json tags of the struct fields. Nested values are written as JSON.
+type CSVEncoder struct {
+	out    *csv.Writer
+	fields [][]int
+	header []string
+	record []string
+}
+
+// NewCSVEncoder returns an encoder for rows of the type of sample, a struct
+// or a pointer to one.
+func NewCSVEncoder(w io.Writer, sample interface{}) *CSVEncoder {
+	typ := reflect.TypeOf(sample)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	fields, header := csvColumns(typ)
+	return &CSVEncoder{
+		out:    csv.NewWriter(w),
+		fields: fields,
+		header: header,
+		record: make([]string, len(fields)),
+	}
+}
+
+// Encode writes row, after the header if it is the first one. Rows are
+// buffered until Flush.
+func (e *CSVEncoder) Encode(row interface{}) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	value := reflect.Indirect(reflect.ValueOf(row))
+	for i, field := range e.fields {
+		e.record[i] = csvValue(value.FieldByIndex(field))
+	}
+	return e.out.Write(e.record)
+}
+
+// Flush writes the buffered rows, and the header if no row was encoded.
+func (e *CSVEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.out.Flush()
+	return e.out.Error()
+}
+
+func (e *CSVEncoder) writeHeader() error {
+	if e.header == nil {
+		return nil
+	}
+	header := e.header
+	e.header = nil
+	return e.out.Write(header)
+}
+
+func csvColumns(typ reflect.Type) ([][]int, []string) {
+	var fields [][]int
+	var header []string
+	for _, field := range reflect.VisibleFields(typ) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, field.Index)
+		header = append(header, name)
+	}
+	return fields, header
+}
+
+func csvValue(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return value.UTC().Format(time.RFC3339)
+	case fmt.Stringer:
+		return value.String()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+
+	encoded, err := json.Marshal(v.Interface())
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
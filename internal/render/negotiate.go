@@ -0,0 +1,69 @@
+package render
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Negotiate picks the offered content type the Accept header prefers,
+// favouring earlier offers on ties. An empty header accepts the first offer.
+func Negotiate(accept string, offered []string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return offered[0], true
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQuality := "", 0.0
+	for _, offer := range offered {
+		if quality := acceptQuality(ranges, offer); quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best, best != ""
+}
+
+type mediaRange struct {
+	value   string
+	quality float64
+}
+
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		r := mediaRange{value: strings.ToLower(strings.TrimSpace(value)), quality: 1}
+		for _, param := range strings.Split(params, ";") {
+			name, q, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(name, "q") {
+				if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+					r.quality = parsed
+				}
+			}
+		}
+		if r.value != "" {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+// acceptQuality is the quality of the most specific range matching value.
+func acceptQuality(ranges []mediaRange, value string) float64 {
+	mainType, _, _ := strings.Cut(value, "/")
+	quality, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch r.value {
+		case value:
+			s = 2
+		case mainType + "/*":
+			s = 1
+		case "*/*", "*":
+			s = 0
+		}
+		if s > specificity {
+			quality, specificity = r.quality, s
+		}
+	}
+	return quality
+}
@@ -0,0 +1,99 @@
+// Package render writes handler responses in the format negotiated from the
+// Accept header of the request.
+package render
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	ContentTypeJSON        = "application/json"
+	ContentTypeMessagePack = "application/msgpack"
+	ContentTypeCSV         = "text/csv"
+)
+
+// Other names clients use for MessagePack.
+var messagePackAliases = []string{"application/x-msgpack", "application/vnd.msgpack"}
+
+func init() {
+	// IDs are strings in every format, as in JSON.
+	msgpack.Register(uuid.UUID{}, func(e *msgpack.Encoder, v reflect.Value) error {
+		return e.EncodeString(v.Interface().(uuid.UUID).String())
+	}, nil)
+}
+
+// Render writes v with status as JSON or, when the client prefers it,
+// MessagePack. Clients that accept neither get 406, except for error
+// responses, which fall back to JSON.
+func Render(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	write(w, r, status, v, nil)
+}
+
+// List is Render for list endpoints. It can also answer text/csv, with a
+// header row and a row for each element of rows, a slice of structs.
+func List(w http.ResponseWriter, r *http.Request, status int, v interface{}, rows interface{}) {
+	write(w, r, status, v, rows)
+}
+
+// Error renders {"error": message}.
+func Error(w http.ResponseWriter, r *http.Request, status int, message string) {
+	Render(w, r, status, map[string]string{"error": message})
+}
+
+func write(w http.ResponseWriter, r *http.Request, status int, v interface{}, rows interface{}) {
+	offered := []string{ContentTypeJSON, ContentTypeMessagePack}
+	offered = append(offered, messagePackAliases...)
+	if rows != nil {
+		offered = append(offered, ContentTypeCSV)
+	}
+
+	contentType, ok := Negotiate(r.Header.Get("Accept"), offered)
+	if !ok {
+		if status < http.StatusBadRequest {
+			NotAcceptable(w, offered)
+			return
+		}
+		contentType = ContentTypeJSON
+	}
+
+	w.Header().Set("Vary", appendVary(w.Header().Get("Vary"), "Accept"))
+	switch contentType {
+	case ContentTypeCSV:
+		w.Header().Set("Content-Type", ContentTypeCSV+"; charset=utf-8")
+		w.WriteHeader(status)
+		writeCSV(w, rows)
+	case ContentTypeJSON:
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	default:
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		enc := msgpack.NewEncoder(w)
+		enc.SetCustomStructTag("json")
+		enc.Encode(v)
+	}
+}
+
+// NotAcceptable writes a 406 listing the offered content types.
+func NotAcceptable(w http.ResponseWriter, offered []string) {
+	http.Error(w, "Acceptable content types: "+strings.Join(offered, ", "), http.StatusNotAcceptable)
+}
+
+func appendVary(vary, header string) string {
+	for _, existing := range strings.Split(vary, ",") {
+		if strings.EqualFold(strings.TrimSpace(existing), header) {
+			return vary
+		}
+	}
+	if vary == "" {
+		return header
+	}
+	return vary + ", " + header
+}
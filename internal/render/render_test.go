@@ -0,0 +1,138 @@
+package render
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testUser struct {
+	ID        uuid.UUID  `json:"id"`
+	Username  string     `json:"username"`
+	Password  string     `json:"-"`
+	Admin     bool       `json:"admin"`
+	Tags      []string   `json:"tags"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func TestNegotiate(t *testing.T) {
+	offered := []string{ContentTypeJSON, ContentTypeMessagePack, ContentTypeCSV}
+	tests := []struct {
+		accept   string
+		expected string
+		ok       bool
+	}{
+		{accept: "", expected: ContentTypeJSON, ok: true},
+		{accept: "*/*", expected: ContentTypeJSON, ok: true},
+		{accept: "text/*", expected: ContentTypeCSV, ok: true},
+		{accept: "application/msgpack", expected: ContentTypeMessagePack, ok: true},
+		{accept: "application/json;q=0.5, text/csv", expected: ContentTypeCSV, ok: true},
+		{accept: "application/*;q=0.2, application/msgpack;q=0.9", expected: ContentTypeMessagePack, ok: true},
+		{accept: "*/*, application/json;q=0", expected: ContentTypeMessagePack, ok: true},
+		{accept: "application/xml", ok: false},
+	}
+
+	for _, tt := range tests {
+		actual, ok := Negotiate(tt.accept, offered)
+		if ok != tt.ok || actual != tt.expected {
+			t.Errorf("Wrong content type for %q, expected: %q %v, actual: %q %v", tt.accept, tt.expected, tt.ok, actual, ok)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	users := []*testUser{
+		{ID: uuid.MustParse("0190b7a4-0000-7000-8000-000000000001"), Username: "alice", Password: "secret", Admin: true, Tags: []string{"a", "b"}, CreatedAt: createdAt},
+		{ID: uuid.MustParse("0190b7a4-0000-7000-8000-000000000002"), Username: "bob, jr", CreatedAt: createdAt},
+	}
+	response := map[string]interface{}{"users": users, "total": 2}
+
+	send := func(accept string, list bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		if list {
+			List(rec, req, http.StatusOK, response, users)
+		} else {
+			Render(rec, req, http.StatusOK, response)
+		}
+		return rec
+	}
+
+	t.Run("json", func(t *testing.T) {
+		rec := send("", true)
+		if rec.Header().Get("Content-Type") != ContentTypeJSON {
+			t.Errorf("Wrong Content-Type, expected: %v, actual: %v", ContentTypeJSON, rec.Header().Get("Content-Type"))
+		}
+		if rec.Header().Get("Vary") != "Accept" {
+			t.Errorf("Wrong Vary, expected: %v, actual: %v", "Accept", rec.Header().Get("Vary"))
+		}
+		var body struct {
+			Users []testUser `json:"users"`
+			Total int        `json:"total"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Error while decoding response: %v", err)
+		}
+		if body.Total != 2 || len(body.Users) != 2 || body.Users[0].Username != "alice" {
+			t.Errorf("Wrong body: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("msgpack", func(t *testing.T) {
+		rec := send("application/x-msgpack", false)
+		if rec.Header().Get("Content-Type") != "application/x-msgpack" {
+			t.Errorf("Wrong Content-Type, expected: %v, actual: %v", "application/x-msgpack", rec.Header().Get("Content-Type"))
+		}
+		var body map[string]interface{}
+		if err := msgpack.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Error while decoding response: %v", err)
+		}
+		first := body["users"].([]interface{})[0].(map[string]interface{})
+		if first["id"] != users[0].ID.String() || first["username"] != "alice" {
+			t.Errorf("Wrong user, expected json field names and string IDs, actual: %v", first)
+		}
+		if _, ok := first["Password"]; ok {
+			t.Errorf("Wrong user, expected no password, actual: %v", first)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		rec := send("text/csv", true)
+		if rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+			t.Errorf("Wrong Content-Type, expected: %v, actual: %v", "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		}
+		expected := "id,username,admin,tags,created_at,deleted_at\n" +
+			"0190b7a4-0000-7000-8000-000000000001,alice,true,\"[\"\"a\"\",\"\"b\"\"]\",2024-01-02T03:04:05Z,\n" +
+			"0190b7a4-0000-7000-8000-000000000002,\"bob, jr\",false,null,2024-01-02T03:04:05Z,\n"
+		if rec.Body.String() != expected {
+			t.Errorf("Wrong body, expected: %q, actual: %q", expected, rec.Body.String())
+		}
+	})
+
+	t.Run("csv is only offered for lists", func(t *testing.T) {
+		rec := send("text/csv", false)
+		if rec.Code != http.StatusNotAcceptable {
+			t.Errorf("Wrong status, expected: %v, actual: %v", http.StatusNotAcceptable, rec.Code)
+		}
+	})
+
+	t.Run("errors fall back to json", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("Accept", "application/xml")
+		rec := httptest.NewRecorder()
+		Error(rec, req, http.StatusNotFound, "User not found")
+		if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != ContentTypeJSON {
+			t.Errorf("Wrong response, expected: %v %v, actual: %v %v", http.StatusNotFound, ContentTypeJSON, rec.Code, rec.Header().Get("Content-Type"))
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) (*models.APIKey, error)
+	GetByID(ctx context.Context, id int64) (*models.APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	// List returns the keys of a user, or the service account keys when
+	// userID is nil.
+	List(ctx context.Context, userID *int) ([]*models.APIKey, error)
+	Revoke(ctx context.Context, id int64) (*models.APIKey, error)
+	// TouchLastUsed records a use of the key. Uses within a minute of the
+	// previous recorded one are not written.
+	TouchLastUsed(ctx context.Context, id int64) error
+}
+
+type PostgresAPIKeyRepository struct {
+	db   *pgxpool.Pool
+	keys *Table[models.APIKey]
+}
+
+func NewPostgresAPIKeyRepository(db *pgxpool.Pool) *PostgresAPIKeyRepository {
+	return &PostgresAPIKeyRepository{
+		db:   db,
+		keys: NewTable[models.APIKey](db, "api_keys", "id").WithTenant("organization_id"),
+	}
+}
+
+func (r *PostgresAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) (*models.APIKey, error) {
+	return r.keys.Create(ctx, Values{
+		"user_id":     key.UserID,
+		"name":        key.Name,
+		"prefix":      key.Prefix,
+		"secret_hash": key.SecretHash,
+		"role":        key.Role,
+		"scopes":      key.Scopes,
+		"expires_at":  key.ExpiresAt,
+	})
+}
+
+func (r *PostgresAPIKeyRepository) GetByID(ctx context.Context, id int64) (*models.APIKey, error) {
+	return r.keys.Get(ctx, id)
+}
+
+func (r *PostgresAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	return r.keys.GetBy(ctx, "prefix", prefix)
+}
+
+func (r *PostgresAPIKeyRepository) List(ctx context.Context, userID *int) ([]*models.APIKey, error) {
+	if userID != nil {
+		return r.keys.List(ctx, ListOptions{Filter: Filter{"user_id": *userID}})
+	}
+
+	where, args := r.keys.scoped(ctx, " WHERE user_id IS NULL", nil)
+	query := fmt.Sprintf(`SELECT %s FROM api_keys%s ORDER BY id`, r.keys.selectList(), where)
+	return r.keys.all(ctx, query, args...)
+}
+
+func (r *PostgresAPIKeyRepository) Revoke(ctx context.Context, id int64) (*models.APIKey, error) {
+	where, args := r.keys.scoped(ctx, " WHERE id = $1", []interface{}{id})
+	query := fmt.Sprintf(`
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()), updated_at = NOW()%s
+		RETURNING %s
+	`, where, r.keys.selectList())
+
+	key, err := r.keys.one(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *PostgresAPIKeyRepository) TouchLastUsed(ctx context.Context, id int64) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to update api key last use: %w", err)
+	}
+	return nil
+}
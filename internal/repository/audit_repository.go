@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AuditRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+	List(ctx context.Context, filter models.AuditEventFilter, limit, offset int) ([]*models.AuditEvent, error)
+	Count(ctx context.Context, filter models.AuditEventFilter) (int, error)
+	// Stream calls fn for every matching event in chronological order without
+	// loading them all into memory.
+	Stream(ctx context.Context, filter models.AuditEventFilter, fn func(event *models.AuditEvent) error) error
+}
+
+type PostgresAuditRepository struct {
+	db     *pgxpool.Pool
+	events *Table[models.AuditEvent]
+}
+
+func NewPostgresAuditRepository(db *pgxpool.Pool) *PostgresAuditRepository {
+	return &PostgresAuditRepository{
+		db:     db,
+		events: NewTable[models.AuditEvent](db, "audit_events", "id").WithTenant("organization_id"),
+	}
+}
+
+func (r *PostgresAuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	created, err := r.events.Create(ctx, Values{
+		"occurred_at": event.OccurredAt,
+		"actor_id":    event.ActorID,
+		"actor_role":  event.ActorRole,
+		"action":      event.Action,
+		"target_type": event.TargetType,
+		"target_id":   event.TargetID,
+		"success":     event.Success,
+		"changes":     event.Changes,
+		"ip":          event.IP,
+		"user_agent":  event.UserAgent,
+		"request_id":  event.RequestID,
+	})
+	if err != nil {
+		return err
+	}
+
+	event.ID = created.ID
+	return nil
+}
+
+func (r *PostgresAuditRepository) List(ctx context.Context, filter models.AuditEventFilter, limit, offset int) ([]*models.AuditEvent, error) {
+	where, args := auditWhere(filter)
+	where, args = r.events.scoped(ctx, where, args)
+	args = append(args, limit, offset)
+
+	query := fmt.Sprintf(`SELECT %s FROM audit_events%s ORDER BY id DESC LIMIT $%d OFFSET $%d`,
+		r.events.selectList(), where, len(args)-1, len(args))
+
+	rows, err := conn(ctx, r.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	events, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.AuditEvent])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return events, nil
+}
+
+func (r *PostgresAuditRepository) Count(ctx context.Context, filter models.AuditEventFilter) (int, error) {
+	where, args := auditWhere(filter)
+	where, args = r.events.scoped(ctx, where, args)
+
+	var count int
+	if err := conn(ctx, r.db).QueryRow(ctx, `SELECT COUNT(*) FROM audit_events`+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+	return count, nil
+}
+
+func (r *PostgresAuditRepository) Stream(ctx context.Context, filter models.AuditEventFilter, fn func(event *models.AuditEvent) error) error {
+	where, args := auditWhere(filter)
+	where, args = r.events.scoped(ctx, where, args)
+	query := fmt.Sprintf(`SELECT %s FROM audit_events%s ORDER BY id`, r.events.selectList(), where)
+
+	rows, err := conn(ctx, r.db).Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to stream audit events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := pgx.RowToAddrOfStructByName[models.AuditEvent](rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func auditWhere(filter models.AuditEventFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != "" {
+		add("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.TargetID != "" {
+		add("target_id = $%d", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		add("occurred_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("occurred_at < $%d", filter.To)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
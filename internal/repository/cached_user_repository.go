@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/cache"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	DefaultUserCacheSize = 10000
+	DefaultUserCacheTTL  = 30 * time.Second
+	// UsersChangedChannel is notified by a trigger on users with
+	// "<organization_id>:<public_id>" for every changed row.
+	UsersChangedChannel = "users_changed"
+)
+
+// CachedUserRepository caches GetByID, and List and Count without filters,
+// of another UserRepository. Its own mutations invalidate the cache right
+// away, and Listen picks up changes made through other replicas. Reads
+// inside a transaction bypass the cache.
+type CachedUserRepository struct {
+	UserRepository
+	users  *cache.LRU[userCacheKey, *models.User]
+	lists  *cache.LRU[userListCacheKey, []*models.User]
+	counts *cache.LRU[int, int]
+}
+
+type userCacheKey struct {
+	organizationID int
+	id             uuid.UUID
+}
+
+type userListCacheKey struct {
+	organizationID int
+	limit, offset  int
+}
+
+func NewCachedUserRepository(repo UserRepository, size int, ttl time.Duration) *CachedUserRepository {
+	if size <= 0 {
+		size = DefaultUserCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultUserCacheTTL
+	}
+	return &CachedUserRepository{
+		UserRepository: repo,
+		users:          cache.NewLRU[userCacheKey, *models.User](size, ttl),
+		lists:          cache.NewLRU[userListCacheKey, []*models.User](size/10+1, ttl),
+		counts:         cache.NewLRU[int, int](size/10+1, ttl),
+	}
+}
+
+func (r *CachedUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	if inTx(ctx) {
+		return r.UserRepository.GetByID(ctx, id)
+	}
+
+	key := userCacheKey{organizationID: cacheTenant(ctx), id: id}
+	if user, ok := r.users.Get(key); ok {
+		return copyUser(user), nil
+	}
+
+	user, err := r.UserRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.users.Set(key, copyUser(user))
+	return user, nil
+}
+
+func (r *CachedUserRepository) List(ctx context.Context, filter models.UserFilter, limit, offset int) ([]*models.User, error) {
+	if inTx(ctx) || len(filter.Attributes) > 0 {
+		return r.UserRepository.List(ctx, filter, limit, offset)
+	}
+
+	key := userListCacheKey{organizationID: cacheTenant(ctx), limit: limit, offset: offset}
+	if users, ok := r.lists.Get(key); ok {
+		return copyUsers(users), nil
+	}
+
+	users, err := r.UserRepository.List(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	r.lists.Set(key, copyUsers(users))
+	return users, nil
+}
+
+func (r *CachedUserRepository) Count(ctx context.Context, filter models.UserFilter) (int, error) {
+	if inTx(ctx) || len(filter.Attributes) > 0 {
+		return r.UserRepository.Count(ctx, filter)
+	}
+
+	key := cacheTenant(ctx)
+	if count, ok := r.counts.Get(key); ok {
+		return count, nil
+	}
+
+	count, err := r.UserRepository.Count(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	r.counts.Set(key, count)
+	return count, nil
+}
+
+func (r *CachedUserRepository) Create(ctx context.Context, user *models.UserCreate) (*models.User, error) {
+	created, err := r.UserRepository.Create(ctx, user)
+	if err == nil {
+		r.Invalidate(created.OrganizationID, created.ID)
+	}
+	return created, err
+}
+
+func (r *CachedUserRepository) Update(ctx context.Context, id uuid.UUID, user *models.UserUpdate) (*models.User, error) {
+	updated, err := r.UserRepository.Update(ctx, id, user)
+	r.Invalidate(cacheTenant(ctx), id)
+	return updated, err
+}
+
+func (r *CachedUserRepository) RevokeTokens(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, err := r.UserRepository.RevokeTokens(ctx, id)
+	r.Invalidate(cacheTenant(ctx), id)
+	return user, err
+}
+
+func (r *CachedUserRepository) SetStatus(ctx context.Context, id uuid.UUID, status string, suspendedUntil *time.Time) (*models.User, error) {
+	user, err := r.UserRepository.SetStatus(ctx, id, status, suspendedUntil)
+	r.Invalidate(cacheTenant(ctx), id)
+	return user, err
+}
+
+func (r *CachedUserRepository) SetAvatar(ctx context.Context, id uuid.UUID, key, url string) (*models.User, error) {
+	user, err := r.UserRepository.SetAvatar(ctx, id, key, url)
+	r.Invalidate(cacheTenant(ctx), id)
+	return user, err
+}
+
+func (r *CachedUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.UserRepository.Delete(ctx, id)
+	r.Invalidate(cacheTenant(ctx), id)
+	return err
+}
+
+func (r *CachedUserRepository) Import(ctx context.Context, mode string, rows []*models.UserImportRow, dryRun bool) (*UserImportResult, error) {
+	result, err := r.UserRepository.Import(ctx, mode, rows, dryRun)
+	if err != nil || dryRun {
+		return result, err
+	}
+	for _, change := range result.Updated {
+		r.Invalidate(change.After.OrganizationID, change.After.ID)
+	}
+	if len(result.Created) > 0 {
+		r.Invalidate(cacheTenant(ctx), uuid.Nil)
+	}
+	return result, nil
+}
+
+// Invalidate drops user id of organizationID, and the lists of users of the
+// organization. An organizationID of 0 stands for any organization.
+func (r *CachedUserRepository) Invalidate(organizationID int, id uuid.UUID) {
+	if organizationID == 0 {
+		r.users.DeleteFunc(func(key userCacheKey) bool { return key.id == id })
+	} else {
+		// Lookups made without a tenant are cached under 0.
+		r.users.Delete(userCacheKey{organizationID: organizationID, id: id})
+		r.users.Delete(userCacheKey{id: id})
+	}
+	r.lists.DeleteFunc(func(key userListCacheKey) bool {
+		return organizationID == 0 || key.organizationID == organizationID || key.organizationID == 0
+	})
+	r.counts.DeleteFunc(func(key int) bool {
+		return organizationID == 0 || key == organizationID || key == 0
+	})
+}
+
+// Purge empties the cache.
+func (r *CachedUserRepository) Purge() {
+	r.users.Purge()
+	r.lists.Purge()
+	r.counts.Purge()
+}
+
+// Listen invalidates the cache on the notifications of UsersChangedChannel
+// until ctx is cancelled. Notifications are sent on commit, which also
+// covers changes made by other replicas and reads that raced with a
+// transaction. The cache is purged whenever the connection is lost, since
+// notifications may have been missed.
+func (r *CachedUserRepository) Listen(ctx context.Context, db *pgxpool.Pool) {
+	for {
+		err := r.listen(ctx, db)
+		if ctx.Err() != nil {
+			return
+		}
+		r.Purge()
+		log.Printf("users cache listener failed, retrying: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (r *CachedUserRepository) listen(ctx context.Context, db *pgxpool.Pool) error {
+	c, err := db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// LISTEN stays active on the connection, so it must not be reused.
+	defer c.Hijack().Close(context.WithoutCancel(ctx))
+
+	if _, err := c.Exec(ctx, "LISTEN "+pgx.Identifier{UsersChangedChannel}.Sanitize()); err != nil {
+		return err
+	}
+	// Changes made before LISTEN took effect were not notified.
+	r.Purge()
+
+	for {
+		notification, err := c.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		organization, publicID, _ := strings.Cut(notification.Payload, ":")
+		organizationID, err := strconv.Atoi(organization)
+		id, idErr := uuid.Parse(publicID)
+		if err := errors.Join(err, idErr); err != nil {
+			r.Purge()
+			continue
+		}
+		r.Invalidate(organizationID, id)
+	}
+}
+
+// cacheTenant is the organization ctx is scoped to, or 0 when it is not.
+func cacheTenant(ctx context.Context) int {
+	if current, ok := tenant.FromContext(ctx); ok {
+		return current.ID
+	}
+	return 0
+}
+
+func copyUsers(users []*models.User) []*models.User {
+	result := make([]*models.User, len(users))
+	for i, user := range users {
+		result[i] = copyUser(user)
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+	"github.com/google/uuid"
+)
+
+type countingUserRepository struct {
+	UserRepository
+	gets, lists int
+}
+
+func (r *countingUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	r.gets++
+	return r.UserRepository.GetByID(ctx, id)
+}
+
+func (r *countingUserRepository) List(ctx context.Context, filter models.UserFilter, limit, offset int) ([]*models.User, error) {
+	r.lists++
+	return r.UserRepository.List(ctx, filter, limit, offset)
+}
+
+func TestCachedUserRepositoryInvalidation(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), tenant.Tenant{ID: models.DefaultOrganizationID})
+	counting := &countingUserRepository{UserRepository: NewMemoryUserRepository()}
+	repo := NewCachedUserRepository(counting, 10, time.Minute)
+
+	user, err := repo.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := repo.GetByID(ctx, user.ID); err != nil {
+			t.Fatalf("Error while getting user: %v", err)
+		}
+		if _, err := repo.List(ctx, models.UserFilter{}, 10, 0); err != nil {
+			t.Fatalf("Error while listing users: %v", err)
+		}
+	}
+	if counting.gets != 1 || counting.lists != 1 {
+		t.Errorf("Wrong number of uncached reads, expected: 1 and 1, actual: %v and %v", counting.gets, counting.lists)
+	}
+
+	cached, _ := repo.GetByID(ctx, user.ID)
+	cached.Username = "changed"
+	if again, _ := repo.GetByID(ctx, user.ID); again.Username != "alice" {
+		t.Errorf("Expected cached user to be a copy, actual username: %v", again.Username)
+	}
+
+	if _, err := repo.Update(ctx, user.ID, &models.UserUpdate{Username: "alice2"}); err != nil {
+		t.Fatalf("Error while updating user: %v", err)
+	}
+	if updated, _ := repo.GetByID(ctx, user.ID); updated.Username != "alice2" {
+		t.Errorf("Wrong username after update, expected: %v, actual: %v", "alice2", updated.Username)
+	}
+	if users, _ := repo.List(ctx, models.UserFilter{}, 10, 0); users[0].Username != "alice2" {
+		t.Errorf("Wrong listed username after update, expected: %v, actual: %v", "alice2", users[0].Username)
+	}
+
+	// Changes made elsewhere are picked up once notified.
+	if _, err := counting.Update(ctx, user.ID, &models.UserUpdate{Username: "alice3"}); err != nil {
+		t.Fatalf("Error while updating user: %v", err)
+	}
+	if stale, _ := repo.GetByID(ctx, user.ID); stale.Username != "alice2" {
+		t.Errorf("Expected cached username before notification, actual: %v", stale.Username)
+	}
+	repo.Invalidate(models.DefaultOrganizationID, user.ID)
+	if fresh, _ := repo.GetByID(ctx, user.ID); fresh.Username != "alice3" {
+		t.Errorf("Wrong username after invalidation, expected: %v, actual: %v", "alice3", fresh.Username)
+	}
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IdempotencyRepository interface {
+	// Reserve marks scope and key in the tenant of ctx as in flight until
+	// expiresAt, unless they already have a response or are in flight. It
+	// reports whether it did; if not, it also returns what is stored, which
+	// is nil when it expired in the meantime.
+	Reserve(ctx context.Context, scope, key, fingerprint string, expiresAt time.Time) (stored *models.IdempotentResponse, reserved bool, err error)
+	// Release removes a reservation that has no response, so the request
+	// can be retried.
+	Release(ctx context.Context, scope, key string) error
+	// Get returns the stored response for scope and key. Expired responses
+	// are reported as ErrNotFound.
+	Get(ctx context.Context, scope, key string) (*models.IdempotentResponse, error)
+	// Save stores the response of a reservation.
+	Save(ctx context.Context, response *models.IdempotentResponse) error
+	DeleteExpired(ctx context.Context) (int, error)
+}
+
+type PostgresIdempotencyRepository struct {
+	db        *pgxpool.Pool
+	responses *Table[models.IdempotentResponse]
+}
+
+func NewPostgresIdempotencyRepository(db *pgxpool.Pool) *PostgresIdempotencyRepository {
+	return &PostgresIdempotencyRepository{
+		db:        db,
+		responses: NewTable[models.IdempotentResponse](db, "idempotency_keys", "key").WithTenant("organization_id"),
+	}
+}
+
+// Reserve inserts a pending row, so no connection or lock is held while the
+// request runs.
+func (r *PostgresIdempotencyRepository) Reserve(ctx context.Context, scope, key, fingerprint string, expiresAt time.Time) (*models.IdempotentResponse, bool, error) {
+	tag, err := conn(ctx, r.db).Exec(ctx, `
+		INSERT INTO idempotency_keys (organization_id, scope, key, fingerprint, status_code, body, expires_at)
+		VALUES ($1, $2, $3, $4, 0, '', $5)
+		ON CONFLICT (organization_id, scope, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status_code = 0,
+			headers = '{}',
+			body = '',
+			created_at = NOW(),
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+	`, tenantID(ctx), scope, key, fingerprint, expiresAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, true, nil
+	}
+
+	stored, err := r.Get(ctx, scope, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return stored, false, nil
+}
+
+func (r *PostgresIdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `
+		DELETE FROM idempotency_keys WHERE organization_id = $1 AND scope = $2 AND key = $3 AND status_code = 0
+	`, tenantID(ctx), scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresIdempotencyRepository) Get(ctx context.Context, scope, key string) (*models.IdempotentResponse, error) {
+	where, args := r.responses.scoped(ctx, " WHERE scope = $1 AND key = $2 AND expires_at > NOW()", []interface{}{scope, key})
+	return r.responses.one(ctx, fmt.Sprintf(`SELECT %s FROM idempotency_keys%s`, r.responses.selectList(), where), args...)
+}
+
+func (r *PostgresIdempotencyRepository) Save(ctx context.Context, response *models.IdempotentResponse) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `
+		INSERT INTO idempotency_keys (organization_id, scope, key, fingerprint, status_code, headers, body, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (organization_id, scope, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status_code = EXCLUDED.status_code,
+			headers = EXCLUDED.headers,
+			body = EXCLUDED.body,
+			created_at = NOW(),
+			expires_at = EXCLUDED.expires_at
+	`, tenantID(ctx), response.Scope, response.Key, response.Fingerprint, response.StatusCode, response.Headers, response.Body, response.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresIdempotencyRepository) DeleteExpired(ctx context.Context) (int, error) {
+	tag, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func idempotencyName(ctx context.Context, scope, key string) string {
+	return strconv.Itoa(tenantID(ctx)) + ":" + scope + ":" + key
+}
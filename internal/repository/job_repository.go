@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type JobRepository interface {
+	// Insert stores a new job. When job has a unique key that is already used
+	// by a waiting or running job, that job is returned with inserted false.
+	Insert(ctx context.Context, job *models.JobInsert) (result *models.Job, inserted bool, err error)
+	Get(ctx context.Context, id int64) (*models.Job, error)
+	// Claim marks up to limit available jobs of the given kinds that are due
+	// at now as running and returns them. Concurrent callers never get the
+	// same job.
+	Claim(ctx context.Context, kinds []string, now time.Time, limit int) ([]*models.Job, error)
+	Complete(ctx context.Context, id int64, now time.Time) error
+	Retry(ctx context.Context, id int64, runAt time.Time, lastError string) error
+	Discard(ctx context.Context, id int64, now time.Time, lastError string) error
+	// Rescue makes jobs locked before lockedBefore available again. They were
+	// left running by a worker that died.
+	Rescue(ctx context.Context, lockedBefore time.Time) (int, error)
+	DeleteFinished(ctx context.Context, finishedBefore time.Time) (int, error)
+	// DueSchedule reports whether the named schedule is due at now and, if
+	// so, moves it to next. A schedule seen for the first time is created
+	// with next and is not due. Only one caller gets true for a given run.
+	DueSchedule(ctx context.Context, name string, now, next time.Time) (bool, error)
+}
+
+type PostgresJobRepository struct {
+	db   *pgxpool.Pool
+	jobs *Table[models.Job]
+}
+
+func NewPostgresJobRepository(db *pgxpool.Pool) *PostgresJobRepository {
+	return &PostgresJobRepository{
+		db:   db,
+		jobs: NewTable[models.Job](db, "jobs", "id"),
+	}
+}
+
+func (r *PostgresJobRepository) Insert(ctx context.Context, job *models.JobInsert) (*models.Job, bool, error) {
+	var uniqueKey *string
+	if job.UniqueKey != "" {
+		uniqueKey = &job.UniqueKey
+	}
+
+	insert := fmt.Sprintf(`
+		INSERT INTO jobs (kind, args, unique_key, max_attempts, run_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (unique_key) WHERE unique_key IS NOT NULL AND status IN ('available', 'running') DO NOTHING
+		RETURNING %s
+	`, r.jobs.selectList())
+	lookup := fmt.Sprintf(`SELECT %s FROM jobs WHERE unique_key = $1 AND status IN ('available', 'running')`, r.jobs.selectList())
+
+	// The job holding the key can finish between the insert and the lookup,
+	// or not be visible to the lookup yet. Either way the key is free by the
+	// next insert or the lookup finds it, so a few rounds are enough.
+	for attempt := 0; ; attempt++ {
+		created, err := r.jobs.one(ctx, insert, job.Kind, job.Args, uniqueKey, job.MaxAttempts, job.RunAt)
+		if err == nil {
+			return created, true, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, false, fmt.Errorf("failed to insert job: %w", err)
+		}
+
+		existing, err := r.jobs.one(ctx, lookup, job.UniqueKey)
+		if err == nil {
+			return existing, false, nil
+		}
+		if !errors.Is(err, ErrNotFound) || attempt == 2 {
+			return nil, false, fmt.Errorf("failed to get job by unique key: %w", err)
+		}
+	}
+}
+
+func (r *PostgresJobRepository) Get(ctx context.Context, id int64) (*models.Job, error) {
+	return r.jobs.Get(ctx, id)
+}
+
+func (r *PostgresJobRepository) Claim(ctx context.Context, kinds []string, now time.Time, limit int) ([]*models.Job, error) {
+	query := fmt.Sprintf(`
+		UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_at = $1, updated_at = $1
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE status = 'available' AND run_at <= $1 AND kind = ANY($2)
+			ORDER BY run_at, id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING %s
+	`, r.jobs.selectList())
+
+	rows, err := conn(ctx, r.db).Query(ctx, query, now, kinds, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim jobs: %w", err)
+	}
+
+	jobs, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Job])
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+func (r *PostgresJobRepository) Complete(ctx context.Context, id int64, now time.Time) error {
+	_, err := r.jobs.Update(ctx, id, Values{
+		"status":      models.JobStatusCompleted,
+		"finished_at": now,
+		"locked_at":   nil,
+	})
+	return err
+}
+
+func (r *PostgresJobRepository) Retry(ctx context.Context, id int64, runAt time.Time, lastError string) error {
+	_, err := r.jobs.Update(ctx, id, Values{
+		"status":     models.JobStatusAvailable,
+		"run_at":     runAt,
+		"last_error": lastError,
+		"locked_at":  nil,
+	})
+	return err
+}
+
+func (r *PostgresJobRepository) Discard(ctx context.Context, id int64, now time.Time, lastError string) error {
+	_, err := r.jobs.Update(ctx, id, Values{
+		"status":      models.JobStatusDiscarded,
+		"finished_at": now,
+		"last_error":  lastError,
+		"locked_at":   nil,
+	})
+	return err
+}
+
+func (r *PostgresJobRepository) Rescue(ctx context.Context, lockedBefore time.Time) (int, error) {
+	result, err := conn(ctx, r.db).Exec(ctx, `
+		UPDATE jobs SET status = 'available', locked_at = NULL, updated_at = NOW()
+		WHERE status = 'running' AND locked_at < $1
+	`, lockedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rescue jobs: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (r *PostgresJobRepository) DeleteFinished(ctx context.Context, finishedBefore time.Time) (int, error) {
+	result, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM jobs WHERE finished_at < $1`, finishedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete finished jobs: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (r *PostgresJobRepository) DueSchedule(ctx context.Context, name string, now, next time.Time) (bool, error) {
+	db := conn(ctx, r.db)
+
+	result, err := db.Exec(ctx, `
+		INSERT INTO job_schedules (name, next_run_at) VALUES ($1, $2)
+		ON CONFLICT (name) DO NOTHING
+	`, name, next)
+	if err != nil {
+		return false, fmt.Errorf("failed to create job schedule: %w", err)
+	}
+	if result.RowsAffected() == 1 {
+		return false, nil
+	}
+
+	result, err = db.Exec(ctx, `
+		UPDATE job_schedules SET next_run_at = $3, updated_at = NOW()
+		WHERE name = $1 AND next_run_at <= $2
+	`, name, now, next)
+	if err != nil {
+		return false, fmt.Errorf("failed to update job schedule: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+type MemoryAPIKeyRepository struct {
+	mu     sync.RWMutex
+	nextID int64
+	keys   []*models.APIKey
+}
+
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{}
+}
+
+func (r *MemoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) (*models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.keys {
+		if existing.Prefix == key.Prefix {
+			return nil, ErrConflict
+		}
+	}
+
+	r.nextID++
+	now := time.Now()
+	created := *key
+	created.ID = r.nextID
+	created.OrganizationID = tenantID(ctx)
+	created.Scopes = append([]string(nil), key.Scopes...)
+	created.CreatedAt = now
+	created.UpdatedAt = now
+	r.keys = append(r.keys, &created)
+
+	c := created
+	return &c, nil
+}
+
+func (r *MemoryAPIKeyRepository) GetByID(ctx context.Context, id int64) (*models.APIKey, error) {
+	return r.find(ctx, func(key *models.APIKey) bool { return key.ID == id })
+}
+
+func (r *MemoryAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	return r.find(ctx, func(key *models.APIKey) bool { return key.Prefix == prefix })
+}
+
+func (r *MemoryAPIKeyRepository) List(ctx context.Context, userID *int) ([]*models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := []*models.APIKey{}
+	for _, key := range r.keys {
+		if !inTenant(ctx, key.OrganizationID) {
+			continue
+		}
+		if (userID == nil && key.UserID == nil) || (userID != nil && key.UserID != nil && *key.UserID == *userID) {
+			c := *key
+			result = append(result, &c)
+		}
+	}
+	return result, nil
+}
+
+func (r *MemoryAPIKeyRepository) Revoke(ctx context.Context, id int64) (*models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range r.keys {
+		if key.ID == id && inTenant(ctx, key.OrganizationID) {
+			if key.RevokedAt == nil {
+				now := time.Now()
+				key.RevokedAt = &now
+				key.UpdatedAt = now
+			}
+			c := *key
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryAPIKeyRepository) TouchLastUsed(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range r.keys {
+		if key.ID == id {
+			now := time.Now()
+			if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= time.Minute {
+				key.LastUsedAt = &now
+			}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryAPIKeyRepository) find(ctx context.Context, match func(key *models.APIKey) bool) (*models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if inTenant(ctx, key.OrganizationID) && match(key) {
+			c := *key
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+type MemoryAuditRepository struct {
+	mu     sync.RWMutex
+	events []*models.AuditEvent
+}
+
+func NewMemoryAuditRepository() *MemoryAuditRepository {
+	return &MemoryAuditRepository{}
+}
+
+func (r *MemoryAuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *event
+	stored.ID = int64(len(r.events) + 1)
+	stored.OrganizationID = tenantID(ctx)
+	r.events = append(r.events, &stored)
+	event.ID = stored.ID
+
+	return nil
+}
+
+func (r *MemoryAuditRepository) List(ctx context.Context, filter models.AuditEventFilter, limit, offset int) ([]*models.AuditEvent, error) {
+	matching := r.matching(ctx, filter)
+
+	var events []*models.AuditEvent
+	for i := len(matching) - 1 - offset; i >= 0 && len(events) < limit; i-- {
+		events = append(events, matching[i])
+	}
+	return events, nil
+}
+
+func (r *MemoryAuditRepository) Count(ctx context.Context, filter models.AuditEventFilter) (int, error) {
+	return len(r.matching(ctx, filter)), nil
+}
+
+func (r *MemoryAuditRepository) Stream(ctx context.Context, filter models.AuditEventFilter, fn func(event *models.AuditEvent) error) error {
+	for _, event := range r.matching(ctx, filter) {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryAuditRepository) matching(ctx context.Context, filter models.AuditEventFilter) []*models.AuditEvent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var events []*models.AuditEvent
+	for _, event := range r.events {
+		if !inTenant(ctx, event.OrganizationID) ||
+			filter.ActorID != "" && event.ActorID != filter.ActorID ||
+			filter.Action != "" && event.Action != filter.Action ||
+			filter.TargetID != "" && event.TargetID != filter.TargetID ||
+			!filter.From.IsZero() && event.OccurredAt.Before(filter.From) ||
+			!filter.To.IsZero() && !event.OccurredAt.Before(filter.To) {
+			continue
+		}
+		c := *event
+		events = append(events, &c)
+	}
+	return events
+}
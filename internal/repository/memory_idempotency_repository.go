@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+type MemoryIdempotencyRepository struct {
+	mu        sync.Mutex
+	responses map[string]*models.IdempotentResponse
+}
+
+func NewMemoryIdempotencyRepository() *MemoryIdempotencyRepository {
+	return &MemoryIdempotencyRepository{
+		responses: make(map[string]*models.IdempotentResponse),
+	}
+}
+
+func (r *MemoryIdempotencyRepository) Reserve(ctx context.Context, scope, key, fingerprint string, expiresAt time.Time) (*models.IdempotentResponse, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := idempotencyName(ctx, scope, key)
+	if stored, ok := r.responses[name]; ok && stored.ExpiresAt.After(time.Now()) {
+		c := *stored
+		return &c, false, nil
+	}
+	r.responses[name] = &models.IdempotentResponse{
+		OrganizationID: tenantID(ctx),
+		Scope:          scope,
+		Key:            key,
+		Fingerprint:    fingerprint,
+		CreatedAt:      time.Now(),
+		ExpiresAt:      expiresAt,
+	}
+	return nil, true, nil
+}
+
+func (r *MemoryIdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := idempotencyName(ctx, scope, key)
+	if stored, ok := r.responses[name]; ok && stored.Pending() {
+		delete(r.responses, name)
+	}
+	return nil
+}
+
+func (r *MemoryIdempotencyRepository) Get(ctx context.Context, scope, key string) (*models.IdempotentResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	response, ok := r.responses[idempotencyName(ctx, scope, key)]
+	if !ok || !response.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	c := *response
+	return &c, nil
+}
+
+func (r *MemoryIdempotencyRepository) Save(ctx context.Context, response *models.IdempotentResponse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	saved := *response
+	saved.OrganizationID = tenantID(ctx)
+	saved.CreatedAt = time.Now()
+	r.responses[idempotencyName(ctx, response.Scope, response.Key)] = &saved
+	return nil
+}
+
+func (r *MemoryIdempotencyRepository) DeleteExpired(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for name, response := range r.responses {
+		if !response.ExpiresAt.After(time.Now()) {
+			delete(r.responses, name)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
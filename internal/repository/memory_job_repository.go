@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+type MemoryJobRepository struct {
+	mu        sync.Mutex
+	nextID    int64
+	jobs      map[int64]*models.Job
+	schedules map[string]time.Time
+}
+
+func NewMemoryJobRepository() *MemoryJobRepository {
+	return &MemoryJobRepository{
+		jobs:      make(map[int64]*models.Job),
+		schedules: make(map[string]time.Time),
+	}
+}
+
+func (r *MemoryJobRepository) Insert(ctx context.Context, job *models.JobInsert) (*models.Job, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if job.UniqueKey != "" {
+		for _, existing := range r.jobs {
+			active := existing.Status == models.JobStatusAvailable || existing.Status == models.JobStatusRunning
+			if active && existing.UniqueKey != nil && *existing.UniqueKey == job.UniqueKey {
+				c := *existing
+				return &c, false, nil
+			}
+		}
+	}
+
+	r.nextID++
+	now := time.Now().UTC()
+	created := &models.Job{
+		ID:          r.nextID,
+		Kind:        job.Kind,
+		Args:        job.Args,
+		Status:      models.JobStatusAvailable,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if job.UniqueKey != "" {
+		key := job.UniqueKey
+		created.UniqueKey = &key
+	}
+	r.jobs[created.ID] = created
+
+	c := *created
+	return &c, true, nil
+}
+
+func (r *MemoryJobRepository) Get(ctx context.Context, id int64) (*models.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *job
+	return &c, nil
+}
+
+func (r *MemoryJobRepository) Claim(ctx context.Context, kinds []string, now time.Time, limit int) ([]*models.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wanted := make(map[string]bool, len(kinds))
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+
+	var due []*models.Job
+	for _, job := range r.jobs {
+		if job.Status == models.JobStatusAvailable && wanted[job.Kind] && !job.RunAt.After(now) {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].RunAt.Equal(due[j].RunAt) {
+			return due[i].RunAt.Before(due[j].RunAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*models.Job, len(due))
+	for i, job := range due {
+		locked := now
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.LockedAt = &locked
+		job.UpdatedAt = now
+		c := *job
+		claimed[i] = &c
+	}
+	return claimed, nil
+}
+
+func (r *MemoryJobRepository) Complete(ctx context.Context, id int64, now time.Time) error {
+	return r.update(id, func(job *models.Job) {
+		job.Status = models.JobStatusCompleted
+		job.FinishedAt = &now
+		job.LockedAt = nil
+	})
+}
+
+func (r *MemoryJobRepository) Retry(ctx context.Context, id int64, runAt time.Time, lastError string) error {
+	return r.update(id, func(job *models.Job) {
+		job.Status = models.JobStatusAvailable
+		job.RunAt = runAt
+		job.LastError = lastError
+		job.LockedAt = nil
+	})
+}
+
+func (r *MemoryJobRepository) Discard(ctx context.Context, id int64, now time.Time, lastError string) error {
+	return r.update(id, func(job *models.Job) {
+		job.Status = models.JobStatusDiscarded
+		job.FinishedAt = &now
+		job.LastError = lastError
+		job.LockedAt = nil
+	})
+}
+
+func (r *MemoryJobRepository) Rescue(ctx context.Context, lockedBefore time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rescued := 0
+	for _, job := range r.jobs {
+		if job.Status == models.JobStatusRunning && job.LockedAt != nil && job.LockedAt.Before(lockedBefore) {
+			job.Status = models.JobStatusAvailable
+			job.LockedAt = nil
+			rescued++
+		}
+	}
+	return rescued, nil
+}
+
+func (r *MemoryJobRepository) DeleteFinished(ctx context.Context, finishedBefore time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for id, job := range r.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(finishedBefore) {
+			delete(r.jobs, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *MemoryJobRepository) DueSchedule(ctx context.Context, name string, now, next time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.schedules[name]
+	if !ok {
+		r.schedules[name] = next
+		return false, nil
+	}
+	if current.After(now) {
+		return false, nil
+	}
+	r.schedules[name] = next
+	return true, nil
+}
+
+func (r *MemoryJobRepository) update(id int64, fn func(job *models.Job)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	fn(job)
+	job.UpdatedAt = time.Now().UTC()
+	return nil
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+type MemoryMFARepository struct {
+	mu            sync.Mutex
+	totp          map[int]*models.UserTOTP
+	recoveryCodes map[int]map[string]bool
+}
+
+func NewMemoryMFARepository() *MemoryMFARepository {
+	return &MemoryMFARepository{
+		totp:          make(map[int]*models.UserTOTP),
+		recoveryCodes: make(map[int]map[string]bool),
+	}
+}
+
+func (r *MemoryMFARepository) GetTOTP(ctx context.Context, userID int) (*models.UserTOTP, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totp, ok := r.totp[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *totp
+	return &c, nil
+}
+
+func (r *MemoryMFARepository) SaveTOTP(ctx context.Context, userID int, secret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.totp[userID]; ok && existing.ConfirmedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	r.totp[userID] = &models.UserTOTP{UserID: userID, Secret: secret, CreatedAt: now, UpdatedAt: now}
+	return nil
+}
+
+func (r *MemoryMFARepository) ConfirmTOTP(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totp, ok := r.totp[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	totp.ConfirmedAt = &now
+	totp.UpdatedAt = now
+	return nil
+}
+
+func (r *MemoryMFARepository) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totp, ok := r.totp[userID]
+	if !ok || totp.LastUsedStep >= step {
+		return false, nil
+	}
+	totp.LastUsedStep = step
+	return true, nil
+}
+
+func (r *MemoryMFARepository) RecordFailure(ctx context.Context, userID int, maxFailures int, lockedUntil time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totp, ok := r.totp[userID]
+	if !ok {
+		return false, ErrNotFound
+	}
+	totp.FailedAttempts++
+	if totp.FailedAttempts < maxFailures {
+		return false, nil
+	}
+	totp.FailedAttempts = 0
+	totp.LockedUntil = &lockedUntil
+	return true, nil
+}
+
+func (r *MemoryMFARepository) ResetFailures(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if totp, ok := r.totp[userID]; ok {
+		totp.FailedAttempts = 0
+		totp.LockedUntil = nil
+	}
+	return nil
+}
+
+func (r *MemoryMFARepository) DeleteTOTP(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.totp[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.totp, userID)
+	delete(r.recoveryCodes, userID)
+	return nil
+}
+
+func (r *MemoryMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	codes := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		codes[hash] = true
+	}
+	r.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (r *MemoryMFARepository) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.recoveryCodes[userID][hash] {
+		return false, nil
+	}
+	delete(r.recoveryCodes[userID], hash)
+	return true, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+// MemoryOrganizationRepository starts with the default organization created by
+// the migrations.
+type MemoryOrganizationRepository struct {
+	mu            sync.RWMutex
+	organizations []*models.Organization
+}
+
+func NewMemoryOrganizationRepository() *MemoryOrganizationRepository {
+	now := time.Now()
+	return &MemoryOrganizationRepository{
+		organizations: []*models.Organization{{
+			ID:        models.DefaultOrganizationID,
+			Slug:      "default",
+			Name:      "Default",
+			CreatedAt: now,
+			UpdatedAt: now,
+		}},
+	}
+}
+
+func (r *MemoryOrganizationRepository) Create(ctx context.Context, organization *models.OrganizationCreate) (*models.Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.organizations {
+		if existing.Slug == organization.Slug {
+			return nil, ErrConflict
+		}
+	}
+
+	now := time.Now()
+	created := &models.Organization{
+		ID:        r.organizations[len(r.organizations)-1].ID + 1,
+		Slug:      organization.Slug,
+		Name:      organization.Name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.organizations = append(r.organizations, created)
+
+	c := *created
+	return &c, nil
+}
+
+func (r *MemoryOrganizationRepository) GetByID(ctx context.Context, id int) (*models.Organization, error) {
+	return r.find(func(organization *models.Organization) bool { return organization.ID == id })
+}
+
+func (r *MemoryOrganizationRepository) GetBySlug(ctx context.Context, slug string) (*models.Organization, error) {
+	return r.find(func(organization *models.Organization) bool { return organization.Slug == slug })
+}
+
+func (r *MemoryOrganizationRepository) List(ctx context.Context) ([]*models.Organization, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]*models.Organization, len(r.organizations))
+	for i, organization := range r.organizations {
+		c := *organization
+		result[i] = &c
+	}
+	return result, nil
+}
+
+func (r *MemoryOrganizationRepository) find(match func(organization *models.Organization) bool) (*models.Organization, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, organization := range r.organizations {
+		if match(organization) {
+			c := *organization
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+type MemoryOutboxRepository struct {
+	mu     sync.Mutex
+	nextID int64
+	events []*models.OutboxEvent
+}
+
+func NewMemoryOutboxRepository() *MemoryOutboxRepository {
+	return &MemoryOutboxRepository{}
+}
+
+func (r *MemoryOutboxRepository) Add(ctx context.Context, event *models.DomainEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	r.events = append(r.events, &models.OutboxEvent{
+		ID:            r.nextID,
+		EventID:       event.ID,
+		EventType:     event.Type,
+		OrderingKey:   event.OrderingKey,
+		Payload:       event.Payload,
+		Status:        models.OutboxStatusPending,
+		CreatedAt:     event.OccurredAt,
+		NextAttemptAt: time.Now(),
+	})
+	return nil
+}
+
+func (r *MemoryOutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	blocked := make(map[string]bool)
+	var due []*models.OutboxEvent
+	for _, event := range r.events {
+		if event.Status != models.OutboxStatusPending {
+			continue
+		}
+		if !blocked[event.OrderingKey] && !event.NextAttemptAt.After(now) && len(due) < limit {
+			event.NextAttemptAt = now.Add(lease)
+			c := *event
+			due = append(due, &c)
+		}
+		blocked[event.OrderingKey] = true
+	}
+	return due, nil
+}
+
+func (r *MemoryOutboxRepository) MarkDelivered(ctx context.Context, id int64) error {
+	return r.update(id, func(event *models.OutboxEvent) {
+		now := time.Now()
+		event.Status = models.OutboxStatusDelivered
+		event.DeliveredAt = &now
+	})
+}
+
+func (r *MemoryOutboxRepository) MarkRetry(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	return r.update(id, func(event *models.OutboxEvent) {
+		event.Attempts = attempts
+		event.NextAttemptAt = nextAttemptAt
+		event.LastError = lastError
+	})
+}
+
+func (r *MemoryOutboxRepository) MarkFailed(ctx context.Context, id int64, attempts int, lastError string) error {
+	return r.update(id, func(event *models.OutboxEvent) {
+		event.Status = models.OutboxStatusFailed
+		event.Attempts = attempts
+		event.LastError = lastError
+	})
+}
+
+func (r *MemoryOutboxRepository) DeleteDelivered(ctx context.Context, deliveredBefore time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.events[:0]
+	for _, event := range r.events {
+		if event.Status != models.OutboxStatusDelivered || !event.DeliveredAt.Before(deliveredBefore) {
+			kept = append(kept, event)
+		}
+	}
+	deleted := len(r.events) - len(kept)
+	r.events = kept
+	return deleted, nil
+}
+
+// Events returns a snapshot of all stored events.
+func (r *MemoryOutboxRepository) Events() []*models.OutboxEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := make([]*models.OutboxEvent, len(r.events))
+	for i, event := range r.events {
+		c := *event
+		events[i] = &c
+	}
+	return events
+}
+
+func (r *MemoryOutboxRepository) update(id int64, fn func(event *models.OutboxEvent)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, event := range r.events {
+		if event.ID == id {
+			fn(event)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
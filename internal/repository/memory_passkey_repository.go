@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+type MemoryPasskeyRepository struct {
+	mu         sync.RWMutex
+	nextID     int64
+	passkeys   []*models.Passkey
+	challenges map[string]time.Time
+}
+
+func NewMemoryPasskeyRepository() *MemoryPasskeyRepository {
+	return &MemoryPasskeyRepository{challenges: make(map[string]time.Time)}
+}
+
+func (r *MemoryPasskeyRepository) Create(ctx context.Context, passkey *models.Passkey) (*models.Passkey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.passkeys {
+		if bytes.Equal(existing.CredentialID, passkey.CredentialID) {
+			return nil, ErrConflict
+		}
+	}
+
+	r.nextID++
+	created := *passkey
+	created.ID = r.nextID
+	created.OrganizationID = tenantID(ctx)
+	created.Transports = append([]string{}, passkey.Transports...)
+	created.CreatedAt = time.Now()
+	r.passkeys = append(r.passkeys, &created)
+
+	c := created
+	return &c, nil
+}
+
+func (r *MemoryPasskeyRepository) GetByCredentialID(ctx context.Context, credentialID []byte) (*models.Passkey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, passkey := range r.passkeys {
+		if inTenant(ctx, passkey.OrganizationID) && bytes.Equal(passkey.CredentialID, credentialID) {
+			c := *passkey
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryPasskeyRepository) ListByUser(ctx context.Context, userID int) ([]*models.Passkey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := []*models.Passkey{}
+	for _, passkey := range r.passkeys {
+		if inTenant(ctx, passkey.OrganizationID) && passkey.UserID == userID {
+			c := *passkey
+			result = append(result, &c)
+		}
+	}
+	return result, nil
+}
+
+func (r *MemoryPasskeyRepository) Delete(ctx context.Context, id int64, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, passkey := range r.passkeys {
+		if passkey.ID == id && passkey.UserID == userID && inTenant(ctx, passkey.OrganizationID) {
+			r.passkeys = append(r.passkeys[:i], r.passkeys[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryPasskeyRepository) RecordUse(ctx context.Context, id int64, signCount int64, backupState bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, passkey := range r.passkeys {
+		if passkey.ID == id {
+			now := time.Now()
+			passkey.SignCount = signCount
+			passkey.BackupState = backupState
+			passkey.LastUsedAt = &now
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryPasskeyRepository) SaveChallenge(ctx context.Context, challenge string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.challenges[challenge]; ok {
+		return ErrConflict
+	}
+	r.challenges[challenge] = expiresAt
+	return nil
+}
+
+func (r *MemoryPasskeyRepository) UseChallenge(ctx context.Context, challenge string, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expiresAt, ok := r.challenges[challenge]
+	if !ok {
+		return false, nil
+	}
+	delete(r.challenges, challenge)
+	return expiresAt.After(now), nil
+}
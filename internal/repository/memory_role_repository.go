@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryRoleRepository starts with the permissions and roles seeded by the
+// migrations. It resolves user IDs through users.
+type MemoryRoleRepository struct {
+	mu          sync.RWMutex
+	users       UserRepository
+	nextID      int
+	permissions []*models.Permission
+	roles       map[string]*models.Role
+	userRoles   map[int][]string
+}
+
+func NewMemoryRoleRepository(users UserRepository) *MemoryRoleRepository {
+	r := &MemoryRoleRepository{
+		users:     users,
+		roles:     make(map[string]*models.Role),
+		userRoles: make(map[int][]string),
+	}
+
+	all := []string{
+		models.PermissionAuditRead,
+		models.PermissionOrganizationsManage,
+		models.PermissionRolesManage,
+		models.PermissionSessionsManage,
+		models.PermissionUsersDelete,
+		models.PermissionUsersExport,
+		models.PermissionUsersRead,
+		models.PermissionUsersWrite,
+		models.PermissionWebhooksManage,
+	}
+	for _, name := range all {
+		r.permissions = append(r.permissions, &models.Permission{Name: name})
+	}
+
+	r.addRole(models.RoleAdmin, true, all)
+	r.addRole(models.RoleUser, true, []string{models.PermissionUsersRead})
+	r.addRole("support", false, []string{models.PermissionUsersRead})
+	return r
+}
+
+func (r *MemoryRoleRepository) ListPermissions(ctx context.Context) ([]*models.Permission, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]*models.Permission, len(r.permissions))
+	for i, p := range r.permissions {
+		c := *p
+		result[i] = &c
+	}
+	return result, nil
+}
+
+func (r *MemoryRoleRepository) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]*models.Role, 0, len(r.roles))
+	for _, role := range r.roles {
+		result = append(result, copyRole(role))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (r *MemoryRoleRepository) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	role, ok := r.roles[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyRole(role), nil
+}
+
+func (r *MemoryRoleRepository) CreateRole(ctx context.Context, role *models.RoleCreate) (*models.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.roles[role.Name]; ok {
+		return nil, ErrConflict
+	}
+	created := r.addRole(role.Name, false, role.Permissions)
+	created.Description = role.Description
+	return copyRole(created), nil
+}
+
+func (r *MemoryRoleRepository) UpdateRole(ctx context.Context, name string, update *models.RoleUpdate) (*models.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	role, ok := r.roles[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if update.Description != nil {
+		role.Description = *update.Description
+	}
+	if update.Permissions != nil {
+		role.Permissions = sortedUnique(update.Permissions)
+	}
+	role.UpdatedAt = time.Now()
+	return copyRole(role), nil
+}
+
+func (r *MemoryRoleRepository) DeleteRole(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.roles[name]; !ok {
+		return ErrNotFound
+	}
+	for _, roles := range r.userRoles {
+		for _, role := range roles {
+			if role == name {
+				return ErrInUse
+			}
+		}
+	}
+	delete(r.roles, name)
+	return nil
+}
+
+func (r *MemoryRoleRepository) UserRoles(ctx context.Context, userID int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string{}, r.userRoles[userID]...), nil
+}
+
+func (r *MemoryRoleRepository) SetUserRoles(ctx context.Context, userID int, roles []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, role := range roles {
+		if _, ok := r.roles[role]; !ok {
+			return ErrNotFound
+		}
+	}
+	r.userRoles[userID] = sortedUnique(roles)
+	return nil
+}
+
+func (r *MemoryRoleRepository) UserPermissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	user, err := r.users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var permissions []string
+	for _, name := range r.userRoles[user.InternalID] {
+		if role, ok := r.roles[name]; ok {
+			permissions = append(permissions, role.Permissions...)
+		}
+	}
+	return sortedUnique(permissions), nil
+}
+
+func (r *MemoryRoleRepository) RolePermissions(ctx context.Context, name string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	role, ok := r.roles[name]
+	if !ok {
+		return []string{}, nil
+	}
+	return append([]string{}, role.Permissions...), nil
+}
+
+func (r *MemoryRoleRepository) addRole(name string, builtin bool, permissions []string) *models.Role {
+	r.nextID++
+	now := time.Now()
+	role := &models.Role{
+		ID:          r.nextID,
+		Name:        name,
+		Builtin:     builtin,
+		Permissions: sortedUnique(permissions),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	r.roles[name] = role
+	return role
+}
+
+func copyRole(role *models.Role) *models.Role {
+	c := *role
+	c.Permissions = append([]string{}, role.Permissions...)
+	return &c
+}
+
+func sortedUnique(values []string) []string {
+	result := uniqueStrings(values)
+	sort.Strings(result)
+	if result == nil {
+		result = []string{}
+	}
+	return result
+}
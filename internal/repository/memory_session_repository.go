@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions []*models.Session
+}
+
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{}
+}
+
+func (r *MemorySessionRepository) Create(ctx context.Context, session *models.Session) (*models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	created := *session
+	created.ID = id
+	created.OrganizationID = tenantID(ctx)
+	created.CreatedAt = now
+	created.LastSeenAt = now
+	created.RevokedAt = nil
+	r.sessions = append(r.sessions, &created)
+
+	c := created
+	return &c, nil
+}
+
+func (r *MemorySessionRepository) ListActive(ctx context.Context, userID int) ([]*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := []*models.Session{}
+	for _, session := range r.sessions {
+		if r.active(ctx, session) && session.UserID == userID {
+			c := *session
+			result = append(result, &c)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastSeenAt.After(result[j].LastSeenAt)
+	})
+	return result, nil
+}
+
+func (r *MemorySessionRepository) Touch(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, session := range r.sessions {
+		if session.ID == id && r.active(ctx, session) {
+			session.LastSeenAt = time.Now()
+			c := *session
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemorySessionRepository) Revoke(ctx context.Context, id uuid.UUID, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, session := range r.sessions {
+		if session.ID == id && session.UserID == userID && r.active(ctx, session) {
+			now := time.Now()
+			session.RevokedAt = &now
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemorySessionRepository) RevokeAll(ctx context.Context, userID int, keep uuid.UUID) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []uuid.UUID
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.ID != keep && r.active(ctx, session) {
+			now := time.Now()
+			session.RevokedAt = &now
+			ids = append(ids, session.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *MemorySessionRepository) active(ctx context.Context, session *models.Session) bool {
+	return inTenant(ctx, session.OrganizationID) && session.RevokedAt == nil && session.ExpiresAt.After(time.Now())
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+type MemoryUserIdentityRepository struct {
+	mu         sync.RWMutex
+	nextID     int64
+	identities []*models.UserIdentity
+}
+
+func NewMemoryUserIdentityRepository() *MemoryUserIdentityRepository {
+	return &MemoryUserIdentityRepository{}
+}
+
+func (r *MemoryUserIdentityRepository) Create(ctx context.Context, identity *models.UserIdentity) (*models.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	organizationID := tenantID(ctx)
+	for _, existing := range r.identities {
+		if existing.OrganizationID == organizationID && existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return nil, ErrConflict
+		}
+	}
+
+	r.nextID++
+	created := *identity
+	created.ID = r.nextID
+	created.OrganizationID = organizationID
+	created.CreatedAt = time.Now()
+	r.identities = append(r.identities, &created)
+
+	c := created
+	return &c, nil
+}
+
+func (r *MemoryUserIdentityRepository) GetBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, identity := range r.identities {
+		if inTenant(ctx, identity.OrganizationID) && identity.Provider == provider && identity.Subject == subject {
+			c := *identity
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserIdentityRepository) ListByUser(ctx context.Context, userID int) ([]*models.UserIdentity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := []*models.UserIdentity{}
+	for _, identity := range r.identities {
+		if inTenant(ctx, identity.OrganizationID) && identity.UserID == userID {
+			c := *identity
+			result = append(result, &c)
+		}
+	}
+	return result, nil
+}
+
+func (r *MemoryUserIdentityRepository) Delete(ctx context.Context, id int64, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, identity := range r.identities {
+		if identity.ID == id && identity.UserID == userID && inTenant(ctx, identity.OrganizationID) {
+			r.identities = append(r.identities[:i], r.identities[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryUserIdentityRepository) TouchLastLogin(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, identity := range r.identities {
+		if identity.ID == id {
+			now := time.Now()
+			identity.LastLoginAt = &now
+			return nil
+		}
+	}
+	return ErrNotFound
+}
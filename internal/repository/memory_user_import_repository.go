@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryUserImportRepository struct {
+	mu      sync.RWMutex
+	imports map[uuid.UUID]*models.UserImport
+	data    map[uuid.UUID][]byte
+}
+
+func NewMemoryUserImportRepository() *MemoryUserImportRepository {
+	return &MemoryUserImportRepository{
+		imports: make(map[uuid.UUID]*models.UserImport),
+		data:    make(map[uuid.UUID][]byte),
+	}
+}
+
+func (r *MemoryUserImportRepository) Create(ctx context.Context, userImport *models.UserImport, data []byte) (*models.UserImport, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	created := *userImport
+	created.ID = id
+	created.OrganizationID = tenantID(ctx)
+	created.Status = models.UserImportStatusPending
+	created.UserImportReport = models.UserImportReport{Errors: []models.UserImportRowError{}}
+	created.CreatedAt = now
+	created.UpdatedAt = now
+	r.imports[id] = &created
+	r.data[id] = append([]byte(nil), data...)
+
+	c := created
+	return &c, nil
+}
+
+func (r *MemoryUserImportRepository) Get(ctx context.Context, id uuid.UUID) (*models.UserImport, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	userImport, ok := r.imports[id]
+	if !ok || !inTenant(ctx, userImport.OrganizationID) {
+		return nil, ErrNotFound
+	}
+	c := *userImport
+	return &c, nil
+}
+
+func (r *MemoryUserImportRepository) Data(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	data, ok := r.data[id]
+	if !ok || !inTenant(ctx, r.imports[id].OrganizationID) {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+func (r *MemoryUserImportRepository) SetStatus(ctx context.Context, id uuid.UUID, status string) (*models.UserImport, error) {
+	return r.update(ctx, id, func(userImport *models.UserImport) {
+		userImport.Status = status
+	})
+}
+
+func (r *MemoryUserImportRepository) Finish(ctx context.Context, id uuid.UUID, status string, report *models.UserImportReport, failure string) (*models.UserImport, error) {
+	finished, err := r.update(ctx, id, func(userImport *models.UserImport) {
+		now := time.Now()
+		userImport.Status = status
+		if report != nil {
+			userImport.UserImportReport = *report
+		}
+		userImport.Error = failure
+		userImport.FinishedAt = &now
+	})
+	if err == nil {
+		r.mu.Lock()
+		delete(r.data, id)
+		r.mu.Unlock()
+	}
+	return finished, err
+}
+
+func (r *MemoryUserImportRepository) update(ctx context.Context, id uuid.UUID, fn func(userImport *models.UserImport)) (*models.UserImport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userImport, ok := r.imports[id]
+	if !ok || !inTenant(ctx, userImport.OrganizationID) {
+		return nil, ErrNotFound
+	}
+	fn(userImport)
+	userImport.UpdatedAt = time.Now()
+
+	c := *userImport
+	return &c, nil
+}
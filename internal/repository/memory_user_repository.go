@@ -0,0 +1,425 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/google/uuid"
+)
+
+type MemoryUserRepository struct {
+	mu     sync.RWMutex
+	nextID int
+	users  map[uuid.UUID]*models.User
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users: make(map[uuid.UUID]*models.User),
+	}
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.UserCreate) (*models.User, error) {
+	passwordHash, err := hashPassword(user.Password, user.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role == "" {
+		user.Role = "user"
+	}
+
+	publicID, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate user ID: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	organizationID := tenantID(ctx)
+	if r.isTaken(organizationID, uuid.Nil, user.Username, user.Email) {
+		return nil, ErrConflict
+	}
+
+	r.nextID++
+	now := time.Now()
+	newUser := &models.User{
+		InternalID:     r.nextID,
+		ID:             publicID,
+		OrganizationID: organizationID,
+		Username:       user.Username,
+		Email:          user.Email,
+		PasswordHash:   passwordHash,
+		Role:           user.Role,
+		Status:         models.UserStatusActive,
+		Attributes:     map[string]interface{}{},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	r.users[newUser.ID] = newUser
+
+	return copyUser(newUser), nil
+}
+
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok || !inTenant(ctx, user.OrganizationID) {
+		return nil, ErrNotFound
+	}
+
+	return copyUser(user), nil
+}
+
+func (r *MemoryUserRepository) GetByInternalID(ctx context.Context, id int) (*models.User, error) {
+	return r.findOne(ctx, func(user *models.User) bool {
+		return user.InternalID == id
+	})
+}
+
+func (r *MemoryUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.findOne(ctx, func(user *models.User) bool {
+		return user.Username == username
+	})
+}
+
+func (r *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findOne(ctx, func(user *models.User) bool {
+		return user.Email == email
+	})
+}
+
+func (r *MemoryUserRepository) Update(ctx context.Context, id uuid.UUID, user *models.UserUpdate) (*models.User, error) {
+	var passwordHash string
+	if user.Password != "" || user.PasswordHash != "" {
+		hash, err := hashPassword(user.Password, user.PasswordHash)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash = hash
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.users[id]
+	if !ok || !inTenant(ctx, current.OrganizationID) {
+		return nil, ErrNotFound
+	}
+
+	updated := copyUser(current)
+	if user.Username != "" {
+		updated.Username = user.Username
+	}
+	if user.Email != "" {
+		updated.Email = user.Email
+	}
+	if passwordHash != "" {
+		updated.PasswordHash = passwordHash
+	}
+	if user.Role != "" {
+		updated.Role = user.Role
+	}
+	if user.DisplayName != nil {
+		updated.DisplayName = *user.DisplayName
+	}
+	if user.Locale != nil {
+		updated.Locale = *user.Locale
+	}
+	if user.Timezone != nil {
+		updated.Timezone = *user.Timezone
+	}
+	if user.Attributes != nil {
+		updated.Attributes = maps.Clone(user.Attributes)
+	}
+
+	if r.isTaken(updated.OrganizationID, id, updated.Username, updated.Email) {
+		return nil, ErrConflict
+	}
+
+	updated.UpdatedAt = time.Now()
+	r.users[id] = updated
+
+	return copyUser(updated), nil
+}
+
+func (r *MemoryUserRepository) RevokeTokens(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || !inTenant(ctx, user.OrganizationID) {
+		return nil, ErrNotFound
+	}
+
+	user.TokenVersion++
+	user.UpdatedAt = time.Now()
+	return copyUser(user), nil
+}
+
+func (r *MemoryUserRepository) SetStatus(ctx context.Context, id uuid.UUID, status string, suspendedUntil *time.Time) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || !inTenant(ctx, user.OrganizationID) {
+		return nil, ErrNotFound
+	}
+
+	user.Status = status
+	user.SuspendedUntil = suspendedUntil
+	user.UpdatedAt = time.Now()
+	return copyUser(user), nil
+}
+
+func (r *MemoryUserRepository) SetAvatar(ctx context.Context, id uuid.UUID, key, url string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || !inTenant(ctx, user.OrganizationID) {
+		return nil, ErrNotFound
+	}
+
+	user.AvatarKey = key
+	user.AvatarURL = url
+	user.UpdatedAt = time.Now()
+	return copyUser(user), nil
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[id]; !ok || !inTenant(ctx, user.OrganizationID) {
+		return ErrNotFound
+	}
+	delete(r.users, id)
+
+	return nil
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context, filter models.UserFilter, limit, offset int) ([]*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]*models.User, 0, len(r.users))
+	for _, user := range r.users {
+		if inTenant(ctx, user.OrganizationID) && matchesFilter(user, filter) {
+			all = append(all, user)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].InternalID < all[j].InternalID
+	})
+
+	var users []*models.User
+	for i := offset; i < len(all) && len(users) < limit; i++ {
+		users = append(users, copyUser(all[i]))
+	}
+
+	return users, nil
+}
+
+func (r *MemoryUserRepository) Count(ctx context.Context, filter models.UserFilter) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, user := range r.users {
+		if inTenant(ctx, user.OrganizationID) && matchesFilter(user, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *MemoryUserRepository) Stream(ctx context.Context, fn func(user *models.User) error) error {
+	users, err := r.List(ctx, models.UserFilter{}, math.MaxInt, 0)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) Import(ctx context.Context, mode string, rows []*models.UserImportRow, dryRun bool) (*UserImportResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	organizationID := tenantID(ctx)
+	byUsername := make(map[string]*models.User)
+	byEmail := make(map[string]*models.User)
+	for _, user := range r.users {
+		if user.OrganizationID == organizationID {
+			byUsername[user.Username] = user
+			byEmail[user.Email] = user
+		}
+	}
+
+	// Like in Postgres, rows are checked against the users that existed
+	// before the import.
+	result := &UserImportResult{}
+	var accepted []*models.UserImportRow
+	for _, row := range rows {
+		owner, existing := byUsername[row.Username], byEmail[row.Email]
+		var conflicts []models.UserImportRowError
+		if mode == models.UserImportModeUpsert {
+			if owner != nil && owner.Email != row.Email {
+				conflicts = append(conflicts, models.UserImportRowError{Row: row.Row, Field: "username", Error: "already exists"})
+			}
+			if existing == nil && row.Password == "" && row.PasswordHash == "" {
+				conflicts = append(conflicts, models.UserImportRowError{Row: row.Row, Field: "password", Error: "is required for new users"})
+			}
+		} else {
+			if owner != nil {
+				conflicts = append(conflicts, models.UserImportRowError{Row: row.Row, Field: "username", Error: "already exists"})
+			}
+			if existing != nil {
+				conflicts = append(conflicts, models.UserImportRowError{Row: row.Row, Field: "email", Error: "already exists"})
+			}
+		}
+		if len(conflicts) > 0 {
+			result.Errors = append(result.Errors, conflicts...)
+			continue
+		}
+		accepted = append(accepted, row)
+	}
+	sort.Slice(result.Errors, func(i, j int) bool {
+		a, b := result.Errors[i], result.Errors[j]
+		return a.Row < b.Row || a.Row == b.Row && a.Field < b.Field
+	})
+
+	now := time.Now()
+	for _, row := range accepted {
+		passwordHash := row.PasswordHash
+		if passwordHash == "" && row.Password != "" && !dryRun {
+			var err error
+			if passwordHash, err = hashPassword(row.Password, ""); err != nil {
+				return nil, err
+			}
+		}
+
+		existing := byEmail[row.Email]
+		if existing == nil {
+			publicID, err := uuid.NewV7()
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate user ID: %w", err)
+			}
+			role := row.Role
+			if role == "" {
+				role = "user"
+			}
+			created := &models.User{
+				ID:             publicID,
+				OrganizationID: organizationID,
+				Username:       row.Username,
+				Email:          row.Email,
+				PasswordHash:   passwordHash,
+				Role:           role,
+				Status:         models.UserStatusActive,
+				Attributes:     map[string]interface{}{},
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			}
+			if !dryRun {
+				r.nextID++
+				created.InternalID = r.nextID
+				r.users[created.ID] = created
+			}
+			result.Created = append(result.Created, copyUser(created))
+			continue
+		}
+
+		if existing.Username == row.Username && (row.Role == "" || existing.Role == row.Role) && row.Password == "" && row.PasswordHash == "" {
+			result.Unchanged++
+			continue
+		}
+		updated := copyUser(existing)
+		updated.Username = row.Username
+		if row.Role != "" {
+			updated.Role = row.Role
+		}
+		if passwordHash != "" {
+			updated.PasswordHash = passwordHash
+		}
+		updated.UpdatedAt = now
+		if !dryRun {
+			r.users[updated.ID] = updated
+		}
+		result.Updated = append(result.Updated, UserChange{Before: copyUser(existing), After: copyUser(updated)})
+	}
+
+	return result, nil
+}
+
+func (r *MemoryUserRepository) findOne(ctx context.Context, match func(user *models.User) bool) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if inTenant(ctx, user.OrganizationID) && match(user) {
+			return copyUser(user), nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// isTaken reports whether another user than exceptID already holds the
+// username or email in the organization. Callers must hold the lock.
+func (r *MemoryUserRepository) isTaken(organizationID int, exceptID uuid.UUID, username, email string) bool {
+	for id, user := range r.users {
+		if id == exceptID || user.OrganizationID != organizationID {
+			continue
+		}
+		if user.Username == username || user.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFilter compares attributes as JSON, like the containment checks in
+// Postgres.
+func matchesFilter(user *models.User, filter models.UserFilter) bool {
+	for name, value := range filter.Attributes {
+		attribute, ok := user.Attributes[name]
+		if !ok {
+			return false
+		}
+		actual, _ := json.Marshal(attribute)
+		matched := false
+		for _, candidate := range attributeValues(value) {
+			if expected, _ := json.Marshal(candidate); bytes.Equal(actual, expected) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func copyUser(user *models.User) *models.User {
+	c := *user
+	c.Attributes = maps.Clone(user.Attributes)
+	return &c
+}
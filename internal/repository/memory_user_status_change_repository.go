@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+type MemoryUserStatusChangeRepository struct {
+	mu      sync.RWMutex
+	nextID  int64
+	changes []*models.UserStatusChange
+}
+
+func NewMemoryUserStatusChangeRepository() *MemoryUserStatusChangeRepository {
+	return &MemoryUserStatusChangeRepository{}
+}
+
+func (r *MemoryUserStatusChangeRepository) Create(ctx context.Context, change *models.UserStatusChange) (*models.UserStatusChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	created := *change
+	created.ID = r.nextID
+	created.OrganizationID = tenantID(ctx)
+	created.CreatedAt = time.Now()
+	r.changes = append(r.changes, &created)
+
+	c := created
+	return &c, nil
+}
+
+func (r *MemoryUserStatusChangeRepository) ListByUser(ctx context.Context, userID int) ([]*models.UserStatusChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := []*models.UserStatusChange{}
+	for i := len(r.changes) - 1; i >= 0; i-- {
+		change := r.changes[i]
+		if inTenant(ctx, change.OrganizationID) && change.UserID == userID {
+			c := *change
+			result = append(result, &c)
+		}
+	}
+	return result, nil
+}
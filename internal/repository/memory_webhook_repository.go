@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+)
+
+type MemoryWebhookRepository struct {
+	mu             sync.RWMutex
+	nextID         int64
+	subscriptions  map[int64]*models.WebhookSubscription
+	deliveries     []*models.WebhookDelivery
+	nextDeliveryID int64
+}
+
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{
+		subscriptions: make(map[int64]*models.WebhookSubscription),
+	}
+}
+
+func (r *MemoryWebhookRepository) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscriptionCreate) (*models.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	now := time.Now()
+	s := &models.WebhookSubscription{
+		ID:         r.nextID,
+		URL:        subscription.URL,
+		EventTypes: append([]string(nil), subscription.EventTypes...),
+		Secret:     subscription.Secret,
+		Active:     true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	r.subscriptions[s.ID] = s
+
+	c := *s
+	return &c, nil
+}
+
+func (r *MemoryWebhookRepository) GetSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.subscriptions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *s
+	return &c, nil
+}
+
+func (r *MemoryWebhookRepository) ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]*models.WebhookSubscription, 0, len(r.subscriptions))
+	for _, s := range r.subscriptions {
+		c := *s
+		result = append(result, &c)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (r *MemoryWebhookRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.subscriptions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.subscriptions, id)
+
+	deliveries := r.deliveries[:0]
+	for _, d := range r.deliveries {
+		if d.SubscriptionID != id {
+			deliveries = append(deliveries, d)
+		}
+	}
+	r.deliveries = deliveries
+	return nil
+}
+
+func (r *MemoryWebhookRepository) EnqueueDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, d := range r.deliveries {
+		if d.SubscriptionID == delivery.SubscriptionID && d.EventID == delivery.EventID {
+			return nil
+		}
+	}
+
+	r.nextDeliveryID++
+	now := time.Now()
+	r.deliveries = append(r.deliveries, &models.WebhookDelivery{
+		ID:             r.nextDeliveryID,
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Payload:        delivery.Payload,
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	})
+	return nil
+}
+
+func (r *MemoryWebhookRepository) GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, d := range r.deliveries {
+		if d.ID == id {
+			c := *d
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryWebhookRepository) ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter, limit, offset int) ([]*models.WebhookDelivery, error) {
+	matched := r.matchDeliveries(filter)
+	// Newest first, like the Postgres implementation.
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
+	}
+
+	if offset >= len(matched) {
+		return []*models.WebhookDelivery{}, nil
+	}
+	end := offset + limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[offset:end], nil
+}
+
+func (r *MemoryWebhookRepository) CountDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) (int, error) {
+	return len(r.matchDeliveries(filter)), nil
+}
+
+func (r *MemoryWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var due []*models.WebhookDelivery
+	for _, d := range r.deliveries {
+		if len(due) < limit && d.Status == models.WebhookDeliveryPending && !d.NextAttemptAt.After(now) {
+			d.NextAttemptAt = now.Add(lease)
+			c := *d
+			due = append(due, &c)
+		}
+	}
+	return due, nil
+}
+
+func (r *MemoryWebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, d := range r.deliveries {
+		if d.ID == delivery.ID {
+			d.Status = delivery.Status
+			d.Attempts = delivery.Attempts
+			d.NextAttemptAt = delivery.NextAttemptAt
+			d.LastStatusCode = delivery.LastStatusCode
+			d.LastError = delivery.LastError
+			d.DeliveredAt = delivery.DeliveredAt
+			d.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryWebhookRepository) ReplayDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, d := range r.deliveries {
+		if d.ID == id && d.Status != models.WebhookDeliveryPending {
+			now := time.Now()
+			d.Status = models.WebhookDeliveryPending
+			d.Attempts = 0
+			d.NextAttemptAt = now
+			d.DeliveredAt = nil
+			d.UpdatedAt = now
+			c := *d
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryWebhookRepository) matchDeliveries(filter models.WebhookDeliveryFilter) []*models.WebhookDelivery {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []*models.WebhookDelivery
+	for _, d := range r.deliveries {
+		if filter.SubscriptionID != 0 && d.SubscriptionID != filter.SubscriptionID {
+			continue
+		}
+		if filter.Status != "" && d.Status != filter.Status {
+			continue
+		}
+		if filter.EventType != "" && d.EventType != filter.EventType {
+			continue
+		}
+		c := *d
+		result = append(result, &c)
+	}
+	return result
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type MFARepository interface {
+	GetTOTP(ctx context.Context, userID int) (*models.UserTOTP, error)
+	// SaveTOTP starts an enrollment with secret, replacing any unconfirmed one.
+	SaveTOTP(ctx context.Context, userID int, secret string) error
+	ConfirmTOTP(ctx context.Context, userID int) error
+	// UseTOTPStep records that a code of step was accepted. It returns false
+	// when a code of that step or a later one was already used.
+	UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	// RecordFailure counts an invalid code. The maxFailures-th one in a row
+	// locks codes until lockedUntil and starts counting again. It reports
+	// whether the user is locked now.
+	RecordFailure(ctx context.Context, userID int, maxFailures int, lockedUntil time.Time) (bool, error)
+	// ResetFailures clears the invalid codes counted for a user.
+	ResetFailures(ctx context.Context, userID int) error
+	// DeleteTOTP removes the enrollment and recovery codes of a user.
+	DeleteTOTP(ctx context.Context, userID int) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error
+	// UseRecoveryCode marks an unused code as used. It returns false when
+	// there is no such code.
+	UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error)
+}
+
+type PostgresMFARepository struct {
+	db   *pgxpool.Pool
+	totp *Table[models.UserTOTP]
+}
+
+func NewPostgresMFARepository(db *pgxpool.Pool) *PostgresMFARepository {
+	return &PostgresMFARepository{
+		db:   db,
+		totp: NewTable[models.UserTOTP](db, "user_totp", "user_id"),
+	}
+}
+
+func (r *PostgresMFARepository) GetTOTP(ctx context.Context, userID int) (*models.UserTOTP, error) {
+	return r.totp.Get(ctx, userID)
+}
+
+func (r *PostgresMFARepository) SaveTOTP(ctx context.Context, userID int, secret string) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `
+		INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, confirmed_at = NULL, last_used_step = 0, updated_at = NOW()
+		WHERE user_totp.confirmed_at IS NULL
+	`, userID, secret)
+	if err != nil {
+		return fmt.Errorf("failed to save totp: %w", mapError(err))
+	}
+	return nil
+}
+
+func (r *PostgresMFARepository) ConfirmTOTP(ctx context.Context, userID int) error {
+	tag, err := conn(ctx, r.db).Exec(ctx, `
+		UPDATE user_totp SET confirmed_at = NOW(), updated_at = NOW() WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to confirm totp: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresMFARepository) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	tag, err := conn(ctx, r.db).Exec(ctx, `
+		UPDATE user_totp SET last_used_step = $2, updated_at = NOW()
+		WHERE user_id = $1 AND last_used_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp use: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *PostgresMFARepository) RecordFailure(ctx context.Context, userID int, maxFailures int, lockedUntil time.Time) (bool, error) {
+	var locked bool
+	err := conn(ctx, r.db).QueryRow(ctx, `
+		UPDATE user_totp SET
+			failed_attempts = CASE WHEN failed_attempts + 1 >= $2 THEN 0 ELSE failed_attempts + 1 END,
+			locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN $3 ELSE locked_until END,
+			updated_at = NOW()
+		WHERE user_id = $1
+		RETURNING locked_until IS NOT NULL AND locked_until = $3
+	`, userID, maxFailures, lockedUntil).Scan(&locked)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp failure: %w", mapError(err))
+	}
+	return locked, nil
+}
+
+func (r *PostgresMFARepository) ResetFailures(ctx context.Context, userID int) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `
+		UPDATE user_totp SET failed_attempts = 0, locked_until = NULL, updated_at = NOW()
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to reset totp failures: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresMFARepository) DeleteTOTP(ctx context.Context, userID int) error {
+	db := conn(ctx, r.db)
+	if _, err := db.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return r.totp.Delete(ctx, userID)
+}
+
+func (r *PostgresMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `
+		WITH deleted AS (DELETE FROM mfa_recovery_codes WHERE user_id = $1)
+		INSERT INTO mfa_recovery_codes (user_id, code_hash)
+		SELECT $1, hash FROM unnest($2::TEXT[]) AS hash
+	`, userID, hashes)
+	if err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresMFARepository) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	tag, err := conn(ctx, r.db).Exec(ctx, `
+		UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM mfa_recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+		)
+	`, userID, hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
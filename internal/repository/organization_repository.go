@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OrganizationRepository interface {
+	Create(ctx context.Context, organization *models.OrganizationCreate) (*models.Organization, error)
+	GetByID(ctx context.Context, id int) (*models.Organization, error)
+	GetBySlug(ctx context.Context, slug string) (*models.Organization, error)
+	List(ctx context.Context) ([]*models.Organization, error)
+}
+
+type PostgresOrganizationRepository struct {
+	organizations *Table[models.Organization]
+}
+
+func NewPostgresOrganizationRepository(db *pgxpool.Pool) *PostgresOrganizationRepository {
+	return &PostgresOrganizationRepository{
+		organizations: NewTable[models.Organization](db, "organizations", "id"),
+	}
+}
+
+func (r *PostgresOrganizationRepository) Create(ctx context.Context, organization *models.OrganizationCreate) (*models.Organization, error) {
+	return r.organizations.Create(ctx, Values{
+		"slug": organization.Slug,
+		"name": organization.Name,
+	})
+}
+
+func (r *PostgresOrganizationRepository) GetByID(ctx context.Context, id int) (*models.Organization, error) {
+	return r.organizations.Get(ctx, id)
+}
+
+func (r *PostgresOrganizationRepository) GetBySlug(ctx context.Context, slug string) (*models.Organization, error) {
+	return r.organizations.GetBy(ctx, "slug", slug)
+}
+
+func (r *PostgresOrganizationRepository) List(ctx context.Context) ([]*models.Organization, error) {
+	return r.organizations.List(ctx, ListOptions{})
+}
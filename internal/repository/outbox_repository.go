@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OutboxRepository interface {
+	Add(ctx context.Context, event *models.DomainEvent) error
+	// ClaimDue returns up to limit pending events whose next attempt is due,
+	// at most one per ordering key and only when no older event with the same
+	// key is still pending. Their next attempt is moved lease into the future,
+	// so other dispatchers skip them while they are delivered and pick them up
+	// again should this one stop before marking them.
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error)
+	MarkDelivered(ctx context.Context, id int64) error
+	MarkRetry(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error
+	MarkFailed(ctx context.Context, id int64, attempts int, lastError string) error
+	DeleteDelivered(ctx context.Context, deliveredBefore time.Time) (int, error)
+}
+
+type PostgresOutboxRepository struct {
+	db     *pgxpool.Pool
+	events *Table[models.OutboxEvent]
+}
+
+func NewPostgresOutboxRepository(db *pgxpool.Pool) *PostgresOutboxRepository {
+	return &PostgresOutboxRepository{
+		db:     db,
+		events: NewTable[models.OutboxEvent](db, "outbox_events", "id"),
+	}
+}
+
+func (r *PostgresOutboxRepository) Add(ctx context.Context, event *models.DomainEvent) error {
+	_, err := r.events.Create(ctx, Values{
+		"event_id":        event.ID,
+		"event_type":      event.Type,
+		"ordering_key":    event.OrderingKey,
+		"payload":         event.Payload,
+		"status":          models.OutboxStatusPending,
+		"created_at":      event.OccurredAt,
+		"next_attempt_at": Now,
+	})
+	return err
+}
+
+func (r *PostgresOutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	query := fmt.Sprintf(`
+		UPDATE outbox_events
+		SET next_attempt_at = NOW() + $3 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT o.id
+			FROM outbox_events o
+			WHERE o.status = $1
+			  AND o.next_attempt_at <= NOW()
+			  AND NOT EXISTS (
+				SELECT 1 FROM outbox_events p
+				WHERE p.ordering_key = o.ordering_key AND p.status = $1 AND p.id < o.id
+			  )
+			ORDER BY o.id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING %s
+	`, r.events.selectList())
+
+	rows, err := conn(ctx, r.db).Query(ctx, query, models.OutboxStatusPending, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+
+	events, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.OutboxEvent])
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+func (r *PostgresOutboxRepository) MarkDelivered(ctx context.Context, id int64) error {
+	_, err := r.events.Update(ctx, id, Values{
+		"status":       models.OutboxStatusDelivered,
+		"delivered_at": Now,
+	})
+	return err
+}
+
+func (r *PostgresOutboxRepository) MarkRetry(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := r.events.Update(ctx, id, Values{
+		"attempts":        attempts,
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      lastError,
+	})
+	return err
+}
+
+func (r *PostgresOutboxRepository) MarkFailed(ctx context.Context, id int64, attempts int, lastError string) error {
+	_, err := r.events.Update(ctx, id, Values{
+		"status":     models.OutboxStatusFailed,
+		"attempts":   attempts,
+		"last_error": lastError,
+	})
+	return err
+}
+
+func (r *PostgresOutboxRepository) DeleteDelivered(ctx context.Context, deliveredBefore time.Time) (int, error) {
+	result, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM outbox_events WHERE status = $1 AND delivered_at < $2`,
+		models.OutboxStatusDelivered, deliveredBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete delivered outbox events: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
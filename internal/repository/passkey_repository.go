@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PasskeyRepository interface {
+	Create(ctx context.Context, passkey *models.Passkey) (*models.Passkey, error)
+	GetByCredentialID(ctx context.Context, credentialID []byte) (*models.Passkey, error)
+	ListByUser(ctx context.Context, userID int) ([]*models.Passkey, error)
+	// Delete removes a passkey of userID.
+	Delete(ctx context.Context, id int64, userID int) error
+	// RecordUse stores the state reported by the authenticator at a login.
+	RecordUse(ctx context.Context, id int64, signCount int64, backupState bool) error
+	// SaveChallenge stores the challenge of a ceremony until expiresAt.
+	SaveChallenge(ctx context.Context, challenge string, expiresAt time.Time) error
+	// UseChallenge deletes a challenge. It returns false when there is no
+	// such challenge or it expired before now.
+	UseChallenge(ctx context.Context, challenge string, now time.Time) (bool, error)
+}
+
+type PostgresPasskeyRepository struct {
+	db       *pgxpool.Pool
+	passkeys *Table[models.Passkey]
+}
+
+func NewPostgresPasskeyRepository(db *pgxpool.Pool) *PostgresPasskeyRepository {
+	return &PostgresPasskeyRepository{
+		db:       db,
+		passkeys: NewTable[models.Passkey](db, "passkeys", "id").WithTenant("organization_id"),
+	}
+}
+
+func (r *PostgresPasskeyRepository) Create(ctx context.Context, passkey *models.Passkey) (*models.Passkey, error) {
+	return r.passkeys.Create(ctx, Values{
+		"user_id":          passkey.UserID,
+		"name":             passkey.Name,
+		"credential_id":    passkey.CredentialID,
+		"public_key":       passkey.PublicKey,
+		"attestation_type": passkey.AttestationType,
+		"aaguid":           passkey.AAGUID,
+		"sign_count":       passkey.SignCount,
+		"transports":       passkey.Transports,
+		"backup_eligible":  passkey.BackupEligible,
+		"backup_state":     passkey.BackupState,
+	})
+}
+
+func (r *PostgresPasskeyRepository) GetByCredentialID(ctx context.Context, credentialID []byte) (*models.Passkey, error) {
+	return r.passkeys.GetBy(ctx, "credential_id", credentialID)
+}
+
+func (r *PostgresPasskeyRepository) ListByUser(ctx context.Context, userID int) ([]*models.Passkey, error) {
+	return r.passkeys.List(ctx, ListOptions{Filter: Filter{"user_id": userID}})
+}
+
+func (r *PostgresPasskeyRepository) Delete(ctx context.Context, id int64, userID int) error {
+	where, args := r.passkeys.scoped(ctx, " WHERE id = $1 AND user_id = $2", []interface{}{id, userID})
+	tag, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM passkeys`+where, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete passkey: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresPasskeyRepository) RecordUse(ctx context.Context, id int64, signCount int64, backupState bool) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `
+		UPDATE passkeys SET sign_count = $2, backup_state = $3, last_used_at = NOW() WHERE id = $1
+	`, id, signCount, backupState)
+	if err != nil {
+		return fmt.Errorf("failed to record passkey use: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresPasskeyRepository) SaveChallenge(ctx context.Context, challenge string, expiresAt time.Time) error {
+	// Challenges of ceremonies that were never finished are removed on the
+	// way, once they are well past their expiry.
+	_, err := conn(ctx, r.db).Exec(ctx, `
+		WITH expired AS (DELETE FROM passkey_challenges WHERE expires_at < $2::TIMESTAMP - INTERVAL '1 day')
+		INSERT INTO passkey_challenges (challenge, expires_at) VALUES ($1, $2)
+	`, challenge, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save passkey challenge: %w", mapError(err))
+	}
+	return nil
+}
+
+func (r *PostgresPasskeyRepository) UseChallenge(ctx context.Context, challenge string, now time.Time) (bool, error) {
+	tag, err := conn(ctx, r.db).Exec(ctx, `
+		DELETE FROM passkey_challenges WHERE challenge = $1 AND expires_at > $2
+	`, challenge, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to use passkey challenge: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RoleRepository stores roles, their permissions and the roles of users.
+// Methods that write several tables should run inside TxManager.WithinTx.
+type RoleRepository interface {
+	ListPermissions(ctx context.Context) ([]*models.Permission, error)
+	ListRoles(ctx context.Context) ([]*models.Role, error)
+	GetRole(ctx context.Context, name string) (*models.Role, error)
+	CreateRole(ctx context.Context, role *models.RoleCreate) (*models.Role, error)
+	UpdateRole(ctx context.Context, name string, role *models.RoleUpdate) (*models.Role, error)
+	// DeleteRole returns ErrInUse while the role is assigned to a user.
+	DeleteRole(ctx context.Context, name string) error
+	UserRoles(ctx context.Context, userID int) ([]string, error)
+	// SetUserRoles replaces the roles of a user. All roles must exist.
+	SetUserRoles(ctx context.Context, userID int, roles []string) error
+	// UserPermissions returns the union of the permissions of a user's roles.
+	UserPermissions(ctx context.Context, userID uuid.UUID) ([]string, error)
+	RolePermissions(ctx context.Context, role string) ([]string, error)
+}
+
+const roleSelect = `
+	SELECT r.id, r.name, r.description, r.builtin, r.created_at, r.updated_at,
+		ARRAY(SELECT rp.permission FROM role_permissions rp WHERE rp.role_id = r.id ORDER BY rp.permission) AS permissions
+	FROM roles r
+`
+
+type PostgresRoleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRoleRepository(db *pgxpool.Pool) *PostgresRoleRepository {
+	return &PostgresRoleRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresRoleRepository) ListPermissions(ctx context.Context) ([]*models.Permission, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, `SELECT name, description FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+
+	permissions, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Permission])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+func (r *PostgresRoleRepository) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, roleSelect+` ORDER BY r.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	roles, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Role])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *PostgresRoleRepository) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, roleSelect+` WHERE r.name = $1`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	role, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[models.Role])
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return role, nil
+}
+
+func (r *PostgresRoleRepository) CreateRole(ctx context.Context, role *models.RoleCreate) (*models.Role, error) {
+	var id int
+	err := conn(ctx, r.db).QueryRow(ctx, `INSERT INTO roles (name, description) VALUES ($1, $2) RETURNING id`,
+		role.Name, role.Description).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role: %w", mapError(err))
+	}
+
+	if err := r.setPermissions(ctx, id, role.Permissions); err != nil {
+		return nil, err
+	}
+	return r.GetRole(ctx, role.Name)
+}
+
+func (r *PostgresRoleRepository) UpdateRole(ctx context.Context, name string, role *models.RoleUpdate) (*models.Role, error) {
+	var id int
+	err := conn(ctx, r.db).QueryRow(ctx, `
+		UPDATE roles SET description = COALESCE($2, description), updated_at = NOW()
+		WHERE name = $1
+		RETURNING id
+	`, name, role.Description).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update role: %w", mapError(err))
+	}
+
+	if role.Permissions != nil {
+		if err := r.setPermissions(ctx, id, role.Permissions); err != nil {
+			return nil, err
+		}
+	}
+	return r.GetRole(ctx, name)
+}
+
+func (r *PostgresRoleRepository) DeleteRole(ctx context.Context, name string) error {
+	result, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM roles WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", mapError(err))
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRoleRepository) UserRoles(ctx context.Context, userID int) ([]string, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, `
+		SELECT r.name FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY r.name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+
+	roles, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *PostgresRoleRepository) SetUserRoles(ctx context.Context, userID int, roles []string) error {
+	db := conn(ctx, r.db)
+
+	if _, err := db.Exec(ctx, `DELETE FROM user_roles WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to set user roles: %w", err)
+	}
+
+	result, err := db.Exec(ctx, `
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE name = ANY($2)
+	`, userID, roles)
+	if err != nil {
+		return fmt.Errorf("failed to set user roles: %w", mapError(err))
+	}
+	if int(result.RowsAffected()) != len(uniqueStrings(roles)) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRoleRepository) UserPermissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, `
+		SELECT DISTINCT rp.permission
+		FROM users u
+		JOIN user_roles ur ON ur.user_id = u.id
+		JOIN role_permissions rp ON rp.role_id = ur.role_id
+		WHERE u.public_id = $1
+		ORDER BY rp.permission
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user permissions: %w", err)
+	}
+
+	permissions, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+func (r *PostgresRoleRepository) RolePermissions(ctx context.Context, role string) ([]string, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, `
+		SELECT rp.permission FROM role_permissions rp JOIN roles r ON r.id = rp.role_id
+		WHERE r.name = $1
+		ORDER BY rp.permission
+	`, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+
+	permissions, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+func (r *PostgresRoleRepository) setPermissions(ctx context.Context, roleID int, permissions []string) error {
+	db := conn(ctx, r.db)
+
+	if _, err := db.Exec(ctx, `DELETE FROM role_permissions WHERE role_id = $1`, roleID); err != nil {
+		return fmt.Errorf("failed to set role permissions: %w", err)
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO role_permissions (role_id, permission)
+		SELECT $1, p FROM UNNEST($2::TEXT[]) AS p
+		ON CONFLICT DO NOTHING
+	`, roleID, permissions)
+	if err != nil {
+		return fmt.Errorf("failed to set role permissions: %w", mapError(err))
+	}
+	return nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) (*models.Session, error)
+	// ListActive returns the sessions of userID that are neither revoked nor
+	// expired, most recently seen first.
+	ListActive(ctx context.Context, userID int) ([]*models.Session, error)
+	// Touch records activity on an active session. It returns ErrNotFound
+	// for revoked and expired sessions.
+	Touch(ctx context.Context, id uuid.UUID) (*models.Session, error)
+	// Revoke revokes an active session of userID.
+	Revoke(ctx context.Context, id uuid.UUID, userID int) error
+	// RevokeAll revokes the active sessions of userID except keep, and
+	// returns the IDs of the revoked ones.
+	RevokeAll(ctx context.Context, userID int, keep uuid.UUID) ([]uuid.UUID, error)
+}
+
+const activeSession = ` AND revoked_at IS NULL AND expires_at > NOW()`
+
+type PostgresSessionRepository struct {
+	db       *pgxpool.Pool
+	sessions *Table[models.Session]
+}
+
+func NewPostgresSessionRepository(db *pgxpool.Pool) *PostgresSessionRepository {
+	return &PostgresSessionRepository{
+		db:       db,
+		sessions: NewTable[models.Session](db, "sessions", "id").WithTenant("organization_id"),
+	}
+}
+
+func (r *PostgresSessionRepository) Create(ctx context.Context, session *models.Session) (*models.Session, error) {
+	return r.sessions.Create(ctx, Values{
+		"user_id":    session.UserID,
+		"user_agent": session.UserAgent,
+		"ip":         session.IP,
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+func (r *PostgresSessionRepository) ListActive(ctx context.Context, userID int) ([]*models.Session, error) {
+	where, args := r.sessions.scoped(ctx, " WHERE user_id = $1"+activeSession, []interface{}{userID})
+	query := fmt.Sprintf(`SELECT %s FROM sessions%s ORDER BY last_seen_at DESC`, r.sessions.selectList(), where)
+	return r.sessions.all(ctx, query, args...)
+}
+
+func (r *PostgresSessionRepository) Touch(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	where, args := r.sessions.scoped(ctx, " WHERE id = $1"+activeSession, []interface{}{id})
+	query := fmt.Sprintf(`UPDATE sessions SET last_seen_at = NOW()%s RETURNING %s`, where, r.sessions.selectList())
+	return r.sessions.one(ctx, query, args...)
+}
+
+func (r *PostgresSessionRepository) Revoke(ctx context.Context, id uuid.UUID, userID int) error {
+	where, args := r.sessions.scoped(ctx, " WHERE id = $1 AND user_id = $2"+activeSession, []interface{}{id, userID})
+	tag, err := conn(ctx, r.db).Exec(ctx, `UPDATE sessions SET revoked_at = NOW()`+where, args...)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresSessionRepository) RevokeAll(ctx context.Context, userID int, keep uuid.UUID) ([]uuid.UUID, error) {
+	where, args := r.sessions.scoped(ctx, " WHERE user_id = $1 AND id <> $2"+activeSession, []interface{}{userID, keep})
+	rows, err := conn(ctx, r.db).Query(ctx, `UPDATE sessions SET revoked_at = NOW()`+where+` RETURNING id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,399 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	uniqueViolationCode     = "23505"
+	foreignKeyViolationCode = "23503"
+)
+
+// DBTX is the subset of pgx shared by *pgxpool.Pool, *pgx.Conn and pgx.Tx.
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// Values maps column names to the values written by Create and Update.
+type Values map[string]interface{}
+
+// Filter maps column names to the values they must be equal to.
+type Filter map[string]interface{}
+
+type ListOptions struct {
+	Limit   int
+	Offset  int
+	Filter  Filter
+	OrderBy string
+	Desc    bool
+}
+
+// Table implements the common CRUD queries for a table whose rows scan into T.
+// The selected columns are taken from the `db` tags of T, so T must tag every
+// exported field with its column name (or `db:"-"` to skip it).
+type Table[T any] struct {
+	db      DBTX
+	name    string
+	key     string
+	columns []string
+	known   map[string]bool
+	tenant  string
+}
+
+func NewTable[T any](db DBTX, name string, key string) *Table[T] {
+	columns := structColumns(reflect.TypeOf((*T)(nil)).Elem())
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known[column] = true
+	}
+
+	return &Table[T]{
+		db:      db,
+		name:    name,
+		key:     key,
+		columns: columns,
+		known:   known,
+	}
+}
+
+// WithTenant scopes every query of the table to the organization in ctx
+// through column, which Create also fills in. Calls without a tenant in ctx
+// are not scoped.
+func (t *Table[T]) WithTenant(column string) *Table[T] {
+	t.tenant = column
+	return t
+}
+
+func (t *Table[T]) Create(ctx context.Context, values Values) (*T, error) {
+	values = t.withTimestamps(values, "created_at", "updated_at")
+	if current, ok := tenant.FromContext(ctx); ok && t.tenant != "" {
+		if _, set := values[t.tenant]; !set {
+			values[t.tenant] = current.ID
+		}
+	}
+
+	columns, placeholders, args, err := t.sortedValues(values)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) RETURNING %s`,
+		t.ident(), joinIdents(columns), strings.Join(placeholders, ", "), t.selectList())
+
+	item, err := t.one(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", t.name, err)
+	}
+	return item, nil
+}
+
+func (t *Table[T]) Get(ctx context.Context, key interface{}) (*T, error) {
+	return t.GetBy(ctx, t.key, key)
+}
+
+func (t *Table[T]) GetBy(ctx context.Context, column string, value interface{}) (*T, error) {
+	if !t.known[column] {
+		return nil, fmt.Errorf("unknown column %q in %s", column, t.name)
+	}
+
+	where, args := t.scoped(ctx, fmt.Sprintf(" WHERE %s = $1", quote(column)), []interface{}{value})
+	query := fmt.Sprintf(`SELECT %s FROM %s%s`, t.selectList(), t.ident(), where)
+
+	item, err := t.one(ctx, query, args...)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get %s: %w", t.name, err)
+	}
+	return item, nil
+}
+
+func (t *Table[T]) Update(ctx context.Context, key interface{}, values Values) (*T, error) {
+	values = t.withTimestamps(values, "updated_at")
+	if len(values) == 0 {
+		return t.Get(ctx, key)
+	}
+
+	columns, placeholders, args, err := t.sortedValues(values)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = quote(column) + " = " + placeholders[i]
+	}
+	args = append(args, key)
+	where, args := t.scoped(ctx, fmt.Sprintf(" WHERE %s = $%d", quote(t.key), len(args)), args)
+
+	query := fmt.Sprintf(`UPDATE %s SET %s%s RETURNING %s`,
+		t.ident(), strings.Join(assignments, ", "), where, t.selectList())
+
+	item, err := t.one(ctx, query, args...)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update %s: %w", t.name, err)
+	}
+	return item, nil
+}
+
+func (t *Table[T]) Delete(ctx context.Context, key interface{}) error {
+	where, args := t.scoped(ctx, fmt.Sprintf(" WHERE %s = $1", quote(t.key)), []interface{}{key})
+	query := fmt.Sprintf(`DELETE FROM %s%s`, t.ident(), where)
+
+	result, err := conn(ctx, t.db).Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", t.name, mapError(err))
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (t *Table[T]) List(ctx context.Context, opts ListOptions) ([]*T, error) {
+	where, args, err := t.where(opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	where, args = t.scoped(ctx, where, args)
+
+	orderBy := opts.OrderBy
+	if orderBy == "" {
+		orderBy = t.key
+	}
+	if !t.known[orderBy] {
+		return nil, fmt.Errorf("unknown column %q in %s", orderBy, t.name)
+	}
+	direction := "ASC"
+	if opts.Desc {
+		direction = "DESC"
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM %s%s ORDER BY %s %s`, t.selectList(), t.ident(), where, quote(orderBy), direction)
+	if opts.Limit > 0 {
+		args = append(args, opts.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if opts.Offset > 0 {
+		args = append(args, opts.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	return t.all(ctx, query, args...)
+}
+
+func (t *Table[T]) Count(ctx context.Context, filter Filter) (int, error) {
+	where, args, err := t.where(filter)
+	if err != nil {
+		return 0, err
+	}
+	where, args = t.scoped(ctx, where, args)
+
+	var count int
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s%s`, t.ident(), where)
+	if err := conn(ctx, t.db).QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", t.name, err)
+	}
+	return count, nil
+}
+
+func (t *Table[T]) one(ctx context.Context, query string, args ...interface{}) (*T, error) {
+	rows, err := conn(ctx, t.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	item, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[T])
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return item, nil
+}
+
+func (t *Table[T]) all(ctx context.Context, query string, args ...interface{}) ([]*T, error) {
+	rows, err := conn(ctx, t.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", t.name, err)
+	}
+
+	items, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[T])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", t.name, err)
+	}
+	return items, nil
+}
+
+func (t *Table[T]) where(filter Filter) (string, []interface{}, error) {
+	if len(filter) == 0 {
+		return "", nil, nil
+	}
+
+	columns := make([]string, 0, len(filter))
+	for column := range filter {
+		if !t.known[column] {
+			return "", nil, fmt.Errorf("unknown column %q in %s", column, t.name)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	conditions := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conditions[i] = fmt.Sprintf("%s = $%d", quote(column), i+1)
+		args[i] = filter[column]
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// scoped adds the tenant condition to a WHERE clause (possibly empty) whose
+// placeholders refer to args.
+func (t *Table[T]) scoped(ctx context.Context, where string, args []interface{}) (string, []interface{}) {
+	current, ok := tenant.FromContext(ctx)
+	if !ok || t.tenant == "" {
+		return where, args
+	}
+
+	args = append(args, current.ID)
+	condition := fmt.Sprintf("%s = $%d", quote(t.tenant), len(args))
+	if where == "" {
+		return " WHERE " + condition, args
+	}
+	return where + " AND " + condition, args
+}
+
+// withTimestamps sets the given timestamp columns to NOW() when T has them and
+// the caller did not provide a value.
+func (t *Table[T]) withTimestamps(values Values, columns ...string) Values {
+	result := make(Values, len(values)+len(columns))
+	for column, value := range values {
+		result[column] = value
+	}
+	for _, column := range columns {
+		if _, ok := result[column]; !ok && t.known[column] {
+			result[column] = Now
+		}
+	}
+	return result
+}
+
+// sortedValues returns the columns in a stable order together with the SQL
+// placeholder used for each of them and the matching query arguments.
+func (t *Table[T]) sortedValues(values Values) ([]string, []string, []interface{}, error) {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		if !t.known[column] {
+			return nil, nil, nil, fmt.Errorf("unknown column %q in %s", column, t.name)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	placeholders := make([]string, len(columns))
+	var args []interface{}
+	for i, column := range columns {
+		if expr, ok := values[column].(sqlExpr); ok {
+			placeholders[i] = string(expr)
+			continue
+		}
+		args = append(args, values[column])
+		placeholders[i] = fmt.Sprintf("$%d", len(args))
+	}
+	return columns, placeholders, args, nil
+}
+
+func (t *Table[T]) ident() string {
+	return pgx.Identifier{t.name}.Sanitize()
+}
+
+func (t *Table[T]) selectList() string {
+	return joinIdents(t.columns)
+}
+
+// selectListOf is selectList with the columns qualified by a table alias,
+// for queries that join other tables.
+func (t *Table[T]) selectListOf(alias string) string {
+	qualified := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		qualified[i] = quote(alias) + "." + quote(column)
+	}
+	return strings.Join(qualified, ", ")
+}
+
+// sqlExpr is written into the query as is instead of being sent as an
+// argument. It is unexported so only the expressions below can be used.
+type sqlExpr string
+
+// Now makes Create and Update use the database clock for a column.
+const Now = sqlExpr("NOW()")
+
+func mapError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case uniqueViolationCode:
+			return ErrConflict
+		case foreignKeyViolationCode:
+			return ErrInUse
+		}
+	}
+
+	return err
+}
+
+func structColumns(typ reflect.Type) []string {
+	var columns []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			columns = append(columns, structColumns(field.Type)...)
+			continue
+		}
+
+		column, ok := field.Tag.Lookup("db")
+		if !ok {
+			column = strings.ToLower(field.Name)
+		}
+		column = strings.Split(column, ",")[0]
+		if column == "-" {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+func joinIdents(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quote(column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func quote(column string) string {
+	return pgx.Identifier{column}.Sanitize()
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type tableTestItem struct {
+	ID        int       `db:"id"`
+	Title     string    `db:"title"`
+	Secret    string    `db:"-"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+var errQueryCaptured = errors.New("query captured")
+
+type capturingDB struct {
+	query string
+	args  []interface{}
+}
+
+func (db *capturingDB) Exec(_ context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	db.query, db.args = sql, args
+	return pgconn.CommandTag{}, errQueryCaptured
+}
+
+func (db *capturingDB) Query(_ context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	db.query, db.args = sql, args
+	return nil, errQueryCaptured
+}
+
+func (db *capturingDB) QueryRow(_ context.Context, sql string, args ...interface{}) pgx.Row {
+	db.query, db.args = sql, args
+	return nil
+}
+
+func TestTableUpdateQuery(t *testing.T) {
+	db := &capturingDB{}
+	table := NewTable[tableTestItem](db, "items", "id")
+
+	_, err := table.Update(context.Background(), 7, Values{"title": "new"})
+	if !errors.Is(err, errQueryCaptured) {
+		t.Fatalf("Expected captured query error, actual: %v", err)
+	}
+
+	expected := `UPDATE "items" SET "title" = $1, "updated_at" = NOW() WHERE "id" = $2 RETURNING "id", "title", "updated_at"`
+	if db.query != expected {
+		t.Errorf("Wrong query,\nexpected: %v\nactual:   %v", expected, db.query)
+	}
+	if !reflect.DeepEqual(db.args, []interface{}{"new", 7}) {
+		t.Errorf("Wrong args: %v", db.args)
+	}
+}
+
+func TestTableListQuery(t *testing.T) {
+	db := &capturingDB{}
+	table := NewTable[tableTestItem](db, "items", "id")
+
+	_, err := table.List(context.Background(), ListOptions{Limit: 10, Offset: 20, Filter: Filter{"title": "a"}, OrderBy: "updated_at", Desc: true})
+	if !errors.Is(err, errQueryCaptured) {
+		t.Fatalf("Expected captured query error, actual: %v", err)
+	}
+
+	expected := `SELECT "id", "title", "updated_at" FROM "items" WHERE "title" = $1 ORDER BY "updated_at" DESC LIMIT $2 OFFSET $3`
+	if db.query != expected {
+		t.Errorf("Wrong query,\nexpected: %v\nactual:   %v", expected, db.query)
+	}
+}
+
+func TestTableTenantScope(t *testing.T) {
+	db := &capturingDB{}
+	table := NewTable[tableTestItem](db, "items", "id").WithTenant("title")
+	ctx := tenant.NewContext(context.Background(), tenant.Tenant{ID: 3})
+
+	_, err := table.GetBy(ctx, "updated_at", "x")
+	if !errors.Is(err, errQueryCaptured) {
+		t.Fatalf("Expected captured query error, actual: %v", err)
+	}
+
+	expected := `SELECT "id", "title", "updated_at" FROM "items" WHERE "updated_at" = $1 AND "title" = $2`
+	if db.query != expected {
+		t.Errorf("Wrong query,\nexpected: %v\nactual:   %v", expected, db.query)
+	}
+	if !reflect.DeepEqual(db.args, []interface{}{"x", 3}) {
+		t.Errorf("Wrong args: %v", db.args)
+	}
+
+	table.Delete(context.Background(), 7)
+	if expected := `DELETE FROM "items" WHERE "id" = $1`; db.query != expected {
+		t.Errorf("Wrong unscoped query,\nexpected: %v\nactual:   %v", expected, db.query)
+	}
+}
+
+func TestTableRejectsUnknownColumns(t *testing.T) {
+	table := NewTable[tableTestItem](&capturingDB{}, "items", "id")
+	ctx := context.Background()
+
+	if _, err := table.Create(ctx, Values{"title; DROP TABLE items": "x"}); err == nil {
+		t.Error("Expected unknown column in values to be rejected")
+	}
+	if _, err := table.List(ctx, ListOptions{Filter: Filter{"secret": "x"}}); err == nil {
+		t.Error("Expected unknown column in filter to be rejected")
+	}
+	if _, err := table.List(ctx, ListOptions{OrderBy: "password"}); err == nil {
+		t.Error("Expected unknown order column to be rejected")
+	}
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+)
+
+// tenantID is the organization new rows created with ctx belong to, matching
+// the column defaults of the Postgres tables. It is used by the in-memory
+// repositories.
+func tenantID(ctx context.Context) int {
+	if current, ok := tenant.FromContext(ctx); ok {
+		return current.ID
+	}
+	return models.DefaultOrganizationID
+}
+
+// inTenant reports whether a row of organizationID is visible with ctx.
+func inTenant(ctx context.Context, organizationID int) bool {
+	current, ok := tenant.FromContext(ctx)
+	return !ok || current.ID == organizationID
+}
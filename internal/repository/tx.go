@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TxManager runs fn inside a transaction. Repositories called with the ctx
+// passed to fn take part in that transaction.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+type PostgresTxManager struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresTxManager(db *pgxpool.Pool) *PostgresTxManager {
+	return &PostgresTxManager{
+		db: db,
+	}
+}
+
+func (m *PostgresTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The row-level security policies read the tenant from app.tenant_id.
+	if current, ok := tenant.FromContext(ctx); ok {
+		if _, err := tx.Exec(ctx, `SELECT set_config('app.tenant_id', $1, TRUE)`, strconv.Itoa(current.ID)); err != nil {
+			return fmt.Errorf("failed to set tenant: %w", err)
+		}
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// NoopTxManager runs fn directly. It is used with the in-memory repositories,
+// which have no transactions.
+type NoopTxManager struct{}
+
+func (NoopTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// conn returns the transaction stored in ctx by a TxManager, or db otherwise.
+func conn(ctx context.Context, db DBTX) DBTX {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// inTx reports whether ctx carries a transaction of a TxManager.
+func inTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return ok
+}
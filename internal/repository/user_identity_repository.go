@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UserIdentityRepository interface {
+	Create(ctx context.Context, identity *models.UserIdentity) (*models.UserIdentity, error)
+	GetBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	ListByUser(ctx context.Context, userID int) ([]*models.UserIdentity, error)
+	// Delete removes an identity of userID.
+	Delete(ctx context.Context, id int64, userID int) error
+	TouchLastLogin(ctx context.Context, id int64) error
+}
+
+type PostgresUserIdentityRepository struct {
+	db         *pgxpool.Pool
+	identities *Table[models.UserIdentity]
+}
+
+func NewPostgresUserIdentityRepository(db *pgxpool.Pool) *PostgresUserIdentityRepository {
+	return &PostgresUserIdentityRepository{
+		db:         db,
+		identities: NewTable[models.UserIdentity](db, "user_identities", "id").WithTenant("organization_id"),
+	}
+}
+
+func (r *PostgresUserIdentityRepository) Create(ctx context.Context, identity *models.UserIdentity) (*models.UserIdentity, error) {
+	return r.identities.Create(ctx, Values{
+		"user_id":  identity.UserID,
+		"provider": identity.Provider,
+		"subject":  identity.Subject,
+		"email":    identity.Email,
+	})
+}
+
+func (r *PostgresUserIdentityRepository) GetBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	where, args := r.identities.scoped(ctx, " WHERE provider = $1 AND subject = $2", []interface{}{provider, subject})
+	query := fmt.Sprintf(`SELECT %s FROM user_identities%s`, r.identities.selectList(), where)
+	return r.identities.one(ctx, query, args...)
+}
+
+func (r *PostgresUserIdentityRepository) ListByUser(ctx context.Context, userID int) ([]*models.UserIdentity, error) {
+	return r.identities.List(ctx, ListOptions{Filter: Filter{"user_id": userID}, OrderBy: "id"})
+}
+
+func (r *PostgresUserIdentityRepository) Delete(ctx context.Context, id int64, userID int) error {
+	where, args := r.identities.scoped(ctx, " WHERE id = $1 AND user_id = $2", []interface{}{id, userID})
+	tag, err := conn(ctx, r.db).Exec(ctx, `DELETE FROM user_identities`+where, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete identity: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresUserIdentityRepository) TouchLastLogin(ctx context.Context, id int64) error {
+	_, err := conn(ctx, r.db).Exec(ctx, `UPDATE user_identities SET last_login_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to update identity last login: %w", err)
+	}
+	return nil
+}
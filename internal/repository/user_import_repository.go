@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UserImportRepository interface {
+	// Create stores an import together with its file.
+	Create(ctx context.Context, userImport *models.UserImport, data []byte) (*models.UserImport, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.UserImport, error)
+	// Data returns the file of an import that has not finished.
+	Data(ctx context.Context, id uuid.UUID) ([]byte, error)
+	SetStatus(ctx context.Context, id uuid.UUID, status string) (*models.UserImport, error)
+	// Finish stores the outcome of an import and drops its file.
+	Finish(ctx context.Context, id uuid.UUID, status string, report *models.UserImportReport, failure string) (*models.UserImport, error)
+}
+
+type PostgresUserImportRepository struct {
+	db      *pgxpool.Pool
+	imports *Table[models.UserImport]
+}
+
+func NewPostgresUserImportRepository(db *pgxpool.Pool) *PostgresUserImportRepository {
+	return &PostgresUserImportRepository{
+		db:      db,
+		imports: NewTable[models.UserImport](db, "user_imports", "id").WithTenant("organization_id"),
+	}
+}
+
+func (r *PostgresUserImportRepository) Create(ctx context.Context, userImport *models.UserImport, data []byte) (*models.UserImport, error) {
+	// data has no field in models.UserImport, so Table.Create cannot write it.
+	query := fmt.Sprintf(`
+		INSERT INTO user_imports (organization_id, format, mode, dry_run, status, data, created_by, created_by_role)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING %s
+	`, r.imports.selectList())
+
+	created, err := r.imports.one(ctx, query, tenantID(ctx), userImport.Format, userImport.Mode, userImport.DryRun,
+		models.UserImportStatusPending, data, userImport.CreatedBy, userImport.CreatedByRole)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user import: %w", err)
+	}
+	return created, nil
+}
+
+func (r *PostgresUserImportRepository) Get(ctx context.Context, id uuid.UUID) (*models.UserImport, error) {
+	return r.imports.Get(ctx, id)
+}
+
+func (r *PostgresUserImportRepository) Data(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	where, args := r.imports.scoped(ctx, " WHERE id = $1 AND data IS NOT NULL", []interface{}{id})
+
+	var data []byte
+	if err := conn(ctx, r.db).QueryRow(ctx, `SELECT data FROM user_imports`+where, args...).Scan(&data); err != nil {
+		return nil, mapError(err)
+	}
+	return data, nil
+}
+
+func (r *PostgresUserImportRepository) SetStatus(ctx context.Context, id uuid.UUID, status string) (*models.UserImport, error) {
+	return r.imports.Update(ctx, id, Values{"status": status})
+}
+
+func (r *PostgresUserImportRepository) Finish(ctx context.Context, id uuid.UUID, status string, report *models.UserImportReport, failure string) (*models.UserImport, error) {
+	if report == nil {
+		report = &models.UserImportReport{}
+	}
+	rowErrors := report.Errors
+	if rowErrors == nil {
+		rowErrors = []models.UserImportRowError{}
+	}
+
+	where, args := r.imports.scoped(ctx, " WHERE id = $1", []interface{}{
+		id, status, report.Total, report.Created, report.Updated, report.Unchanged, report.Failed, rowErrors, failure,
+	})
+	query := fmt.Sprintf(`
+		UPDATE user_imports SET
+			status = $2, total_rows = $3, created_rows = $4, updated_rows = $5, unchanged_rows = $6,
+			failed_rows = $7, errors = $8, error = $9, data = NULL, finished_at = NOW(), updated_at = NOW()%s
+		RETURNING %s
+	`, where, r.imports.selectList())
+
+	finished, err := r.imports.one(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finish user import: %w", err)
+	}
+	return finished, nil
+}
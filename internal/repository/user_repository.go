@@ -4,9 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Romasmi/go-rest-api-template/internal/models"
 	"github.com/Romasmi/go-rest-api-template/internal/utils"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -14,281 +20,428 @@ import (
 var (
 	ErrNotFound = errors.New("record not found")
 	ErrConflict = errors.New("record already exists")
+	ErrInUse    = errors.New("record is still in use")
 )
 
-type UserRepository struct {
-	db *pgxpool.Pool
+type UserRepository interface {
+	Create(ctx context.Context, user *models.UserCreate) (*models.User, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByInternalID(ctx context.Context, id int) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	Update(ctx context.Context, id uuid.UUID, user *models.UserUpdate) (*models.User, error)
+	// RevokeTokens bumps the token version of the user, which invalidates all
+	// tokens issued so far.
+	RevokeTokens(ctx context.Context, id uuid.UUID) (*models.User, error)
+	// SetStatus sets the status of the user. suspendedUntil is stored as
+	// given, so it must be nil for any status but suspended.
+	SetStatus(ctx context.Context, id uuid.UUID, status string, suspendedUntil *time.Time) (*models.User, error)
+	// SetAvatar sets the storage key and URL of the avatar, or clears them
+	// when they are empty.
+	SetAvatar(ctx context.Context, id uuid.UUID, key, url string) (*models.User, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	List(ctx context.Context, filter models.UserFilter, limit, offset int) ([]*models.User, error)
+	Count(ctx context.Context, filter models.UserFilter) (int, error)
+	// Stream calls fn with every user in creation order without loading
+	// them all at once.
+	Stream(ctx context.Context, fn func(user *models.User) error) error
+	// Import writes rows in one transaction. The create mode adds them as
+	// new users. The upsert mode updates the users with the same email and
+	// adds the others. Rows that conflict with existing users are skipped
+	// and reported. With dryRun the result is what would have been written.
+	Import(ctx context.Context, mode string, rows []*models.UserImportRow, dryRun bool) (*UserImportResult, error)
 }
 
-func NewUserRepository(db *pgxpool.Pool) *UserRepository {
-	return &UserRepository{
-		db: db,
+// UserImportResult is what UserRepository.Import wrote.
+type UserImportResult struct {
+	Created   []*models.User
+	Updated   []UserChange
+	Unchanged int
+	Errors    []models.UserImportRowError
+}
+
+type UserChange struct {
+	Before *models.User
+	After  *models.User
+}
+
+type PostgresUserRepository struct {
+	users *Table[models.User]
+	tx    TxManager
+}
+
+func NewPostgresUserRepository(db *pgxpool.Pool) *PostgresUserRepository {
+	return &PostgresUserRepository{
+		users: NewTable[models.User](db, "users", "public_id").WithTenant("organization_id"),
+		tx:    NewPostgresTxManager(db),
 	}
 }
 
-func (r *UserRepository) Create(ctx context.Context, user *models.UserCreate) (*models.User, error) {
-	passwordHash, err := utils.HashPassword(user.Password)
+func (r *PostgresUserRepository) Create(ctx context.Context, user *models.UserCreate) (*models.User, error) {
+	passwordHash, err := hashPassword(user.Password, user.PasswordHash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %w", err)
+		return nil, err
 	}
 
 	if user.Role == "" {
 		user.Role = "user"
 	}
 
-	query := `
-		INSERT INTO users (username, email, password_hash, role, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		RETURNING id, username, email, password_hash, role, created_at, updated_at
-	`
+	return r.users.Create(ctx, Values{
+		"username":      user.Username,
+		"email":         user.Email,
+		"password_hash": passwordHash,
+		"role":          user.Role,
+	})
+}
 
-	var newUser models.User
-	err = r.db.QueryRow(ctx, query,
-		user.Username,
-		user.Email,
-		passwordHash,
-		user.Role,
-	).Scan(
-		&newUser.ID,
-		&newUser.Username,
-		&newUser.Email,
-		&newUser.PasswordHash,
-		&newUser.Role,
-		&newUser.CreatedAt,
-		&newUser.UpdatedAt,
-	)
+func (r *PostgresUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return r.users.Get(ctx, id)
+}
 
-	if err != nil {
-		if err.Error() == "ERROR: duplicate key value violates unique constraint \"users_username_key\" (SQLSTATE 23505)" ||
-			err.Error() == "ERROR: duplicate key value violates unique constraint \"users_email_key\" (SQLSTATE 23505)" {
-			return nil, ErrConflict
+func (r *PostgresUserRepository) GetByInternalID(ctx context.Context, id int) (*models.User, error) {
+	return r.users.GetBy(ctx, "id", id)
+}
+
+func (r *PostgresUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.users.GetBy(ctx, "username", username)
+}
+
+func (r *PostgresUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.users.GetBy(ctx, "email", email)
+}
+
+func (r *PostgresUserRepository) Update(ctx context.Context, id uuid.UUID, user *models.UserUpdate) (*models.User, error) {
+	values := Values{}
+	if user.Username != "" {
+		values["username"] = user.Username
+	}
+	if user.Email != "" {
+		values["email"] = user.Email
+	}
+	if user.Password != "" || user.PasswordHash != "" {
+		passwordHash, err := hashPassword(user.Password, user.PasswordHash)
+		if err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		values["password_hash"] = passwordHash
+	}
+	if user.Role != "" {
+		values["role"] = user.Role
+	}
+	if user.DisplayName != nil {
+		values["display_name"] = *user.DisplayName
+	}
+	if user.Locale != nil {
+		values["locale"] = *user.Locale
+	}
+	if user.Timezone != nil {
+		values["timezone"] = *user.Timezone
+	}
+	if user.Attributes != nil {
+		values["attributes"] = user.Attributes
 	}
 
-	return &newUser, nil
+	return r.users.Update(ctx, id, values)
 }
 
-func (r *UserRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
-	query := `
-		SELECT id, username, email, password_hash, role, created_at, updated_at
-		FROM users
-		WHERE id = $1
-	`
-
-	var user models.User
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.Role,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+func (r *PostgresUserRepository) RevokeTokens(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	where, args := r.users.scoped(ctx, " WHERE public_id = $1", []interface{}{id})
+	query := fmt.Sprintf(`
+		UPDATE users SET token_version = token_version + 1, updated_at = NOW()%s
+		RETURNING %s
+	`, where, r.users.selectList())
 
+	user, err := r.users.one(ctx, query, args...)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrNotFound
-		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, fmt.Errorf("failed to revoke tokens: %w", err)
 	}
+	return user, nil
+}
 
-	return &user, nil
+func (r *PostgresUserRepository) SetStatus(ctx context.Context, id uuid.UUID, status string, suspendedUntil *time.Time) (*models.User, error) {
+	return r.users.Update(ctx, id, Values{"status": status, "suspended_until": suspendedUntil})
 }
 
-func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
-	query := `
-		SELECT id, username, email, password_hash, role, created_at, updated_at
-		FROM users
-		WHERE username = $1
-	`
+func (r *PostgresUserRepository) SetAvatar(ctx context.Context, id uuid.UUID, key, url string) (*models.User, error) {
+	return r.users.Update(ctx, id, Values{"avatar_key": key, "avatar_url": url})
+}
 
-	var user models.User
-	err := r.db.QueryRow(ctx, query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.Role,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+func (r *PostgresUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.users.Delete(ctx, id)
+}
 
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrNotFound
-		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
+func (r *PostgresUserRepository) List(ctx context.Context, filter models.UserFilter, limit, offset int) ([]*models.User, error) {
+	where, args := userWhere(filter)
+	where, args = r.users.scoped(ctx, where, args)
+	args = append(args, limit, offset)
 
-	return &user, nil
+	query := fmt.Sprintf(`SELECT %s FROM users%s ORDER BY id LIMIT $%d OFFSET $%d`,
+		r.users.selectList(), where, len(args)-1, len(args))
+	return r.users.all(ctx, query, args...)
 }
 
-func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
-	query := `
-		SELECT id, username, email, password_hash, role, created_at, updated_at
-		FROM users
-		WHERE email = $1
-	`
+func (r *PostgresUserRepository) Count(ctx context.Context, filter models.UserFilter) (int, error) {
+	where, args := userWhere(filter)
+	where, args = r.users.scoped(ctx, where, args)
 
-	var user models.User
-	err := r.db.QueryRow(ctx, query, email).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.Role,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	var count int
+	if err := conn(ctx, r.users.db).QueryRow(ctx, `SELECT COUNT(*) FROM users`+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+func (r *PostgresUserRepository) Stream(ctx context.Context, fn func(user *models.User) error) error {
+	where, args := r.users.scoped(ctx, "", nil)
+	query := fmt.Sprintf(`SELECT %s FROM users%s ORDER BY id`, r.users.selectList(), where)
 
+	rows, err := conn(ctx, r.users.db).Query(ctx, query, args...)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrNotFound
+		return fmt.Errorf("failed to stream users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := pgx.RowToAddrOfStructByName[models.User](rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan user: %w", err)
+		}
+		if err := fn(user); err != nil {
+			return err
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	return &user, nil
+	return rows.Err()
 }
 
-func (r *UserRepository) Update(ctx context.Context, id int, user *models.UserUpdate) (*models.User, error) {
-	tx, err := r.db.Begin(ctx)
+func (r *PostgresUserRepository) Import(ctx context.Context, mode string, rows []*models.UserImportRow, dryRun bool) (*UserImportResult, error) {
+	var result *UserImportResult
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		tx := ctx.Value(txKey{}).(pgx.Tx)
+		if dryRun {
+			// The rows go through the same statements, which are then
+			// rolled back.
+			savepoint, err := tx.Begin(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to begin dry run: %w", err)
+			}
+			defer savepoint.Rollback(ctx)
+			tx = savepoint
+			ctx = context.WithValue(ctx, txKey{}, tx)
+		}
+
+		var err error
+		result, err = r.importRows(ctx, tx, mode, rows, dryRun)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, err
 	}
-	defer tx.Rollback(ctx)
+	return result, nil
+}
 
-	currentUser, err := r.GetByID(ctx, id)
+// importRows copies rows into a temporary table and writes users from it
+// with a few set-based statements. Passwords without a hash are hashed
+// unless it is a dry run.
+func (r *PostgresUserRepository) importRows(ctx context.Context, tx pgx.Tx, mode string, rows []*models.UserImportRow, dryRun bool) (*UserImportResult, error) {
+	_, err := tx.Exec(ctx, `
+		CREATE TEMP TABLE user_import_rows (
+			line INTEGER PRIMARY KEY,
+			username TEXT NOT NULL,
+			email TEXT NOT NULL,
+			has_password BOOLEAN NOT NULL,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL
+		) ON COMMIT DROP
+	`)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create import table: %w", err)
 	}
 
-	if user.Username != "" {
-		currentUser.Username = user.Username
+	columns := []string{"line", "username", "email", "has_password", "password_hash", "role"}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"user_import_rows"}, columns, pgx.CopyFromSlice(len(rows), func(i int) ([]interface{}, error) {
+		row := rows[i]
+		hasPassword := row.Password != "" || row.PasswordHash != ""
+		passwordHash := row.PasswordHash
+		if passwordHash == "" && row.Password != "" && !dryRun {
+			var err error
+			if passwordHash, err = hashPassword(row.Password, ""); err != nil {
+				return nil, err
+			}
+		}
+		return []interface{}{row.Row, row.Username, row.Email, hasPassword, passwordHash, row.Role}, nil
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy import rows: %w", err)
 	}
-	if user.Email != "" {
-		currentUser.Email = user.Email
+
+	organizationID := tenantID(ctx)
+	result := &UserImportResult{}
+	if result.Errors, err = importConflicts(ctx, tx, mode, organizationID); err != nil {
+		return nil, err
 	}
-	if user.Password != "" {
-		passwordHash, err := utils.HashPassword(user.Password)
-		if err != nil {
-			return nil, fmt.Errorf("failed to hash password: %w", err)
+	if len(result.Errors) > 0 {
+		lines := make([]int, len(result.Errors))
+		for i, rowError := range result.Errors {
+			lines[i] = rowError.Row
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM user_import_rows WHERE line = ANY($1)`, lines); err != nil {
+			return nil, fmt.Errorf("failed to skip conflicting rows: %w", err)
 		}
-		currentUser.PasswordHash = passwordHash
-	}
-	if user.Role != "" {
-		currentUser.Role = user.Role
 	}
 
-	query := `
-		UPDATE users
-		SET username = $1, email = $2, password_hash = $3, role = $4, updated_at = NOW()
-		WHERE id = $5
-		RETURNING id, username, email, password_hash, role, created_at, updated_at
-	`
-
-	var updatedUser models.User
-	err = tx.QueryRow(ctx, query,
-		currentUser.Username,
-		currentUser.Email,
-		currentUser.PasswordHash,
-		currentUser.Role,
-		id,
-	).Scan(
-		&updatedUser.ID,
-		&updatedUser.Username,
-		&updatedUser.Email,
-		&updatedUser.PasswordHash,
-		&updatedUser.Role,
-		&updatedUser.CreatedAt,
-		&updatedUser.UpdatedAt,
-	)
-
-	if err != nil {
-		if err.Error() == "ERROR: duplicate key value violates unique constraint \"users_username_key\" (SQLSTATE 23505)" ||
-			err.Error() == "ERROR: duplicate key value violates unique constraint \"users_email_key\" (SQLSTATE 23505)" {
-			return nil, ErrConflict
+	if mode == models.UserImportModeUpsert {
+		if result.Updated, result.Unchanged, err = r.importUpdates(ctx, tx, organizationID); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	query := fmt.Sprintf(`
+		INSERT INTO users (organization_id, username, email, password_hash, role, created_at, updated_at)
+		SELECT $1, r.username, r.email, r.password_hash, COALESCE(NULLIF(r.role, ''), 'user'), NOW(), NOW()
+		FROM user_import_rows r
+		WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.organization_id = $1 AND u.email = r.email)
+		ORDER BY r.line
+		RETURNING %s
+	`, r.users.selectList())
+	if result.Created, err = r.users.all(ctx, query, organizationID); err != nil {
+		return nil, mapError(err)
 	}
 
-	return &updatedUser, nil
+	if _, err := tx.Exec(ctx, `DROP TABLE user_import_rows`); err != nil {
+		return nil, fmt.Errorf("failed to drop import table: %w", err)
+	}
+	return result, nil
 }
 
-func (r *UserRepository) Delete(ctx context.Context, id int) error {
+// importConflicts finds the rows that cannot be written given the users that
+// already exist.
+func importConflicts(ctx context.Context, tx pgx.Tx, mode string, organizationID int) ([]models.UserImportRowError, error) {
 	query := `
-		DELETE FROM users
-		WHERE id = $1
+		SELECT r.line, 'username', 'already exists' FROM user_import_rows r
+		JOIN users u ON u.organization_id = $1 AND u.username = r.username
+		UNION ALL
+		SELECT r.line, 'email', 'already exists' FROM user_import_rows r
+		JOIN users u ON u.organization_id = $1 AND u.email = r.email
+		ORDER BY 1, 2
 	`
+	if mode == models.UserImportModeUpsert {
+		query = `
+			SELECT r.line, 'username', 'already exists' FROM user_import_rows r
+			JOIN users u ON u.organization_id = $1 AND u.username = r.username AND u.email <> r.email
+			UNION ALL
+			SELECT r.line, 'password', 'is required for new users' FROM user_import_rows r
+			WHERE NOT r.has_password
+				AND NOT EXISTS (SELECT 1 FROM users u WHERE u.organization_id = $1 AND u.email = r.email)
+			ORDER BY 1, 2
+		`
+	}
 
-	result, err := r.db.Exec(ctx, query, id)
+	rows, err := tx.Query(ctx, query, organizationID)
 	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		return nil, fmt.Errorf("failed to check import rows: %w", err)
 	}
-
-	if result.RowsAffected() == 0 {
-		return ErrNotFound
+	conflicts, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.UserImportRowError])
+	if err != nil {
+		return nil, fmt.Errorf("failed to check import rows: %w", err)
 	}
-
-	return nil
+	return conflicts, nil
 }
 
-func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*models.User, error) {
-	query := `
-		SELECT id, username, email, password_hash, role, created_at, updated_at
-		FROM users
-		ORDER BY id
-		LIMIT $1 OFFSET $2
-	`
+// importUpdates updates the users matched by email that differ from their
+// row, and counts the ones that do not.
+func (r *PostgresUserRepository) importUpdates(ctx context.Context, tx pgx.Tx, organizationID int) ([]UserChange, int, error) {
+	const changed = `u.organization_id = $1 AND u.email = r.email
+		AND (u.username <> r.username OR (r.role <> '' AND u.role <> r.role) OR r.has_password)`
 
-	rows, err := r.db.Query(ctx, query, limit, offset)
+	var matched int
+	err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM user_import_rows r JOIN users u ON u.organization_id = $1 AND u.email = r.email
+	`, organizationID).Scan(&matched)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list users: %w", err)
+		return nil, 0, fmt.Errorf("failed to match import rows: %w", err)
 	}
-	defer rows.Close()
 
-	var users []*models.User
-	for rows.Next() {
-		var user models.User
-		err := rows.Scan(
-			&user.ID,
-			&user.Username,
-			&user.Email,
-			&user.PasswordHash,
-			&user.Role,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan user: %w", err)
+	before, err := r.users.all(ctx, fmt.Sprintf(`
+		SELECT %s FROM users u JOIN user_import_rows r ON %s ORDER BY r.line FOR UPDATE OF u
+	`, r.users.selectListOf("u"), changed), organizationID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	after, err := r.users.all(ctx, fmt.Sprintf(`
+		UPDATE users u SET
+			username = r.username,
+			role = COALESCE(NULLIF(r.role, ''), u.role),
+			password_hash = COALESCE(NULLIF(r.password_hash, ''), u.password_hash),
+			updated_at = NOW()
+		FROM user_import_rows r WHERE %s
+		RETURNING %s
+	`, changed, r.users.selectListOf("u")), organizationID)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	updated := make(map[int]*models.User, len(after))
+	for _, user := range after {
+		updated[user.InternalID] = user
+	}
+	changes := make([]UserChange, 0, len(before))
+	for _, user := range before {
+		changes = append(changes, UserChange{Before: user, After: updated[user.InternalID]})
+	}
+	return changes, matched - len(changes), nil
+}
+
+// userWhere matches attributes by containment, which the GIN index on
+// attributes serves.
+func userWhere(filter models.UserFilter) (string, []interface{}) {
+	names := make([]string, 0, len(filter.Attributes))
+	for name := range filter.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var conditions []string
+	var args []interface{}
+	for _, name := range names {
+		var alternatives []string
+		for _, value := range attributeValues(filter.Attributes[name]) {
+			args = append(args, map[string]interface{}{name: value})
+			alternatives = append(alternatives, fmt.Sprintf("attributes @> $%d", len(args)))
 		}
-		users = append(users, &user)
+		conditions = append(conditions, "("+strings.Join(alternatives, " OR ")+")")
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating users: %w", err)
+	if len(conditions) == 0 {
+		return "", nil
 	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
 
-	return users, nil
+// attributeValues are the JSON values a filter value matches: the string
+// itself, and the number or boolean it spells.
+func attributeValues(value string) []interface{} {
+	values := []interface{}{value}
+	if value == "true" || value == "false" {
+		values = append(values, value == "true")
+	} else if number, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(number, 0) && !math.IsNaN(number) {
+		values = append(values, number)
+	}
+	return values
 }
 
-func (r *UserRepository) Count(ctx context.Context) (int, error) {
-	query := `
-		SELECT COUNT(*)
-		FROM users
-	`
+// hashPassword returns passwordHash, or hashes password with the default
+// cost for callers that did not hash it themselves.
+func hashPassword(password, passwordHash string) (string, error) {
+	if passwordHash != "" {
+		return passwordHash, nil
+	}
 
-	var count int
-	err := r.db.QueryRow(ctx, query).Scan(&count)
+	passwordHash, err := utils.HashPassword(password)
 	if err != nil {
-		return 0, fmt.Errorf("failed to count users: %w", err)
+		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
-
-	return count, nil
+	return passwordHash, nil
 }
@@ -0,0 +1,414 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+	"github.com/Romasmi/go-rest-api-template/internal/testutil"
+	"github.com/Romasmi/go-rest-api-template/internal/utils"
+	"github.com/google/uuid"
+)
+
+func TestMemoryUserRepository(t *testing.T) {
+	runUserRepositoryContract(t, func(t *testing.T) repository.UserRepository {
+		return repository.NewMemoryUserRepository()
+	})
+}
+
+func TestCachedUserRepository(t *testing.T) {
+	runUserRepositoryContract(t, func(t *testing.T) repository.UserRepository {
+		return repository.NewCachedUserRepository(repository.NewMemoryUserRepository(), 10, time.Minute)
+	})
+}
+
+func TestPostgresUserRepository(t *testing.T) {
+	if os.Getenv(testutil.DatabaseURLEnv) == "" {
+		t.Skipf("%s is not set", testutil.DatabaseURLEnv)
+	}
+
+	runUserRepositoryContract(t, func(t *testing.T) repository.UserRepository {
+		db := testutil.NewDatabase(t)
+		if _, err := db.DB.Exec(context.Background(), `INSERT INTO organizations (slug, name) VALUES ('acme', 'Acme')`); err != nil {
+			t.Fatalf("Error while creating organization: %v", err)
+		}
+		return repository.NewPostgresUserRepository(db.DB)
+	})
+}
+
+func runUserRepositoryContract(t *testing.T, newRepo func(t *testing.T) repository.UserRepository) {
+	ctx := context.Background()
+
+	t.Run("create and get", func(t *testing.T) {
+		repo := newRepo(t)
+
+		created, err := repo.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+		if err != nil {
+			t.Fatalf("Error while creating user: %v", err)
+		}
+		if created.ID.Version() != 7 {
+			t.Errorf("Expected a UUIDv7 public ID, actual: %v", created.ID)
+		}
+		if created.InternalID == 0 {
+			t.Error("Expected created user to have an internal ID")
+		}
+		if created.Role != "user" {
+			t.Errorf("Wrong default role, expected: %v, actual: %v", "user", created.Role)
+		}
+		if !utils.CheckPassword("password1", created.PasswordHash) {
+			t.Error("Expected stored password hash to match the password")
+		}
+
+		byID, err := repo.GetByID(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Error while getting user by ID: %v", err)
+		}
+		if byID.Username != "alice" {
+			t.Errorf("Wrong username, expected: %v, actual: %v", "alice", byID.Username)
+		}
+
+		byInternalID, err := repo.GetByInternalID(ctx, created.InternalID)
+		if err != nil {
+			t.Fatalf("Error while getting user by internal ID: %v", err)
+		}
+		if byInternalID.ID != created.ID {
+			t.Errorf("Wrong user by internal ID, expected: %v, actual: %v", created.ID, byInternalID.ID)
+		}
+
+		if _, err := repo.GetByUsername(ctx, "alice"); err != nil {
+			t.Errorf("Error while getting user by username: %v", err)
+		}
+		if _, err := repo.GetByEmail(ctx, "alice@example.com"); err != nil {
+			t.Errorf("Error while getting user by email: %v", err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		repo := newRepo(t)
+
+		missing := uuid.New()
+
+		if _, err := repo.GetByID(ctx, missing); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for GetByID, actual: %v", err)
+		}
+		if _, err := repo.GetByInternalID(ctx, 12345); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for GetByInternalID, actual: %v", err)
+		}
+		if _, err := repo.GetByUsername(ctx, "nobody"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for GetByUsername, actual: %v", err)
+		}
+		if _, err := repo.GetByEmail(ctx, "nobody@example.com"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for GetByEmail, actual: %v", err)
+		}
+		if _, err := repo.Update(ctx, missing, &models.UserUpdate{Username: "ghost"}); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for Update, actual: %v", err)
+		}
+		if err := repo.Delete(ctx, missing); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for Delete, actual: %v", err)
+		}
+	})
+
+	t.Run("uniqueness", func(t *testing.T) {
+		repo := newRepo(t)
+		mustCreateUser(t, repo, "alice")
+		bob := mustCreateUser(t, repo, "bob")
+
+		_, err := repo.Create(ctx, &models.UserCreate{Username: "alice", Email: "other@example.com", Password: "password1"})
+		if !errors.Is(err, repository.ErrConflict) {
+			t.Errorf("Expected ErrConflict for duplicate username, actual: %v", err)
+		}
+
+		_, err = repo.Create(ctx, &models.UserCreate{Username: "other", Email: "alice@example.com", Password: "password1"})
+		if !errors.Is(err, repository.ErrConflict) {
+			t.Errorf("Expected ErrConflict for duplicate email, actual: %v", err)
+		}
+
+		_, err = repo.Update(ctx, bob.ID, &models.UserUpdate{Email: "alice@example.com"})
+		if !errors.Is(err, repository.ErrConflict) {
+			t.Errorf("Expected ErrConflict when updating to a taken email, actual: %v", err)
+		}
+
+		if _, err := repo.Update(ctx, bob.ID, &models.UserUpdate{Username: "bob"}); err != nil {
+			t.Errorf("Expected keeping own username to succeed, actual: %v", err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := mustCreateUser(t, repo, "alice")
+
+		updated, err := repo.Update(ctx, alice.ID, &models.UserUpdate{Role: "admin", Password: "new-password"})
+		if err != nil {
+			t.Fatalf("Error while updating user: %v", err)
+		}
+		if updated.Username != "alice" || updated.Email != alice.Email {
+			t.Errorf("Expected untouched fields to be kept, actual: %+v", updated)
+		}
+		if updated.Role != "admin" {
+			t.Errorf("Wrong role, expected: %v, actual: %v", "admin", updated.Role)
+		}
+		if !utils.CheckPassword("new-password", updated.PasswordHash) {
+			t.Error("Expected password to be changed")
+		}
+	})
+
+	t.Run("revoke tokens", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := mustCreateUser(t, repo, "alice")
+
+		revoked, err := repo.RevokeTokens(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("Error while revoking tokens: %v", err)
+		}
+		if revoked.TokenVersion != alice.TokenVersion+1 {
+			t.Errorf("Wrong token version, expected: %v, actual: %v", alice.TokenVersion+1, revoked.TokenVersion)
+		}
+		if _, err := repo.RevokeTokens(ctx, uuid.Must(uuid.NewV7())); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for missing user, actual: %v", err)
+		}
+	})
+
+	t.Run("set status", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := mustCreateUser(t, repo, "alice")
+		if alice.Status != models.UserStatusActive {
+			t.Errorf("Wrong default status, expected: %v, actual: %v", models.UserStatusActive, alice.Status)
+		}
+
+		until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		suspended, err := repo.SetStatus(ctx, alice.ID, models.UserStatusSuspended, &until)
+		if err != nil {
+			t.Fatalf("Error while setting status: %v", err)
+		}
+		if suspended.Status != models.UserStatusSuspended || suspended.SuspendedUntil == nil || !suspended.SuspendedUntil.Equal(until) {
+			t.Errorf("Wrong status, expected: %v until %v, actual: %v until %v", models.UserStatusSuspended, until, suspended.Status, suspended.SuspendedUntil)
+		}
+
+		if _, err := repo.SetStatus(ctx, alice.ID, models.UserStatusDisabled, nil); err != nil {
+			t.Fatalf("Error while setting status: %v", err)
+		}
+		if found, _ := repo.GetByID(ctx, alice.ID); found.Status != models.UserStatusDisabled || found.SuspendedUntil != nil {
+			t.Errorf("Wrong status, expected: %v, actual: %v until %v", models.UserStatusDisabled, found.Status, found.SuspendedUntil)
+		}
+	})
+
+	t.Run("profile and attributes", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := mustCreateUser(t, repo, "alice")
+		bob := mustCreateUser(t, repo, "bob")
+		mustCreateUser(t, repo, "carol")
+
+		displayName, timezone := "Alice A.", "Europe/Berlin"
+		updated, err := repo.Update(ctx, alice.ID, &models.UserUpdate{
+			UserProfile: models.UserProfile{DisplayName: &displayName, Timezone: &timezone},
+			Attributes:  map[string]interface{}{"department": "sales", "level": 3, "remote": true},
+		})
+		if err != nil {
+			t.Fatalf("Error while updating profile: %v", err)
+		}
+		if updated.DisplayName != displayName || updated.Timezone != timezone || updated.Locale != "" || updated.Attributes["department"] != "sales" {
+			t.Errorf("Wrong profile: %+v", updated)
+		}
+		if _, err := repo.Update(ctx, bob.ID, &models.UserUpdate{Attributes: map[string]interface{}{"department": "sales", "level": "3"}}); err != nil {
+			t.Fatalf("Error while updating attributes: %v", err)
+		}
+
+		for _, tt := range []struct {
+			filter   map[string]string
+			expected []string
+		}{
+			{filter: map[string]string{"department": "sales"}, expected: []string{"alice", "bob"}},
+			{filter: map[string]string{"department": "sales", "level": "3"}, expected: []string{"alice", "bob"}},
+			{filter: map[string]string{"remote": "true"}, expected: []string{"alice"}},
+			{filter: map[string]string{"department": "support"}, expected: nil},
+		} {
+			filter := models.UserFilter{Attributes: tt.filter}
+			users, err := repo.List(ctx, filter, 10, 0)
+			if err != nil {
+				t.Fatalf("Error while listing users: %v", err)
+			}
+			count, _ := repo.Count(ctx, filter)
+			if fmt.Sprint(usernames(users)) != fmt.Sprint(tt.expected) || count != len(tt.expected) {
+				t.Errorf("Wrong users for %v, expected: %v, actual: %v (count %v)", tt.filter, tt.expected, usernames(users), count)
+			}
+		}
+
+		// Fields left out are kept, and empty ones clear the profile.
+		empty := ""
+		updated, err = repo.Update(ctx, alice.ID, &models.UserUpdate{UserProfile: models.UserProfile{DisplayName: &empty}})
+		if err != nil {
+			t.Fatalf("Error while updating profile: %v", err)
+		}
+		if updated.DisplayName != "" || updated.Timezone != timezone || len(updated.Attributes) != 3 {
+			t.Errorf("Wrong profile after clearing the display name: %+v", updated)
+		}
+
+		withAvatar, err := repo.SetAvatar(ctx, alice.ID, "avatars/a.png", "/files/avatars/a.png")
+		if err != nil {
+			t.Fatalf("Error while setting avatar: %v", err)
+		}
+		if withAvatar.AvatarKey != "avatars/a.png" || withAvatar.AvatarURL != "/files/avatars/a.png" {
+			t.Errorf("Wrong avatar: %v, %v", withAvatar.AvatarKey, withAvatar.AvatarURL)
+		}
+		if cleared, _ := repo.SetAvatar(ctx, alice.ID, "", ""); cleared.AvatarKey != "" || cleared.AvatarURL != "" {
+			t.Errorf("Expected the avatar to be cleared, actual: %v", cleared.AvatarURL)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := mustCreateUser(t, repo, "alice")
+
+		if err := repo.Delete(ctx, alice.ID); err != nil {
+			t.Fatalf("Error while deleting user: %v", err)
+		}
+		if _, err := repo.GetByID(ctx, alice.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound after delete, actual: %v", err)
+		}
+	})
+
+	t.Run("list and count", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, username := range []string{"alice", "bob", "carol"} {
+			mustCreateUser(t, repo, username)
+		}
+
+		count, err := repo.Count(ctx, models.UserFilter{})
+		if err != nil {
+			t.Fatalf("Error while counting users: %v", err)
+		}
+		if count != 3 {
+			t.Errorf("Wrong count, expected: %v, actual: %v", 3, count)
+		}
+
+		page, err := repo.List(ctx, models.UserFilter{}, 2, 1)
+		if err != nil {
+			t.Fatalf("Error while listing users: %v", err)
+		}
+		if len(page) != 2 || page[0].Username != "bob" || page[1].Username != "carol" {
+			t.Errorf("Wrong page, expected: [bob carol], actual: %v", usernames(page))
+		}
+	})
+
+	t.Run("import and stream", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := mustCreateUser(t, repo, "alice")
+
+		rows := []*models.UserImportRow{
+			{Row: 2, Username: "alice", Email: "alice2@example.com", Password: "password1"},
+			{Row: 3, Username: "bob", Email: "bob@example.com", Password: "password1"},
+		}
+		result, err := repo.Import(ctx, models.UserImportModeCreate, rows, true)
+		if err != nil {
+			t.Fatalf("Error while importing users: %v", err)
+		}
+		if len(result.Created) != 1 || len(result.Errors) != 1 || result.Errors[0].Row != 2 || result.Errors[0].Field != "username" {
+			t.Errorf("Wrong dry run, expected bob created and row 2 failed, actual: %v created, errors %v", len(result.Created), result.Errors)
+		}
+		if count, _ := repo.Count(ctx, models.UserFilter{}); count != 1 {
+			t.Errorf("Expected a dry run to write nothing, actual count: %v", count)
+		}
+
+		rows = []*models.UserImportRow{
+			{Row: 2, Username: "alice", Email: "alice@example.com", Role: "admin"},
+			{Row: 3, Username: "bob", Email: "bob@example.com", Password: "password1"},
+			{Row: 4, Username: "carol", Email: "carol@example.com"},
+		}
+		result, err = repo.Import(ctx, models.UserImportModeUpsert, rows, false)
+		if err != nil {
+			t.Fatalf("Error while importing users: %v", err)
+		}
+		if len(result.Created) != 1 || result.Created[0].Username != "bob" || result.Created[0].Role != "user" {
+			t.Errorf("Wrong created users, expected: [bob], actual: %v", usernames(result.Created))
+		}
+		if len(result.Updated) != 1 || result.Updated[0].Before.Role != "user" || result.Updated[0].After.Role != "admin" {
+			t.Errorf("Wrong updated users, expected alice promoted, actual: %v", result.Updated)
+		}
+		if len(result.Errors) != 1 || result.Errors[0].Row != 4 || result.Errors[0].Field != "password" {
+			t.Errorf("Wrong errors, expected a missing password on row 4, actual: %v", result.Errors)
+		}
+		if updated, _ := repo.GetByID(ctx, alice.ID); updated.Role != "admin" || updated.PasswordHash != alice.PasswordHash {
+			t.Errorf("Wrong imported alice, expected the admin role and the same password, actual: %v", updated.Role)
+		}
+
+		var streamed []*models.User
+		err = repo.Stream(ctx, func(user *models.User) error {
+			streamed = append(streamed, user)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Error while streaming users: %v", err)
+		}
+		if len(streamed) != 2 || streamed[0].Username != "alice" || streamed[1].Username != "bob" {
+			t.Errorf("Wrong streamed users, expected: [alice bob], actual: %v", usernames(streamed))
+		}
+	})
+
+	// The factory must provide organization 2 next to the default one.
+	t.Run("tenant scoping", func(t *testing.T) {
+		repo := newRepo(t)
+		defaultCtx := tenant.NewContext(ctx, tenant.Tenant{ID: models.DefaultOrganizationID})
+		acmeCtx := tenant.NewContext(ctx, tenant.Tenant{ID: 2})
+
+		alice := mustCreateUser(t, repo, "alice")
+		acmeAlice, err := repo.Create(acmeCtx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+		if err != nil {
+			t.Fatalf("Expected usernames to be unique per organization, actual: %v", err)
+		}
+		if alice.OrganizationID != models.DefaultOrganizationID || acmeAlice.OrganizationID != 2 {
+			t.Errorf("Wrong organizations, expected: %v and %v, actual: %v and %v",
+				models.DefaultOrganizationID, 2, alice.OrganizationID, acmeAlice.OrganizationID)
+		}
+
+		if found, err := repo.GetByUsername(acmeCtx, "alice"); err != nil || found.ID != acmeAlice.ID {
+			t.Errorf("Expected acme's alice, actual: %v, %v", found, err)
+		}
+		if _, err := repo.GetByID(acmeCtx, alice.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a user of another organization, actual: %v", err)
+		}
+		if _, err := repo.Update(acmeCtx, alice.ID, &models.UserUpdate{Role: "admin"}); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound when updating a user of another organization, actual: %v", err)
+		}
+		if err := repo.Delete(acmeCtx, alice.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound when deleting a user of another organization, actual: %v", err)
+		}
+
+		if count, _ := repo.Count(defaultCtx, models.UserFilter{}); count != 1 {
+			t.Errorf("Wrong scoped count, expected: %v, actual: %v", 1, count)
+		}
+		if count, _ := repo.Count(ctx, models.UserFilter{}); count != 2 {
+			t.Errorf("Wrong unscoped count, expected: %v, actual: %v", 2, count)
+		}
+		if users, _ := repo.List(acmeCtx, models.UserFilter{}, 10, 0); len(users) != 1 || users[0].ID != acmeAlice.ID {
+			t.Errorf("Wrong scoped list: %v", usernames(users))
+		}
+	})
+}
+
+func mustCreateUser(t *testing.T, repo repository.UserRepository, username string) *models.User {
+	t.Helper()
+
+	user, err := repo.Create(context.Background(), &models.UserCreate{
+		Username: username,
+		Email:    username + "@example.com",
+		Password: "password1",
+	})
+	if err != nil {
+		t.Fatalf("Error while creating user %v: %v", username, err)
+	}
+	return user
+}
+
+func usernames(users []*models.User) []string {
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	return names
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserStatusChangeRepository keeps the status history of users.
+type UserStatusChangeRepository interface {
+	Create(ctx context.Context, change *models.UserStatusChange) (*models.UserStatusChange, error)
+	// ListByUser returns the changes of userID, newest first.
+	ListByUser(ctx context.Context, userID int) ([]*models.UserStatusChange, error)
+}
+
+type PostgresUserStatusChangeRepository struct {
+	changes *Table[models.UserStatusChange]
+}
+
+func NewPostgresUserStatusChangeRepository(db *pgxpool.Pool) *PostgresUserStatusChangeRepository {
+	return &PostgresUserStatusChangeRepository{
+		changes: NewTable[models.UserStatusChange](db, "user_status_changes", "id").WithTenant("organization_id"),
+	}
+}
+
+func (r *PostgresUserStatusChangeRepository) Create(ctx context.Context, change *models.UserStatusChange) (*models.UserStatusChange, error) {
+	return r.changes.Create(ctx, Values{
+		"user_id":         change.UserID,
+		"status":          change.Status,
+		"suspended_until": change.SuspendedUntil,
+		"reason":          change.Reason,
+		"changed_by":      change.ChangedBy,
+	})
+}
+
+func (r *PostgresUserStatusChangeRepository) ListByUser(ctx context.Context, userID int) ([]*models.UserStatusChange, error) {
+	return r.changes.List(ctx, ListOptions{Filter: Filter{"user_id": userID}, OrderBy: "id", Desc: true})
+}
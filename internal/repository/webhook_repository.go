@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *models.WebhookSubscriptionCreate) (*models.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	// EnqueueDelivery stores a pending delivery. A delivery of the same event
+	// to the same subscription is only stored once.
+	EnqueueDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter, limit, offset int) ([]*models.WebhookDelivery, error)
+	CountDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) (int, error)
+	// ClaimDueDeliveries returns pending deliveries that are due and moves
+	// their next attempt lease into the future, so other workers skip them
+	// while they are sent.
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	// UpdateDelivery stores the status, attempts, next attempt, last response
+	// and delivered at time of delivery.
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	// ReplayDelivery makes a delivery that is not pending pending again, due
+	// right away and with no attempts. It returns ErrNotFound when there is no
+	// such delivery.
+	ReplayDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error)
+}
+
+type PostgresWebhookRepository struct {
+	db            *pgxpool.Pool
+	subscriptions *Table[models.WebhookSubscription]
+	deliveries    *Table[models.WebhookDelivery]
+}
+
+func NewPostgresWebhookRepository(db *pgxpool.Pool) *PostgresWebhookRepository {
+	return &PostgresWebhookRepository{
+		db:            db,
+		subscriptions: NewTable[models.WebhookSubscription](db, "webhook_subscriptions", "id"),
+		deliveries:    NewTable[models.WebhookDelivery](db, "webhook_deliveries", "id"),
+	}
+}
+
+func (r *PostgresWebhookRepository) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscriptionCreate) (*models.WebhookSubscription, error) {
+	return r.subscriptions.Create(ctx, Values{
+		"url":         subscription.URL,
+		"event_types": subscription.EventTypes,
+		"secret":      subscription.Secret,
+		"active":      true,
+	})
+}
+
+func (r *PostgresWebhookRepository) GetSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	return r.subscriptions.Get(ctx, id)
+}
+
+func (r *PostgresWebhookRepository) ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	return r.subscriptions.List(ctx, ListOptions{})
+}
+
+func (r *PostgresWebhookRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	return r.subscriptions.Delete(ctx, id)
+}
+
+func (r *PostgresWebhookRepository) EnqueueDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`
+
+	_, err := conn(ctx, r.db).Exec(ctx, query,
+		delivery.SubscriptionID, delivery.EventID, delivery.EventType, delivery.Payload, models.WebhookDeliveryPending)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook delivery: %w", mapError(err))
+	}
+	return nil
+}
+
+func (r *PostgresWebhookRepository) GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	return r.deliveries.Get(ctx, id)
+}
+
+func (r *PostgresWebhookRepository) ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter, limit, offset int) ([]*models.WebhookDelivery, error) {
+	return r.deliveries.List(ctx, ListOptions{
+		Limit:  limit,
+		Offset: offset,
+		Filter: deliveryFilter(filter),
+		Desc:   true,
+	})
+}
+
+func (r *PostgresWebhookRepository) CountDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) (int, error) {
+	return r.deliveries.Count(ctx, deliveryFilter(filter))
+}
+
+func (r *PostgresWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	query := fmt.Sprintf(`
+		UPDATE webhook_deliveries
+		SET next_attempt_at = NOW() + $3 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING %s
+	`, r.deliveries.selectList())
+
+	rows, err := conn(ctx, r.db).Query(ctx, query, models.WebhookDeliveryPending, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	deliveries, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.WebhookDelivery])
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+func (r *PostgresWebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	_, err := r.deliveries.Update(ctx, delivery.ID, Values{
+		"status":           delivery.Status,
+		"attempts":         delivery.Attempts,
+		"next_attempt_at":  delivery.NextAttemptAt,
+		"last_status_code": delivery.LastStatusCode,
+		"last_error":       delivery.LastError,
+		"delivered_at":     delivery.DeliveredAt,
+	})
+	return err
+}
+
+func (r *PostgresWebhookRepository) ReplayDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	// A single conditional update, so a delivery the worker is sending
+	// cannot be reset under it.
+	query := fmt.Sprintf(`
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = 0, next_attempt_at = NOW(), delivered_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status <> $2
+		RETURNING %s
+	`, r.deliveries.selectList())
+
+	rows, err := conn(ctx, r.db).Query(ctx, query, id, models.WebhookDeliveryPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+	delivery, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[models.WebhookDelivery])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+func deliveryFilter(filter models.WebhookDeliveryFilter) Filter {
+	result := Filter{}
+	if filter.SubscriptionID != 0 {
+		result["subscription_id"] = filter.SubscriptionID
+	}
+	if filter.Status != "" {
+		result["status"] = filter.Status
+	}
+	if filter.EventType != "" {
+		result["event_type"] = filter.EventType
+	}
+	return result
+}
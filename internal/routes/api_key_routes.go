@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+)
+
+func RegisterAPIKeyRoutes(protected *mux.Router, apiKeys services.APIKeyService) {
+	h := handlers.NewAPIKeyHandler(apiKeys)
+
+	protected.Handle("/api-keys", scoped(auth.ScopeAPIKeys, h.CreateAPIKey)).Methods(http.MethodPost)
+	protected.Handle("/api-keys", scoped(auth.ScopeAPIKeys, h.ListAPIKeys)).Methods(http.MethodGet)
+	protected.Handle("/api-keys/{id}", scoped(auth.ScopeAPIKeys, h.RevokeAPIKey)).Methods(http.MethodDelete)
+}
+
+// scoped limits a handler to API keys with scope. Requests authenticated with
+// a JWT are not affected.
+func scoped(scope string, h http.HandlerFunc) http.Handler {
+	return authMiddleware.RequireScope(scope)(h)
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+)
+
+func RegisterAuditRoutes(admin *mux.Router, audit services.AuditService, permissions authMiddleware.PermissionChecker) {
+	h := handlers.NewAuditHandler(audit)
+	r := permitted(admin, permissions, models.PermissionAuditRead)
+
+	r.HandleFunc("/audit-events", h.ListAuditEvents).Methods(http.MethodGet)
+	r.HandleFunc("/audit-events/export", h.ExportAuditEvents).Methods(http.MethodGet)
+}
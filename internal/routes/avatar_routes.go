@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/schema"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/Romasmi/go-rest-api-template/internal/storage"
+	"github.com/gorilla/mux"
+)
+
+func RegisterAvatarRoutes(protected *mux.Router, users services.UserService, cfg config.ProfileConfig, permissions authMiddleware.PermissionChecker) {
+	h := handlers.NewAvatarHandler(users, cfg.AvatarMaxSize)
+
+	protected.Handle("/me/avatar", scoped(auth.ScopeUsersWrite, h.UploadMyAvatar)).Methods(http.MethodPut)
+	protected.Handle("/me/avatar", scoped(auth.ScopeUsersWrite, h.DeleteMyAvatar)).Methods(http.MethodDelete)
+	protected.Handle("/users/{id}/avatar", authorized(permissions, auth.ScopeUsersWrite, models.PermissionUsersWrite, h.UploadUserAvatar)).Methods(http.MethodPut)
+	protected.Handle("/users/{id}/avatar", authorized(permissions, auth.ScopeUsersWrite, models.PermissionUsersWrite, h.DeleteUserAvatar)).Methods(http.MethodDelete)
+}
+
+// RegisterFileRoutes serves the files of local storage under /files. Other
+// storage serves its files itself.
+func RegisterFileRoutes(r *mux.Router, files storage.Storage) {
+	if local, ok := files.(*storage.Local); ok {
+		r.PathPrefix("/files/").Handler(http.StripPrefix("/files", local)).Methods(http.MethodGet, http.MethodHead)
+	}
+}
+
+// newAttributeSchema loads the configured schema of user attributes, if
+// there is one.
+func newAttributeSchema(config *config.Config) (*schema.Schema, error) {
+	if config.Profile.AttributesSchema == "" {
+		return nil, nil
+	}
+	attributes, err := schema.Load(config.Profile.AttributesSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attributes schema: %w", err)
+	}
+	return attributes, nil
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+)
+
+func RegisterMeRoutes(public *mux.Router, protected *mux.Router, users services.UserService) {
+	h := handlers.NewMeHandler(users)
+
+	public.HandleFunc("/auth/email/confirm", h.ConfirmEmail).Methods(http.MethodPost)
+	protected.Handle("/me", scoped(auth.ScopeUsersRead, h.Get)).Methods(http.MethodGet)
+	protected.Handle("/me", scoped(auth.ScopeUsersWrite, h.Update)).Methods(http.MethodPatch)
+	protected.Handle("/me", scoped(auth.ScopeUsersWrite, h.Delete)).Methods(http.MethodDelete)
+	protected.Handle("/me/password", scoped(auth.ScopeUsersWrite, h.ChangePassword)).Methods(http.MethodPost)
+	protected.Handle("/me/email", scoped(auth.ScopeUsersWrite, h.ChangeEmail)).Methods(http.MethodPost)
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func RegisterMFARoutes(public *mux.Router, protected *mux.Router, users services.UserService, mfa services.MFAService) {
+	h := handlers.NewMFAHandler(users, mfa)
+
+	public.HandleFunc("/auth/mfa/verify", h.Verify).Methods(http.MethodPost)
+	public.HandleFunc("/auth/mfa/enroll", h.EnrollWithToken).Methods(http.MethodPost)
+	public.HandleFunc("/auth/mfa/enroll/confirm", h.ConfirmWithToken).Methods(http.MethodPost)
+	protected.HandleFunc("/mfa/totp", h.Enroll).Methods(http.MethodPost)
+	protected.HandleFunc("/mfa/totp/confirm", h.Confirm).Methods(http.MethodPost)
+	protected.HandleFunc("/mfa/totp", h.Disable).Methods(http.MethodDelete)
+	protected.HandleFunc("/mfa/recovery-codes", h.RegenerateRecoveryCodes).Methods(http.MethodPost)
+}
+
+func newMFAService(db *pgxpool.Pool, config *config.Config, audit services.AuditService) services.MFAService {
+	return services.NewMFAService(repository.NewPostgresMFARepository(db), config.MFA, repository.NewPostgresTxManager(db), audit)
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+)
+
+func RegisterOIDCRoutes(public *mux.Router, protected *mux.Router, oidc services.OIDCService, publicURL, postLoginRedirectURL string) {
+	h := handlers.NewOIDCHandler(oidc, publicURL, postLoginRedirectURL)
+
+	public.HandleFunc("/auth/oidc/{provider}/start", h.Start).Methods(http.MethodGet)
+	public.HandleFunc("/auth/oidc/{provider}/callback", h.Callback).Methods(http.MethodGet)
+	protected.HandleFunc("/auth/oidc/{provider}/link", h.Link).Methods(http.MethodPost)
+	protected.HandleFunc("/identities", h.ListIdentities).Methods(http.MethodGet)
+	protected.HandleFunc("/identities/{id}", h.UnlinkIdentity).Methods(http.MethodDelete)
+}
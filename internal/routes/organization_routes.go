@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+)
+
+func RegisterOrganizationRoutes(protected *mux.Router, platform *mux.Router, organizations services.OrganizationService, permissions authMiddleware.PermissionChecker) {
+	h := handlers.NewOrganizationHandler(organizations)
+	r := permitted(platform, permissions, models.PermissionOrganizationsManage)
+
+	protected.HandleFunc("/organization", h.GetCurrentOrganization).Methods(http.MethodGet)
+	r.HandleFunc("/organizations", h.CreateOrganization).Methods(http.MethodPost)
+	r.HandleFunc("/organizations", h.ListOrganizations).Methods(http.MethodGet)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+)
+
+func RegisterPasskeyRoutes(public *mux.Router, protected *mux.Router, passkeys services.PasskeyService) {
+	h := handlers.NewPasskeyHandler(passkeys)
+
+	public.HandleFunc("/auth/passkey/begin", h.BeginLogin).Methods(http.MethodPost)
+	public.HandleFunc("/auth/passkey/finish", h.FinishLogin).Methods(http.MethodPost)
+	protected.HandleFunc("/passkeys", h.List).Methods(http.MethodGet)
+	protected.HandleFunc("/passkeys/register/begin", h.BeginRegistration).Methods(http.MethodPost)
+	protected.HandleFunc("/passkeys/register/finish", h.FinishRegistration).Methods(http.MethodPost)
+	protected.HandleFunc("/passkeys/{id}", h.Delete).Methods(http.MethodDelete)
+}
@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+)
+
+func RegisterRoleRoutes(admin *mux.Router, platform *mux.Router, roles services.RoleService) {
+	h := handlers.NewRoleHandler(roles)
+	r := permitted(admin, roles, models.PermissionRolesManage)
+	definitions := permitted(platform, roles, models.PermissionRolesManage)
+
+	r.HandleFunc("/permissions", h.ListPermissions).Methods(http.MethodGet)
+	r.HandleFunc("/roles", h.ListRoles).Methods(http.MethodGet)
+	definitions.HandleFunc("/roles", h.CreateRole).Methods(http.MethodPost)
+	definitions.HandleFunc("/roles/{name}", h.UpdateRole).Methods(http.MethodPut)
+	definitions.HandleFunc("/roles/{name}", h.DeleteRole).Methods(http.MethodDelete)
+	r.HandleFunc("/users/{id}/roles", h.GetUserRoles).Methods(http.MethodGet)
+	r.HandleFunc("/users/{id}/roles", h.SetUserRoles).Methods(http.MethodPut)
+}
+
+// permitted returns a subrouter of r whose routes require permission.
+func permitted(r *mux.Router, checker authMiddleware.PermissionChecker, permission string) *mux.Router {
+	sub := r.PathPrefix("").Subrouter()
+	sub.Use(authMiddleware.RequirePermission(checker, permission))
+	return sub
+}
+
+// authorized limits a handler to callers with permission and, for API keys,
+// with scope.
+func authorized(checker authMiddleware.PermissionChecker, scope, permission string, h http.HandlerFunc) http.Handler {
+	return scoped(scope, authMiddleware.RequirePermission(checker, permission)(h).ServeHTTP)
+}
@@ -1,10 +1,17 @@
 package routes
 
 import (
-	"encoding/json"
+	"fmt"
 
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
 	"github.com/Romasmi/go-rest-api-template/internal/config"
 	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/oidc"
+	"github.com/Romasmi/go-rest-api-template/internal/render"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/Romasmi/go-rest-api-template/internal/storage"
 	ghandlers "github.com/gorilla/handlers"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 
@@ -18,7 +25,10 @@ type NotFoundResponse struct {
 	Error string `json:"error"`
 }
 
-func RegisterRoutes(r *mux.Router, db *pgxpool.Pool, config *config.Config) {
+// RegisterRoutes adds all routes to r. users is the repository of the users
+// read path, shared so that its cache can be invalidated. It fails when the
+// config is invalid.
+func RegisterRoutes(r *mux.Router, db *pgxpool.Pool, config *config.Config, tokens auth.TokenService, users repository.UserRepository) error {
 	if r == nil {
 		panic("r must be initialized before routes registration")
 	}
@@ -29,42 +39,121 @@ func RegisterRoutes(r *mux.Router, db *pgxpool.Pool, config *config.Config) {
 		httpSwagger.URL("/swagger/doc.json"),
 	))
 
-	r.Use(ghandlers.RecoveryHandler())
-	r.Use(ghandlers.ProxyHeaders)
+	proxies, err := authMiddleware.ParseTrustedProxies(config.Server.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	r.Use(authMiddleware.Recover)
+	r.Use(authMiddleware.ProxyHeaders(proxies))
 	r.Use(ghandlers.CORS(
 		ghandlers.AllowedOrigins([]string{"*"}),
 		ghandlers.AllowedMethods([]string{
 			http.MethodGet,
 			http.MethodPost,
 			http.MethodPut,
+			http.MethodPatch,
 			http.MethodDelete,
 			http.MethodOptions}),
-		ghandlers.AllowedHeaders([]string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"}),
-		ghandlers.ExposedHeaders([]string{"Link"}),
+		ghandlers.AllowedHeaders([]string{"Accept", "Accept-Encoding", "Authorization", "Content-Type", "If-Modified-Since", "If-None-Match", "X-CSRF-Token", authMiddleware.RequestIDHeader, authMiddleware.APIKeyHeader, authMiddleware.TenantHeader, authMiddleware.IdempotencyKeyHeader}),
+		ghandlers.ExposedHeaders([]string{"ETag", "Link", authMiddleware.RequestIDHeader, authMiddleware.IdempotentReplayedHeader}),
 		ghandlers.AllowCredentials(),
 		ghandlers.MaxAge(300),
 	))
+	r.Use(authMiddleware.Compress(config.Compression.MinSize))
+	r.Use(authMiddleware.RequestInfo)
+
+	files, err := storage.New(config.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to configure storage: %w", err)
+	}
+	RegisterFileRoutes(r, files)
 
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Welcome to the Go REST API Template!"))
 	}).Methods(http.MethodGet)
 
 	api := r.PathPrefix("/api/v1").Subrouter()
-	RegisterUsersRoutes(api, db, config)
+	organizations := repository.NewPostgresOrganizationRepository(db)
+	api.Use(authMiddleware.Tenant(organizations, config.Tenancy.BaseDomain))
 
+	audit := services.NewAuditService(repository.NewPostgresAuditRepository(db))
+	// One session service is shared so its cache sees every revocation made
+	// through this replica.
+	sessions := services.NewSessionService(repository.NewPostgresSessionRepository(db), config.JWT.ExpirationTTL, config.Sessions.CacheTTL, audit)
+	userService, err := newUserService(db, config, users, tokens, audit, sessions, files)
+	if err != nil {
+		return err
+	}
+	roles := services.NewRoleService(repository.NewPostgresRoleRepository(db), users, userService, repository.NewPostgresTxManager(db), audit)
+	apiKeys := services.NewAPIKeyService(repository.NewPostgresAPIKeyRepository(db), repository.NewPostgresUserRepository(db), roles, audit)
+
+	idempotencyKeys := repository.NewPostgresIdempotencyRepository(db)
+
+	public := api.PathPrefix("").Subrouter()
+	public.Use(authMiddleware.DefaultTenant(organizations))
+	public.Use(authMiddleware.Idempotency(idempotencyKeys, config.Idempotency.TTL, 0))
 	protected := api.PathPrefix("").Subrouter()
-	protected.Use(authMiddleware.Authenticator)
+	protected.Use(authMiddleware.Authenticator(userService, apiKeys))
+	protected.Use(authMiddleware.TenantFromClaims(organizations))
+	// Imports are the largest bodies behind authentication, and the ones
+	// that most need to be retried safely.
+	protected.Use(authMiddleware.Idempotency(idempotencyKeys, config.Idempotency.TTL, userImportMaxSize(config.UserImport)))
+	admin := protected.PathPrefix("").Subrouter()
+	admin.Use(authMiddleware.RequireScope(auth.ScopeAdmin))
+	// Roles, webhooks and organizations are shared by all tenants, so only the
+	// default organization manages them.
+	platform := admin.PathPrefix("").Subrouter()
+	platform.Use(authMiddleware.RequireTenant(models.DefaultOrganizationID))
+
+	RegisterUsersRoutes(public, protected, userService, roles)
+	RegisterUserStatusRoutes(admin, userService, roles)
+	RegisterSessionRoutes(protected, admin, userService, sessions, roles)
+	RegisterAPIKeyRoutes(protected, apiKeys)
+	RegisterRoleRoutes(admin, platform, roles)
+	RegisterAuditRoutes(admin, audit, roles)
+	imports, err := NewUserImportService(db, config, users, tokens)
+	if err != nil {
+		return err
+	}
+	RegisterUserImportRoutes(admin, imports, userService, config.UserImport, roles)
+	webhookService, err := NewWebhookService(db, config)
+	if err != nil {
+		return err
+	}
+	RegisterWebhookRoutes(platform, webhookService, roles)
+	RegisterOrganizationRoutes(protected, platform, services.NewOrganizationService(
+		organizations, userService, repository.NewPostgresTxManager(db), audit,
+	), roles)
+
+	RegisterMeRoutes(public, protected, userService)
+	RegisterAvatarRoutes(protected, userService, config.Profile, roles)
+	RegisterMFARoutes(public, protected, userService, newMFAService(db, config, audit))
+
+	providers, err := oidc.NewProviders(config.OIDC)
+	if err != nil {
+		return fmt.Errorf("failed to configure oidc: %w", err)
+	}
+	RegisterOIDCRoutes(public, protected, services.NewOIDCService(
+		providers, config.OIDC, []byte(config.JWT.Secret), repository.NewPostgresUserIdentityRepository(db),
+		userService, repository.NewPostgresTxManager(db), audit,
+	), config.OIDC.PublicURL, config.OIDC.PostLoginRedirectURL)
+
+	passkeys, err := services.NewPasskeyService(
+		config.WebAuthn, repository.NewPostgresPasskeyRepository(db), userService, tokens, audit,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to configure webauthn: %w", err)
+	}
+	RegisterPasskeyRoutes(public, protected, passkeys)
+
 	protected.HandleFunc("/protected", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("This is a protected endpoint"))
 	}).Methods(http.MethodGet)
+
+	return nil
 }
 
 func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotFound)
-	response := &NotFoundResponse{Error: "Route Not found"}
-	err := json.NewEncoder(w).Encode(response)
-	if err != nil {
-		http.Error(w, "Unable to encode response", http.StatusInternalServerError)
-		return
-	}
+	render.Render(w, r, http.StatusNotFound, &NotFoundResponse{Error: "Route Not found"})
 }
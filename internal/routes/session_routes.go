@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+)
+
+func RegisterSessionRoutes(protected *mux.Router, admin *mux.Router, users services.UserService, sessions services.SessionService, permissions authMiddleware.PermissionChecker) {
+	h := handlers.NewSessionHandler(users, sessions)
+
+	protected.Handle("/me/sessions", scoped(auth.ScopeUsersRead, h.ListMine)).Methods(http.MethodGet)
+	protected.Handle("/me/sessions/{id}", scoped(auth.ScopeUsersWrite, h.RevokeMine)).Methods(http.MethodDelete)
+
+	r := permitted(admin, permissions, models.PermissionSessionsManage)
+	r.HandleFunc("/users/{id}/sessions", h.ListForUser).Methods(http.MethodGet)
+	r.HandleFunc("/users/{id}/sessions/{session_id}", h.RevokeForUser).Methods(http.MethodDelete)
+}
@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	"github.com/Romasmi/go-rest-api-template/internal/jobs"
+	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func RegisterUserImportRoutes(admin *mux.Router, imports services.UserImportService, users services.UserService, cfg config.UserImportConfig, permissions authMiddleware.PermissionChecker) {
+	h := handlers.NewUserImportHandler(imports, users, cfg.MaxSize, cfg.MaxSyncSize)
+
+	write := permitted(admin, permissions, models.PermissionUsersWrite)
+	write.HandleFunc("/users/import", h.ImportUsers).Methods(http.MethodPost)
+	write.HandleFunc("/users/imports/{id}", h.GetUserImport).Methods(http.MethodGet)
+
+	// users:read lets regular users look at single users, while an export
+	// hands out the whole organization.
+	export := permitted(admin, permissions, models.PermissionUsersExport)
+	export.HandleFunc("/users/export", h.ExportUsers).Methods(http.MethodGet)
+}
+
+// userImportMaxSize is the body limit of imports, which the idempotency
+// middleware in front of them has to allow as well.
+func userImportMaxSize(cfg config.UserImportConfig) int64 {
+	if cfg.MaxSize > 0 {
+		return cfg.MaxSize
+	}
+	return handlers.DefaultUserImportMaxSize
+}
+
+// NewUserImportService builds the service both for the routes and for the
+// worker running background imports.
+func NewUserImportService(db *pgxpool.Pool, config *config.Config, users repository.UserRepository, tokens auth.TokenService) (services.UserImportService, error) {
+	userService, err := NewUserService(db, config, users, tokens)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewUserImportService(
+		repository.NewPostgresUserImportRepository(db),
+		userService,
+		repository.NewPostgresTxManager(db),
+		jobs.NewClient(repository.NewPostgresJobRepository(db), config.Jobs.MaxAttempts),
+	), nil
+}
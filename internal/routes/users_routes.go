@@ -1,23 +1,79 @@
 package routes
 
 import (
+	"fmt"
 	"net/http"
 
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
 	"github.com/Romasmi/go-rest-api-template/internal/config"
 	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	"github.com/Romasmi/go-rest-api-template/internal/mail"
+	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/password"
 	"github.com/Romasmi/go-rest-api-template/internal/repository"
 	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/Romasmi/go-rest-api-template/internal/storage"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func RegisterUsersRoutes(r *mux.Router, db *pgxpool.Pool, config *config.Config) {
-	h := handlers.NewUserHandler(services.NewUserService(repository.NewUserRepository(db)))
+func RegisterUsersRoutes(public *mux.Router, protected *mux.Router, users services.UserService, permissions authMiddleware.PermissionChecker) {
+	h := handlers.NewUserHandler(users)
 
-	r.HandleFunc("/auth/register", h.Register).Methods(http.MethodPost)
-	r.HandleFunc("/auth/login", h.Login).Methods(http.MethodPost)
-	r.HandleFunc("/users", h.ListUsers).Methods(http.MethodGet)
-	r.HandleFunc("/users/{id}", h.GetUser).Methods(http.MethodGet)
-	r.HandleFunc("/users/{id}", h.UpdateUser).Methods(http.MethodPut)
-	r.HandleFunc("/users/{id}", h.DeleteUser).Methods(http.MethodDelete)
+	public.HandleFunc("/auth/register", h.Register).Methods(http.MethodPost)
+	public.HandleFunc("/auth/login", h.Login).Methods(http.MethodPost)
+	protected.Handle("/users", authorized(permissions, auth.ScopeUsersRead, models.PermissionUsersRead, h.ListUsers)).Methods(http.MethodGet)
+	protected.Handle("/users/{id}", authorized(permissions, auth.ScopeUsersRead, models.PermissionUsersRead, h.GetUser)).Methods(http.MethodGet)
+	protected.Handle("/users/{id}", authorized(permissions, auth.ScopeUsersWrite, models.PermissionUsersWrite, h.UpdateUser)).Methods(http.MethodPut)
+	protected.Handle("/users/{id}", authorized(permissions, auth.ScopeUsersWrite, models.PermissionUsersDelete, h.DeleteUser)).Methods(http.MethodDelete)
+}
+
+// RegisterUserStatusRoutes adds the status endpoints, which only admins can
+// use.
+func RegisterUserStatusRoutes(admin *mux.Router, users services.UserService, permissions authMiddleware.PermissionChecker) {
+	h := handlers.NewUserHandler(users)
+
+	permitted(admin, permissions, models.PermissionUsersWrite).HandleFunc("/users/{id}/status", h.SetUserStatus).Methods(http.MethodPut)
+	// The history holds the reasons of moderation decisions, so it is kept
+	// with the audit log rather than open to anyone with users:read.
+	permitted(admin, permissions, models.PermissionAuditRead).HandleFunc("/users/{id}/status-history", h.GetUserStatusHistory).Methods(http.MethodGet)
+}
+
+// NewUserService builds the user service outside of the HTTP server, for
+// background jobs and the admin CLI.
+func NewUserService(db *pgxpool.Pool, config *config.Config, users repository.UserRepository, tokens auth.TokenService) (services.UserService, error) {
+	audit := services.NewAuditService(repository.NewPostgresAuditRepository(db))
+	sessions := services.NewSessionService(repository.NewPostgresSessionRepository(db), config.JWT.ExpirationTTL, config.Sessions.CacheTTL, audit)
+	files, err := storage.New(config.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure storage: %w", err)
+	}
+	return newUserService(db, config, users, tokens, audit, sessions, files)
+}
+
+func newUserService(db *pgxpool.Pool, config *config.Config, users repository.UserRepository, tokens auth.TokenService, audit services.AuditService, sessions services.SessionService, files storage.Storage) (services.UserService, error) {
+	hasher, err := password.NewHasher(config.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure passwords: %w", err)
+	}
+	attributes, err := newAttributeSchema(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return services.NewUserService(
+		users,
+		tokens,
+		services.WithAudit(audit),
+		services.WithEvents(repository.NewPostgresTxManager(db), repository.NewPostgresOutboxRepository(db)),
+		services.WithRoles(repository.NewPostgresRoleRepository(db)),
+		services.WithMFA(newMFAService(db, config, audit), config.MFA.ChallengeTTL),
+		services.WithPasswords(hasher, password.NewPolicy(config.Password.Policy)),
+		services.WithMailer(mail.NewMailer(config.Mail), config.Mail.ConfirmEmailURL),
+		services.WithSessions(sessions),
+		services.WithStatusHistory(repository.NewPostgresUserStatusChangeRepository(db)),
+		services.WithAttributeSchema(attributes),
+		services.WithAvatars(files, config.Profile.AvatarSize),
+	), nil
 }
@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/jobs"
+	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// testRouter has the user routes behind the same middleware as in
+// RegisterRoutes, with memory repositories instead of the database.
+type testRouter struct {
+	*mux.Router
+	t      *testing.T
+	users  *repository.MemoryUserRepository
+	roles  *repository.MemoryRoleRepository
+	tokens *auth.FakeTokenService
+}
+
+func newTestRouter(t *testing.T, cfg config.UserImportConfig) *testRouter {
+	users := repository.NewMemoryUserRepository()
+	roleRepo := repository.NewMemoryRoleRepository(users)
+	tokens := auth.NewFakeTokenService()
+	audit := services.NewAuditService(repository.NewMemoryAuditRepository())
+	userService := services.NewUserService(users, tokens,
+		services.WithRoles(roleRepo),
+		services.WithStatusHistory(repository.NewMemoryUserStatusChangeRepository()),
+	)
+	roles := services.NewRoleService(roleRepo, users, userService, repository.NoopTxManager{}, audit)
+	imports := services.NewUserImportService(repository.NewMemoryUserImportRepository(), userService, repository.NoopTxManager{}, jobs.NewClient(repository.NewMemoryJobRepository(), 0))
+
+	r := mux.NewRouter()
+	protected := r.PathPrefix("/api/v1").Subrouter()
+	protected.Use(authMiddleware.Authenticator(tokens, nil))
+	protected.Use(authMiddleware.Idempotency(repository.NewMemoryIdempotencyRepository(), time.Hour, userImportMaxSize(cfg)))
+	admin := protected.PathPrefix("").Subrouter()
+	admin.Use(authMiddleware.RequireScope(auth.ScopeAdmin))
+	RegisterUserStatusRoutes(admin, userService, roles)
+	RegisterUserImportRoutes(admin, imports, userService, cfg, roles)
+
+	return &testRouter{Router: r, t: t, users: users, roles: roleRepo, tokens: tokens}
+}
+
+// token creates a user with role and returns a token for it.
+func (r *testRouter) token(username, role string) string {
+	ctx := context.Background()
+	user, err := r.users.Create(ctx, &models.UserCreate{Username: username, Email: username + "@example.com", Password: "password1"})
+	if err != nil {
+		r.t.Fatalf("Error while creating user: %v", err)
+	}
+	if err := r.roles.SetUserRoles(ctx, user.InternalID, []string{role}); err != nil {
+		r.t.Fatalf("Error while assigning role: %v", err)
+	}
+	token, err := r.tokens.Issue(auth.UserClaims(user.ID.String(), role))
+	if err != nil {
+		r.t.Fatalf("Error while issuing token: %v", err)
+	}
+	return token
+}
+
+func (r *testRouter) do(req *http.Request, token string) *httptest.ResponseRecorder {
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminUserRoutes(t *testing.T) {
+	r := newTestRouter(t, config.UserImportConfig{})
+	userToken := r.token("alice", models.RoleUser)
+	adminToken := r.token("root", models.RoleAdmin)
+	target, _ := r.users.Create(context.Background(), &models.UserCreate{Username: "bob", Email: "bob@example.com", Password: "password1"})
+
+	for _, path := range []string{"/api/v1/users/export", "/api/v1/users/" + target.ID.String() + "/status-history"} {
+		for _, tt := range []struct {
+			token  string
+			status int
+		}{
+			{token: userToken, status: http.StatusForbidden},
+			{token: adminToken, status: http.StatusOK},
+		} {
+			rec := r.do(httptest.NewRequest(http.MethodGet, path, nil), tt.token)
+			if rec.Code != tt.status {
+				t.Errorf("Wrong status of %s, expected: %v, actual: %v", path, tt.status, rec.Code)
+			}
+		}
+	}
+}
+
+func TestIdempotentBackgroundImport(t *testing.T) {
+	r := newTestRouter(t, config.UserImportConfig{})
+	adminToken := r.token("root", models.RoleAdmin)
+
+	var body strings.Builder
+	body.WriteString("username,email,password\n")
+	for i := 0; body.Len() <= authMiddleware.DefaultIdempotentBodySize; i++ {
+		fmt.Fprintf(&body, "user%d,user%d@example.com,password%d\n", i, i, i)
+	}
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users/import", strings.NewReader(body.String()))
+		req.Header.Set("Content-Type", "text/csv")
+		req.Header.Set(authMiddleware.IdempotencyKeyHeader, "import-1")
+		return r.do(req, adminToken)
+	}
+
+	first := send()
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("Wrong status, expected: %v, actual: %v %s", http.StatusAccepted, first.Code, first.Body)
+	}
+	retry := send()
+	if retry.Code != http.StatusAccepted || retry.Header().Get(authMiddleware.IdempotentReplayedHeader) != "true" {
+		t.Errorf("Expected the import to be replayed, actual: %v %v", retry.Code, retry.Header())
+	}
+
+	var started, replayed models.UserImport
+	json.Unmarshal(first.Body.Bytes(), &started)
+	json.Unmarshal(retry.Body.Bytes(), &replayed)
+	if started.ID != replayed.ID {
+		t.Errorf("Wrong import, expected: %v, actual: %v", started.ID, replayed.ID)
+	}
+}
@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/handlers"
+	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/secrets"
+	"github.com/Romasmi/go-rest-api-template/internal/services"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func RegisterWebhookRoutes(platform *mux.Router, webhooks services.WebhookService, permissions authMiddleware.PermissionChecker) {
+	h := handlers.NewWebhookHandler(webhooks)
+	r := permitted(platform, permissions, models.PermissionWebhooksManage)
+
+	r.HandleFunc("/webhooks", h.CreateWebhook).Methods(http.MethodPost)
+	r.HandleFunc("/webhooks", h.ListWebhooks).Methods(http.MethodGet)
+	r.HandleFunc("/webhooks/{id}", h.GetWebhook).Methods(http.MethodGet)
+	r.HandleFunc("/webhooks/{id}", h.DeleteWebhook).Methods(http.MethodDelete)
+	r.HandleFunc("/webhook-deliveries", h.ListWebhookDeliveries).Methods(http.MethodGet)
+	r.HandleFunc("/webhook-deliveries/{id}", h.GetWebhookDelivery).Methods(http.MethodGet)
+	r.HandleFunc("/webhook-deliveries/{id}/replay", h.ReplayWebhookDelivery).Methods(http.MethodPost)
+}
+
+// NewWebhookService builds the service both for the routes and for the
+// outbox dispatcher queueing deliveries.
+func NewWebhookService(db *pgxpool.Pool, config *config.Config) (services.WebhookService, error) {
+	cipher, err := NewWebhookSecrets(config)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewWebhookService(
+		repository.NewPostgresWebhookRepository(db), cipher, config.Webhooks.AllowPrivateNetworks,
+	), nil
+}
+
+// NewWebhookSecrets returns the cipher for subscription signing secrets. The
+// key falls back to the JWT secret when webhooks.secretKey is not set.
+func NewWebhookSecrets(config *config.Config) (*secrets.Cipher, error) {
+	key := config.Webhooks.SecretKey
+	if key == "" {
+		key = config.JWT.Secret
+	}
+	cipher, err := secrets.NewCipher([]byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure webhook secrets: %w", err)
+	}
+	return cipher, nil
+}
@@ -0,0 +1,68 @@
+// Package schema checks JSON values against a JSON Schema.
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// ErrInvalid is wrapped by the errors of values that do not match.
+var ErrInvalid = errors.New("does not match the schema")
+
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// Load compiles the JSON Schema in the file at path.
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	return Compile(data)
+}
+
+func Compile(data []byte) (*Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("schema.json", doc); err != nil {
+		return nil, fmt.Errorf("failed to load schema: %w", err)
+	}
+	compiled, err := compiler.Compile("schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema: %w", err)
+	}
+	return &Schema{schema: compiled}, nil
+}
+
+// Validate checks v, a value as decoded by encoding/json. The error names
+// every location that does not match.
+func (s *Schema) Validate(v interface{}) error {
+	err := s.schema.Validate(v)
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	var messages []string
+	for _, unit := range validationErr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		location := unit.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		messages = append(messages, location+": "+unit.Error.String())
+	}
+	return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(messages, "; "))
+}
@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	s, err := Compile([]byte(`{
+		"type": "object",
+		"properties": {
+			"department": {"type": "string", "enum": ["sales", "support"]},
+			"level": {"type": "integer", "minimum": 1}
+		},
+		"additionalProperties": false
+	}`))
+	if err != nil {
+		t.Fatalf("Error while compiling schema: %v", err)
+	}
+
+	for _, tt := range []struct {
+		value    string
+		expected string
+	}{
+		{value: `{"department": "sales", "level": 3}`},
+		{value: `{}`},
+		{value: `{"department": "legal"}`, expected: "/department"},
+		{value: `{"level": 0.5}`, expected: "/level"},
+		{value: `{"team": "a"}`, expected: "team"},
+		{value: `[]`, expected: "/"},
+	} {
+		var value interface{}
+		if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+			t.Fatalf("Error while decoding %s: %v", tt.value, err)
+		}
+
+		err := s.Validate(value)
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("Expected %s to be valid, actual: %v", tt.value, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected ErrInvalid about %q for %s, actual: %v", tt.expected, tt.value, err)
+		}
+	}
+
+	if _, err := Compile([]byte(`{"type": 1}`)); err == nil {
+		t.Errorf("Expected an invalid schema to be refused")
+	}
+}
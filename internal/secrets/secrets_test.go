@@ -0,0 +1,37 @@
+package secrets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher([]byte("key"))
+	if err != nil {
+		t.Fatalf("Error while creating cipher: %v", err)
+	}
+
+	encrypted, err := c.Encrypt("0123456789abcdef")
+	if err != nil {
+		t.Fatalf("Error while encrypting: %v", err)
+	}
+	if strings.Contains(encrypted, "0123456789abcdef") || !strings.HasPrefix(encrypted, prefix) {
+		t.Errorf("Wrong encrypted value: %v", encrypted)
+	}
+	if decrypted, err := c.Decrypt(encrypted); err != nil || decrypted != "0123456789abcdef" {
+		t.Errorf("Wrong decrypted value, expected: %v, actual: %v (%v)", "0123456789abcdef", decrypted, err)
+	}
+
+	if plain, err := c.Decrypt("stored-before-encryption"); err != nil || plain != "stored-before-encryption" {
+		t.Errorf("Wrong plaintext value, expected: %v, actual: %v (%v)", "stored-before-encryption", plain, err)
+	}
+
+	other, _ := NewCipher([]byte("other"))
+	if _, err := other.Decrypt(encrypted); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Expected ErrInvalidCiphertext for another key, actual: %v", err)
+	}
+	if _, err := NewCipher(nil); err == nil {
+		t.Error("Expected an empty key to be refused")
+	}
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	authMiddleware "github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key, followed by the displayable part of the
+// key, an underscore and the secret.
+const APIKeyPrefix = "ak_"
+
+var (
+	ErrForbidden        = errors.New("not allowed")
+	ErrInvalidExpiresAt = errors.New("expires_at must be in the future")
+)
+
+type APIKeyService interface {
+	// Create issues a key for the caller found in ctx, or a service account
+	// key when ServiceAccountRole is set and the caller may manage roles.
+	Create(ctx context.Context, key *models.APIKeyCreate) (*models.APIKeyCreated, error)
+	// List returns the caller's keys, or all service account keys for callers
+	// who may manage roles when serviceAccounts is set.
+	List(ctx context.Context, serviceAccounts bool) ([]*models.APIKey, error)
+	Revoke(ctx context.Context, id int64) (*models.APIKey, error)
+	// Verify makes the service an auth.TokenVerifier for API keys. The claims
+	// are those of the owning user plus the key's scopes.
+	Verify(ctx context.Context, key string) (auth.Claims, error)
+}
+
+type apiKeyService struct {
+	repo        repository.APIKeyRepository
+	users       repository.UserRepository
+	permissions authMiddleware.PermissionChecker
+	audit       AuditService
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, users repository.UserRepository, permissions authMiddleware.PermissionChecker, audit AuditService) APIKeyService {
+	return &apiKeyService{
+		repo:        repo,
+		users:       users,
+		permissions: permissions,
+		audit:       audit,
+	}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, create *models.APIKeyCreate) (*models.APIKeyCreated, error) {
+	claims := auth.FromContext(ctx)
+	for _, scope := range create.Scopes {
+		if !auth.HasScope(claims, scope) {
+			return nil, ErrForbidden
+		}
+	}
+	if create.ExpiresAt != nil && !create.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidExpiresAt
+	}
+
+	key := &models.APIKey{
+		Name:      create.Name,
+		Scopes:    create.Scopes,
+		ExpiresAt: create.ExpiresAt,
+	}
+	if create.ServiceAccountRole != "" {
+		// A service account key carries every permission of its role, so
+		// minting one is managing roles.
+		if err := s.requirePermission(ctx, models.PermissionRolesManage); err != nil {
+			return nil, err
+		}
+		key.Role = create.ServiceAccountRole
+	} else {
+		user, err := s.caller(ctx)
+		if err != nil {
+			return nil, err
+		}
+		key.UserID = &user.InternalID
+	}
+
+	prefix, secret, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key.Prefix = prefix
+	key.SecretHash = hashAPIKeySecret(secret)
+
+	created, err := s.repo.Create(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Action:     models.AuditActionAPIKeyCreate,
+		TargetType: "api_key",
+		TargetID:   strconv.FormatInt(created.ID, 10),
+		Success:    true,
+		Changes: map[string]models.AuditChange{
+			"prefix": {To: created.Prefix},
+			"scopes": {To: created.Scopes},
+		},
+	})
+
+	return &models.APIKeyCreated{APIKey: created, Key: prefix + "_" + secret}, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context, serviceAccounts bool) ([]*models.APIKey, error) {
+	if serviceAccounts {
+		if err := s.requirePermission(ctx, models.PermissionRolesManage); err != nil {
+			return nil, err
+		}
+		return s.repo.List(ctx, nil)
+	}
+
+	user, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.List(ctx, &user.InternalID)
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id int64) (*models.APIKey, error) {
+	key, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	manager, err := s.permissions.HasPermission(ctx, auth.FromContext(ctx), models.PermissionRolesManage)
+	if err != nil {
+		return nil, err
+	}
+	if !manager {
+		user, err := s.caller(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// Other users' keys are reported as missing rather than forbidden.
+		if key.UserID == nil || *key.UserID != user.InternalID {
+			return nil, repository.ErrNotFound
+		}
+	}
+
+	revoked, err := s.repo.Revoke(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Action:     models.AuditActionAPIKeyRevoke,
+		TargetType: "api_key",
+		TargetID:   strconv.FormatInt(revoked.ID, 10),
+		Success:    true,
+	})
+	return revoked, nil
+}
+
+func (s *apiKeyService) Verify(ctx context.Context, raw string) (auth.Claims, error) {
+	prefix, secret, ok := parseAPIKey(raw)
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+
+	key, err := s.repo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, auth.ErrInvalidToken
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now())) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	var claims auth.Claims
+	if key.ServiceAccount() {
+		claims = auth.Claims{"role": key.Role, "service_account": key.Name}
+	} else {
+		user, err := s.users.GetByInternalID(ctx, *key.UserID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, auth.ErrInvalidToken
+			}
+			return nil, err
+		}
+		// Keys stop working while their owner is suspended, disabled or
+		// pending, like the owner's own logins.
+		if user.StatusAt(time.Now()) != models.UserStatusActive {
+			return nil, auth.ErrInvalidToken
+		}
+		claims = auth.UserClaims(user.ID.String(), user.Role)
+	}
+	claims["scopes"] = key.Scopes
+	claims["api_key"] = key.Prefix
+	auth.WithOrganization(claims, key.OrganizationID)
+
+	if err := s.repo.TouchLastUsed(ctx, key.ID); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (s *apiKeyService) requirePermission(ctx context.Context, permission string) error {
+	allowed, err := s.permissions.HasPermission(ctx, auth.FromContext(ctx), permission)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// caller returns the user the request in ctx is authenticated as.
+func (s *apiKeyService) caller(ctx context.Context) (*models.User, error) {
+	userID, _ := auth.FromContext(ctx)["user_id"].(string)
+
+	var (
+		user *models.User
+		err  error
+	)
+	if id, parseErr := uuid.Parse(userID); parseErr == nil {
+		user, err = s.users.GetByID(ctx, id)
+	} else if id, parseErr := strconv.Atoi(userID); parseErr == nil {
+		user, err = s.users.GetByInternalID(ctx, id)
+	} else {
+		return nil, ErrForbidden
+	}
+
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrForbidden
+	}
+	return user, err
+}
+
+func generateAPIKey() (prefix, secret string, err error) {
+	// The prefix is looked up by a unique index, so it is long enough for
+	// collisions not to happen in practice.
+	b := make([]byte, 40)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return APIKeyPrefix + hex.EncodeToString(b[:8]), hex.EncodeToString(b[8:]), nil
+}
+
+// parseAPIKey splits "ak_<16 hex>_<secret>" into its prefix and secret. Keys
+// issued with 8 hex characters are still accepted.
+func parseAPIKey(key string) (prefix, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return APIKeyPrefix + id, secret, true
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
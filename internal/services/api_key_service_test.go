@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	users := repository.NewMemoryUserRepository()
+	repo := repository.NewMemoryAPIKeyRepository()
+	roles := NewRoleService(repository.NewMemoryRoleRepository(users), users, nil, repository.NoopTxManager{}, noopAuditService{})
+	service := NewAPIKeyService(repo, users, roles, noopAuditService{})
+
+	alice, _ := users.Create(context.Background(), &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	ctx := auth.NewContext(context.Background(), auth.UserClaims(alice.ID.String(), alice.Role))
+
+	created, err := service.Create(ctx, &models.APIKeyCreate{Name: "batch", Scopes: []string{auth.ScopeUsersRead}})
+	if err != nil {
+		t.Fatalf("Error while creating key: %v", err)
+	}
+	if len(created.APIKey.Prefix) != len(APIKeyPrefix)+16 {
+		t.Errorf("Wrong prefix length, expected: %v, actual: %v", len(APIKeyPrefix)+16, len(created.APIKey.Prefix))
+	}
+
+	claims, err := service.Verify(ctx, created.Key)
+	if err != nil {
+		t.Fatalf("Error while verifying key: %v", err)
+	}
+	if claims["user_id"] != alice.ID.String() || !auth.HasScope(claims, auth.ScopeUsersRead) || auth.HasScope(claims, auth.ScopeUsersWrite) {
+		t.Errorf("Wrong key claims: %v", claims)
+	}
+
+	stored, _ := repo.GetByID(ctx, created.APIKey.ID)
+	if stored.LastUsedAt == nil {
+		t.Errorf("Expected last used time to be recorded")
+	}
+
+	if _, err := service.Verify(ctx, created.APIKey.Prefix+"_wrong"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for wrong secret, actual: %v", err)
+	}
+
+	keyCtx := auth.NewContext(context.Background(), claims)
+	if _, err := service.Create(keyCtx, &models.APIKeyCreate{Name: "wider", Scopes: []string{auth.ScopeUsersWrite}}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden when widening scopes, actual: %v", err)
+	}
+	if _, err := service.Create(ctx, &models.APIKeyCreate{Name: "svc", Scopes: []string{auth.ScopeAll}, ServiceAccountRole: "user"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for service account key from non-admin, actual: %v", err)
+	}
+
+	if _, err := service.Revoke(ctx, created.APIKey.ID); err != nil {
+		t.Fatalf("Error while revoking key: %v", err)
+	}
+	if _, err := service.Verify(ctx, created.Key); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for revoked key, actual: %v", err)
+	}
+}
+
+func TestAPIKeyOfInactiveUser(t *testing.T) {
+	users := repository.NewMemoryUserRepository()
+	roles := NewRoleService(repository.NewMemoryRoleRepository(users), users, nil, repository.NoopTxManager{}, noopAuditService{})
+	service := NewAPIKeyService(repository.NewMemoryAPIKeyRepository(), users, roles, noopAuditService{})
+
+	alice, _ := users.Create(context.Background(), &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	ctx := auth.NewContext(context.Background(), auth.UserClaims(alice.ID.String(), alice.Role))
+	created, err := service.Create(ctx, &models.APIKeyCreate{Name: "batch", Scopes: []string{auth.ScopeUsersRead}})
+	if err != nil {
+		t.Fatalf("Error while creating key: %v", err)
+	}
+
+	until := time.Now().Add(time.Hour)
+	if _, err := users.SetStatus(ctx, alice.ID, models.UserStatusSuspended, &until); err != nil {
+		t.Fatalf("Error while suspending user: %v", err)
+	}
+	if _, err := service.Verify(ctx, created.Key); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for suspended user, actual: %v", err)
+	}
+
+	if _, err := users.SetStatus(ctx, alice.ID, models.UserStatusDisabled, nil); err != nil {
+		t.Fatalf("Error while disabling user: %v", err)
+	}
+	if _, err := service.Verify(ctx, created.Key); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for disabled user, actual: %v", err)
+	}
+
+	if _, err := users.SetStatus(ctx, alice.ID, models.UserStatusActive, nil); err != nil {
+		t.Fatalf("Error while activating user: %v", err)
+	}
+	if _, err := service.Verify(ctx, created.Key); err != nil {
+		t.Errorf("Error while verifying key of active user: %v", err)
+	}
+}
+
+func TestServiceAccountAPIKey(t *testing.T) {
+	users := repository.NewMemoryUserRepository()
+	roles := NewRoleService(repository.NewMemoryRoleRepository(users), users, nil, repository.NoopTxManager{}, noopAuditService{})
+	service := NewAPIKeyService(repository.NewMemoryAPIKeyRepository(), users, roles, noopAuditService{})
+	adminCtx := auth.NewContext(context.Background(), auth.Claims{"role": "admin"})
+
+	expires := time.Now().Add(time.Hour)
+	created, err := service.Create(adminCtx, &models.APIKeyCreate{Name: "reports", Scopes: []string{auth.ScopeAll}, ServiceAccountRole: "admin", ExpiresAt: &expires})
+	if err != nil {
+		t.Fatalf("Error while creating key: %v", err)
+	}
+
+	claims, err := service.Verify(context.Background(), created.Key)
+	if err != nil {
+		t.Fatalf("Error while verifying key: %v", err)
+	}
+	if claims["role"] != "admin" || claims["service_account"] != "reports" {
+		t.Errorf("Wrong service account claims: %v", claims)
+	}
+
+	keys, _ := service.List(adminCtx, true)
+	if len(keys) != 1 {
+		t.Errorf("Wrong number of service account keys, expected: %v, actual: %v", 1, len(keys))
+	}
+
+	past := time.Now().Add(-time.Hour)
+	if _, err := service.Create(adminCtx, &models.APIKeyCreate{Name: "old", Scopes: []string{auth.ScopeAll}, ServiceAccountRole: "user", ExpiresAt: &past}); !errors.Is(err, ErrInvalidExpiresAt) {
+		t.Errorf("Expected ErrInvalidExpiresAt, actual: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/requestinfo"
+)
+
+type AuditService interface {
+	// Record stores event, filling in the actor from the token claims and the
+	// client details from the request info found in ctx.
+	Record(ctx context.Context, event *models.AuditEvent)
+	List(ctx context.Context, filter models.AuditEventFilter, page, pageSize int) ([]*models.AuditEvent, int, error)
+	Export(ctx context.Context, filter models.AuditEventFilter, fn func(event *models.AuditEvent) error) error
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	return &auditService{
+		repo: repo,
+	}
+}
+
+func (s *auditService) Record(ctx context.Context, event *models.AuditEvent) {
+	claims := auth.FromContext(ctx)
+	if event.ActorID == "" {
+		event.ActorID, _ = claims["user_id"].(string)
+	}
+	if event.ActorRole == "" {
+		event.ActorRole, _ = claims["role"].(string)
+	}
+
+	info := requestinfo.FromContext(ctx)
+	event.IP = info.IP
+	event.UserAgent = info.UserAgent
+	event.RequestID = info.RequestID
+	event.OccurredAt = time.Now().UTC()
+
+	// The audited change has already happened at this point, so a failure to
+	// record it is logged rather than turned into an error for the client.
+	if err := s.repo.Create(context.WithoutCancel(ctx), event); err != nil {
+		log.Printf("failed to record audit event %s for %s (request %s): %v", event.Action, event.TargetID, event.RequestID, err)
+	}
+}
+
+func (s *auditService) List(ctx context.Context, filter models.AuditEventFilter, page, pageSize int) ([]*models.AuditEvent, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	events, err := s.repo.List(ctx, filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := s.repo.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return events, count, nil
+}
+
+func (s *auditService) Export(ctx context.Context, filter models.AuditEventFilter, fn func(event *models.AuditEvent) error) error {
+	return s.repo.Stream(ctx, filter, fn)
+}
+
+type noopAuditService struct{}
+
+func (noopAuditService) Record(context.Context, *models.AuditEvent) {}
+
+func (noopAuditService) List(context.Context, models.AuditEventFilter, int, int) ([]*models.AuditEvent, int, error) {
+	return nil, 0, nil
+}
+
+func (noopAuditService) Export(context.Context, models.AuditEventFilter, func(*models.AuditEvent) error) error {
+	return nil
+}
+
+// UserChanges returns the fields that differ between two versions of a user.
+// Either side may be nil for creations and deletions. Fields hidden from JSON
+// are skipped, and a changed password is reported without its hash.
+func UserChanges(before, after *models.User) map[string]models.AuditChange {
+	from := userFields(before)
+	to := userFields(after)
+
+	changes := make(map[string]models.AuditChange)
+	for field := range mergeKeys(from, to) {
+		if field == "updated_at" {
+			continue
+		}
+
+		oldValue, hadOld := from[field]
+		newValue, hasNew := to[field]
+		if hadOld && hasNew && string(oldValue) == string(newValue) {
+			continue
+		}
+		changes[field] = models.AuditChange{From: rawValue(oldValue), To: rawValue(newValue)}
+	}
+
+	oldHash, newHash := "", ""
+	if before != nil {
+		oldHash = before.PasswordHash
+	}
+	if after != nil {
+		newHash = after.PasswordHash
+	}
+	if oldHash != newHash {
+		change := models.AuditChange{}
+		if oldHash != "" {
+			change.From = models.Redacted
+		}
+		if newHash != "" {
+			change.To = models.Redacted
+		}
+		changes["password"] = change
+	}
+
+	return changes
+}
+
+func userFields(user *models.User) map[string]json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if user == nil {
+		return fields
+	}
+
+	payload, err := json.Marshal(user)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(payload, &fields)
+	return fields
+}
+
+func mergeKeys(a, b map[string]json.RawMessage) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for key := range a {
+		keys[key] = struct{}{}
+	}
+	for key := range b {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
+func rawValue(raw json.RawMessage) interface{} {
+	if raw == nil {
+		return nil
+	}
+	var value interface{}
+	json.Unmarshal(raw, &value)
+	return value
+}
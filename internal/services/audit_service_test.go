@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/requestinfo"
+)
+
+func TestUserMutationsAreAudited(t *testing.T) {
+	auditRepo := repository.NewMemoryAuditRepository()
+	service := NewUserService(repository.NewMemoryUserRepository(), auth.NewFakeTokenService(), WithAudit(NewAuditService(auditRepo)))
+
+	user, err := service.Create(context.Background(), &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+
+	ctx := auth.NewContext(context.Background(), auth.UserClaims("admin-id", "admin"))
+	ctx = requestinfo.NewContext(ctx, requestinfo.Info{IP: "10.0.0.1", UserAgent: "test-agent", RequestID: "req-1"})
+
+	if _, err := service.Update(ctx, user.ID, &models.UserUpdate{Role: "admin", Password: "new-password"}); err != nil {
+		t.Fatalf("Error while updating user: %v", err)
+	}
+
+	events, _, err := NewAuditService(auditRepo).List(context.Background(), models.AuditEventFilter{Action: models.AuditActionUserUpdate}, 1, 10)
+	if err != nil {
+		t.Fatalf("Error while listing audit events: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Wrong number of update events, expected: %v, actual: %v", 1, len(events))
+	}
+
+	event := events[0]
+	if event.ActorID != "admin-id" || event.ActorRole != "admin" {
+		t.Errorf("Wrong actor: %v (%v)", event.ActorID, event.ActorRole)
+	}
+	if event.IP != "10.0.0.1" || event.UserAgent != "test-agent" || event.RequestID != "req-1" {
+		t.Errorf("Wrong request info: %+v", event)
+	}
+	if event.TargetID != user.ID.String() {
+		t.Errorf("Wrong target, expected: %v, actual: %v", user.ID, event.TargetID)
+	}
+	if change := event.Changes["role"]; change.From != "user" || change.To != "admin" {
+		t.Errorf("Wrong role change: %+v", change)
+	}
+	if change := event.Changes["password"]; change.From != models.Redacted || change.To != models.Redacted {
+		t.Errorf("Expected password change to be redacted, actual: %+v", change)
+	}
+	if _, ok := event.Changes["username"]; ok {
+		t.Error("Expected unchanged username not to be recorded")
+	}
+}
+
+func TestLoginAttemptsAreAudited(t *testing.T) {
+	ctx := context.Background()
+	auditRepo := repository.NewMemoryAuditRepository()
+	service := NewUserService(repository.NewMemoryUserRepository(), auth.NewFakeTokenService(), WithAudit(NewAuditService(auditRepo)))
+
+	if _, err := service.Register(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"}); err != nil {
+		t.Fatalf("Error while registering: %v", err)
+	}
+	service.Login(ctx, &models.UserLogin{Username: "alice", Password: "wrong-password"})
+	service.Login(ctx, &models.UserLogin{Username: "nobody", Password: "password1"})
+	service.Login(ctx, &models.UserLogin{Username: "alice", Password: "password1"})
+
+	var results []bool
+	NewAuditService(auditRepo).Export(ctx, models.AuditEventFilter{Action: models.AuditActionUserLogin}, func(event *models.AuditEvent) error {
+		results = append(results, event.Success)
+		return nil
+	})
+
+	if len(results) != 3 || results[0] || results[1] || !results[2] {
+		t.Errorf("Wrong login audit results, expected: [false false true], actual: %v", results)
+	}
+}
+
+type failingAuditRepository struct {
+	*repository.MemoryAuditRepository
+}
+
+func (failingAuditRepository) Create(context.Context, *models.AuditEvent) error {
+	return errors.New("connection refused")
+}
+
+func TestAuditRecordFailuresAreLogged(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := requestinfo.NewContext(context.Background(), requestinfo.Info{RequestID: "req-1"})
+	NewAuditService(failingAuditRepository{}).Record(ctx, &models.AuditEvent{Action: models.AuditActionUserUpdate, TargetID: "user-1"})
+
+	if output := logged.String(); !strings.Contains(output, "user-1") || !strings.Contains(output, "req-1") || !strings.Contains(output, "connection refused") {
+		t.Errorf("Wrong log output: %q", output)
+	}
+}
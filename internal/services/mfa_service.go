@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+)
+
+const (
+	recoveryCodeCount      = 10
+	defaultMaxMFAFailures  = 5
+	defaultMFALockDuration = 15 * time.Minute
+)
+
+var (
+	ErrMFAEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrMFARequired    = errors.New("two-factor authentication is required for this role")
+	ErrInvalidMFACode = errors.New("invalid two-factor code")
+	ErrMFALocked      = errors.New("too many invalid two-factor codes, try again later")
+)
+
+type MFAService interface {
+	// Enroll starts TOTP enrollment with a new secret. It replaces an
+	// unconfirmed enrollment.
+	Enroll(ctx context.Context, user *models.User) (*models.TOTPEnrollment, error)
+	// Confirm enables TOTP once code shows the app was set up, and returns
+	// the recovery codes.
+	Confirm(ctx context.Context, user *models.User, code string) ([]string, error)
+	Disable(ctx context.Context, user *models.User, code string) error
+	// RegenerateRecoveryCodes replaces all recovery codes of user.
+	RegenerateRecoveryCodes(ctx context.Context, user *models.User, code string) ([]string, error)
+	Enabled(ctx context.Context, user *models.User) (bool, error)
+	// Required reports whether the role of user must use two-factor
+	// authentication.
+	Required(user *models.User) bool
+	// Verify accepts a TOTP code, or a recovery code when code is empty. Each
+	// code is only accepted once. Too many invalid codes in a row make it
+	// return ErrMFALocked for a while.
+	Verify(ctx context.Context, user *models.User, code, recoveryCode string) error
+}
+
+type mfaService struct {
+	repo   repository.MFARepository
+	config config.MFAConfig
+	tx     repository.TxManager
+	audit  AuditService
+}
+
+func NewMFAService(repo repository.MFARepository, cfg config.MFAConfig, tx repository.TxManager, audit AuditService) MFAService {
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = defaultMaxMFAFailures
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = defaultMFALockDuration
+	}
+	return &mfaService{
+		repo:   repo,
+		config: cfg,
+		tx:     tx,
+		audit:  audit,
+	}
+}
+
+func (s *mfaService) Enroll(ctx context.Context, user *models.User) (*models.TOTPEnrollment, error) {
+	enabled, err := s.Enabled(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAEnabled
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveTOTP(ctx, user.InternalID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPURI(s.issuer(), user.Username, secret),
+	}, nil
+}
+
+func (s *mfaService) Confirm(ctx context.Context, user *models.User, code string) ([]string, error) {
+	totp, err := s.repo.GetTOTP(ctx, user.InternalID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrMFANotEnabled
+		}
+		return nil, err
+	}
+	if totp.ConfirmedAt != nil {
+		return nil, ErrMFAEnabled
+	}
+	// Codes for a pending secret count towards the lockout like any other,
+	// so that confirming cannot be used to guess them.
+	if err := s.check(ctx, totp, code, ""); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.ConfirmTOTP(ctx, user.InternalID); err != nil {
+			return err
+		}
+		var err error
+		codes, err = s.replaceRecoveryCodes(ctx, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, user, models.AuditActionMFAEnable)
+	return codes, nil
+}
+
+func (s *mfaService) Disable(ctx context.Context, user *models.User, code string) error {
+	if s.Required(user) {
+		return ErrMFARequired
+	}
+	if err := s.Verify(ctx, user, code, ""); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteTOTP(ctx, user.InternalID); err != nil {
+		return err
+	}
+
+	s.record(ctx, user, models.AuditActionMFADisable)
+	return nil
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, user *models.User, code string) ([]string, error) {
+	if err := s.Verify(ctx, user, code, ""); err != nil {
+		return nil, err
+	}
+	codes, err := s.replaceRecoveryCodes(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, user, models.AuditActionMFARecoveryCodes)
+	return codes, nil
+}
+
+func (s *mfaService) Enabled(ctx context.Context, user *models.User) (bool, error) {
+	totp, err := s.repo.GetTOTP(ctx, user.InternalID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return totp.ConfirmedAt != nil, nil
+}
+
+func (s *mfaService) Required(user *models.User) bool {
+	return slices.Contains(s.config.RequiredRoles, user.Role)
+}
+
+func (s *mfaService) Verify(ctx context.Context, user *models.User, code, recoveryCode string) error {
+	totp, err := s.repo.GetTOTP(ctx, user.InternalID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrMFANotEnabled
+		}
+		return err
+	}
+	if totp.ConfirmedAt == nil {
+		return ErrMFANotEnabled
+	}
+	return s.check(ctx, totp, code, recoveryCode)
+}
+
+// check verifies a code, counting invalid ones towards the lockout.
+func (s *mfaService) check(ctx context.Context, totp *models.UserTOTP, code, recoveryCode string) error {
+	if totp.LockedUntil != nil && totp.LockedUntil.After(time.Now()) {
+		return ErrMFALocked
+	}
+
+	err := s.verifyCode(ctx, totp, code, recoveryCode)
+	if errors.Is(err, ErrInvalidMFACode) {
+		lockedUntil := time.Now().Add(s.config.LockoutDuration).UTC()
+		locked, recordErr := s.repo.RecordFailure(ctx, totp.UserID, s.config.MaxFailures, lockedUntil)
+		if recordErr != nil {
+			return recordErr
+		}
+		if locked {
+			return ErrMFALocked
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if totp.FailedAttempts > 0 || totp.LockedUntil != nil {
+		return s.repo.ResetFailures(ctx, totp.UserID)
+	}
+	return nil
+}
+
+func (s *mfaService) verifyCode(ctx context.Context, totp *models.UserTOTP, code, recoveryCode string) error {
+	if code != "" {
+		return s.verifyTOTP(ctx, totp, code)
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, totp.UserID, hashRecoveryCode(recoveryCode))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *mfaService) verifyTOTP(ctx context.Context, totp *models.UserTOTP, code string) error {
+	step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	fresh, err := s.repo.UseTOTPStep(ctx, totp.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *mfaService) replaceRecoveryCodes(ctx context.Context, user *models.User) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := hex.EncodeToString(b)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, user.InternalID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *mfaService) issuer() string {
+	if s.config.Issuer == "" {
+		return "Go REST API"
+	}
+	return s.config.Issuer
+}
+
+func (s *mfaService) record(ctx context.Context, user *models.User, action string) {
+	s.audit.Record(ctx, &models.AuditEvent{
+		ActorID:    user.ID.String(),
+		ActorRole:  user.Role,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Success:    true,
+	})
+}
+
+// hashRecoveryCode ignores case and separators, which users tend to get
+// wrong when typing codes in.
+func hashRecoveryCode(code string) string {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+)
+
+func newMFATestServices(requiredRoles ...string) (UserService, MFAService, *auth.FakeTokenService) {
+	tokens := auth.NewFakeTokenService()
+	audit := NewAuditService(repository.NewMemoryAuditRepository())
+	mfa := NewMFAService(repository.NewMemoryMFARepository(), config.MFAConfig{RequiredRoles: requiredRoles}, repository.NoopTxManager{}, audit)
+	users := NewUserService(repository.NewMemoryUserRepository(), tokens, WithMFA(mfa, time.Minute))
+	return users, mfa, tokens
+}
+
+func currentCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+
+	code, err := auth.TOTPCode(secret, auth.TOTPStep(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("Error while generating code: %v", err)
+	}
+	return code
+}
+
+func TestMFALogin(t *testing.T) {
+	ctx := context.Background()
+	users, mfa, tokens := newMFATestServices()
+
+	user, err := users.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	login := &models.UserLogin{Username: "alice", Password: "password1"}
+
+	enrollment, err := mfa.Enroll(ctx, user)
+	if err != nil {
+		t.Fatalf("Error while enrolling: %v", err)
+	}
+	if result, _ := users.Login(ctx, login); result.Token == "" {
+		t.Error("Expected unconfirmed enrollment to keep password-only login")
+	}
+
+	if _, err := mfa.Confirm(ctx, user, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Expected ErrInvalidMFACode for wrong code, actual: %v", err)
+	}
+	recoveryCodes, err := mfa.Confirm(ctx, user, currentCode(t, enrollment.Secret, -1))
+	if err != nil {
+		t.Fatalf("Error while confirming: %v", err)
+	}
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Errorf("Wrong recovery code count, expected: %v, actual: %v", recoveryCodeCount, len(recoveryCodes))
+	}
+
+	result, err := users.Login(ctx, login)
+	if err != nil {
+		t.Fatalf("Error while logging in: %v", err)
+	}
+	if result.Token != "" || !result.MFARequired || result.MFAToken == "" {
+		t.Fatalf("Expected MFA challenge, actual: %+v", result)
+	}
+	if claims, _ := tokens.Verify(ctx, result.MFAToken); claims["user_id"] != nil {
+		t.Errorf("Expected MFA token without user_id, actual: %v", claims)
+	}
+
+	// The code of the previous step was used to confirm, so it is rejected.
+	if _, err := users.VerifyMFA(ctx, &models.MFAVerify{MFAToken: result.MFAToken, Code: currentCode(t, enrollment.Secret, -1)}); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Expected reused code to be rejected, actual: %v", err)
+	}
+	token, err := users.VerifyMFA(ctx, &models.MFAVerify{MFAToken: result.MFAToken, Code: currentCode(t, enrollment.Secret, 0)})
+	if err != nil {
+		t.Fatalf("Error while verifying code: %v", err)
+	}
+	if claims, _ := tokens.Verify(ctx, token); claims["user_id"] != user.ID.String() {
+		t.Errorf("Wrong user_id, expected: %v, actual: %v", user.ID, claims["user_id"])
+	}
+
+	recovery := &models.MFAVerify{MFAToken: result.MFAToken, RecoveryCode: " " + recoveryCodes[0] + " "}
+	if _, err := users.VerifyMFA(ctx, recovery); err != nil {
+		t.Errorf("Error while verifying recovery code: %v", err)
+	}
+	if _, err := users.VerifyMFA(ctx, recovery); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Expected used recovery code to be rejected, actual: %v", err)
+	}
+
+	if _, err := users.VerifyMFA(ctx, &models.MFAVerify{MFAToken: token, Code: "123456"}); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("Expected session token to be rejected as MFA token, actual: %v", err)
+	}
+}
+
+func TestMFARequiredRole(t *testing.T) {
+	ctx := context.Background()
+	users, mfa, _ := newMFATestServices(models.RoleAdmin)
+
+	admin, err := users.Create(ctx, &models.UserCreate{Username: "root", Email: "root@example.com", Password: "password1", Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+
+	result, err := users.Login(ctx, &models.UserLogin{Username: "root", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while logging in: %v", err)
+	}
+	if result.Token != "" || !result.MFAEnrollmentRequired {
+		t.Fatalf("Expected enrollment to be required, actual: %+v", result)
+	}
+	if _, err := users.VerifyMFA(ctx, &models.MFAVerify{MFAToken: result.MFAToken, Code: "123456"}); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("Expected enrollment token to be rejected by VerifyMFA, actual: %v", err)
+	}
+
+	enrollment, err := users.EnrollMFA(ctx, result.MFAToken)
+	if err != nil {
+		t.Fatalf("Error while enrolling: %v", err)
+	}
+	enrolled, err := users.ConfirmMFAEnrollment(ctx, result.MFAToken, currentCode(t, enrollment.Secret, 0))
+	if err != nil {
+		t.Fatalf("Error while confirming enrollment: %v", err)
+	}
+	if enrolled.Token == "" || len(enrolled.RecoveryCodes) != recoveryCodeCount {
+		t.Errorf("Wrong enrollment result: %+v", enrolled)
+	}
+
+	if err := mfa.Disable(ctx, admin, currentCode(t, enrollment.Secret, 1)); !errors.Is(err, ErrMFARequired) {
+		t.Errorf("Expected ErrMFARequired when disabling, actual: %v", err)
+	}
+}
+
+func TestMFALockout(t *testing.T) {
+	ctx := context.Background()
+	users, mfa, _ := newMFATestServices()
+
+	user, err := users.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	enrollment, _ := mfa.Enroll(ctx, user)
+	if _, err := mfa.Confirm(ctx, user, currentCode(t, enrollment.Secret, -1)); err != nil {
+		t.Fatalf("Error while confirming: %v", err)
+	}
+
+	result, err := users.Login(ctx, &models.UserLogin{Username: "alice", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while logging in: %v", err)
+	}
+	wrong := &models.MFAVerify{MFAToken: result.MFAToken, RecoveryCode: "00000-00000"}
+	for i := 1; i < defaultMaxMFAFailures; i++ {
+		if _, err := users.VerifyMFA(ctx, wrong); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("Wrong error for failure %v, expected: %v, actual: %v", i, ErrInvalidMFACode, err)
+		}
+	}
+	if _, err := users.VerifyMFA(ctx, wrong); !errors.Is(err, ErrMFALocked) {
+		t.Errorf("Expected ErrMFALocked after %v failures, actual: %v", defaultMaxMFAFailures, err)
+	}
+
+	valid := &models.MFAVerify{MFAToken: result.MFAToken, Code: currentCode(t, enrollment.Secret, 0)}
+	if _, err := users.VerifyMFA(ctx, valid); !errors.Is(err, ErrMFALocked) {
+		t.Errorf("Expected a valid code to be refused while locked, actual: %v", err)
+	}
+}
+
+func TestMFAConfirmLockout(t *testing.T) {
+	ctx := context.Background()
+	users, mfa, _ := newMFATestServices()
+
+	user, err := users.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	enrollment, _ := mfa.Enroll(ctx, user)
+
+	wrong := currentCode(t, enrollment.Secret, 10)
+	for i := 1; i < defaultMaxMFAFailures; i++ {
+		if _, err := mfa.Confirm(ctx, user, wrong); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("Wrong error for failure %v, expected: %v, actual: %v", i, ErrInvalidMFACode, err)
+		}
+	}
+	if _, err := mfa.Confirm(ctx, user, wrong); !errors.Is(err, ErrMFALocked) {
+		t.Errorf("Expected ErrMFALocked after %v failures, actual: %v", defaultMaxMFAFailures, err)
+	}
+	if _, err := mfa.Confirm(ctx, user, currentCode(t, enrollment.Secret, 0)); !errors.Is(err, ErrMFALocked) {
+		t.Errorf("Expected a valid code to be refused while locked, actual: %v", err)
+	}
+}
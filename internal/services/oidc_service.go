@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/oidc"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+)
+
+// oidcFlowTTL is how long a user has to complete sign-in at the provider.
+const oidcFlowTTL = 10 * time.Minute
+
+var (
+	ErrIdentityLinked   = errors.New("identity is linked to another user")
+	ErrIdentityNoEmail  = errors.New("identity provider did not return an email")
+	ErrOIDCStateInvalid = errors.New("invalid or expired sign-in state")
+)
+
+type OIDCService interface {
+	// Start begins a sign-in with provider. It returns the URL to send the
+	// browser to and the sealed state to keep in the oidc.StateCookie cookie.
+	// When link is set, the identity is linked to the caller in ctx instead.
+	Start(ctx context.Context, provider string, link bool) (string, string, error)
+	// Callback completes the flow and signs the user in like Login.
+	Callback(ctx context.Context, provider, sealedState, state, code string) (*models.LoginResult, error)
+	// ListIdentities returns the identities linked to the caller.
+	ListIdentities(ctx context.Context) ([]*models.UserIdentity, error)
+	// Unlink removes an identity of the caller.
+	Unlink(ctx context.Context, id int64) error
+}
+
+type oidcService struct {
+	providers  map[string]oidc.Provider
+	config     config.OIDCConfig
+	stateKey   []byte
+	identities repository.UserIdentityRepository
+	users      UserService
+	tx         repository.TxManager
+	audit      AuditService
+}
+
+func NewOIDCService(providers map[string]oidc.Provider, cfg config.OIDCConfig, stateKey []byte, identities repository.UserIdentityRepository, users UserService, tx repository.TxManager, audit AuditService) OIDCService {
+	return &oidcService{
+		providers:  providers,
+		config:     cfg,
+		stateKey:   stateKey,
+		identities: identities,
+		users:      users,
+		tx:         tx,
+		audit:      audit,
+	}
+}
+
+func (s *oidcService) Start(ctx context.Context, providerName string, link bool) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", oidc.ErrUnknownProvider
+	}
+
+	state, err := oidc.NewState(providerName, oidcFlowTTL)
+	if err != nil {
+		return "", "", err
+	}
+	if current, ok := tenant.FromContext(ctx); ok {
+		state.OrganizationID = current.ID
+		state.OrganizationSlug = current.Slug
+	}
+	if link {
+		user, err := s.caller(ctx)
+		if err != nil {
+			return "", "", err
+		}
+		state.LinkUserID = user.ID.String()
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, s.redirectURL(providerName), state.State, state.Nonce, state.CodeChallenge())
+	if err != nil {
+		return "", "", err
+	}
+
+	sealed, err := oidc.Seal(s.stateKey, state)
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, sealed, nil
+}
+
+func (s *oidcService) Callback(ctx context.Context, providerName, sealedState, stateParam, code string) (*models.LoginResult, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, oidc.ErrUnknownProvider
+	}
+
+	state, err := oidc.Open(s.stateKey, sealedState)
+	if err != nil || state.Provider != providerName || state.State != stateParam {
+		return nil, ErrOIDCStateInvalid
+	}
+	if state.OrganizationID != 0 {
+		ctx = tenant.NewContext(ctx, tenant.Tenant{ID: state.OrganizationID, Slug: state.OrganizationSlug})
+	}
+
+	identity, err := provider.Exchange(ctx, s.redirectURL(providerName), code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUser(ctx, identity, state.LinkUserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.users.SignIn(ctx, user)
+}
+
+func (s *oidcService) ListIdentities(ctx context.Context) ([]*models.UserIdentity, error) {
+	user, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.identities.ListByUser(ctx, user.InternalID)
+}
+
+func (s *oidcService) Unlink(ctx context.Context, id int64) error {
+	user, err := s.caller(ctx)
+	if err != nil {
+		return err
+	}
+	return s.identities.Delete(ctx, id, user.InternalID)
+}
+
+// resolveUser finds the user identity signs in as, linking or creating one
+// on first sign-in.
+func (s *oidcService) resolveUser(ctx context.Context, identity *oidc.Identity, linkUserID string) (*models.User, error) {
+	linked, err := s.identities.GetBySubject(ctx, identity.Provider, identity.Subject)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	if linked != nil {
+		// Identities point at the internal user key, which the legacy token
+		// lookup accepts.
+		user, err := s.users.GetByTokenUserID(ctx, strconv.Itoa(linked.UserID))
+		if err != nil {
+			return nil, err
+		}
+		if linkUserID != "" && linkUserID != user.ID.String() {
+			return nil, ErrIdentityLinked
+		}
+		if err := s.identities.TouchLastLogin(ctx, linked.ID); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	var user *models.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		switch {
+		case linkUserID != "":
+			user, err = s.users.GetByTokenUserID(ctx, linkUserID)
+		case s.config.Providers[identity.Provider].LinkByEmail && identity.EmailVerified:
+			user, err = s.users.GetByEmail(ctx, identity.Email)
+			if errors.Is(err, repository.ErrNotFound) {
+				user, err = s.createUser(ctx, identity)
+			}
+		default:
+			user, err = s.createUser(ctx, identity)
+		}
+		if err != nil {
+			return err
+		}
+
+		created, err := s.identities.Create(ctx, &models.UserIdentity{
+			UserID:   user.InternalID,
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		})
+		if err != nil {
+			return err
+		}
+		return s.identities.TouchLastLogin(ctx, created.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		ActorID:    user.ID.String(),
+		ActorRole:  user.Role,
+		Action:     models.AuditActionIdentityLink,
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Success:    true,
+		Changes: map[string]models.AuditChange{
+			"provider": {To: identity.Provider},
+			"subject":  {To: identity.Subject},
+		},
+	})
+	return user, nil
+}
+
+// createUser creates a user for a first sign-in. The user gets a random
+// password, so it can only sign in through the provider until it sets one.
+func (s *oidcService) createUser(ctx context.Context, identity *oidc.Identity) (*models.User, error) {
+	if identity.Email == "" {
+		return nil, ErrIdentityNoEmail
+	}
+	if _, err := s.users.GetByEmail(ctx, identity.Email); err == nil {
+		return nil, ErrUserExists
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	base := usernameFor(identity)
+	username := base
+	for attempt := 0; ; attempt++ {
+		user, err := s.users.Create(ctx, &models.UserCreate{
+			Username: username,
+			Email:    identity.Email,
+			Role:     models.RoleUser,
+			// No password matches this hash until the user sets one.
+			PasswordHash: "!",
+		})
+		if !errors.Is(err, repository.ErrConflict) || attempt == 3 {
+			return user, err
+		}
+
+		suffix, err := randomHex(2)
+		if err != nil {
+			return nil, err
+		}
+		username = base + "-" + suffix
+	}
+}
+
+func (s *oidcService) caller(ctx context.Context) (*models.User, error) {
+	userID, _ := auth.FromContext(ctx)["user_id"].(string)
+	user, err := s.users.GetByTokenUserID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrForbidden
+	}
+	return user, err
+}
+
+func (s *oidcService) redirectURL(provider string) string {
+	return strings.TrimSuffix(s.config.PublicURL, "/") + "/api/v1/auth/oidc/" + provider + "/callback"
+}
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// usernameFor derives a username from what the provider knows about the
+// user.
+func usernameFor(identity *oidc.Identity) string {
+	username := identity.PreferredUsername
+	if username == "" {
+		username, _, _ = strings.Cut(identity.Email, "@")
+	}
+	username = usernameInvalidChars.ReplaceAllString(username, "")
+	if len(username) > 90 {
+		username = username[:90]
+	}
+	if len(username) < 3 {
+		username = identity.Provider + "-" + username
+	}
+	return strings.ToLower(username)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
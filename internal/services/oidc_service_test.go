@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/oidc"
+	"github.com/Romasmi/go-rest-api-template/internal/oidc/oidctest"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+)
+
+type oidcFixture struct {
+	service  OIDCService
+	provider *oidctest.Provider
+	users    *repository.MemoryUserRepository
+	tokens   *auth.FakeTokenService
+}
+
+func newOIDCFixture(t *testing.T, linkByEmail bool) *oidcFixture {
+	provider := oidctest.NewProvider(t)
+	cfg := config.OIDCConfig{
+		PublicURL: "http://api.example.com",
+		Providers: map[string]config.OIDCProviderConfig{
+			"test": {Issuer: provider.URL, ClientID: oidctest.ClientID, ClientSecret: oidctest.ClientSecret, LinkByEmail: linkByEmail},
+		},
+	}
+	providers, err := oidc.NewProviders(cfg)
+	if err != nil {
+		t.Fatalf("Error while building providers: %v", err)
+	}
+
+	users := repository.NewMemoryUserRepository()
+	tokens := auth.NewFakeTokenService()
+	audit := NewAuditService(repository.NewMemoryAuditRepository())
+	service := NewOIDCService(providers, cfg, []byte("state-key"), repository.NewMemoryUserIdentityRepository(),
+		NewUserService(users, tokens), repository.NoopTxManager{}, audit)
+
+	return &oidcFixture{service: service, provider: provider, users: users, tokens: tokens}
+}
+
+// signIn runs a flow through the fake provider and returns the claims of the
+// issued token.
+func (f *oidcFixture) signIn(t *testing.T, ctx context.Context, link bool) (auth.Claims, error) {
+	t.Helper()
+
+	authURL, state, err := f.service.Start(ctx, "test", link)
+	if err != nil {
+		t.Fatalf("Error while starting flow: %v", err)
+	}
+	callback, err := f.provider.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Error while authorizing: %v", err)
+	}
+
+	result, err := f.service.Callback(context.Background(), "test", state, callback.Get("state"), callback.Get("code"))
+	if err != nil {
+		return nil, err
+	}
+	return f.tokens.Verify(ctx, result.Token)
+}
+
+func TestOIDCSignInCreatesUser(t *testing.T) {
+	f := newOIDCFixture(t, false)
+	ctx := context.Background()
+
+	claims, err := f.signIn(t, ctx, false)
+	if err != nil {
+		t.Fatalf("Error while signing in: %v", err)
+	}
+	user, err := f.users.GetByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Error while getting created user: %v", err)
+	}
+	if claims["user_id"] != user.ID.String() {
+		t.Errorf("Wrong user_id, expected: %v, actual: %v", user.ID, claims["user_id"])
+	}
+
+	again, err := f.signIn(t, ctx, false)
+	if err != nil {
+		t.Fatalf("Error while signing in again: %v", err)
+	}
+	if again["user_id"] != user.ID.String() {
+		t.Errorf("Wrong user_id on second sign-in, expected: %v, actual: %v", user.ID, again["user_id"])
+	}
+	if count, _ := f.users.Count(ctx, models.UserFilter{}); count != 1 {
+		t.Errorf("Wrong user count, expected: %v, actual: %v", 1, count)
+	}
+}
+
+func TestOIDCSignInExistingEmail(t *testing.T) {
+	ctx := context.Background()
+	for _, linkByEmail := range []bool{false, true} {
+		f := newOIDCFixture(t, linkByEmail)
+		existing, err := f.users.Create(ctx, &models.UserCreate{Username: "alice2", Email: "alice@example.com", Password: "password1"})
+		if err != nil {
+			t.Fatalf("Error while creating user: %v", err)
+		}
+
+		claims, err := f.signIn(t, ctx, false)
+		if !linkByEmail {
+			if !errors.Is(err, ErrUserExists) {
+				t.Errorf("Expected ErrUserExists without linkByEmail, actual: %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error while signing in: %v", err)
+		}
+		if claims["user_id"] != existing.ID.String() {
+			t.Errorf("Wrong user_id, expected: %v, actual: %v", existing.ID, claims["user_id"])
+		}
+	}
+}
+
+func TestOIDCLinkIdentity(t *testing.T) {
+	f := newOIDCFixture(t, false)
+	ctx := context.Background()
+
+	bob, err := f.users.Create(ctx, &models.UserCreate{Username: "bob", Email: "bob@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	bobCtx := auth.NewContext(ctx, auth.UserClaims(bob.ID.String(), bob.Role))
+
+	claims, err := f.signIn(t, bobCtx, true)
+	if err != nil {
+		t.Fatalf("Error while linking identity: %v", err)
+	}
+	if claims["user_id"] != bob.ID.String() {
+		t.Errorf("Wrong user_id, expected: %v, actual: %v", bob.ID, claims["user_id"])
+	}
+
+	identities, err := f.service.ListIdentities(bobCtx)
+	if err != nil || len(identities) != 1 {
+		t.Fatalf("Wrong identities, expected one, actual: %v, %v", identities, err)
+	}
+
+	carol, _ := f.users.Create(ctx, &models.UserCreate{Username: "carol", Email: "carol@example.com", Password: "password1"})
+	carolCtx := auth.NewContext(ctx, auth.UserClaims(carol.ID.String(), carol.Role))
+	if _, err := f.signIn(t, carolCtx, true); !errors.Is(err, ErrIdentityLinked) {
+		t.Errorf("Expected ErrIdentityLinked, actual: %v", err)
+	}
+
+	if err := f.service.Unlink(carolCtx, identities[0].ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected another user's identity to be not found, actual: %v", err)
+	}
+	if err := f.service.Unlink(bobCtx, identities[0].ID); err != nil {
+		t.Errorf("Error while unlinking identity: %v", err)
+	}
+}
+
+func TestOIDCCallbackRejectsTamperedState(t *testing.T) {
+	f := newOIDCFixture(t, false)
+
+	authURL, state, err := f.service.Start(context.Background(), "test", false)
+	if err != nil {
+		t.Fatalf("Error while starting flow: %v", err)
+	}
+	callback, err := f.provider.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Error while authorizing: %v", err)
+	}
+
+	if _, err := f.service.Callback(context.Background(), "test", state, "other", callback.Get("code")); !errors.Is(err, ErrOIDCStateInvalid) {
+		t.Errorf("Expected ErrOIDCStateInvalid for wrong state, actual: %v", err)
+	}
+	if _, err := f.service.Callback(context.Background(), "test", state+"x", callback.Get("state"), callback.Get("code")); !errors.Is(err, ErrOIDCStateInvalid) {
+		t.Errorf("Expected ErrOIDCStateInvalid for tampered cookie, actual: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+)
+
+var ErrOrganizationExists = errors.New("organization already exists")
+
+type OrganizationService interface {
+	// Create provisions an organization together with its first admin user.
+	Create(ctx context.Context, organization *models.OrganizationCreate) (*models.Organization, error)
+	List(ctx context.Context) ([]*models.Organization, error)
+	// Current returns the organization the call is scoped to.
+	Current(ctx context.Context) (*models.Organization, error)
+}
+
+type organizationService struct {
+	repo  repository.OrganizationRepository
+	users UserService
+	tx    repository.TxManager
+	audit AuditService
+}
+
+func NewOrganizationService(repo repository.OrganizationRepository, users UserService, tx repository.TxManager, audit AuditService) OrganizationService {
+	return &organizationService{
+		repo:  repo,
+		users: users,
+		tx:    tx,
+		audit: audit,
+	}
+}
+
+func (s *organizationService) Create(ctx context.Context, organization *models.OrganizationCreate) (*models.Organization, error) {
+	var created *models.Organization
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if created, err = s.repo.Create(ctx, organization); err != nil {
+			if errors.Is(err, repository.ErrConflict) {
+				return ErrOrganizationExists
+			}
+			return err
+		}
+
+		admin := organization.Admin
+		admin.Role = models.RoleAdmin
+		_, err = s.users.Create(tenant.NewContext(ctx, tenant.Tenant{ID: created.ID, Slug: created.Slug}), &admin)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Action:     models.AuditActionOrganizationCreate,
+		TargetType: "organization",
+		TargetID:   strconv.Itoa(created.ID),
+		Success:    true,
+		Changes: map[string]models.AuditChange{
+			"slug": {To: created.Slug},
+			"name": {To: created.Name},
+		},
+	})
+	return created, nil
+}
+
+func (s *organizationService) List(ctx context.Context) ([]*models.Organization, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *organizationService) Current(ctx context.Context) (*models.Organization, error) {
+	current, ok := tenant.FromContext(ctx)
+	if !ok {
+		return s.repo.GetByID(ctx, models.DefaultOrganizationID)
+	}
+	return s.repo.GetByID(ctx, current.ID)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+)
+
+func TestCreateOrganization(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	roles := repository.NewMemoryRoleRepository(users)
+	tokens := auth.NewFakeTokenService()
+	audit := NewAuditService(repository.NewMemoryAuditRepository())
+	userService := NewUserService(users, tokens, WithRoles(roles))
+	service := NewOrganizationService(repository.NewMemoryOrganizationRepository(), userService, repository.NoopTxManager{}, audit)
+
+	if _, err := userService.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"}); err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+
+	create := &models.OrganizationCreate{
+		Slug:  "acme",
+		Name:  "Acme",
+		Admin: models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"},
+	}
+	acme, err := service.Create(ctx, create)
+	if err != nil {
+		t.Fatalf("Error while creating organization: %v", err)
+	}
+	if _, err := service.Create(ctx, create); !errors.Is(err, ErrOrganizationExists) {
+		t.Errorf("Expected ErrOrganizationExists, actual: %v", err)
+	}
+
+	acmeCtx := tenant.NewContext(ctx, tenant.Tenant{ID: acme.ID, Slug: acme.Slug})
+	admin, err := users.GetByUsername(acmeCtx, "alice")
+	if err != nil {
+		t.Fatalf("Error while getting organization admin: %v", err)
+	}
+	if admin.OrganizationID != acme.ID || admin.Role != models.RoleAdmin {
+		t.Errorf("Wrong organization admin, expected: %v in %v, actual: %v in %v", models.RoleAdmin, acme.ID, admin.Role, admin.OrganizationID)
+	}
+
+	result, err := userService.Login(acmeCtx, &models.UserLogin{Username: "alice", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while logging in: %v", err)
+	}
+	claims, _ := tokens.Verify(ctx, result.Token)
+	if id, _ := auth.OrganizationID(claims); id != acme.ID {
+		t.Errorf("Wrong organization claim, expected: %v, actual: %v", acme.ID, id)
+	}
+
+	current, err := service.Current(acmeCtx)
+	if err != nil || current.Slug != "acme" {
+		t.Errorf("Wrong current organization, expected: %v, actual: %v, %v", "acme", current, err)
+	}
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+// passkeyCeremonyTTL is how long the browser has to complete a ceremony.
+const passkeyCeremonyTTL = 5 * time.Minute
+
+var (
+	ErrPasskeysDisabled      = errors.New("passkeys are not configured")
+	ErrPasskeyFailed         = errors.New("passkey verification failed")
+	ErrInvalidPasskeySession = errors.New("invalid or expired passkey session")
+)
+
+type PasskeyService interface {
+	// BeginRegistration starts adding a passkey for the caller in ctx.
+	BeginRegistration(ctx context.Context) (*models.PasskeyCeremony, error)
+	FinishRegistration(ctx context.Context, finish *models.PasskeyFinish) (*models.Passkey, error)
+	// List returns the passkeys of the caller.
+	List(ctx context.Context) ([]*models.Passkey, error)
+	// Delete removes a passkey of the caller.
+	Delete(ctx context.Context, id int64) error
+	// BeginLogin starts a login with a passkey of username or, when it is
+	// empty or has none, with any discoverable passkey.
+	BeginLogin(ctx context.Context, username string) (*models.PasskeyCeremony, error)
+	// FinishLogin verifies the assertion and continues like Login, so users
+	// with two-factor authentication still get an MFA challenge.
+	FinishLogin(ctx context.Context, finish *models.PasskeyFinish) (*models.LoginResult, error)
+}
+
+type passkeyService struct {
+	webauthn *webauthn.WebAuthn
+	repo     repository.PasskeyRepository
+	users    UserService
+	tokens   auth.TokenService
+	audit    AuditService
+}
+
+// NewPasskeyService returns a service that fails with ErrPasskeysDisabled
+// when cfg has no relying party ID.
+func NewPasskeyService(cfg config.WebAuthnConfig, repo repository.PasskeyRepository, users UserService, tokens auth.TokenService, audit AuditService) (PasskeyService, error) {
+	s := &passkeyService{
+		repo:   repo,
+		users:  users,
+		tokens: tokens,
+		audit:  audit,
+	}
+	if cfg.RPID == "" {
+		return s, nil
+	}
+
+	displayName := cfg.RPDisplayName
+	if displayName == "" {
+		displayName = cfg.RPID
+	}
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: displayName,
+		RPOrigins:     cfg.RPOrigins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure webauthn: %w", err)
+	}
+	s.webauthn = wa
+	return s, nil
+}
+
+func (s *passkeyService) BeginRegistration(ctx context.Context) (*models.PasskeyCeremony, error) {
+	if s.webauthn == nil {
+		return nil, ErrPasskeysDisabled
+	}
+	user, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := s.webauthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(user.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin passkey registration: %w", err)
+	}
+	return s.ceremony(ctx, creation, session, auth.PurposePasskeyRegister, user.user.OrganizationID)
+}
+
+func (s *passkeyService) FinishRegistration(ctx context.Context, finish *models.PasskeyFinish) (*models.Passkey, error) {
+	if s.webauthn == nil {
+		return nil, ErrPasskeysDisabled
+	}
+	user, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.session(ctx, finish.Session, auth.PurposePasskeyRegister)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(finish.Credential)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyFailed, err)
+	}
+	credential, err := s.webauthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyFailed, err)
+	}
+
+	transports := make([]string, len(credential.Transport))
+	for i, transport := range credential.Transport {
+		transports[i] = string(transport)
+	}
+	passkey, err := s.repo.Create(ctx, &models.Passkey{
+		UserID:          user.user.InternalID,
+		Name:            finish.Name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       int64(credential.Authenticator.SignCount),
+		Transports:      transports,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, models.AuditActionPasskeyRegister, user.user, passkey)
+	return passkey, nil
+}
+
+func (s *passkeyService) List(ctx context.Context) ([]*models.Passkey, error) {
+	user, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return user.passkeys, nil
+}
+
+func (s *passkeyService) Delete(ctx context.Context, id int64) error {
+	user, err := s.caller(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id, user.user.InternalID); err != nil {
+		return err
+	}
+
+	s.record(ctx, models.AuditActionPasskeyDelete, user.user, &models.Passkey{ID: id})
+	return nil
+}
+
+func (s *passkeyService) BeginLogin(ctx context.Context, username string) (*models.PasskeyCeremony, error) {
+	if s.webauthn == nil {
+		return nil, ErrPasskeysDisabled
+	}
+
+	// Unknown usernames get the same discoverable ceremony as an empty one,
+	// so the response does not tell which users exist.
+	if username != "" {
+		user, err := s.users.GetByUsername(ctx, username)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+		if user != nil {
+			passkeyUser, err := s.passkeyUser(ctx, user)
+			if err != nil {
+				return nil, err
+			}
+			if len(passkeyUser.passkeys) > 0 {
+				assertion, session, err := s.webauthn.BeginLogin(passkeyUser)
+				if err != nil {
+					return nil, fmt.Errorf("failed to begin passkey login: %w", err)
+				}
+				return s.ceremony(ctx, assertion, session, auth.PurposePasskeyLogin, user.OrganizationID)
+			}
+		}
+	}
+
+	assertion, session, err := s.webauthn.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin passkey login: %w", err)
+	}
+	current, _ := tenant.FromContext(ctx)
+	organizationID := current.ID
+	if organizationID == 0 {
+		organizationID = models.DefaultOrganizationID
+	}
+	return s.ceremony(ctx, assertion, session, auth.PurposePasskeyLogin, organizationID)
+}
+
+func (s *passkeyService) FinishLogin(ctx context.Context, finish *models.PasskeyFinish) (*models.LoginResult, error) {
+	if s.webauthn == nil {
+		return nil, ErrPasskeysDisabled
+	}
+	session, err := s.session(ctx, finish.Session, auth.PurposePasskeyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(finish.Credential)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyFailed, err)
+	}
+
+	var user *passkeyUser
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		id, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		found, err := s.users.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		user, err = s.passkeyUser(ctx, found)
+		return user, err
+	}
+
+	var credential *webauthn.Credential
+	if len(session.UserID) > 0 {
+		var found webauthn.User
+		if found, err = findUser(nil, session.UserID); err == nil {
+			credential, err = s.webauthn.ValidateLogin(found, *session, parsed)
+		}
+	} else {
+		credential, err = s.webauthn.ValidateDiscoverableLogin(findUser, *session, parsed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyFailed, err)
+	}
+	// A counter that did not increase means the key may have been copied.
+	if credential.Authenticator.CloneWarning {
+		return nil, fmt.Errorf("%w: sign count did not increase", ErrPasskeyFailed)
+	}
+
+	for _, passkey := range user.passkeys {
+		if string(passkey.CredentialID) == string(credential.ID) {
+			if err := s.repo.RecordUse(ctx, passkey.ID, int64(credential.Authenticator.SignCount), credential.Flags.BackupState); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.users.SignIn(ctx, user.user)
+}
+
+// ceremony seals session into a short-lived token the client sends back to
+// finish the ceremony. Its challenge is stored so that the token can only be
+// used once.
+func (s *passkeyService) ceremony(ctx context.Context, options interface{}, session *webauthn.SessionData, purpose string, organizationID int) (*models.PasskeyCeremony, error) {
+	expiresAt := time.Now().Add(passkeyCeremonyTTL)
+	if err := s.repo.SaveChallenge(ctx, session.Challenge, expiresAt.UTC()); err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode passkey session: %w", err)
+	}
+
+	token, err := s.tokens.Issue(auth.Claims{
+		"passkey_session":      string(encoded),
+		auth.ClaimOrganization: strconv.Itoa(organizationID),
+		auth.ClaimPurpose:      purpose,
+		"exp":                  expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate passkey session: %w", err)
+	}
+	return &models.PasskeyCeremony{Options: options, Session: token}, nil
+}
+
+// session opens a token created by ceremony and uses up its challenge. It
+// must be used in the organization it was created in.
+func (s *passkeyService) session(ctx context.Context, token, purpose string) (*webauthn.SessionData, error) {
+	claims, err := s.tokens.Verify(ctx, token)
+	if err != nil || claims[auth.ClaimPurpose] != purpose {
+		return nil, ErrInvalidPasskeySession
+	}
+	organizationID, _ := auth.OrganizationID(claims)
+	if current, ok := tenant.FromContext(ctx); ok && current.ID != organizationID {
+		return nil, ErrInvalidPasskeySession
+	}
+
+	encoded, _ := claims["passkey_session"].(string)
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(encoded), &session); err != nil {
+		return nil, ErrInvalidPasskeySession
+	}
+
+	fresh, err := s.repo.UseChallenge(ctx, session.Challenge, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrInvalidPasskeySession
+	}
+	return &session, nil
+}
+
+func (s *passkeyService) caller(ctx context.Context) (*passkeyUser, error) {
+	userID, _ := auth.FromContext(ctx)["user_id"].(string)
+	user, err := s.users.GetByTokenUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrForbidden
+		}
+		return nil, err
+	}
+	return s.passkeyUser(ctx, user)
+}
+
+func (s *passkeyService) passkeyUser(ctx context.Context, user *models.User) (*passkeyUser, error) {
+	passkeys, err := s.repo.ListByUser(ctx, user.InternalID)
+	if err != nil {
+		return nil, err
+	}
+	return &passkeyUser{user: user, passkeys: passkeys}, nil
+}
+
+func (s *passkeyService) record(ctx context.Context, action string, user *models.User, passkey *models.Passkey) {
+	changes := map[string]models.AuditChange{"user_id": {To: user.ID.String()}}
+	if passkey.Name != "" {
+		changes["name"] = models.AuditChange{To: passkey.Name}
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Action:     action,
+		TargetType: "passkey",
+		TargetID:   strconv.FormatInt(passkey.ID, 10),
+		Success:    true,
+		Changes:    changes,
+	})
+}
+
+// passkeyUser adapts a user and its passkeys to webauthn.User. The user
+// handle is the public user ID.
+type passkeyUser struct {
+	user     *models.User
+	passkeys []*models.Passkey
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.user.Username
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.passkeys))
+	for i, passkey := range u.passkeys {
+		transports := make([]protocol.AuthenticatorTransport, len(passkey.Transports))
+		for j, transport := range passkey.Transports {
+			transports[j] = protocol.AuthenticatorTransport(transport)
+		}
+		credentials[i] = webauthn.Credential{
+			ID:              passkey.CredentialID,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: passkey.BackupEligible,
+				BackupState:    passkey.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    passkey.AAGUID,
+				SignCount: uint32(passkey.SignCount),
+			},
+		}
+	}
+	return credentials
+}
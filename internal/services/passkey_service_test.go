@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/webauthntest"
+)
+
+func TestPasskeyLogin(t *testing.T) {
+	ctx := context.Background()
+	tokens := auth.NewFakeTokenService()
+	audit := NewAuditService(repository.NewMemoryAuditRepository())
+	users := NewUserService(repository.NewMemoryUserRepository(), tokens)
+	passkeys, err := NewPasskeyService(config.WebAuthnConfig{RPID: "localhost", RPOrigins: []string{"http://localhost:3000"}},
+		repository.NewMemoryPasskeyRepository(), users, tokens, audit)
+	if err != nil {
+		t.Fatalf("Error while creating passkey service: %v", err)
+	}
+	authenticator := webauthntest.New("http://localhost:3000")
+
+	alice, err := users.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	aliceCtx := auth.NewContext(ctx, auth.UserClaims(alice.ID.String(), alice.Role))
+
+	registration, err := passkeys.BeginRegistration(aliceCtx)
+	if err != nil {
+		t.Fatalf("Error while beginning registration: %v", err)
+	}
+	credential, err := authenticator.Register(registration.Options)
+	if err != nil {
+		t.Fatalf("Error while creating credential: %v", err)
+	}
+	if _, err := passkeys.FinishRegistration(aliceCtx, &models.PasskeyFinish{Session: "invalid", Credential: credential}); !errors.Is(err, ErrInvalidPasskeySession) {
+		t.Errorf("Expected ErrInvalidPasskeySession, actual: %v", err)
+	}
+	passkey, err := passkeys.FinishRegistration(aliceCtx, &models.PasskeyFinish{Session: registration.Session, Name: "laptop", Credential: credential})
+	if err != nil {
+		t.Fatalf("Error while finishing registration: %v", err)
+	}
+	if passkey.Name != "laptop" || len(passkey.CredentialID) == 0 || len(passkey.PublicKey) == 0 {
+		t.Errorf("Wrong passkey: %+v", passkey)
+	}
+
+	// Usernames and discoverable credentials both sign in as alice.
+	for _, username := range []string{"alice", ""} {
+		ceremony, err := passkeys.BeginLogin(ctx, username)
+		if err != nil {
+			t.Fatalf("Error while beginning login: %v", err)
+		}
+		assertion, err := authenticator.Login(ceremony.Options)
+		if err != nil {
+			t.Fatalf("Error while signing assertion: %v", err)
+		}
+		result, err := passkeys.FinishLogin(ctx, &models.PasskeyFinish{Session: ceremony.Session, Credential: assertion})
+		if err != nil {
+			t.Fatalf("Error while finishing login: %v", err)
+		}
+		if claims, _ := tokens.Verify(ctx, result.Token); claims["user_id"] != alice.ID.String() {
+			t.Errorf("Wrong user_id, expected: %v, actual: %v", alice.ID, claims["user_id"])
+		}
+
+		// A ceremony can only be finished once.
+		if _, err := passkeys.FinishLogin(ctx, &models.PasskeyFinish{Session: ceremony.Session, Credential: assertion}); !errors.Is(err, ErrInvalidPasskeySession) {
+			t.Errorf("Expected ErrInvalidPasskeySession for a reused session, actual: %v", err)
+		}
+
+		// Registration sessions cannot be used to log in.
+		if _, err := passkeys.FinishLogin(ctx, &models.PasskeyFinish{Session: registration.Session, Credential: assertion}); !errors.Is(err, ErrInvalidPasskeySession) {
+			t.Errorf("Expected ErrInvalidPasskeySession, actual: %v", err)
+		}
+	}
+
+	list, err := passkeys.List(aliceCtx)
+	if err != nil {
+		t.Fatalf("Error while listing passkeys: %v", err)
+	}
+	if len(list) != 1 || list[0].SignCount != 2 || list[0].LastUsedAt == nil {
+		t.Errorf("Wrong passkeys after login: %+v", list)
+	}
+
+	if err := passkeys.Delete(aliceCtx, passkey.ID); err != nil {
+		t.Fatalf("Error while deleting passkey: %v", err)
+	}
+	ceremony, err := passkeys.BeginLogin(ctx, "")
+	if err != nil {
+		t.Fatalf("Error while beginning login: %v", err)
+	}
+	assertion, _ := authenticator.Login(ceremony.Options)
+	if _, err := passkeys.FinishLogin(ctx, &models.PasskeyFinish{Session: ceremony.Session, Credential: assertion}); !errors.Is(err, ErrPasskeyFailed) {
+		t.Errorf("Expected ErrPasskeyFailed for deleted passkey, actual: %v", err)
+	}
+}
+
+func TestPasskeyLoginAsksForMFA(t *testing.T) {
+	ctx := context.Background()
+	users, mfa, tokens := newMFATestServices()
+	passkeys, err := NewPasskeyService(config.WebAuthnConfig{RPID: "localhost", RPOrigins: []string{"http://localhost:3000"}},
+		repository.NewMemoryPasskeyRepository(), users, tokens, NewAuditService(repository.NewMemoryAuditRepository()))
+	if err != nil {
+		t.Fatalf("Error while creating passkey service: %v", err)
+	}
+	authenticator := webauthntest.New("http://localhost:3000")
+
+	alice, err := users.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	aliceCtx := auth.NewContext(ctx, auth.UserClaims(alice.ID.String(), alice.Role))
+	registration, _ := passkeys.BeginRegistration(aliceCtx)
+	credential, _ := authenticator.Register(registration.Options)
+	if _, err := passkeys.FinishRegistration(aliceCtx, &models.PasskeyFinish{Session: registration.Session, Credential: credential}); err != nil {
+		t.Fatalf("Error while finishing registration: %v", err)
+	}
+
+	enrollment, _ := mfa.Enroll(ctx, alice)
+	if _, err := mfa.Confirm(ctx, alice, currentCode(t, enrollment.Secret, 0)); err != nil {
+		t.Fatalf("Error while confirming: %v", err)
+	}
+
+	ceremony, err := passkeys.BeginLogin(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Error while beginning login: %v", err)
+	}
+	assertion, _ := authenticator.Login(ceremony.Options)
+	result, err := passkeys.FinishLogin(ctx, &models.PasskeyFinish{Session: ceremony.Session, Credential: assertion})
+	if err != nil {
+		t.Fatalf("Error while finishing login: %v", err)
+	}
+	if result.Token != "" || !result.MFARequired || result.MFAToken == "" {
+		t.Errorf("Expected MFA challenge, actual: %+v", result)
+	}
+}
+
+func TestPasskeysDisabled(t *testing.T) {
+	tokens := auth.NewFakeTokenService()
+	users := NewUserService(repository.NewMemoryUserRepository(), tokens)
+	passkeys, err := NewPasskeyService(config.WebAuthnConfig{}, repository.NewMemoryPasskeyRepository(), users, tokens, NewAuditService(repository.NewMemoryAuditRepository()))
+	if err != nil {
+		t.Fatalf("Error while creating passkey service: %v", err)
+	}
+
+	if _, err := passkeys.BeginLogin(context.Background(), ""); !errors.Is(err, ErrPasskeysDisabled) {
+		t.Errorf("Expected ErrPasskeysDisabled, actual: %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownRole       = errors.New("unknown role")
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrBuiltinRole       = errors.New("builtin roles cannot be deleted")
+)
+
+type RoleService interface {
+	ListPermissions(ctx context.Context) ([]*models.Permission, error)
+	ListRoles(ctx context.Context) ([]*models.Role, error)
+	GetRole(ctx context.Context, name string) (*models.Role, error)
+	CreateRole(ctx context.Context, role *models.RoleCreate) (*models.Role, error)
+	UpdateRole(ctx context.Context, name string, role *models.RoleUpdate) (*models.Role, error)
+	DeleteRole(ctx context.Context, name string) error
+	GetUserRoles(ctx context.Context, userID uuid.UUID) ([]string, error)
+	SetUserRoles(ctx context.Context, userID uuid.UUID, roles []string) ([]string, error)
+	// HasPermission reports whether the user or service account behind claims
+	// has permission through any of its roles. It makes the service a
+	// middleware.PermissionChecker.
+	HasPermission(ctx context.Context, claims auth.Claims, permission string) (bool, error)
+}
+
+type roleService struct {
+	repo        repository.RoleRepository
+	users       repository.UserRepository
+	userService UserService
+	tx          repository.TxManager
+	audit       AuditService
+}
+
+// NewRoleService returns a RoleService that changes the roles of users
+// through userService, so that the primary role on the user is updated with
+// the usual events.
+func NewRoleService(repo repository.RoleRepository, users repository.UserRepository, userService UserService, tx repository.TxManager, audit AuditService) RoleService {
+	return &roleService{
+		repo:        repo,
+		users:       users,
+		userService: userService,
+		tx:          tx,
+		audit:       audit,
+	}
+}
+
+func (s *roleService) ListPermissions(ctx context.Context) ([]*models.Permission, error) {
+	return s.repo.ListPermissions(ctx)
+}
+
+func (s *roleService) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	return s.repo.ListRoles(ctx)
+}
+
+func (s *roleService) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	return s.repo.GetRole(ctx, name)
+}
+
+func (s *roleService) CreateRole(ctx context.Context, role *models.RoleCreate) (*models.Role, error) {
+	if err := s.checkPermissions(ctx, role.Permissions); err != nil {
+		return nil, err
+	}
+
+	var created *models.Role
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = s.repo.CreateRole(ctx, role)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordRoleChange(ctx, models.AuditActionRoleCreate, created.Name, nil, created)
+	return created, nil
+}
+
+func (s *roleService) UpdateRole(ctx context.Context, name string, role *models.RoleUpdate) (*models.Role, error) {
+	if err := s.checkPermissions(ctx, role.Permissions); err != nil {
+		return nil, err
+	}
+
+	before, err := s.repo.GetRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated *models.Role
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		updated, err = s.repo.UpdateRole(ctx, name, role)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordRoleChange(ctx, models.AuditActionRoleUpdate, name, before, updated)
+	return updated, nil
+}
+
+func (s *roleService) DeleteRole(ctx context.Context, name string) error {
+	role, err := s.repo.GetRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	if role.Builtin {
+		return ErrBuiltinRole
+	}
+
+	if err := s.repo.DeleteRole(ctx, name); err != nil {
+		return err
+	}
+
+	s.recordRoleChange(ctx, models.AuditActionRoleDelete, name, role, nil)
+	return nil
+}
+
+func (s *roleService) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.UserRoles(ctx, user.InternalID)
+}
+
+func (s *roleService) SetUserRoles(ctx context.Context, userID uuid.UUID, roles []string) ([]string, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := s.repo.UserRoles(ctx, user.InternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.userService.SetRoles(ctx, userID, roles); err != nil {
+		return nil, err
+	}
+
+	after, err := s.repo.UserRoles(ctx, user.InternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Action:     models.AuditActionUserRoles,
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Success:    true,
+		Changes:    map[string]models.AuditChange{"roles": {From: before, To: after}},
+	})
+	return after, nil
+}
+
+func (s *roleService) HasPermission(ctx context.Context, claims auth.Claims, permission string) (bool, error) {
+	return hasPermission(ctx, s.repo, s.users, claims, permission)
+}
+
+func (s *roleService) checkPermissions(ctx context.Context, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	known, err := s.repo.ListPermissions(ctx)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(known))
+	for _, p := range known {
+		names[p.Name] = true
+	}
+
+	for _, p := range permissions {
+		if !names[p] {
+			return ErrUnknownPermission
+		}
+	}
+	return nil
+}
+
+func (s *roleService) recordRoleChange(ctx context.Context, action, name string, before, after *models.Role) {
+	changes := map[string]models.AuditChange{}
+	var from, to interface{}
+	if before != nil {
+		from = before.Permissions
+	}
+	if after != nil {
+		to = after.Permissions
+	}
+	changes["permissions"] = models.AuditChange{From: from, To: to}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Action:     action,
+		TargetType: "role",
+		TargetID:   name,
+		Success:    true,
+		Changes:    changes,
+	})
+}
+
+// hasPermission resolves the permissions of the user in claims, or of the
+// role of a service account, which has no user.
+func hasPermission(ctx context.Context, roles repository.RoleRepository, users repository.UserRepository, claims auth.Claims, permission string) (bool, error) {
+	var (
+		permissions []string
+		user        *models.User
+		err         error
+	)
+
+	userID, _ := claims["user_id"].(string)
+	if id, parseErr := uuid.Parse(userID); parseErr == nil {
+		permissions, err = roles.UserPermissions(ctx, id)
+	} else if id, parseErr := strconv.Atoi(userID); parseErr == nil {
+		user, err = users.GetByInternalID(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+		permissions, err = roles.UserPermissions(ctx, user.ID)
+	} else if role, ok := claims["role"].(string); ok && role != "" {
+		permissions, err = roles.RolePermissions(ctx, role)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range permissions {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
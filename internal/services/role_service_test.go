@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/google/uuid"
+)
+
+func TestRolePermissions(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	roleRepo := repository.NewMemoryRoleRepository(users)
+	audit := NewAuditService(repository.NewMemoryAuditRepository())
+	outbox := repository.NewMemoryOutboxRepository()
+	service := NewUserService(users, auth.NewFakeTokenService(), WithRoles(roleRepo), WithEvents(repository.NoopTxManager{}, outbox))
+	roles := NewRoleService(roleRepo, users, service, repository.NoopTxManager{}, audit)
+
+	alice, err := service.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	claims := auth.UserClaims(alice.ID.String(), alice.Role)
+
+	if ok, _ := roles.HasPermission(ctx, claims, models.PermissionUsersRead); !ok {
+		t.Errorf("Expected the user role to have %v", models.PermissionUsersRead)
+	}
+	for _, permission := range []string{models.PermissionUsersWrite, models.PermissionUsersDelete} {
+		if ok, _ := roles.HasPermission(ctx, claims, permission); ok {
+			t.Errorf("Expected the user role not to have %v", permission)
+		}
+	}
+	if ok, _ := roles.HasPermission(ctx, claims, models.PermissionRolesManage); ok {
+		t.Errorf("Expected the user role not to have %v", models.PermissionRolesManage)
+	}
+
+	_, err = service.Update(auth.NewContext(ctx, claims), alice.ID, &models.UserUpdate{Role: models.RoleAdmin})
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for self-promotion, actual: %v", err)
+	}
+
+	if _, err := roles.SetUserRoles(ctx, alice.ID, []string{"missing"}); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("Expected ErrUnknownRole, actual: %v", err)
+	}
+
+	assigned, err := roles.SetUserRoles(ctx, alice.ID, []string{"support", models.RoleUser})
+	if err != nil {
+		t.Fatalf("Error while setting roles: %v", err)
+	}
+	if !reflect.DeepEqual(assigned, []string{"support", models.RoleUser}) {
+		t.Errorf("Wrong roles, expected: %v, actual: %v", []string{"support", models.RoleUser}, assigned)
+	}
+	if user, _ := users.GetByID(ctx, alice.ID); user.Role != "support" {
+		t.Errorf("Wrong primary role, expected: %v, actual: %v", "support", user.Role)
+	}
+	events := outbox.Events()
+	if last := events[len(events)-1]; last.EventType != models.EventUserUpdated || !strings.Contains(string(last.Payload), `"role":{"from":"user","to":"support"}`) {
+		t.Errorf("Expected a %v event for the primary role, actual: %v %s", models.EventUserUpdated, last.EventType, last.Payload)
+	}
+
+	if _, err := roles.UpdateRole(ctx, "support", &models.RoleUpdate{Permissions: []string{"users:fly"}}); !errors.Is(err, ErrUnknownPermission) {
+		t.Errorf("Expected ErrUnknownPermission, actual: %v", err)
+	}
+	if err := roles.DeleteRole(ctx, models.RoleAdmin); !errors.Is(err, ErrBuiltinRole) {
+		t.Errorf("Expected ErrBuiltinRole, actual: %v", err)
+	}
+	if err := roles.DeleteRole(ctx, "support"); !errors.Is(err, repository.ErrInUse) {
+		t.Errorf("Expected ErrInUse for an assigned role, actual: %v", err)
+	}
+
+	if _, err := roles.CreateRole(ctx, &models.RoleCreate{Name: "auditor", Permissions: []string{models.PermissionAuditRead}}); err != nil {
+		t.Fatalf("Error while creating role: %v", err)
+	}
+	serviceAccount := auth.Claims{"role": "auditor", "service_account": "reports"}
+	if ok, _ := roles.HasPermission(ctx, serviceAccount, models.PermissionAuditRead); !ok {
+		t.Errorf("Expected service account to get %v from its role", models.PermissionAuditRead)
+	}
+}
+
+type failingRoleRepository struct {
+	repository.RoleRepository
+}
+
+func (failingRoleRepository) UserPermissions(context.Context, uuid.UUID) ([]string, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestHasPermissionReportsErrorsForLegacyTokens(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	roles := NewRoleService(failingRoleRepository{repository.NewMemoryRoleRepository(users)}, users, nil, repository.NoopTxManager{}, noopAuditService{})
+
+	alice, _ := users.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	claims := auth.Claims{"user_id": strconv.Itoa(alice.InternalID), "role": alice.Role}
+
+	if ok, err := roles.HasPermission(ctx, claims, models.PermissionUsersRead); err == nil || ok {
+		t.Errorf("Expected the repository error, actual: %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/requestinfo"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSessionCacheTTL = 30 * time.Second
+	sessionCacheSize       = 10000
+)
+
+var ErrSessionRevoked = errors.New("session is revoked or expired")
+
+type SessionService interface {
+	// Start records a session for a login of user from the request in ctx.
+	Start(ctx context.Context, user *models.User) (*models.Session, error)
+	// Check fails with ErrSessionRevoked unless session id is active, and
+	// records activity on it. Results are cached, so the database is asked
+	// at most once per cache TTL for each session.
+	Check(ctx context.Context, id uuid.UUID) error
+	// List returns the active sessions of user, marking the one of the
+	// caller in ctx as current.
+	List(ctx context.Context, user *models.User) ([]*models.Session, error)
+	Revoke(ctx context.Context, user *models.User, id uuid.UUID) error
+	// RevokeOthers signs user out of every session except keep.
+	RevokeOthers(ctx context.Context, user *models.User, keep uuid.UUID) error
+}
+
+type sessionService struct {
+	repo     repository.SessionRepository
+	lifetime time.Duration
+	audit    AuditService
+
+	mu       sync.Mutex
+	cacheTTL time.Duration
+	cache    map[uuid.UUID]sessionCacheEntry
+}
+
+type sessionCacheEntry struct {
+	active  bool
+	expires time.Time
+}
+
+// NewSessionService returns a service for sessions that last as long as the
+// login tokens, lifetime.
+func NewSessionService(repo repository.SessionRepository, lifetime, cacheTTL time.Duration, audit AuditService) SessionService {
+	if lifetime <= 0 {
+		lifetime = auth.DefaultTokenTTL
+	}
+	if cacheTTL <= 0 {
+		cacheTTL = defaultSessionCacheTTL
+	}
+	return &sessionService{
+		repo:     repo,
+		lifetime: lifetime,
+		audit:    audit,
+		cacheTTL: cacheTTL,
+		cache:    make(map[uuid.UUID]sessionCacheEntry),
+	}
+}
+
+func (s *sessionService) Start(ctx context.Context, user *models.User) (*models.Session, error) {
+	info := requestinfo.FromContext(ctx)
+	return s.repo.Create(ctx, &models.Session{
+		UserID:    user.InternalID,
+		UserAgent: info.UserAgent,
+		IP:        info.IP,
+		ExpiresAt: time.Now().Add(s.lifetime),
+	})
+}
+
+func (s *sessionService) Check(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	s.mu.Lock()
+	entry, ok := s.cache[id]
+	s.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		if !entry.active {
+			return ErrSessionRevoked
+		}
+		return nil
+	}
+
+	_, err := s.repo.Touch(ctx, id)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	s.remember(id, err == nil, now)
+	if err != nil {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+func (s *sessionService) List(ctx context.Context, user *models.User) ([]*models.Session, error) {
+	sessions, err := s.repo.ListActive(ctx, user.InternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	current, _ := auth.SessionID(auth.FromContext(ctx))
+	for _, session := range sessions {
+		session.Current = session.ID.String() == current
+	}
+	return sessions, nil
+}
+
+func (s *sessionService) Revoke(ctx context.Context, user *models.User, id uuid.UUID) error {
+	if err := s.repo.Revoke(ctx, id, user.InternalID); err != nil {
+		return err
+	}
+	s.forget(id)
+	s.record(ctx, user, id)
+	return nil
+}
+
+func (s *sessionService) RevokeOthers(ctx context.Context, user *models.User, keep uuid.UUID) error {
+	ids, err := s.repo.RevokeAll(ctx, user.InternalID, keep)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s.forget(id)
+		s.record(ctx, user, id)
+	}
+	return nil
+}
+
+func (s *sessionService) remember(id uuid.UUID, active bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.cache) >= sessionCacheSize {
+		for key, entry := range s.cache {
+			if !now.Before(entry.expires) {
+				delete(s.cache, key)
+			}
+		}
+		// Every entry is fresh: start over rather than grow without bound.
+		if len(s.cache) >= sessionCacheSize {
+			s.cache = make(map[uuid.UUID]sessionCacheEntry)
+		}
+	}
+	s.cache[id] = sessionCacheEntry{active: active, expires: now.Add(s.cacheTTL)}
+}
+
+// forget marks a revoked session so this replica rejects it right away.
+func (s *sessionService) forget(id uuid.UUID) {
+	s.remember(id, false, time.Now())
+}
+
+func (s *sessionService) record(ctx context.Context, user *models.User, id uuid.UUID) {
+	s.audit.Record(ctx, &models.AuditEvent{
+		Action:     models.AuditActionSessionRevoke,
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Success:    true,
+		Changes: map[string]models.AuditChange{
+			"session": {From: id.String()},
+		},
+	})
+}
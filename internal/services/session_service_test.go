@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/requestinfo"
+	"github.com/google/uuid"
+)
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	tokens := auth.NewFakeTokenService()
+	sessionRepo := repository.NewMemorySessionRepository()
+	sessions := NewSessionService(sessionRepo, time.Hour, time.Minute, noopAuditService{})
+	service := NewUserService(repository.NewMemoryUserRepository(), tokens, WithSessions(sessions))
+
+	if _, err := service.Register(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"}); err != nil {
+		t.Fatalf("Error while registering: %v", err)
+	}
+	login := func(userAgent string) (string, auth.Claims) {
+		ctx := requestinfo.NewContext(ctx, requestinfo.Info{IP: "192.0.2.1", UserAgent: userAgent})
+		result, err := service.Login(ctx, &models.UserLogin{Username: "alice", Password: "password1"})
+		if err != nil {
+			t.Fatalf("Error while logging in: %v", err)
+		}
+		claims, err := service.Verify(ctx, result.Token)
+		if err != nil {
+			t.Fatalf("Error while verifying token: %v", err)
+		}
+		return result.Token, claims
+	}
+	laptopToken, laptop := login("laptop")
+	phoneToken, phone := login("phone")
+
+	alice, _ := service.GetByTokenUserID(ctx, laptop["user_id"].(string))
+	list, err := sessions.List(auth.NewContext(ctx, laptop), alice)
+	if err != nil {
+		t.Fatalf("Error while listing sessions: %v", err)
+	}
+	// The registration started a session too.
+	if len(list) != 3 {
+		t.Fatalf("Wrong number of sessions, expected: %v, actual: %v", 3, len(list))
+	}
+	for _, session := range list {
+		laptopSession, _ := auth.SessionID(laptop)
+		if session.Current != (session.ID.String() == laptopSession) {
+			t.Errorf("Wrong current flag for session %v: %v", session.UserAgent, session.Current)
+		}
+		if session.UserAgent == "phone" && session.IP != "192.0.2.1" {
+			t.Errorf("Wrong session IP, expected: %v, actual: %v", "192.0.2.1", session.IP)
+		}
+	}
+
+	phoneSession, _ := auth.SessionID(phone)
+	if err := sessions.Revoke(ctx, alice, uuid.MustParse(phoneSession)); err != nil {
+		t.Fatalf("Error while revoking session: %v", err)
+	}
+	if _, err := service.Verify(ctx, phoneToken); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Expected revoked session to be rejected, actual: %v", err)
+	}
+	if _, err := service.Verify(ctx, laptopToken); err != nil {
+		t.Errorf("Error while verifying other session: %v", err)
+	}
+	if err := sessions.Revoke(ctx, alice, uuid.MustParse(phoneSession)); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for revoked session, actual: %v", err)
+	}
+
+	_, tablet := login("tablet")
+	newToken, err := service.ChangePassword(auth.NewContext(ctx, laptop), alice.ID, &models.PasswordChange{CurrentPassword: "password1", NewPassword: "new-password"})
+	if err != nil {
+		t.Fatalf("Error while changing password: %v", err)
+	}
+	newClaims, err := service.Verify(ctx, newToken)
+	if err != nil {
+		t.Fatalf("Error while verifying new token: %v", err)
+	}
+	if newClaims[auth.ClaimSession] != laptop[auth.ClaimSession] {
+		t.Errorf("Expected new token to keep the session, expected: %v, actual: %v", laptop[auth.ClaimSession], newClaims[auth.ClaimSession])
+	}
+	tabletSession, _ := auth.SessionID(tablet)
+	if err := sessions.Check(ctx, uuid.MustParse(tabletSession)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected other sessions to be revoked, actual: %v", err)
+	}
+}
+
+func TestSessionCache(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemorySessionRepository()
+	sessions := NewSessionService(repo, time.Hour, time.Minute, noopAuditService{})
+	user := &models.User{InternalID: 1}
+
+	session, err := sessions.Start(ctx, user)
+	if err != nil {
+		t.Fatalf("Error while starting session: %v", err)
+	}
+	if err := sessions.Check(ctx, session.ID); err != nil {
+		t.Fatalf("Error while checking session: %v", err)
+	}
+
+	// Revoked elsewhere, so this service only notices once the cache expires.
+	if err := repo.Revoke(ctx, session.ID, user.InternalID); err != nil {
+		t.Fatalf("Error while revoking session: %v", err)
+	}
+	if err := sessions.Check(ctx, session.ID); err != nil {
+		t.Errorf("Expected cached result, actual: %v", err)
+	}
+
+	uncached := NewSessionService(repo, time.Hour, time.Nanosecond, noopAuditService{})
+	if err := uncached.Check(ctx, session.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected ErrSessionRevoked, actual: %v", err)
+	}
+}
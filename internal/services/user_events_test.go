@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+)
+
+func TestUserLifecycleEvents(t *testing.T) {
+	ctx := context.Background()
+	outbox := repository.NewMemoryOutboxRepository()
+	service := NewUserService(repository.NewMemoryUserRepository(), auth.NewFakeTokenService(), WithEvents(repository.NoopTxManager{}, outbox))
+
+	user, err := service.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	if _, err := service.Update(ctx, user.ID, &models.UserUpdate{Email: "alice@example.org"}); err != nil {
+		t.Fatalf("Error while updating user: %v", err)
+	}
+	if err := service.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Error while deleting user: %v", err)
+	}
+
+	expected := []string{models.EventUserRegistered, models.EventUserUpdated, models.EventUserEmailChanged, models.EventUserDeleted}
+	events := outbox.Events()
+	if len(events) != len(expected) {
+		t.Fatalf("Wrong number of events, expected: %v, actual: %v", len(expected), len(events))
+	}
+	for i, event := range events {
+		if event.EventType != expected[i] {
+			t.Errorf("Wrong event type, expected: %v, actual: %v", expected[i], event.EventType)
+		}
+		if event.OrderingKey != "user:"+user.ID.String() {
+			t.Errorf("Wrong ordering key, expected: %v, actual: %v", "user:"+user.ID.String(), event.OrderingKey)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/jobs"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// maxImportLine bounds a line of an NDJSON import.
+const maxImportLine = 1 << 20
+
+// ErrInvalidImport is returned for imports that cannot be read at all, as
+// opposed to rows that are reported.
+var ErrInvalidImport = errors.New("invalid import")
+
+// UserImportArgs runs a stored import in the background.
+type UserImportArgs struct {
+	ImportID       uuid.UUID `json:"import_id"`
+	OrganizationID int       `json:"organization_id"`
+	Organization   string    `json:"organization"`
+}
+
+func (UserImportArgs) Kind() string {
+	return "users.import"
+}
+
+// JobInserter enqueues background jobs. It is implemented by *jobs.Client.
+type JobInserter interface {
+	Insert(ctx context.Context, args jobs.JobArgs, opts *jobs.InsertOptions) (*models.Job, error)
+}
+
+type UserImportService interface {
+	// Import reads users from data and imports them right away.
+	Import(ctx context.Context, opts *models.UserImportOptions, data io.Reader) (*models.UserImportReport, error)
+	// Start stores data and imports it in a users.import job.
+	Start(ctx context.Context, opts *models.UserImportOptions, data []byte) (*models.UserImport, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.UserImport, error)
+	// Run is the handler of the users.import job. The import acts as the
+	// user who started it.
+	Run(ctx context.Context, job *jobs.Job[UserImportArgs]) error
+}
+
+type userImportService struct {
+	repo  repository.UserImportRepository
+	users UserService
+	tx    repository.TxManager
+	jobs  JobInserter
+}
+
+func NewUserImportService(repo repository.UserImportRepository, users UserService, tx repository.TxManager, jobs JobInserter) UserImportService {
+	return &userImportService{
+		repo:  repo,
+		users: users,
+		tx:    tx,
+		jobs:  jobs,
+	}
+}
+
+func (s *userImportService) Import(ctx context.Context, opts *models.UserImportOptions, data io.Reader) (*models.UserImportReport, error) {
+	if err := checkImportOptions(opts); err != nil {
+		return nil, err
+	}
+
+	rows, rowErrors, err := parseUserImport(opts.Format, data)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.users.Import(ctx, opts, rows)
+	if err != nil {
+		return nil, err
+	}
+	// Rows that could not be read were not passed on, so they are only
+	// counted here.
+	unread := make(map[int]bool)
+	for _, rowError := range rowErrors {
+		unread[rowError.Row] = true
+	}
+	report.Total += len(unread)
+	addImportErrors(report, rowErrors)
+	return report, nil
+}
+
+func (s *userImportService) Start(ctx context.Context, opts *models.UserImportOptions, data []byte) (*models.UserImport, error) {
+	if err := checkImportOptions(opts); err != nil {
+		return nil, err
+	}
+
+	claims := auth.FromContext(ctx)
+	userImport := &models.UserImport{UserImportOptions: *opts}
+	userImport.CreatedBy, _ = claims["user_id"].(string)
+	userImport.CreatedByRole, _ = claims["role"].(string)
+	current, _ := tenant.FromContext(ctx)
+
+	var created *models.UserImport
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if created, err = s.repo.Create(ctx, userImport, data); err != nil {
+			return err
+		}
+		_, err = s.jobs.Insert(ctx, UserImportArgs{
+			ImportID:       created.ID,
+			OrganizationID: created.OrganizationID,
+			Organization:   current.Slug,
+		}, nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start import: %w", err)
+	}
+	return created, nil
+}
+
+func (s *userImportService) Get(ctx context.Context, id uuid.UUID) (*models.UserImport, error) {
+	return s.repo.Get(ctx, id)
+}
+
+func (s *userImportService) Run(ctx context.Context, job *jobs.Job[UserImportArgs]) error {
+	ctx = tenant.NewContext(ctx, tenant.Tenant{ID: job.Args.OrganizationID, Slug: job.Args.Organization})
+	userImport, err := s.repo.Get(ctx, job.Args.ImportID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return jobs.Cancel(err)
+		}
+		return err
+	}
+	if userImport.FinishedAt != nil {
+		return nil
+	}
+
+	claims := auth.UserClaims(userImport.CreatedBy, userImport.CreatedByRole)
+	ctx = auth.NewContext(ctx, auth.WithOrganization(claims, userImport.OrganizationID))
+	if _, err := s.repo.SetStatus(ctx, userImport.ID, models.UserImportStatusRunning); err != nil {
+		return err
+	}
+
+	// The report is stored in the transaction of the import, so a retry
+	// never imports the same rows twice.
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		data, err := s.repo.Data(ctx, userImport.ID)
+		if err != nil {
+			return err
+		}
+		report, err := s.Import(ctx, &userImport.UserImportOptions, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		_, err = s.repo.Finish(ctx, userImport.ID, models.UserImportStatusCompleted, report, "")
+		return err
+	})
+	if err == nil {
+		return nil
+	}
+
+	permanent := errors.Is(err, ErrInvalidImport) || errors.Is(err, ErrForbidden)
+	if !permanent && job.Attempt < job.MaxAttempts {
+		return err
+	}
+	if _, finishErr := s.repo.Finish(ctx, userImport.ID, models.UserImportStatusFailed, nil, err.Error()); finishErr != nil {
+		return finishErr
+	}
+	return jobs.Cancel(err)
+}
+
+func checkImportOptions(opts *models.UserImportOptions) error {
+	if opts.Mode == "" {
+		opts.Mode = models.UserImportModeCreate
+	}
+	if opts.Format != models.UserImportFormatCSV && opts.Format != models.UserImportFormatNDJSON {
+		return fmt.Errorf("%w: unknown format %q", ErrInvalidImport, opts.Format)
+	}
+	if opts.Mode != models.UserImportModeCreate && opts.Mode != models.UserImportModeUpsert {
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidImport, opts.Mode)
+	}
+	return nil
+}
+
+// parseUserImport reads the rows of an import. Rows that cannot be read are
+// returned as errors; only a file that cannot be read at all is an error.
+func parseUserImport(format string, data io.Reader) ([]*models.UserImportRow, []models.UserImportRowError, error) {
+	if format == models.UserImportFormatNDJSON {
+		return parseUserImportNDJSON(data)
+	}
+	return parseUserImportCSV(data)
+}
+
+// parseUserImportCSV reads a CSV file whose header names the columns. Only
+// username and email are required, and unknown columns are ignored, so that
+// an export can be imported again.
+func parseUserImportCSV(data io.Reader) ([]*models.UserImportRow, []models.UserImportRowError, error) {
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("%w: the file is empty", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, required := range []string{"username", "email"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("%w: the header has no %s column", ErrInvalidImport, required)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	var rows []*models.UserImportRow
+	var rowErrors []models.UserImportRowError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			rowErrors = append(rowErrors, models.UserImportRowError{Row: parseError.StartLine, Error: parseError.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read import: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, &models.UserImportRow{
+			Row:      line,
+			Username: strings.TrimSpace(field(record, "username")),
+			Email:    strings.TrimSpace(field(record, "email")),
+			Password: field(record, "password"),
+			Role:     strings.TrimSpace(field(record, "role")),
+		})
+	}
+	return rows, rowErrors, nil
+}
+
+// parseUserImportNDJSON reads a JSON object per line. Blank lines are
+// skipped and unknown fields are ignored.
+func parseUserImportNDJSON(data io.Reader) ([]*models.UserImportRow, []models.UserImportRowError, error) {
+	scanner := bufio.NewScanner(data)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLine)
+
+	var rows []*models.UserImportRow
+	var rowErrors []models.UserImportRowError
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		row := &models.UserImportRow{}
+		if err := json.Unmarshal(text, row); err != nil {
+			rowErrors = append(rowErrors, models.UserImportRowError{Row: line, Error: "is not a valid JSON object"})
+			continue
+		}
+		row.Row = line
+		row.Username = strings.TrimSpace(row.Username)
+		row.Email = strings.TrimSpace(row.Email)
+		row.Role = strings.TrimSpace(row.Role)
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, nil, fmt.Errorf("%w: line %d is too long", ErrInvalidImport, line+1)
+		}
+		return nil, nil, fmt.Errorf("failed to read import: %w", err)
+	}
+	return rows, rowErrors, nil
+}
+
+// addImportErrors adds rowErrors to report, in row order, and counts the rows
+// that failed.
+func addImportErrors(report *models.UserImportReport, rowErrors []models.UserImportRowError) {
+	report.Errors = append(report.Errors, rowErrors...)
+	if report.Errors == nil {
+		report.Errors = []models.UserImportRowError{}
+	}
+	sort.SliceStable(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Row < report.Errors[j].Row
+	})
+
+	failed := make(map[int]bool)
+	for _, rowError := range report.Errors {
+		failed[rowError.Row] = true
+	}
+	report.Failed = len(failed)
+}
+
+func validationMessage(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters", fieldError.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fieldError.Param())
+	}
+	return fmt.Sprintf("failed the %s check", fieldError.Tag())
+}
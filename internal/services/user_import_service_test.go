@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/jobs"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+)
+
+type fakeJobInserter struct {
+	inserted []jobs.JobArgs
+}
+
+func (f *fakeJobInserter) Insert(ctx context.Context, args jobs.JobArgs, opts *jobs.InsertOptions) (*models.Job, error) {
+	f.inserted = append(f.inserted, args)
+	return &models.Job{}, nil
+}
+
+func newUserImportTestServices() (UserImportService, UserService, *repository.MemoryAuditRepository, *fakeJobInserter) {
+	users := repository.NewMemoryUserRepository()
+	auditRepo := repository.NewMemoryAuditRepository()
+	userService := NewUserService(users, auth.NewFakeTokenService(),
+		WithAudit(NewAuditService(auditRepo)),
+		WithRoles(repository.NewMemoryRoleRepository(users)),
+	)
+	inserter := &fakeJobInserter{}
+	imports := NewUserImportService(repository.NewMemoryUserImportRepository(), userService, repository.NoopTxManager{}, inserter)
+	return imports, userService, auditRepo, inserter
+}
+
+func TestUserImport(t *testing.T) {
+	ctx := auth.NewContext(context.Background(), auth.UserClaims("admin-id", models.RoleAdmin))
+
+	t.Run("csv with errors", func(t *testing.T) {
+		imports, users, auditRepo, _ := newUserImportTestServices()
+		if _, err := users.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"}); err != nil {
+			t.Fatalf("Error while creating user: %v", err)
+		}
+
+		data := "\ufeffUsername,email,password,role,ignored\n" +
+			"alice,alice2@example.com,password1,,x\n" +
+			"bob,bob@example.com,password1,admin,x\n" +
+			"carol,not-an-email,password1,,x\n" +
+			"dave,dave@example.com,,,x\n" +
+			"erin,bob@example.com,password1,,x\n" +
+			"frank,frank@example.com,password1,ghost,x\n" +
+			"\"broken,x\n"
+		report, err := imports.Import(ctx, &models.UserImportOptions{Format: models.UserImportFormatCSV}, strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("Error while importing users: %v", err)
+		}
+
+		if report.Total != 7 || report.Created != 1 || report.Failed != 6 {
+			t.Errorf("Wrong report, expected: 7 total, 1 created, 6 failed, actual: %+v", report)
+		}
+		expected := map[int]string{2: "username", 4: "email", 5: "password", 6: "email", 7: "role", 8: ""}
+		for _, rowError := range report.Errors {
+			field, ok := expected[rowError.Row]
+			if !ok || field != rowError.Field {
+				t.Errorf("Unexpected error: %+v", rowError)
+			}
+		}
+		if bob, err := users.GetByUsername(ctx, "bob"); err != nil || bob.Role != models.RoleAdmin {
+			t.Errorf("Expected bob to be imported as an admin, actual: %v, %v", bob, err)
+		}
+
+		events, _, err := NewAuditService(auditRepo).List(context.Background(), models.AuditEventFilter{Action: models.AuditActionUserImport}, 1, 10)
+		if err != nil {
+			t.Fatalf("Error while listing audit events: %v", err)
+		}
+		if len(events) != 1 || events[0].Changes["created"].To != 1 {
+			t.Errorf("Wrong import audit events: %+v", events)
+		}
+	})
+
+	t.Run("dry run and upsert", func(t *testing.T) {
+		imports, users, _, _ := newUserImportTestServices()
+		alice, err := users.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+		if err != nil {
+			t.Fatalf("Error while creating user: %v", err)
+		}
+
+		data := `{"username":"alice","email":"alice@example.com","role":"admin"}
+
+{"username":"bob","email":"bob@example.com","password":"password1"}
+{"username":"carol","email":"carol@example.com"}
+not json
+`
+		opts := &models.UserImportOptions{Format: models.UserImportFormatNDJSON, Mode: models.UserImportModeUpsert, DryRun: true}
+		report, err := imports.Import(ctx, opts, strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("Error while checking import: %v", err)
+		}
+		if report.Total != 4 || report.Created != 1 || report.Updated != 1 || report.Failed != 2 {
+			t.Errorf("Wrong dry run report, expected: 4 total, 1 created, 1 updated, 2 failed, actual: %+v", report)
+		}
+		if _, err := users.GetByUsername(ctx, "bob"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected a dry run to write nothing, actual: %v", err)
+		}
+
+		opts.DryRun = false
+		if _, err := imports.Import(ctx, opts, strings.NewReader(data)); err != nil {
+			t.Fatalf("Error while importing users: %v", err)
+		}
+		if updated, _ := users.GetByID(ctx, alice.ID); updated.Role != models.RoleAdmin {
+			t.Errorf("Wrong role, expected: %v, actual: %v", models.RoleAdmin, updated.Role)
+		}
+
+		report, err = imports.Import(ctx, opts, strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("Error while importing users again: %v", err)
+		}
+		if report.Unchanged != 1 || report.Updated != 1 || report.Created != 0 {
+			t.Errorf("Wrong report for the same import, expected alice unchanged and bob's password set again, actual: %+v", report)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		imports, _, _, _ := newUserImportTestServices()
+		for _, tt := range []struct {
+			opts *models.UserImportOptions
+			data string
+		}{
+			{opts: &models.UserImportOptions{Format: "xml"}, data: ""},
+			{opts: &models.UserImportOptions{Format: models.UserImportFormatCSV, Mode: "replace"}, data: "username,email\n"},
+			{opts: &models.UserImportOptions{Format: models.UserImportFormatCSV}, data: ""},
+			{opts: &models.UserImportOptions{Format: models.UserImportFormatCSV}, data: "name,email\n"},
+		} {
+			if _, err := imports.Import(ctx, tt.opts, strings.NewReader(tt.data)); !errors.Is(err, ErrInvalidImport) {
+				t.Errorf("Expected ErrInvalidImport for %+v and %q, actual: %v", tt.opts, tt.data, err)
+			}
+		}
+	})
+
+	t.Run("forbidden role", func(t *testing.T) {
+		imports, users, _, _ := newUserImportTestServices()
+		creator, err := users.Create(ctx, &models.UserCreate{Username: "manager", Email: "manager@example.com", Password: "password1"})
+		if err != nil {
+			t.Fatalf("Error while creating user: %v", err)
+		}
+		userCtx := auth.NewContext(context.Background(), auth.UserClaims(creator.ID.String(), creator.Role))
+
+		data := "username,email,password,role\nbob,bob@example.com,password1,admin\n"
+		_, err = imports.Import(userCtx, &models.UserImportOptions{Format: models.UserImportFormatCSV}, strings.NewReader(data))
+		if !errors.Is(err, ErrForbidden) {
+			t.Errorf("Expected ErrForbidden, actual: %v", err)
+		}
+	})
+
+	t.Run("background", func(t *testing.T) {
+		imports, users, _, inserter := newUserImportTestServices()
+
+		data := "username,email,password\nbob,bob@example.com,password1\ncarol,carol,password1\n"
+		started, err := imports.Start(ctx, &models.UserImportOptions{Format: models.UserImportFormatCSV}, []byte(data))
+		if err != nil {
+			t.Fatalf("Error while starting import: %v", err)
+		}
+		if started.Status != models.UserImportStatusPending || started.CreatedBy != "admin-id" {
+			t.Errorf("Wrong started import: %+v", started)
+		}
+		if len(inserter.inserted) != 1 {
+			t.Fatalf("Wrong number of jobs, expected: %v, actual: %v", 1, len(inserter.inserted))
+		}
+
+		args := inserter.inserted[0].(UserImportArgs)
+		job := &jobs.Job[UserImportArgs]{Attempt: 1, MaxAttempts: 3, Args: args}
+		if err := imports.Run(context.Background(), job); err != nil {
+			t.Fatalf("Error while running import: %v", err)
+		}
+
+		finished, err := imports.Get(ctx, started.ID)
+		if err != nil {
+			t.Fatalf("Error while getting import: %v", err)
+		}
+		if finished.Status != models.UserImportStatusCompleted || finished.FinishedAt == nil {
+			t.Errorf("Wrong status, expected: %v, actual: %v", models.UserImportStatusCompleted, finished.Status)
+		}
+		if finished.Total != 2 || finished.Created != 1 || finished.Failed != 1 || len(finished.Errors) != 1 {
+			t.Errorf("Wrong report: %+v", finished.UserImportReport)
+		}
+		if _, err := users.GetByUsername(ctx, "bob"); err != nil {
+			t.Errorf("Expected bob to be imported, actual: %v", err)
+		}
+
+		if err := imports.Run(context.Background(), job); err != nil {
+			t.Errorf("Expected a finished import to be skipped, actual: %v", err)
+		}
+	})
+}
@@ -1,52 +1,615 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/Romasmi/go-rest-api-template/internal/utils"
+	"log"
+	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/Romasmi/go-rest-api-template/internal/middleware"
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/images"
+	"github.com/Romasmi/go-rest-api-template/internal/mail"
 	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/password"
 	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/schema"
+	"github.com/Romasmi/go-rest-api-template/internal/storage"
+	"github.com/Romasmi/go-rest-api-template/internal/tenant"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
-type UserService struct {
-	repo *repository.UserRepository
+const (
+	defaultMFAChallengeTTL = 5 * time.Minute
+	emailChangeTTL         = 24 * time.Hour
+	defaultAvatarSize      = 256
+	// maxAttributesSize bounds the attributes of a user encoded as JSON.
+	maxAttributesSize = 16 << 10
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUserExists         = errors.New("username or email already exists")
+	ErrInvalidMFAToken    = errors.New("invalid or expired mfa token")
+	ErrInvalidEmailToken  = errors.New("invalid or expired email confirmation token")
+	// ErrUserInactive is wrapped by the *UserStatusError of users who
+	// cannot sign in.
+	ErrUserInactive        = errors.New("user is not active")
+	ErrInvalidStatusChange = errors.New("invalid status change")
+	ErrInvalidAttributes   = errors.New("invalid attributes")
+	ErrAvatarsDisabled     = errors.New("avatars are not configured")
+)
+
+// UserStatusError is returned when a user who is not active signs in.
+type UserStatusError struct {
+	Status         string
+	SuspendedUntil *time.Time
 }
 
-func NewUserService(repo *repository.UserRepository) *UserService {
-	return &UserService{
-		repo: repo,
+func (e *UserStatusError) Error() string {
+	if e.Status == models.UserStatusSuspended && e.SuspendedUntil != nil {
+		return "user is suspended until " + e.SuspendedUntil.UTC().Format(time.RFC3339)
 	}
+	return "user is " + strings.ReplaceAll(e.Status, "_", " ")
 }
 
-func (s *UserService) Create(ctx context.Context, user *models.UserCreate) (*models.User, error) {
-	return s.repo.Create(ctx, user)
+func (e *UserStatusError) Unwrap() error {
+	return ErrUserInactive
+}
+
+type UserService interface {
+	Create(ctx context.Context, user *models.UserCreate) (*models.User, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByTokenUserID(ctx context.Context, userID string) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	Update(ctx context.Context, id uuid.UUID, user *models.UserUpdate) (*models.User, error)
+	// SetRoles replaces the roles of the user. The first one becomes its
+	// primary role, which is shown on the user and put in its tokens.
+	SetRoles(ctx context.Context, id uuid.UUID, roles []string) (*models.User, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	List(ctx context.Context, filter models.UserFilter, page, pageSize int) ([]*models.User, int, error)
+	// Login checks the password. With two-factor authentication the result
+	// holds an MFA token for VerifyMFA or EnrollMFA instead of a token.
+	Login(ctx context.Context, login *models.UserLogin) (*models.LoginResult, error)
+	Register(ctx context.Context, user *models.UserCreate) (string, error)
+	// SignIn continues like Login for a user authenticated by other means,
+	// such as an external identity provider.
+	SignIn(ctx context.Context, user *models.User) (*models.LoginResult, error)
+	// IssueToken returns a token for a fully authenticated user.
+	IssueToken(ctx context.Context, user *models.User) (string, error)
+	VerifyMFA(ctx context.Context, verify *models.MFAVerify) (string, error)
+	// EnrollMFA and ConfirmMFAEnrollment let a user whose role requires
+	// two-factor authentication enroll during login.
+	EnrollMFA(ctx context.Context, mfaToken string) (*models.TOTPEnrollment, error)
+	ConfirmMFAEnrollment(ctx context.Context, mfaToken, code string) (*models.MFAEnrolled, error)
+	// Verify makes the service an auth.TokenVerifier for login tokens. Tokens
+	// of revoked sessions, of deleted or disabled users and tokens revoked by
+	// ChangePassword are rejected.
+	Verify(ctx context.Context, token string) (auth.Claims, error)
+	// UpdateProfile is Update for users changing themselves. It refuses role
+	// changes with ErrForbidden.
+	UpdateProfile(ctx context.Context, id uuid.UUID, profile *models.ProfileUpdate) (*models.User, error)
+	// ChangePassword signs the user out everywhere and returns a new token
+	// for the current session.
+	ChangePassword(ctx context.Context, id uuid.UUID, change *models.PasswordChange) (string, error)
+	// RequestEmailChange mails a confirmation token to the new address. The
+	// email only changes once ConfirmEmailChange is called with it.
+	RequestEmailChange(ctx context.Context, id uuid.UUID, email string) error
+	ConfirmEmailChange(ctx context.Context, token string) (*models.User, error)
+	// Import creates, or in the upsert mode also updates, users in bulk.
+	// Rows that fail are reported and skipped; the others are written in one
+	// transaction, with the same events as single changes.
+	Import(ctx context.Context, opts *models.UserImportOptions, rows []*models.UserImportRow) (*models.UserImportReport, error)
+	// Export calls fn with every user without loading them all at once.
+	Export(ctx context.Context, fn func(user *models.User) error) error
+	// ResetPassword sets a new password for the user, without the current
+	// one, and signs the user out everywhere.
+	ResetPassword(ctx context.Context, id uuid.UUID, newPassword string) (*models.User, error)
+	// SetStatus changes the status of the user and records it in the status
+	// history. Users leaving the active status are signed out everywhere,
+	// and SignIn refuses them with a *UserStatusError.
+	SetStatus(ctx context.Context, id uuid.UUID, update *models.UserStatusUpdate) (*models.User, error)
+	// StatusHistory returns the status changes of the user, newest first.
+	StatusHistory(ctx context.Context, id uuid.UUID) ([]*models.UserStatusChange, error)
+	// SetAvatar crops and scales image, an uploaded JPEG, PNG, GIF or WebP
+	// file, and makes it the avatar of the user. Images that cannot be used
+	// fail with an error of the images package.
+	SetAvatar(ctx context.Context, id uuid.UUID, image []byte) (*models.User, error)
+	DeleteAvatar(ctx context.Context, id uuid.UUID) (*models.User, error)
+}
+
+type userService struct {
+	repo         repository.UserRepository
+	tokens       auth.TokenService
+	audit        AuditService
+	tx           repository.TxManager
+	outbox       repository.OutboxRepository
+	roles        repository.RoleRepository
+	mfa          MFAService
+	challengeTTL time.Duration
+	hasher       *password.Hasher
+	policy       *password.Policy
+	mailer       mail.Mailer
+	confirmURL   string
+	sessions     SessionService
+	statuses     repository.UserStatusChangeRepository
+	attributes   *schema.Schema
+	files        storage.Storage
+	avatarSize   int
+}
+
+type UserServiceOption func(s *userService)
+
+func WithAudit(audit AuditService) UserServiceOption {
+	return func(s *userService) {
+		s.audit = audit
+	}
+}
+
+// WithEvents makes the service write domain events to outbox in the same
+// transaction as the user change.
+func WithEvents(tx repository.TxManager, outbox repository.OutboxRepository) UserServiceOption {
+	return func(s *userService) {
+		s.tx = tx
+		s.outbox = outbox
+	}
+}
+
+// WithRoles keeps user_roles in step with the role of created and updated
+// users. Changing a role then requires the roles:manage permission.
+func WithRoles(roles repository.RoleRepository) UserServiceOption {
+	return func(s *userService) {
+		s.roles = roles
+	}
+}
+
+// WithMFA makes Login ask for a second factor from users who enabled it,
+// and enrollment from users whose role requires it.
+func WithMFA(mfa MFAService, challengeTTL time.Duration) UserServiceOption {
+	return func(s *userService) {
+		s.mfa = mfa
+		if challengeTTL > 0 {
+			s.challengeTTL = challengeTTL
+		}
+	}
+}
+
+// WithPasswords hashes new passwords with hasher and checks them against
+// policy. Login rehashes passwords hasher considers outdated.
+func WithPasswords(hasher *password.Hasher, policy *password.Policy) UserServiceOption {
+	return func(s *userService) {
+		s.hasher = hasher
+		s.policy = policy
+	}
 }
 
-func (s *UserService) GetByID(ctx context.Context, id int) (*models.User, error) {
+// WithMailer sends email change confirmations through mailer. confirmURL is
+// the page that confirms them, if there is one.
+func WithMailer(mailer mail.Mailer, confirmURL string) UserServiceOption {
+	return func(s *userService) {
+		s.mailer = mailer
+		s.confirmURL = confirmURL
+	}
+}
+
+// WithSessions starts a session for every login token and makes Verify
+// reject tokens of revoked sessions.
+func WithSessions(sessions SessionService) UserServiceOption {
+	return func(s *userService) {
+		s.sessions = sessions
+	}
+}
+
+// WithStatusHistory records every status change with its reason.
+func WithStatusHistory(statuses repository.UserStatusChangeRepository) UserServiceOption {
+	return func(s *userService) {
+		s.statuses = statuses
+	}
+}
+
+// WithAttributeSchema checks the attributes of users against attributes.
+func WithAttributeSchema(attributes *schema.Schema) UserServiceOption {
+	return func(s *userService) {
+		s.attributes = attributes
+	}
+}
+
+// WithAvatars keeps avatars in files, scaled to size by size pixels.
+func WithAvatars(files storage.Storage, size int) UserServiceOption {
+	return func(s *userService) {
+		s.files = files
+		if size > 0 {
+			s.avatarSize = size
+		}
+	}
+}
+
+func NewUserService(repo repository.UserRepository, tokens auth.TokenService, opts ...UserServiceOption) UserService {
+	// The zero config is valid: bcrypt with the default cost.
+	hasher, _ := password.NewHasher(config.PasswordConfig{})
+	s := &userService{
+		repo:         repo,
+		tokens:       tokens,
+		audit:        noopAuditService{},
+		tx:           repository.NoopTxManager{},
+		challengeTTL: defaultMFAChallengeTTL,
+		hasher:       hasher,
+		mailer:       mail.LogMailer{},
+		avatarSize:   defaultAvatarSize,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *userService) Create(ctx context.Context, user *models.UserCreate) (*models.User, error) {
+	newUser, err := s.create(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordUserChange(ctx, models.AuditActionUserCreate, nil, newUser)
+	return newUser, nil
+}
+
+func (s *userService) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	return s.repo.GetByID(ctx, id)
 }
 
-func (s *UserService) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+// GetByTokenUserID resolves the user_id claim of a token. Tokens issued before
+// the switch to public IDs carry the internal integer key and are still
+// accepted until they expire.
+func (s *userService) GetByTokenUserID(ctx context.Context, userID string) (*models.User, error) {
+	if id, err := uuid.Parse(userID); err == nil {
+		return s.repo.GetByID(ctx, id)
+	}
+
+	if id, err := strconv.Atoi(userID); err == nil {
+		return s.repo.GetByInternalID(ctx, id)
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+func (s *userService) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	return s.repo.GetByUsername(ctx, username)
 }
 
-func (s *UserService) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+func (s *userService) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	return s.repo.GetByEmail(ctx, email)
 }
 
-func (s *UserService) Update(ctx context.Context, id int, user *models.UserUpdate) (*models.User, error) {
-	return s.repo.Update(ctx, id, user)
+func (s *userService) Update(ctx context.Context, id uuid.UUID, user *models.UserUpdate) (*models.User, error) {
+	before, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Attributes != nil {
+		if err := s.checkAttributes(user.Attributes); err != nil {
+			return nil, err
+		}
+	}
+
+	roleChanged := user.Role != "" && user.Role != before.Role
+	if roleChanged {
+		if err := s.checkRoleChange(ctx, user.Role); err != nil {
+			return nil, err
+		}
+	}
+	if user.Password != "" {
+		username, email := user.Username, user.Email
+		if username == "" {
+			username = before.Username
+		}
+		if email == "" {
+			email = before.Email
+		}
+		if user.PasswordHash, err = s.hashPassword(user.Password, username, email); err != nil {
+			return nil, err
+		}
+	}
+
+	var updated *models.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if updated, err = s.repo.Update(ctx, id, user); err != nil {
+			return err
+		}
+		if roleChanged {
+			if err := s.assignRole(ctx, updated); err != nil {
+				return err
+			}
+		}
+
+		changes := UserChanges(before, updated)
+		if len(changes) == 0 {
+			return nil
+		}
+		if err := s.publish(ctx, models.EventUserUpdated, updated, changes); err != nil {
+			return err
+		}
+		if _, ok := changes["email"]; ok {
+			return s.publish(ctx, models.EventUserEmailChanged, updated, map[string]models.AuditChange{"email": changes["email"]})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordUserChange(ctx, models.AuditActionUserUpdate, before, updated)
+	return updated, nil
+}
+
+func (s *userService) SetRoles(ctx context.Context, id uuid.UUID, roles []string) (*models.User, error) {
+	if s.roles == nil || len(roles) == 0 {
+		return nil, ErrUnknownRole
+	}
+
+	before, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated *models.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.roles.SetUserRoles(ctx, before.InternalID, roles); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrUnknownRole
+			}
+			return err
+		}
+		var err error
+		if updated, err = s.repo.Update(ctx, id, &models.UserUpdate{Role: roles[0]}); err != nil {
+			return err
+		}
+
+		changes := UserChanges(before, updated)
+		if len(changes) == 0 {
+			return nil
+		}
+		return s.publish(ctx, models.EventUserUpdated, updated, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *userService) Delete(ctx context.Context, id uuid.UUID) error {
+	before, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.publish(ctx, models.EventUserDeleted, before, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.recordUserChange(ctx, models.AuditActionUserDelete, before, nil)
+	return nil
+}
+
+func (s *userService) ResetPassword(ctx context.Context, id uuid.UUID, newPassword string) (*models.User, error) {
+	var user *models.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.Update(ctx, id, &models.UserUpdate{Password: newPassword}); err != nil {
+			return err
+		}
+		var err error
+		user, err = s.repo.RevokeTokens(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.revokeSessions(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
-func (s *UserService) Delete(ctx context.Context, id int) error {
-	return s.repo.Delete(ctx, id)
+func (s *userService) SetStatus(ctx context.Context, id uuid.UUID, update *models.UserStatusUpdate) (*models.User, error) {
+	var suspendedUntil *time.Time
+	if update.Status == models.UserStatusSuspended {
+		if update.SuspendedUntil == nil || !update.SuspendedUntil.After(time.Now()) {
+			return nil, fmt.Errorf("%w: suspended_until must be in the future", ErrInvalidStatusChange)
+		}
+		until := update.SuspendedUntil.UTC()
+		suspendedUntil = &until
+	} else if update.SuspendedUntil != nil {
+		return nil, fmt.Errorf("%w: suspended_until is only allowed for suspended users", ErrInvalidStatusChange)
+	}
+
+	// Admins cannot lock themselves out.
+	changedBy, _ := auth.FromContext(ctx)["user_id"].(string)
+	if changedBy == id.String() {
+		return nil, ErrForbidden
+	}
+
+	before, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.repo.SetStatus(ctx, id, update.Status, suspendedUntil); err != nil {
+			return err
+		}
+		if update.Status != models.UserStatusActive {
+			if user, err = s.repo.RevokeTokens(ctx, id); err != nil {
+				return err
+			}
+		}
+		if s.statuses != nil {
+			_, err := s.statuses.Create(ctx, &models.UserStatusChange{
+				UserID:         user.InternalID,
+				Status:         user.Status,
+				SuspendedUntil: user.SuspendedUntil,
+				Reason:         update.Reason,
+				ChangedBy:      changedBy,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		changes := UserChanges(before, user)
+		if len(changes) == 0 {
+			return nil
+		}
+		return s.publish(ctx, models.EventUserUpdated, user, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordUserChange(ctx, models.AuditActionUserStatus, before, user)
+	if update.Status != models.UserStatusActive {
+		if err := s.revokeSessions(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+func (s *userService) StatusHistory(ctx context.Context, id uuid.UUID) ([]*models.UserStatusChange, error) {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.statuses == nil {
+		return []*models.UserStatusChange{}, nil
+	}
+	return s.statuses.ListByUser(ctx, user.InternalID)
+}
+
+func (s *userService) SetAvatar(ctx context.Context, id uuid.UUID, image []byte) (*models.User, error) {
+	if s.files == nil {
+		return nil, ErrAvatarsDisabled
+	}
+	before, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	avatar, contentType, err := images.Square(image, s.avatarSize)
+	if err != nil {
+		return nil, err
+	}
+	extension := ".png"
+	if contentType == "image/jpeg" {
+		extension = ".jpg"
+	}
+	// Keys change with the content, so avatars can be cached for good.
+	hash := sha256.Sum256(avatar)
+	key := "avatars/" + id.String() + "/" + hex.EncodeToString(hash[:8]) + extension
+	if err := s.files.Put(ctx, key, bytes.NewReader(avatar), contentType); err != nil {
+		return nil, fmt.Errorf("failed to store avatar: %w", err)
+	}
+
+	return s.setAvatar(ctx, before, key, s.files.URL(key))
 }
 
-func (s *UserService) List(ctx context.Context, page, pageSize int) ([]*models.User, int, error) {
+func (s *userService) DeleteAvatar(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	if s.files == nil {
+		return nil, ErrAvatarsDisabled
+	}
+	before, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if before.AvatarKey == "" {
+		return before, nil
+	}
+	return s.setAvatar(ctx, before, "", "")
+}
+
+// setAvatar points the user at a stored avatar, or at none when key is
+// empty, and deletes the previous one.
+func (s *userService) setAvatar(ctx context.Context, before *models.User, key, url string) (*models.User, error) {
+	var user *models.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.repo.SetAvatar(ctx, before.ID, key, url); err != nil {
+			return err
+		}
+
+		changes := UserChanges(before, user)
+		if len(changes) == 0 {
+			return nil
+		}
+		return s.publish(ctx, models.EventUserUpdated, user, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordUserChange(ctx, models.AuditActionUserUpdate, before, user)
+	if before.AvatarKey != "" && before.AvatarKey != key {
+		if err := s.files.Delete(ctx, before.AvatarKey); err != nil {
+			log.Printf("failed to delete avatar %s: %v", before.AvatarKey, err)
+		}
+	}
+	return user, nil
+}
+
+// checkAttributes bounds the size of attributes and checks them against the
+// schema. They are checked as decoded from JSON, which is how they are
+// stored.
+func (s *userService) checkAttributes(attributes map[string]interface{}) error {
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAttributes, err)
+	}
+	if len(encoded) > maxAttributesSize {
+		return fmt.Errorf("%w: larger than %d bytes", ErrInvalidAttributes, maxAttributesSize)
+	}
+	if s.attributes == nil {
+		return nil
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAttributes, err)
+	}
+	if err := s.attributes.Validate(decoded); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAttributes, err)
+	}
+	return nil
+}
+
+// revokeSessions signs user out of every session.
+func (s *userService) revokeSessions(ctx context.Context, user *models.User) error {
+	if s.sessions == nil {
+		return nil
+	}
+	return s.sessions.RevokeOthers(ctx, user, uuid.Nil)
+}
+
+func (s *userService) List(ctx context.Context, filter models.UserFilter, page, pageSize int) ([]*models.User, int, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -59,12 +622,12 @@ func (s *UserService) List(ctx context.Context, page, pageSize int) ([]*models.U
 
 	offset := (page - 1) * pageSize
 
-	users, err := s.repo.List(ctx, pageSize, offset)
+	users, err := s.repo.List(ctx, filter, pageSize, offset)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	count, err := s.repo.Count(ctx)
+	count, err := s.repo.Count(ctx, filter)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -72,40 +635,671 @@ func (s *UserService) List(ctx context.Context, page, pageSize int) ([]*models.U
 	return users, count, nil
 }
 
-func (s *UserService) Login(ctx context.Context, login *models.UserLogin) (string, error) {
+func (s *userService) Login(ctx context.Context, login *models.UserLogin) (*models.LoginResult, error) {
 	user, err := s.repo.GetByUsername(ctx, login.Username)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
-			return "", fmt.Errorf("invalid username or password")
+			s.recordLogin(ctx, nil, login.Username, false)
+			return nil, ErrInvalidCredentials
 		}
-		return "", err
+		return nil, err
 	}
 
-	if !utils.CheckPassword(login.Password, user.PasswordHash) {
-		return "", fmt.Errorf("invalid username or password")
+	if !s.hasher.Verify(login.Password, user.PasswordHash) {
+		s.recordLogin(ctx, user, login.Username, false)
+		return nil, ErrInvalidCredentials
 	}
+	if s.hasher.NeedsRehash(user.PasswordHash) {
+		s.rehash(ctx, user, login.Password)
+	}
+
+	return s.SignIn(ctx, user)
+}
+
+func (s *userService) SignIn(ctx context.Context, user *models.User) (*models.LoginResult, error) {
+	if err := s.checkActive(ctx, user); err != nil {
+		return nil, err
+	}
+	if s.mfa != nil {
+		enabled, err := s.mfa.Enabled(ctx, user)
+		if err != nil {
+			return nil, err
+		}
 
-	token, err := middleware.GenerateJWT(strconv.Itoa(user.ID), user.Role)
+		purpose := ""
+		switch {
+		case enabled:
+			purpose = auth.PurposeMFA
+		case s.mfa.Required(user):
+			purpose = auth.PurposeMFAEnroll
+		}
+		if purpose != "" {
+			token, err := s.tokens.Issue(auth.MFAChallengeClaims(user.ID.String(), user.OrganizationID, purpose, s.challengeTTL))
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate mfa token: %w", err)
+			}
+			return &models.LoginResult{
+				MFARequired:           enabled,
+				MFAEnrollmentRequired: !enabled,
+				MFAToken:              token,
+			}, nil
+		}
+	}
+
+	token, err := s.IssueToken(ctx, user)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+		return nil, err
+	}
+	return &models.LoginResult{Token: token}, nil
+}
+
+func (s *userService) IssueToken(ctx context.Context, user *models.User) (string, error) {
+	if err := s.checkActive(ctx, user); err != nil {
+		return "", err
+	}
+
+	token, err := s.loginToken(ctx, user)
+	if err != nil {
+		return "", err
 	}
 
+	s.recordLogin(ctx, user, user.Username, true)
 	return token, nil
 }
 
-func (s *UserService) Register(ctx context.Context, user *models.UserCreate) (string, error) {
-	newUser, err := s.repo.Create(ctx, user)
+func (s *userService) Register(ctx context.Context, user *models.UserCreate) (string, error) {
+	user.Role = models.RoleUser
+	newUser, err := s.create(ctx, user)
 	if err != nil {
 		if errors.Is(err, repository.ErrConflict) {
-			return "", fmt.Errorf("username or email already exists")
+			return "", ErrUserExists
 		}
 		return "", err
 	}
 
-	token, err := middleware.GenerateJWT(strconv.Itoa(newUser.ID), newUser.Role)
+	s.audit.Record(ctx, &models.AuditEvent{
+		ActorID:    newUser.ID.String(),
+		ActorRole:  newUser.Role,
+		Action:     models.AuditActionUserRegister,
+		TargetType: "user",
+		TargetID:   newUser.ID.String(),
+		Success:    true,
+		Changes:    UserChanges(nil, newUser),
+	})
+
+	return s.loginToken(ctx, newUser)
+}
+
+func (s *userService) VerifyMFA(ctx context.Context, verify *models.MFAVerify) (string, error) {
+	user, err := s.challengeUser(ctx, verify.MFAToken, auth.PurposeMFA)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.mfa.Verify(ctx, user, verify.Code, verify.RecoveryCode); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) || errors.Is(err, ErrMFALocked) {
+			s.recordLogin(ctx, user, user.Username, false)
+		}
+		return "", err
+	}
+	return s.IssueToken(ctx, user)
+}
+
+func (s *userService) EnrollMFA(ctx context.Context, mfaToken string) (*models.TOTPEnrollment, error) {
+	user, err := s.challengeUser(ctx, mfaToken, auth.PurposeMFAEnroll)
+	if err != nil {
+		return nil, err
+	}
+	return s.mfa.Enroll(ctx, user)
+}
+
+func (s *userService) ConfirmMFAEnrollment(ctx context.Context, mfaToken, code string) (*models.MFAEnrolled, error) {
+	user, err := s.challengeUser(ctx, mfaToken, auth.PurposeMFAEnroll)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := s.mfa.Confirm(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.IssueToken(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MFAEnrolled{Token: token, RecoveryCodes: codes}, nil
+}
+
+func (s *userService) Verify(ctx context.Context, token string) (auth.Claims, error) {
+	claims, err := s.tokens.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return claims, nil
+	}
+
+	// The tenant of the request is not known yet, so the user is looked up
+	// in the organization the token was issued in.
+	organizationID, ok := auth.OrganizationID(claims)
+	if !ok {
+		organizationID = models.DefaultOrganizationID
+	}
+	ctx = tenant.NewContext(ctx, tenant.Tenant{ID: organizationID})
+
+	if sessionID, ok := auth.SessionID(claims); ok && s.sessions != nil {
+		id, err := uuid.Parse(sessionID)
+		if err != nil {
+			return nil, auth.ErrInvalidToken
+		}
+		if err := s.sessions.Check(ctx, id); err != nil {
+			if errors.Is(err, ErrSessionRevoked) {
+				return nil, auth.ErrInvalidToken
+			}
+			return nil, err
+		}
+		return claims, nil
+	}
+
+	// Tokens without a session are checked against the user instead.
+	user, err := s.GetByTokenUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if auth.TokenVersion(claims) != user.TokenVersion || user.StatusAt(time.Now()) != models.UserStatusActive {
+		return nil, auth.ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, id uuid.UUID, profile *models.ProfileUpdate) (*models.User, error) {
+	if profile.Role != "" {
+		return nil, ErrForbidden
+	}
+	return s.Update(ctx, id, &models.UserUpdate{Username: profile.Username, UserProfile: profile.UserProfile})
+}
+
+func (s *userService) ChangePassword(ctx context.Context, id uuid.UUID, change *models.PasswordChange) (string, error) {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if !s.hasher.Verify(change.CurrentPassword, user.PasswordHash) {
+		return "", ErrInvalidCredentials
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.Update(ctx, id, &models.UserUpdate{Password: change.NewPassword}); err != nil {
+			return err
+		}
+		user, err = s.repo.RevokeTokens(ctx, id)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if s.sessions == nil {
+		return s.loginToken(ctx, user)
+	}
+	// The current session stays signed in with a new token. Callers with a
+	// token from before sessions get a new one.
+	sessionID, _ := auth.SessionID(auth.FromContext(ctx))
+	current, parseErr := uuid.Parse(sessionID)
+	if err := s.sessions.RevokeOthers(ctx, user, current); err != nil {
+		return "", err
+	}
+	if parseErr != nil {
+		return s.loginToken(ctx, user)
+	}
+	token, err := s.tokens.Issue(auth.WithSession(userClaims(user), sessionID))
 	if err != nil {
 		return "", fmt.Errorf("failed to generate token: %w", err)
 	}
+	return token, nil
+}
+
+func (s *userService) RequestEmailChange(ctx context.Context, id uuid.UUID, email string) error {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing, err := s.repo.GetByEmail(ctx, email); err == nil && existing.ID != user.ID {
+		return ErrUserExists
+	} else if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	// email_from ties the token to the current address, so it cannot undo a
+	// later change.
+	token, err := s.tokens.Issue(auth.Claims{
+		"email_user_id":        user.ID.String(),
+		"email":                email,
+		"email_from":           user.Email,
+		auth.ClaimOrganization: strconv.Itoa(user.OrganizationID),
+		auth.ClaimPurpose:      auth.PurposeEmailChange,
+		"exp":                  time.Now().Add(emailChangeTTL).Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate email token: %w", err)
+	}
+
+	confirmation := token
+	if s.confirmURL != "" {
+		confirmation = s.confirmURL + "?token=" + url.QueryEscape(token)
+	}
+	return s.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Confirm the new email address of %s with:\n\n%s\n\nIt expires in %s. If you did not ask for this change, ignore this email.\n",
+			user.Username, confirmation, emailChangeTTL),
+	})
+}
+
+func (s *userService) ConfirmEmailChange(ctx context.Context, token string) (*models.User, error) {
+	claims, err := s.tokens.Verify(ctx, token)
+	if err != nil || claims[auth.ClaimPurpose] != auth.PurposeEmailChange {
+		return nil, ErrInvalidEmailToken
+	}
+	organizationID, _ := auth.OrganizationID(claims)
+	if current, ok := tenant.FromContext(ctx); ok && current.ID != organizationID {
+		return nil, ErrInvalidEmailToken
+	}
+
+	userID, _ := claims["email_user_id"].(string)
+	email, _ := claims["email"].(string)
+	id, err := uuid.Parse(userID)
+	if err != nil || email == "" {
+		return nil, ErrInvalidEmailToken
+	}
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidEmailToken
+		}
+		return nil, err
+	}
+	if user.Email != claims["email_from"] {
+		return nil, ErrInvalidEmailToken
+	}
+
+	updated, err := s.Update(ctx, id, &models.UserUpdate{Email: email})
+	if errors.Is(err, repository.ErrConflict) {
+		return nil, ErrUserExists
+	}
+	return updated, err
+}
+
+func (s *userService) Import(ctx context.Context, opts *models.UserImportOptions, rows []*models.UserImportRow) (*models.UserImportReport, error) {
+	valid, rowErrors, err := s.checkImportRows(ctx, opts, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *repository.UserImportResult
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if result, err = s.repo.Import(ctx, opts.Mode, valid, opts.DryRun); err != nil || opts.DryRun {
+			return err
+		}
+
+		for _, user := range result.Created {
+			if err := s.assignRole(ctx, user); err != nil {
+				return err
+			}
+			if err := s.publish(ctx, models.EventUserRegistered, user, nil); err != nil {
+				return err
+			}
+		}
+		for _, change := range result.Updated {
+			if change.After.Role != change.Before.Role {
+				if err := s.assignRole(ctx, change.After); err != nil {
+					return err
+				}
+			}
+			if changes := UserChanges(change.Before, change.After); len(changes) > 0 {
+				if err := s.publish(ctx, models.EventUserUpdated, change.After, changes); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import users: %w", err)
+	}
+
+	report := &models.UserImportReport{
+		Total:     len(rows),
+		Created:   len(result.Created),
+		Updated:   len(result.Updated),
+		Unchanged: result.Unchanged,
+	}
+	addImportErrors(report, append(rowErrors, result.Errors...))
+
+	if !opts.DryRun {
+		s.audit.Record(ctx, &models.AuditEvent{
+			Action:     models.AuditActionUserImport,
+			TargetType: "users",
+			Success:    true,
+			Changes: map[string]models.AuditChange{
+				"created": {To: report.Created},
+				"updated": {To: report.Updated},
+				"failed":  {To: report.Failed},
+			},
+		})
+	}
+	return report, nil
+}
+
+func (s *userService) Export(ctx context.Context, fn func(user *models.User) error) error {
+	return s.repo.Stream(ctx, fn)
+}
+
+// checkImportRows validates rows on their own and against each other, and
+// hashes the passwords of the valid ones unless opts.DryRun. Roles are
+// checked like on Update: unknown roles fail their rows, and assigning any
+// role but the default one without roles:manage fails the whole import with
+// ErrForbidden.
+func (s *userService) checkImportRows(ctx context.Context, opts *models.UserImportOptions, rows []*models.UserImportRow) ([]*models.UserImportRow, []models.UserImportRowError, error) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		return name
+	})
 
+	var rowErrors []models.UserImportRowError
+	failed := make(map[int]bool)
+	fail := func(row *models.UserImportRow, field, message string) {
+		rowErrors = append(rowErrors, models.UserImportRowError{Row: row.Row, Field: field, Error: message})
+		failed[row.Row] = true
+	}
+
+	usernames := make(map[string]int)
+	emails := make(map[string]int)
+	roles := make(map[string]error)
+	for _, row := range rows {
+		if err := validate.Struct(row); err != nil {
+			var validationErrors validator.ValidationErrors
+			if !errors.As(err, &validationErrors) {
+				return nil, nil, err
+			}
+			for _, fieldError := range validationErrors {
+				fail(row, fieldError.Field(), validationMessage(fieldError))
+			}
+		}
+		if opts.Mode == models.UserImportModeCreate && row.Password == "" {
+			fail(row, "password", "is required")
+		}
+
+		if line, ok := usernames[row.Username]; ok {
+			fail(row, "username", fmt.Sprintf("is already used on line %d", line))
+		} else {
+			usernames[row.Username] = row.Row
+		}
+		if line, ok := emails[row.Email]; ok {
+			fail(row, "email", fmt.Sprintf("is already used on line %d", line))
+		} else {
+			emails[row.Email] = row.Row
+		}
+
+		if row.Role != "" && row.Role != models.RoleUser {
+			err, checked := roles[row.Role]
+			if !checked {
+				err = s.checkRoleChange(ctx, row.Role)
+				roles[row.Role] = err
+			}
+			switch {
+			case errors.Is(err, ErrUnknownRole):
+				fail(row, "role", err.Error())
+			case err != nil:
+				return nil, nil, err
+			}
+		}
+
+		if row.Password != "" && s.policy != nil {
+			var policyError *password.PolicyError
+			if err := s.policy.Check(row.Password, row.Username, row.Email); errors.As(err, &policyError) {
+				fail(row, "password", strings.Join(policyError.Violations, ", "))
+			}
+		}
+	}
+
+	var valid []*models.UserImportRow
+	for _, row := range rows {
+		if failed[row.Row] {
+			continue
+		}
+		if row.Password != "" && !opts.DryRun {
+			hash, err := s.hasher.Hash(row.Password)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to hash password: %w", err)
+			}
+			row.PasswordHash = hash
+		}
+		valid = append(valid, row)
+	}
+	return valid, rowErrors, nil
+}
+
+// challengeUser resolves the user of an MFA token issued by SignIn for
+// purpose. The token must be used in the organization it was issued in.
+func (s *userService) challengeUser(ctx context.Context, mfaToken, purpose string) (*models.User, error) {
+	if s.mfa == nil {
+		return nil, ErrMFANotEnabled
+	}
+
+	claims, err := s.tokens.Verify(ctx, mfaToken)
+	if err != nil || claims[auth.ClaimPurpose] != purpose {
+		return nil, ErrInvalidMFAToken
+	}
+	organizationID, _ := auth.OrganizationID(claims)
+	if current, ok := tenant.FromContext(ctx); ok && current.ID != organizationID {
+		return nil, ErrInvalidMFAToken
+	}
+
+	userID, _ := claims["mfa_user_id"].(string)
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	user, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidMFAToken
+	}
+	return user, err
+}
+
+// create stores the user together with its user.registered event.
+func (s *userService) create(ctx context.Context, user *models.UserCreate) (*models.User, error) {
+	if user.PasswordHash == "" {
+		var err error
+		if user.PasswordHash, err = s.hashPassword(user.Password, user.Username, user.Email); err != nil {
+			return nil, err
+		}
+	}
+
+	var newUser *models.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if newUser, err = s.repo.Create(ctx, user); err != nil {
+			return err
+		}
+		if err := s.assignRole(ctx, newUser); err != nil {
+			return err
+		}
+		return s.publish(ctx, models.EventUserRegistered, newUser, nil)
+	})
+	return newUser, err
+}
+
+// userClaims are the claims of a token that acts as user.
+func userClaims(user *models.User) auth.Claims {
+	claims := auth.WithOrganization(auth.UserClaims(user.ID.String(), user.Role), user.OrganizationID)
+	return auth.WithTokenVersion(claims, user.TokenVersion)
+}
+
+// checkActive refuses to sign in users who are not active.
+func (s *userService) checkActive(ctx context.Context, user *models.User) error {
+	status := user.StatusAt(time.Now())
+	if status == models.UserStatusActive {
+		return nil
+	}
+	s.recordLogin(ctx, user, user.Username, false)
+	return &UserStatusError{Status: status, SuspendedUntil: user.SuspendedUntil}
+}
+
+// loginToken starts a session for user and returns a token for it.
+func (s *userService) loginToken(ctx context.Context, user *models.User) (string, error) {
+	claims := userClaims(user)
+	if s.sessions != nil {
+		session, err := s.sessions.Start(ctx, user)
+		if err != nil {
+			return "", err
+		}
+		claims = auth.WithSession(claims, session.ID.String())
+	}
+
+	token, err := s.tokens.Issue(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
 	return token, nil
 }
+
+// hashPassword checks a new password against the policy and hashes it.
+func (s *userService) hashPassword(plain, username, email string) (string, error) {
+	if s.policy != nil {
+		if err := s.policy.Check(plain, username, email); err != nil {
+			return "", err
+		}
+	}
+	return s.hasher.Hash(plain)
+}
+
+// rehash replaces an outdated password hash after a successful login. The
+// login goes ahead if that fails; it is retried at the next one.
+func (s *userService) rehash(ctx context.Context, user *models.User, plain string) {
+	hash, err := s.hasher.Hash(plain)
+	if err == nil {
+		_, err = s.repo.Update(ctx, user.ID, &models.UserUpdate{PasswordHash: hash})
+	}
+	if err != nil {
+		log.Printf("failed to rehash password of user %s: %v", user.ID, err)
+	}
+}
+
+// checkRoleChange makes sure role exists and that the caller, when there is
+// one in ctx, may manage roles.
+func (s *userService) checkRoleChange(ctx context.Context, role string) error {
+	if s.roles == nil {
+		return nil
+	}
+
+	if _, err := s.roles.GetRole(ctx, role); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUnknownRole
+		}
+		return err
+	}
+
+	// Callers without claims, such as the admin CLI, are trusted.
+	claims := auth.FromContext(ctx)
+	if len(claims) == 0 {
+		return nil
+	}
+	allowed, err := hasPermission(ctx, s.roles, s.repo, claims, models.PermissionRolesManage)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// assignRole replaces the roles of user with its primary role.
+func (s *userService) assignRole(ctx context.Context, user *models.User) error {
+	if s.roles == nil {
+		return nil
+	}
+
+	if err := s.roles.SetUserRoles(ctx, user.InternalID, []string{user.Role}); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUnknownRole
+		}
+		return err
+	}
+	return nil
+}
+
+// publish adds a user event to the outbox. It must be called inside
+// s.tx.WithinTx so the event is only stored if the change is.
+func (s *userService) publish(ctx context.Context, eventType string, user *models.User, changes map[string]models.AuditChange) error {
+	if s.outbox == nil {
+		return nil
+	}
+
+	current, _ := tenant.FromContext(ctx)
+	payload, err := json.Marshal(models.UserEventPayload{Organization: current.Slug, User: user, Changes: changes})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return fmt.Errorf("failed to generate event id: %w", err)
+	}
+
+	event := &models.DomainEvent{
+		ID:          id,
+		Type:        eventType,
+		OrderingKey: "user:" + user.ID.String(),
+		OccurredAt:  time.Now().UTC(),
+		Payload:     payload,
+	}
+	if err := s.outbox.Add(ctx, event); err != nil {
+		return fmt.Errorf("failed to add %s event to outbox: %w", eventType, err)
+	}
+	return nil
+}
+
+func (s *userService) recordUserChange(ctx context.Context, action string, before, after *models.User) {
+	target := after
+	if target == nil {
+		target = before
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   target.ID.String(),
+		Success:    true,
+		Changes:    UserChanges(before, after),
+	})
+}
+
+// recordLogin audits a login attempt. Unknown usernames are recorded by name
+// since there is no user ID to point at.
+func (s *userService) recordLogin(ctx context.Context, user *models.User, username string, success bool) {
+	event := &models.AuditEvent{
+		Action:     models.AuditActionUserLogin,
+		TargetType: "username",
+		TargetID:   username,
+		Success:    success,
+	}
+	if user != nil {
+		event.TargetType = "user"
+		event.TargetID = user.ID.String()
+	}
+	if success {
+		event.ActorID = user.ID.String()
+		event.ActorRole = user.Role
+	}
+
+	s.audit.Record(ctx, event)
+}
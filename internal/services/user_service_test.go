@@ -0,0 +1,371 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	stdimage "image"
+	"image/png"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/auth"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/images"
+	"github.com/Romasmi/go-rest-api-template/internal/mail"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/password"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/schema"
+	"github.com/Romasmi/go-rest-api-template/internal/storage"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	tokens := auth.NewFakeTokenService()
+	service := NewUserService(repository.NewMemoryUserRepository(), tokens)
+
+	token, err := service.Register(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while registering: %v", err)
+	}
+
+	claims, err := tokens.Verify(ctx, token)
+	if err != nil {
+		t.Fatalf("Error while verifying token: %v", err)
+	}
+	if claims["role"] != "user" {
+		t.Errorf("Wrong role claim, expected: %v, actual: %v", "user", claims["role"])
+	}
+
+	user, err := service.GetByTokenUserID(ctx, claims["user_id"].(string))
+	if err != nil {
+		t.Fatalf("Error while resolving token user: %v", err)
+	}
+	if user.Username != "alice" {
+		t.Errorf("Wrong token user, expected: %v, actual: %v", "alice", user.Username)
+	}
+
+	legacy, err := service.GetByTokenUserID(ctx, strconv.Itoa(user.InternalID))
+	if err != nil || legacy.ID != user.ID {
+		t.Errorf("Expected legacy integer user_id to resolve to %v, actual: %v, %v", user.ID, legacy, err)
+	}
+
+	_, err = service.Register(ctx, &models.UserCreate{Username: "alice", Email: "other@example.com", Password: "password1"})
+	if !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, actual: %v", err)
+	}
+
+	if _, err := service.Login(ctx, &models.UserLogin{Username: "alice", Password: "password1"}); err != nil {
+		t.Errorf("Error while logging in: %v", err)
+	}
+
+	_, err = service.Login(ctx, &models.UserLogin{Username: "alice", Password: "wrong-password"})
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for wrong password, actual: %v", err)
+	}
+
+	_, err = service.Login(ctx, &models.UserLogin{Username: "nobody", Password: "password1"})
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for unknown user, actual: %v", err)
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	ctx := context.Background()
+	service := NewUserService(repository.NewMemoryUserRepository(), auth.NewFakeTokenService())
+
+	for _, username := range []string{"alice", "bob", "carol"} {
+		_, err := service.Create(ctx, &models.UserCreate{Username: username, Email: username + "@example.com", Password: "password1"})
+		if err != nil {
+			t.Fatalf("Error while creating %v: %v", username, err)
+		}
+	}
+
+	users, count, err := service.List(ctx, models.UserFilter{}, 2, 2)
+	if err != nil {
+		t.Fatalf("Error while listing users: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Wrong count, expected: %v, actual: %v", 3, count)
+	}
+	if len(users) != 1 || users[0].Username != "carol" {
+		t.Errorf("Wrong second page: %+v", users)
+	}
+}
+
+func TestPasswordPolicyAndRehash(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryUserRepository()
+	tokens := auth.NewFakeTokenService()
+	argon2Hasher, err := password.NewHasher(config.PasswordConfig{
+		Algorithm: password.AlgorithmArgon2id,
+		Argon2:    config.Argon2Config{Memory: 1024, Iterations: 1, Parallelism: 1},
+	})
+	if err != nil {
+		t.Fatalf("Error while creating hasher: %v", err)
+	}
+	policy := password.NewPolicy(config.PasswordPolicyConfig{MinLength: 10, RejectUserInfo: true})
+
+	// alice signed up while passwords were hashed with bcrypt.
+	if _, err := NewUserService(repo, tokens).Register(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"}); err != nil {
+		t.Fatalf("Error while registering: %v", err)
+	}
+	service := NewUserService(repo, tokens, WithPasswords(argon2Hasher, policy))
+
+	if _, err := service.Register(ctx, &models.UserCreate{Username: "bob", Email: "bob@example.com", Password: "bob-password"}); !errors.Is(err, password.ErrWeakPassword) {
+		t.Errorf("Expected ErrWeakPassword for password with username, actual: %v", err)
+	}
+
+	before, _ := repo.GetByUsername(ctx, "alice")
+	if _, err := service.Login(ctx, &models.UserLogin{Username: "alice", Password: "password1"}); err != nil {
+		t.Fatalf("Error while logging in: %v", err)
+	}
+	after, _ := repo.GetByUsername(ctx, "alice")
+	if !strings.HasPrefix(after.PasswordHash, "$argon2id$") || argon2Hasher.NeedsRehash(after.PasswordHash) {
+		t.Errorf("Expected password to be rehashed with argon2id, actual: %v", after.PasswordHash)
+	}
+	if _, err := service.Login(ctx, &models.UserLogin{Username: "alice", Password: "password1"}); err != nil {
+		t.Errorf("Error while logging in after rehash: %v", err)
+	}
+	if again, _ := repo.GetByUsername(ctx, "alice"); again.PasswordHash != after.PasswordHash || before.PasswordHash == after.PasswordHash {
+		t.Error("Expected exactly one rehash")
+	}
+
+	if _, err := service.Update(ctx, after.ID, &models.UserUpdate{Email: "carol@example.com", Password: "carol-password"}); !errors.Is(err, password.ErrWeakPassword) {
+		t.Errorf("Expected ErrWeakPassword for password with new email, actual: %v", err)
+	}
+}
+
+type recordingMailer struct {
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, message mail.Message) error {
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func TestSelfService(t *testing.T) {
+	ctx := context.Background()
+	tokens := auth.NewFakeTokenService()
+	mailer := &recordingMailer{}
+	service := NewUserService(repository.NewMemoryUserRepository(), tokens, WithMailer(mailer, "https://app.example.com/confirm-email"))
+
+	token, err := service.Register(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while registering: %v", err)
+	}
+	claims, err := service.Verify(ctx, token)
+	if err != nil {
+		t.Fatalf("Error while verifying token: %v", err)
+	}
+	alice, _ := service.GetByTokenUserID(ctx, claims["user_id"].(string))
+
+	if _, err := service.UpdateProfile(ctx, alice.ID, &models.ProfileUpdate{Role: models.RoleAdmin}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for role change, actual: %v", err)
+	}
+
+	if _, err := service.ChangePassword(ctx, alice.ID, &models.PasswordChange{CurrentPassword: "wrong", NewPassword: "new-password"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for wrong current password, actual: %v", err)
+	}
+	newToken, err := service.ChangePassword(ctx, alice.ID, &models.PasswordChange{CurrentPassword: "password1", NewPassword: "new-password"})
+	if err != nil {
+		t.Fatalf("Error while changing password: %v", err)
+	}
+	if _, err := service.Verify(ctx, token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Expected old token to be revoked, actual: %v", err)
+	}
+	if _, err := service.Verify(ctx, newToken); err != nil {
+		t.Errorf("Error while verifying new token: %v", err)
+	}
+
+	if err := service.RequestEmailChange(ctx, alice.ID, "alice@example.org"); err != nil {
+		t.Fatalf("Error while requesting email change: %v", err)
+	}
+	if len(mailer.messages) != 1 || mailer.messages[0].To != "alice@example.org" {
+		t.Fatalf("Expected confirmation mail to the new address, actual: %+v", mailer.messages)
+	}
+	if user, _ := service.GetByID(ctx, alice.ID); user.Email != "alice@example.com" {
+		t.Errorf("Expected email to stay unconfirmed, actual: %v", user.Email)
+	}
+
+	_, link, _ := strings.Cut(mailer.messages[0].Body, "?token=")
+	emailToken, _, _ := strings.Cut(link, "\n")
+	if _, err := service.ConfirmEmailChange(ctx, newToken); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("Expected login token to be rejected, actual: %v", err)
+	}
+	updated, err := service.ConfirmEmailChange(ctx, emailToken)
+	if err != nil {
+		t.Fatalf("Error while confirming email change: %v", err)
+	}
+	if updated.Email != "alice@example.org" {
+		t.Errorf("Wrong email, expected: %v, actual: %v", "alice@example.org", updated.Email)
+	}
+	if _, err := service.ConfirmEmailChange(ctx, emailToken); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("Expected used token to be rejected, actual: %v", err)
+	}
+}
+
+func TestUserStatus(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	service := NewUserService(users, auth.NewFakeTokenService(), WithStatusHistory(repository.NewMemoryUserStatusChangeRepository()))
+
+	token, err := service.Register(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while registering: %v", err)
+	}
+	alice, _ := service.GetByUsername(ctx, "alice")
+	adminCtx := auth.NewContext(ctx, auth.UserClaims("admin-id", models.RoleAdmin))
+
+	until := time.Now().Add(time.Hour)
+	suspended, err := service.SetStatus(adminCtx, alice.ID, &models.UserStatusUpdate{Status: models.UserStatusSuspended, SuspendedUntil: &until, Reason: "spam"})
+	if err != nil {
+		t.Fatalf("Error while suspending user: %v", err)
+	}
+	if suspended.Status != models.UserStatusSuspended || suspended.SuspendedUntil == nil {
+		t.Errorf("Wrong status, expected: %v, actual: %v", models.UserStatusSuspended, suspended.Status)
+	}
+	if _, err := service.Verify(ctx, token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Expected the token of a suspended user to be rejected, actual: %v", err)
+	}
+	_, err = service.Login(ctx, &models.UserLogin{Username: "alice", Password: "password1"})
+	var statusError *UserStatusError
+	if !errors.As(err, &statusError) || !errors.Is(err, ErrUserInactive) || statusError.Status != models.UserStatusSuspended {
+		t.Errorf("Expected a suspended UserStatusError, actual: %v", err)
+	}
+	if _, err := service.Login(ctx, &models.UserLogin{Username: "alice", Password: "wrong"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for a wrong password, actual: %v", err)
+	}
+
+	// Suspensions end by themselves.
+	past := time.Now().Add(-time.Minute)
+	if _, err := users.SetStatus(ctx, alice.ID, models.UserStatusSuspended, &past); err != nil {
+		t.Fatalf("Error while setting status: %v", err)
+	}
+	if _, err := service.Login(ctx, &models.UserLogin{Username: "alice", Password: "password1"}); err != nil {
+		t.Errorf("Expected an ended suspension to allow login, actual: %v", err)
+	}
+
+	if _, err := service.SetStatus(adminCtx, alice.ID, &models.UserStatusUpdate{Status: models.UserStatusDisabled, Reason: "fraud"}); err != nil {
+		t.Fatalf("Error while disabling user: %v", err)
+	}
+	if _, err := service.Login(ctx, &models.UserLogin{Username: "alice", Password: "password1"}); !errors.Is(err, ErrUserInactive) || err.Error() != "user is disabled" {
+		t.Errorf("Expected the user to be disabled, actual: %v", err)
+	}
+	if _, err := service.SetStatus(adminCtx, alice.ID, &models.UserStatusUpdate{Status: models.UserStatusActive}); err != nil {
+		t.Fatalf("Error while activating user: %v", err)
+	}
+
+	history, err := service.StatusHistory(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("Error while getting status history: %v", err)
+	}
+	if len(history) != 3 || history[0].Status != models.UserStatusActive || history[1].Reason != "fraud" || history[2].ChangedBy != "admin-id" {
+		t.Errorf("Wrong history: %+v", history)
+	}
+
+	for _, update := range []*models.UserStatusUpdate{
+		{Status: models.UserStatusSuspended},
+		{Status: models.UserStatusSuspended, SuspendedUntil: &past},
+		{Status: models.UserStatusDisabled, SuspendedUntil: &until},
+	} {
+		if _, err := service.SetStatus(adminCtx, alice.ID, update); !errors.Is(err, ErrInvalidStatusChange) {
+			t.Errorf("Expected ErrInvalidStatusChange for %+v, actual: %v", update, err)
+		}
+	}
+	selfCtx := auth.NewContext(ctx, auth.UserClaims(alice.ID.String(), alice.Role))
+	if _, err := service.SetStatus(selfCtx, alice.ID, &models.UserStatusUpdate{Status: models.UserStatusDisabled}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for a change of the own status, actual: %v", err)
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	ctx := context.Background()
+	attributes, err := schema.Compile([]byte(`{
+		"type": "object",
+		"properties": {"department": {"type": "string"}, "level": {"type": "integer"}},
+		"additionalProperties": false
+	}`))
+	if err != nil {
+		t.Fatalf("Error while compiling schema: %v", err)
+	}
+	files := storage.NewMemory()
+	service := NewUserService(repository.NewMemoryUserRepository(), auth.NewFakeTokenService(),
+		WithAttributeSchema(attributes), WithAvatars(files, 32))
+
+	alice, err := service.Create(ctx, &models.UserCreate{Username: "alice", Email: "alice@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+	if _, err := service.Create(ctx, &models.UserCreate{Username: "bob", Email: "bob@example.com", Password: "password1"}); err != nil {
+		t.Fatalf("Error while creating user: %v", err)
+	}
+
+	displayName, locale := "Alice", "de-DE"
+	updated, err := service.UpdateProfile(ctx, alice.ID, &models.ProfileUpdate{UserProfile: models.UserProfile{DisplayName: &displayName, Locale: &locale}})
+	if err != nil {
+		t.Fatalf("Error while updating profile: %v", err)
+	}
+	if updated.DisplayName != displayName || updated.Locale != locale {
+		t.Errorf("Wrong profile, expected: %v and %v, actual: %v and %v", displayName, locale, updated.DisplayName, updated.Locale)
+	}
+
+	if _, err := service.Update(ctx, alice.ID, &models.UserUpdate{Attributes: map[string]interface{}{"department": "sales", "level": 2}}); err != nil {
+		t.Fatalf("Error while setting attributes: %v", err)
+	}
+	for _, invalid := range []map[string]interface{}{
+		{"level": "high"},
+		{"team": "a"},
+		{"department": strings.Repeat("a", maxAttributesSize)},
+	} {
+		if _, err := service.Update(ctx, alice.ID, &models.UserUpdate{Attributes: invalid}); !errors.Is(err, ErrInvalidAttributes) {
+			t.Errorf("Expected ErrInvalidAttributes for %.40v, actual: %v", invalid, err)
+		}
+	}
+	users, count, err := service.List(ctx, models.UserFilter{Attributes: map[string]string{"level": "2"}}, 1, 10)
+	if err != nil {
+		t.Fatalf("Error while listing users: %v", err)
+	}
+	if count != 1 || len(users) != 1 || users[0].ID != alice.ID {
+		t.Errorf("Wrong filtered users, expected only alice, actual: %v of %+v", count, users)
+	}
+
+	var image bytes.Buffer
+	png.Encode(&image, stdimage.NewRGBA(stdimage.Rect(0, 0, 100, 50)))
+	withAvatar, err := service.SetAvatar(ctx, alice.ID, image.Bytes())
+	if err != nil {
+		t.Fatalf("Error while setting avatar: %v", err)
+	}
+	if !strings.HasPrefix(withAvatar.AvatarURL, "/files/avatars/"+alice.ID.String()+"/") || withAvatar.AvatarURL != files.URL(withAvatar.AvatarKey) {
+		t.Errorf("Wrong avatar URL: %v", withAvatar.AvatarURL)
+	}
+	stored, _ := files.Get(ctx, withAvatar.AvatarKey)
+	if config, _, err := stdimage.DecodeConfig(stored); err != nil || config.Width != 32 || config.Height != 32 {
+		t.Errorf("Expected a 32x32 avatar, actual: %+v, %v", config, err)
+	}
+
+	image.Reset()
+	png.Encode(&image, stdimage.NewGray(stdimage.Rect(0, 0, 40, 40)))
+	replaced, err := service.SetAvatar(ctx, alice.ID, image.Bytes())
+	if err != nil {
+		t.Fatalf("Error while replacing avatar: %v", err)
+	}
+	if keys := files.Keys(); len(keys) != 1 || keys[0] != replaced.AvatarKey {
+		t.Errorf("Expected only the new avatar to be kept, actual: %v", keys)
+	}
+	if _, err := service.SetAvatar(ctx, alice.ID, []byte("GIF89a")); !errors.Is(err, images.ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, actual: %v", err)
+	}
+
+	deleted, err := service.DeleteAvatar(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("Error while deleting avatar: %v", err)
+	}
+	if deleted.AvatarURL != "" || len(files.Keys()) != 0 {
+		t.Errorf("Expected the avatar to be deleted, actual: %v, %v", deleted.AvatarURL, files.Keys())
+	}
+}
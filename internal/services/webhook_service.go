@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/secrets"
+	"github.com/Romasmi/go-rest-api-template/internal/webhooks"
+)
+
+var (
+	ErrDeliveryPending   = errors.New("webhook delivery is still pending")
+	ErrInvalidWebhookURL = errors.New("webhook url is not allowed")
+)
+
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, subscription *models.WebhookSubscriptionCreate) (*models.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter, page, pageSize int) ([]*models.WebhookDelivery, int, error)
+	GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error)
+	// Replay schedules a finished delivery to be sent again right away.
+	Replay(ctx context.Context, id int64) (*models.WebhookDelivery, error)
+	// Deliver queues a delivery of event for every matching subscription. It
+	// makes the service an events.Sink fed by the outbox dispatcher.
+	Deliver(ctx context.Context, event *models.DomainEvent) error
+}
+
+type webhookService struct {
+	repo         repository.WebhookRepository
+	secrets      *secrets.Cipher
+	allowPrivate bool
+}
+
+// NewWebhookService returns the webhook service. Signing secrets are
+// encrypted with cipher before they are stored. Unless allowPrivate is set,
+// subscriptions must point at public addresses.
+func NewWebhookService(repo repository.WebhookRepository, cipher *secrets.Cipher, allowPrivate bool) WebhookService {
+	return &webhookService{
+		repo:         repo,
+		secrets:      cipher,
+		allowPrivate: allowPrivate,
+	}
+}
+
+func (s *webhookService) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscriptionCreate) (*models.WebhookSubscription, error) {
+	if err := webhooks.CheckURL(ctx, subscription.URL, s.allowPrivate); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhookURL, err)
+	}
+
+	secret, err := s.secrets.Encrypt(subscription.Secret)
+	if err != nil {
+		return nil, err
+	}
+	create := *subscription
+	create.Secret = secret
+	return s.repo.CreateSubscription(ctx, &create)
+}
+
+func (s *webhookService) GetSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	return s.repo.GetSubscription(ctx, id)
+}
+
+func (s *webhookService) ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	return s.repo.ListSubscriptions(ctx)
+}
+
+func (s *webhookService) DeleteSubscription(ctx context.Context, id int64) error {
+	return s.repo.DeleteSubscription(ctx, id)
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter, page, pageSize int) ([]*models.WebhookDelivery, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	deliveries, err := s.repo.ListDeliveries(ctx, filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := s.repo.CountDeliveries(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, count, nil
+}
+
+func (s *webhookService) GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	return s.repo.GetDelivery(ctx, id)
+}
+
+func (s *webhookService) Replay(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	delivery, err := s.repo.ReplayDelivery(ctx, id)
+	if !errors.Is(err, repository.ErrNotFound) {
+		return delivery, err
+	}
+
+	// Nothing was replayed: the delivery is either missing or still pending.
+	if _, err := s.repo.GetDelivery(ctx, id); err != nil {
+		return nil, err
+	}
+	return nil, ErrDeliveryPending
+}
+
+func (s *webhookService) Deliver(ctx context.Context, event *models.DomainEvent) error {
+	subscriptions, err := s.repo.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	for _, subscription := range subscriptions {
+		if !subscription.Active || !subscription.Matches(event.Type) {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+			}
+		}
+
+		err := s.repo.EnqueueDelivery(ctx, &models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
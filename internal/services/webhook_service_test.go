@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/secrets"
+	"github.com/google/uuid"
+)
+
+func newWebhookCipher(t *testing.T) *secrets.Cipher {
+	t.Helper()
+	cipher, err := secrets.NewCipher([]byte("test-key"))
+	if err != nil {
+		t.Fatalf("Error while creating cipher: %v", err)
+	}
+	return cipher
+}
+
+func TestWebhookDeliverAndReplay(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryWebhookRepository()
+	service := NewWebhookService(repo, newWebhookCipher(t), true)
+
+	deleted, _ := service.CreateSubscription(ctx, &models.WebhookSubscriptionCreate{URL: "http://a.example.com", EventTypes: []string{models.EventUserDeleted}, Secret: "0123456789abcdef"})
+	all, _ := service.CreateSubscription(ctx, &models.WebhookSubscriptionCreate{URL: "http://b.example.com", EventTypes: []string{models.WebhookAllEvents}, Secret: "0123456789abcdef"})
+
+	event := &models.DomainEvent{ID: uuid.New(), Type: models.EventUserRegistered, OccurredAt: time.Now(), Payload: []byte(`{}`)}
+	for i := 0; i < 2; i++ {
+		if err := service.Deliver(ctx, event); err != nil {
+			t.Fatalf("Error while delivering event: %v", err)
+		}
+	}
+
+	deliveries, count, err := service.ListDeliveries(ctx, models.WebhookDeliveryFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("Error while listing deliveries: %v", err)
+	}
+	if count != 1 || deliveries[0].SubscriptionID != all.ID {
+		t.Fatalf("Expected a single delivery to subscription %v, actual: %+v", all.ID, deliveries)
+	}
+	if c, _ := repo.CountDeliveries(ctx, models.WebhookDeliveryFilter{SubscriptionID: deleted.ID}); c != 0 {
+		t.Errorf("Wrong deliveries for non-matching subscription, expected: %v, actual: %v", 0, c)
+	}
+
+	if _, err := service.Replay(ctx, deliveries[0].ID); !errors.Is(err, ErrDeliveryPending) {
+		t.Errorf("Expected ErrDeliveryPending, actual: %v", err)
+	}
+
+	dead := deliveries[0]
+	dead.Status = models.WebhookDeliveryDead
+	dead.Attempts = 8
+	repo.UpdateDelivery(ctx, dead)
+
+	replayed, err := service.Replay(ctx, dead.ID)
+	if err != nil {
+		t.Fatalf("Error while replaying: %v", err)
+	}
+	if replayed.Status != models.WebhookDeliveryPending || replayed.Attempts != 0 {
+		t.Errorf("Wrong replayed state, expected: %v/%v, actual: %v/%v", models.WebhookDeliveryPending, 0, replayed.Status, replayed.Attempts)
+	}
+}
+
+func TestWebhookSubscriptionsAreChecked(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryWebhookRepository()
+	cipher := newWebhookCipher(t)
+	service := NewWebhookService(repo, cipher, false)
+
+	_, err := service.CreateSubscription(ctx, &models.WebhookSubscriptionCreate{URL: "http://169.254.169.254/latest", EventTypes: []string{models.WebhookAllEvents}, Secret: "0123456789abcdef"})
+	if !errors.Is(err, ErrInvalidWebhookURL) {
+		t.Errorf("Expected ErrInvalidWebhookURL, actual: %v", err)
+	}
+
+	created, err := service.CreateSubscription(ctx, &models.WebhookSubscriptionCreate{URL: "https://93.184.215.14/hook", EventTypes: []string{models.WebhookAllEvents}, Secret: "0123456789abcdef"})
+	if err != nil {
+		t.Fatalf("Error while creating subscription: %v", err)
+	}
+	stored, _ := repo.GetSubscription(ctx, created.ID)
+	if stored.Secret == "0123456789abcdef" {
+		t.Error("Expected the secret to be stored encrypted")
+	}
+	if secret, err := cipher.Decrypt(stored.Secret); err != nil || secret != "0123456789abcdef" {
+		t.Errorf("Wrong decrypted secret, expected: %v, actual: %v (%v)", "0123456789abcdef", secret, err)
+	}
+}
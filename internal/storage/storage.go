@@ -0,0 +1,57 @@
+// Package storage keeps uploaded files, such as avatars, in a local directory
+// or an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+)
+
+var (
+	ErrNotFound   = errors.New("file not found")
+	ErrInvalidKey = errors.New("invalid file key")
+)
+
+// Storage keeps files by key. Keys are relative slash-separated paths such as
+// "avatars/<user id>/<hash>.png".
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	// Get returns ErrNotFound for missing files.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete does nothing for missing files.
+	Delete(ctx context.Context, key string) error
+	// URL is where clients download the file from.
+	URL(key string) string
+}
+
+func New(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocal(cfg.Local.Dir, cfg.PublicURL), nil
+	case "s3":
+		timeout := cfg.S3.Timeout
+		if timeout <= 0 {
+			timeout = defaultS3Timeout
+		}
+		return NewS3(cfg.S3, cfg.PublicURL, &http.Client{Timeout: timeout})
+	}
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+}
+
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
+
+func joinURL(base, key string) string {
+	return strings.TrimSuffix(base, "/") + "/" + uriEncode(key, false)
+}
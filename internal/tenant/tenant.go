@@ -0,0 +1,22 @@
+package tenant
+
+import "context"
+
+// Tenant is the organization a request or service call acts within.
+type Tenant struct {
+	ID   int
+	Slug string
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, t Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tenant stored in ctx. Calls without a tenant, such
+// as background workers, are not scoped to any organization.
+func FromContext(ctx context.Context) (Tenant, bool) {
+	t, ok := ctx.Value(contextKey{}).(Tenant)
+	return t, ok
+}
@@ -0,0 +1,83 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/database"
+	"github.com/jackc/pgx/v5"
+)
+
+const DatabaseURLEnv = "TEST_DATABASE_URL"
+
+// NewDatabase creates a throwaway schema in the database pointed to by
+// TEST_DATABASE_URL, runs the embedded migrations inside it and drops it when
+// the test finishes. The test is skipped when the variable is not set.
+func NewDatabase(t *testing.T) *database.DbConnection {
+	t.Helper()
+
+	dbURL := os.Getenv(DatabaseURLEnv)
+	if dbURL == "" {
+		t.Skipf("%s is not set", DatabaseURLEnv)
+	}
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := execAdmin(ctx, dbURL, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("Error while creating schema: %v", err)
+	}
+
+	schemaURL, err := withSearchPath(dbURL, schema)
+	if err != nil {
+		t.Fatalf("Error while parsing %s: %v", DatabaseURLEnv, err)
+	}
+
+	conn := &database.DbConnection{Config: &config.Config{
+		Database: config.DatabaseConfig{URL: schemaURL, MaxConnections: 4},
+	}}
+
+	t.Cleanup(func() {
+		conn.Close()
+		if err := execAdmin(ctx, dbURL, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Logf("Error while dropping schema %v: %v", schema, err)
+		}
+	})
+
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("Error while connecting to test schema: %v", err)
+	}
+	if err := conn.RunMigrations("up"); err != nil {
+		t.Fatalf("Error while running migrations: %v", err)
+	}
+
+	return conn
+}
+
+func execAdmin(ctx context.Context, dbURL string, sql string) error {
+	conn, err := pgx.Connect(ctx, dbURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
+
+func withSearchPath(dbURL string, schema string) (string, error) {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
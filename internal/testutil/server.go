@@ -0,0 +1,198 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Romasmi/go-rest-api-template/internal/application"
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/database"
+)
+
+const APIPrefix = "/api/v1"
+
+// Server runs the full application router on an httptest.Server backed by an
+// isolated database schema.
+type Server struct {
+	*httptest.Server
+	DB     *database.DbConnection
+	Config *config.Config
+	t      *testing.T
+}
+
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+
+	db := NewDatabase(t)
+	cfg := *db.Config
+	cfg.JWT = config.JWTConfig{Secret: "test-secret"}
+	cfg.Storage = config.StorageConfig{Local: config.LocalStorageConfig{Dir: t.TempDir()}}
+
+	app, err := application.NewApp(&cfg, db)
+	if err != nil {
+		t.Fatalf("Error while building app: %v", err)
+	}
+	server := httptest.NewServer(app.Router())
+	t.Cleanup(server.Close)
+
+	return &Server{
+		Server: server,
+		DB:     db,
+		Config: &cfg,
+		t:      t,
+	}
+}
+
+// Request starts building a request against the API. The path is relative to
+// the /api/v1 prefix.
+func (s *Server) Request(method, path string) *Request {
+	return &Request{
+		server:  s,
+		method:  method,
+		path:    APIPrefix + path,
+		headers: http.Header{},
+	}
+}
+
+// Register creates a user and returns its token.
+func (s *Server) Register(username, password string) string {
+	s.t.Helper()
+
+	var body map[string]string
+	s.Request(http.MethodPost, "/auth/register").
+		JSON(map[string]string{
+			"username": username,
+			"email":    username + "@example.com",
+			"password": password,
+		}).
+		Do().
+		ExpectStatus(http.StatusCreated).
+		Decode(&body)
+
+	return body["token"]
+}
+
+// Login returns a token for existing credentials.
+func (s *Server) Login(username, password string) string {
+	s.t.Helper()
+
+	var body map[string]string
+	s.Request(http.MethodPost, "/auth/login").
+		JSON(map[string]string{"username": username, "password": password}).
+		Do().
+		ExpectStatus(http.StatusOK).
+		Decode(&body)
+
+	return body["token"]
+}
+
+// GrantRole makes role the only role of username, bypassing the API. Tokens
+// issued afterwards carry the new role.
+func (s *Server) GrantRole(username, role string) {
+	s.t.Helper()
+
+	ctx := context.Background()
+	_, err := s.DB.DB.Exec(ctx, `UPDATE users SET role = $2 WHERE username = $1`, username, role)
+	if err == nil {
+		_, err = s.DB.DB.Exec(ctx, `
+			WITH u AS (SELECT id FROM users WHERE username = $1),
+				deleted AS (DELETE FROM user_roles WHERE user_id = (SELECT id FROM u))
+			INSERT INTO user_roles (user_id, role_id)
+			SELECT (SELECT id FROM u), id FROM roles WHERE name = $2
+		`, username, role)
+	}
+	if err != nil {
+		s.t.Fatalf("Error while granting %v to %v: %v", role, username, err)
+	}
+}
+
+type Request struct {
+	server  *Server
+	method  string
+	path    string
+	body    []byte
+	headers http.Header
+}
+
+func (r *Request) JSON(body interface{}) *Request {
+	r.server.t.Helper()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		r.server.t.Fatalf("Error while encoding request body: %v", err)
+	}
+	r.body = payload
+	r.headers.Set("Content-Type", "application/json")
+
+	return r
+}
+
+func (r *Request) Body(body []byte, contentType string) *Request {
+	r.body = body
+	r.headers.Set("Content-Type", contentType)
+	return r
+}
+
+func (r *Request) WithToken(token string) *Request {
+	r.headers.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func (r *Request) Header(key, value string) *Request {
+	r.headers.Set(key, value)
+	return r
+}
+
+func (r *Request) Do() *Response {
+	t := r.server.t
+	t.Helper()
+
+	req, err := http.NewRequest(r.method, r.server.URL+r.path, bytes.NewReader(r.body))
+	if err != nil {
+		t.Fatalf("Error while building request: %v", err)
+	}
+	req.Header = r.headers
+
+	resp, err := r.server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Error while sending %v %v: %v", r.method, r.path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error while reading response body: %v", err)
+	}
+
+	return &Response{Response: resp, Body: body, t: t}
+}
+
+type Response struct {
+	*http.Response
+	Body []byte
+	t    *testing.T
+}
+
+func (r *Response) ExpectStatus(code int) *Response {
+	r.t.Helper()
+
+	if r.StatusCode != code {
+		r.t.Fatalf("Wrong status for %v %v, expected: %v, actual: %v, body: %s",
+			r.Request.Method, r.Request.URL.Path, code, r.StatusCode, r.Body)
+	}
+	return r
+}
+
+func (r *Response) Decode(v interface{}) *Response {
+	r.t.Helper()
+
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		r.t.Fatalf("Error while decoding response %s: %v", r.Body, err)
+	}
+	return r
+}
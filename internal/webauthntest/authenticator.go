@@ -0,0 +1,243 @@
+// Package webauthntest is a software passkey authenticator for tests.
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+var ErrNoCredential = errors.New("no matching credential")
+
+// Authenticator creates resident ES256 credentials with "none" attestation
+// and answers ceremonies as a browser at Origin would.
+type Authenticator struct {
+	Origin      string
+	credentials []*credential
+}
+
+type credential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	counter    uint32
+}
+
+func New(origin string) *Authenticator {
+	return &Authenticator{Origin: origin}
+}
+
+type descriptor struct {
+	ID string `json:"id"`
+}
+
+type creationOptions struct {
+	PublicKey struct {
+		Challenge string `json:"challenge"`
+		RP        struct {
+			ID string `json:"id"`
+		} `json:"rp"`
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+		ExcludeCredentials []descriptor `json:"excludeCredentials"`
+	} `json:"publicKey"`
+}
+
+type requestOptions struct {
+	PublicKey struct {
+		Challenge        string       `json:"challenge"`
+		RPID             string       `json:"rpId"`
+		AllowCredentials []descriptor `json:"allowCredentials"`
+	} `json:"publicKey"`
+}
+
+// Register answers the creation options returned by a registration ceremony
+// with a new credential.
+func (a *Authenticator) Register(options interface{}) (json.RawMessage, error) {
+	var opts creationOptions
+	if err := decodeOptions(options, &opts); err != nil {
+		return nil, err
+	}
+	rpID := a.rpID(opts.PublicKey.RP.ID)
+	for _, excluded := range opts.PublicKey.ExcludeCredentials {
+		if a.find(rpID, excluded.ID) != nil {
+			return nil, errors.New("credential already registered")
+		}
+	}
+	userHandle, err := decodeBase64(opts.PublicKey.User.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode user id: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	cred := &credential{id: id, rpID: rpID, userHandle: userHandle, key: key}
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1,
+		XCoord: key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	authData := cred.authData(flagUserPresent | flagUserVerified | flagAttestedData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(id)))
+	authData = append(authData, id...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(struct {
+		Format   string                 `cbor:"fmt"`
+		AttStmt  map[string]interface{} `cbor:"attStmt"`
+		AuthData []byte                 `cbor:"authData"`
+	}{"none", map[string]interface{}{}, authData})
+	if err != nil {
+		return nil, err
+	}
+
+	a.credentials = append(a.credentials, cred)
+	return json.Marshal(map[string]interface{}{
+		"id":    encodeBase64(id),
+		"rawId": encodeBase64(id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    encodeBase64(a.clientData("webauthn.create", opts.PublicKey.Challenge)),
+			"attestationObject": encodeBase64(attestation),
+			"transports":        []string{"internal"},
+		},
+	})
+}
+
+// Login answers the request options returned by a login ceremony with an
+// assertion from a matching credential.
+func (a *Authenticator) Login(options interface{}) (json.RawMessage, error) {
+	var opts requestOptions
+	if err := decodeOptions(options, &opts); err != nil {
+		return nil, err
+	}
+	rpID := a.rpID(opts.PublicKey.RPID)
+
+	var cred *credential
+	if len(opts.PublicKey.AllowCredentials) == 0 {
+		cred = a.find(rpID, "")
+	}
+	for _, allowed := range opts.PublicKey.AllowCredentials {
+		if cred = a.find(rpID, allowed.ID); cred != nil {
+			break
+		}
+	}
+	if cred == nil {
+		return nil, ErrNoCredential
+	}
+
+	cred.counter++
+	authData := cred.authData(flagUserPresent | flagUserVerified)
+	clientData := a.clientData("webauthn.get", opts.PublicKey.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"id":    encodeBase64(cred.id),
+		"rawId": encodeBase64(cred.id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    encodeBase64(clientData),
+			"authenticatorData": encodeBase64(authData),
+			"signature":         encodeBase64(signature),
+			"userHandle":        encodeBase64(cred.userHandle),
+		},
+	})
+}
+
+// find returns the credential for rpID with the given base64url ID, or the
+// first one for rpID when id is empty.
+func (a *Authenticator) find(rpID, id string) *credential {
+	rawID, _ := decodeBase64(id)
+	for _, cred := range a.credentials {
+		if cred.rpID == rpID && (id == "" || bytes.Equal(cred.id, rawID)) {
+			return cred
+		}
+	}
+	return nil
+}
+
+// rpID defaults to the host of the origin like browsers do.
+func (a *Authenticator) rpID(rpID string) string {
+	if rpID != "" {
+		return rpID
+	}
+	origin, _ := url.Parse(a.Origin)
+	return origin.Hostname()
+}
+
+func (a *Authenticator) clientData(ceremony, challenge string) []byte {
+	clientData, _ := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    a.Origin,
+	})
+	return clientData
+}
+
+func (c *credential) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(c.rpID))
+	authData := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(authData, c.counter)
+}
+
+func decodeOptions(options interface{}, v interface{}) error {
+	raw, ok := options.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(options); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("failed to decode options: %w", err)
+	}
+	return nil
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
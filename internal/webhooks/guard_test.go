@@ -0,0 +1,37 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url          string
+		allowPrivate bool
+		valid        bool
+	}{
+		{url: "https://93.184.215.14/hook", valid: true},
+		{url: "http://127.0.0.1:8080/hook"},
+		{url: "http://10.0.0.5/hook"},
+		{url: "http://169.254.169.254/latest/meta-data"},
+		{url: "http://[::1]/hook"},
+		{url: "http://[::ffff:192.168.1.1]/hook"},
+		{url: "http://100.64.0.1/hook"},
+		{url: "http://127.0.0.1:8080/hook", allowPrivate: true, valid: true},
+		{url: "ftp://93.184.215.14/hook", allowPrivate: true},
+		{url: "http:///hook", allowPrivate: true},
+	}
+
+	for _, tt := range tests {
+		err := CheckURL(context.Background(), tt.url, tt.allowPrivate)
+		if (err == nil) != tt.valid {
+			t.Errorf("Wrong result for %s, expected valid: %v, actual: %v", tt.url, tt.valid, err)
+		}
+	}
+
+	if err := CheckURL(context.Background(), "http://127.0.0.1/hook", false); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("Expected ErrForbiddenAddress, actual: %v", err)
+	}
+}
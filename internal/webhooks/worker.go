@@ -0,0 +1,203 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/config"
+	"github.com/Romasmi/go-rest-api-template/internal/events"
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/secrets"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 50
+	defaultMaxAttempts  = 8
+	defaultTimeout      = 10 * time.Second
+	defaultLease        = 5 * time.Minute
+)
+
+type WorkerOptions struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is the number of failed attempts after which a delivery is
+	// moved to the dead state. Dead deliveries can be replayed by an admin.
+	MaxAttempts int
+	Backoff     func(attempts int) time.Duration
+	// Lease is how long claimed deliveries are hidden from other workers
+	// while they are sent. It should be well above the client timeout times
+	// the batch size.
+	Lease time.Duration
+	// Client sends the deliveries. It defaults to NewClient, which refuses
+	// private addresses.
+	Client *http.Client
+}
+
+func NewWorkerOptions(config config.WebhooksConfig) WorkerOptions {
+	opts := WorkerOptions{
+		PollInterval: config.PollInterval,
+		MaxAttempts:  config.MaxAttempts,
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	opts.Client = NewClient(timeout, config.AllowPrivateNetworks)
+	return opts
+}
+
+// Worker sends pending webhook deliveries to their subscribers.
+type Worker struct {
+	repo    repository.WebhookRepository
+	secrets *secrets.Cipher
+	opts    WorkerOptions
+	logger  *log.Logger
+}
+
+// NewWorker returns a worker that decrypts subscription secrets with cipher.
+func NewWorker(repo repository.WebhookRepository, cipher *secrets.Cipher, opts WorkerOptions) *Worker {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = events.ExponentialBackoff(10*time.Second, 6*time.Hour)
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = defaultLease
+	}
+	if opts.Client == nil {
+		opts.Client = NewClient(defaultTimeout, false)
+	}
+
+	return &Worker{
+		repo:    repo,
+		secrets: cipher,
+		opts:    opts,
+		logger:  log.New(log.Writer(), "WEBHOOKS: ", log.LstdFlags),
+	}
+}
+
+// Run delivers webhooks until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := w.DeliverOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			w.logger.Printf("failed to deliver webhooks: %v", err)
+		}
+
+		if n == w.opts.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverOnce attempts one batch of due deliveries and returns how many were
+// claimed. Claiming leases the deliveries instead of keeping them locked, so
+// no transaction is held open while subscribers are called. Deliveries left
+// when the lease runs out are not attempted and become due again.
+func (w *Worker) DeliverOnce(ctx context.Context) (int, error) {
+	claimedAt := time.Now()
+	deliveries, err := w.repo.ClaimDueDeliveries(ctx, w.opts.BatchSize, w.opts.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		if time.Since(claimedAt) >= w.opts.Lease {
+			break
+		}
+
+		subscription, err := w.repo.GetSubscription(ctx, delivery.SubscriptionID)
+		if err != nil {
+			return len(deliveries), err
+		}
+
+		statusCode, sendErr := w.send(ctx, subscription, delivery)
+		w.record(delivery, statusCode, sendErr)
+		if err := w.repo.UpdateDelivery(ctx, delivery); err != nil {
+			return len(deliveries), err
+		}
+	}
+	return len(deliveries), nil
+}
+
+func (w *Worker) send(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	if !subscription.Active {
+		return 0, fmt.Errorf("subscription %d is inactive", subscription.ID)
+	}
+
+	secret, err := w.secrets.Decrypt(subscription.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt secret of subscription %d: %w", subscription.ID, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(secret, now, delivery.Payload))
+	req.Header.Set(EventIDHeader, delivery.EventID.String())
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+
+	resp, err := w.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (w *Worker) record(delivery *models.WebhookDelivery, statusCode int, sendErr error) {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= w.opts.MaxAttempts:
+		delivery.Status = models.WebhookDeliveryDead
+		delivery.LastError = sendErr.Error()
+	default:
+		delivery.LastError = sendErr.Error()
+		delivery.NextAttemptAt = now.Add(w.opts.Backoff(delivery.Attempts))
+	}
+}
@@ -0,0 +1,151 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Romasmi/go-rest-api-template/internal/models"
+	"github.com/Romasmi/go-rest-api-template/internal/repository"
+	"github.com/Romasmi/go-rest-api-template/internal/secrets"
+	"github.com/google/uuid"
+)
+
+const testSecret = "0123456789abcdef"
+
+func newCipher(t *testing.T) *secrets.Cipher {
+	t.Helper()
+	cipher, err := secrets.NewCipher([]byte("test-key"))
+	if err != nil {
+		t.Fatalf("Error while creating cipher: %v", err)
+	}
+	return cipher
+}
+
+func newSubscription(t *testing.T, repo repository.WebhookRepository, url string) *models.WebhookSubscription {
+	t.Helper()
+	secret, err := newCipher(t).Encrypt(testSecret)
+	if err != nil {
+		t.Fatalf("Error while encrypting secret: %v", err)
+	}
+	subscription, err := repo.CreateSubscription(context.Background(), &models.WebhookSubscriptionCreate{
+		URL:        url,
+		EventTypes: []string{models.WebhookAllEvents},
+		Secret:     secret,
+	})
+	if err != nil {
+		t.Fatalf("Error while creating subscription: %v", err)
+	}
+
+	err = repo.EnqueueDelivery(context.Background(), &models.WebhookDelivery{
+		SubscriptionID: subscription.ID,
+		EventID:        uuid.New(),
+		EventType:      models.EventUserRegistered,
+		Payload:        []byte(`{"type":"user.registered"}`),
+	})
+	if err != nil {
+		t.Fatalf("Error while enqueueing delivery: %v", err)
+	}
+	return subscription
+}
+
+func TestWorkerSignsDeliveries(t *testing.T) {
+	var verifyErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = Verify(testSecret, r.Header.Get(SignatureHeader), r.Header.Get(TimestampHeader), body, time.Minute)
+	}))
+	defer server.Close()
+
+	repo := repository.NewMemoryWebhookRepository()
+	newSubscription(t, repo, server.URL)
+
+	worker := NewWorker(repo, newCipher(t), WorkerOptions{Client: NewClient(time.Second, true)})
+	if _, err := worker.DeliverOnce(context.Background()); err != nil {
+		t.Fatalf("Error while delivering: %v", err)
+	}
+	if verifyErr != nil {
+		t.Errorf("Expected valid signature, actual: %v", verifyErr)
+	}
+
+	delivery, _ := repo.GetDelivery(context.Background(), 1)
+	if delivery.Status != models.WebhookDeliverySucceeded || delivery.LastStatusCode != http.StatusOK {
+		t.Errorf("Wrong delivery state, expected: %v/%v, actual: %v/%v", models.WebhookDeliverySucceeded, http.StatusOK, delivery.Status, delivery.LastStatusCode)
+	}
+
+	if err := Verify(testSecret, Sign(testSecret, time.Unix(0, 0), []byte("{}")), "0", []byte("{}"), time.Minute); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for stale timestamp, actual: %v", err)
+	}
+}
+
+func TestWorkerRetriesUntilDead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	repo := repository.NewMemoryWebhookRepository()
+	newSubscription(t, repo, server.URL)
+
+	worker := NewWorker(repo, newCipher(t), WorkerOptions{
+		Client:      NewClient(time.Second, true),
+		MaxAttempts: 3,
+		Backoff:     func(int) time.Duration { return 0 },
+	})
+	for i := 0; i < 4; i++ {
+		if _, err := worker.DeliverOnce(context.Background()); err != nil {
+			t.Fatalf("Error while delivering: %v", err)
+		}
+	}
+
+	delivery, _ := repo.GetDelivery(context.Background(), 1)
+	if delivery.Status != models.WebhookDeliveryDead || delivery.Attempts != 3 {
+		t.Errorf("Wrong delivery state, expected: %v/%v, actual: %v/%v", models.WebhookDeliveryDead, 3, delivery.Status, delivery.Attempts)
+	}
+	if delivery.LastStatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Wrong last status code, expected: %v, actual: %v", http.StatusServiceUnavailable, delivery.LastStatusCode)
+	}
+}
+
+func TestWorkerRefusesPrivateAddresses(t *testing.T) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	repo := repository.NewMemoryWebhookRepository()
+	newSubscription(t, repo, server.URL)
+
+	worker := NewWorker(repo, newCipher(t), WorkerOptions{Backoff: func(int) time.Duration { return 0 }})
+	if _, err := worker.DeliverOnce(context.Background()); err != nil {
+		t.Fatalf("Error while delivering: %v", err)
+	}
+	if called {
+		t.Error("Expected the loopback subscriber not to be called")
+	}
+
+	delivery, _ := repo.GetDelivery(context.Background(), 1)
+	if delivery.Status != models.WebhookDeliveryPending || !strings.Contains(delivery.LastError, ErrForbiddenAddress.Error()) {
+		t.Errorf("Wrong delivery state, expected: %v/%v, actual: %v/%v", models.WebhookDeliveryPending, ErrForbiddenAddress, delivery.Status, delivery.LastError)
+	}
+}
+
+func TestClaimDueDeliveriesLeases(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryWebhookRepository()
+	newSubscription(t, repo, "http://example.com")
+
+	claimed, err := repo.ClaimDueDeliveries(ctx, 10, time.Minute)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("Wrong first claim, expected: %v, actual: %v (%v)", 1, len(claimed), err)
+	}
+	again, err := repo.ClaimDueDeliveries(ctx, 10, time.Minute)
+	if err != nil || len(again) != 0 {
+		t.Errorf("Wrong second claim, expected: %v, actual: %v (%v)", 0, len(again), err)
+	}
+}
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_public_id_key;
+ALTER TABLE users DROP COLUMN IF EXISTS public_id;
+DROP FUNCTION IF EXISTS uuid_generate_v7(TIMESTAMPTZ);
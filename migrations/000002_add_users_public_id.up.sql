@@ -0,0 +1,22 @@
+-- Time-ordered UUIDv7 built from a v4 UUID: the first 48 bits are replaced by
+-- the unix time in milliseconds and the version nibble is switched to 7.
+CREATE OR REPLACE FUNCTION uuid_generate_v7(ts TIMESTAMPTZ DEFAULT clock_timestamp())
+RETURNS UUID AS $$
+    SELECT encode(
+        set_bit(
+            set_bit(
+                overlay(uuid_send(gen_random_uuid())
+                        PLACING substring(int8send(floor(extract(epoch FROM ts) * 1000)::BIGINT) FROM 3)
+                        FROM 1 FOR 6),
+                52, 1),
+            53, 1),
+        'hex')::UUID;
+$$ LANGUAGE SQL VOLATILE;
+
+ALTER TABLE users ADD COLUMN public_id UUID;
+
+UPDATE users SET public_id = uuid_generate_v7(COALESCE(created_at, NOW())) WHERE public_id IS NULL;
+
+ALTER TABLE users ALTER COLUMN public_id SET DEFAULT uuid_generate_v7();
+ALTER TABLE users ALTER COLUMN public_id SET NOT NULL;
+ALTER TABLE users ADD CONSTRAINT users_public_id_key UNIQUE (public_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_outbox_events_ordering_key;
+DROP INDEX IF EXISTS idx_outbox_events_due;
+DROP TABLE IF EXISTS outbox_events;
@@ -0,0 +1,58 @@
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(100) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS roles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    builtin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE RESTRICT,
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX idx_user_roles_role_id ON user_roles(role_id);
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:read', 'List and view users'),
+    ('users:write', 'Update users'),
+    ('users:delete', 'Delete users'),
+    ('users:export', 'Export every user of the organization'),
+    ('roles:manage', 'Manage roles and assign them to users'),
+    ('audit:read', 'Read and export the audit log'),
+    ('webhooks:manage', 'Manage webhook subscriptions and deliveries');
+
+INSERT INTO roles (name, description, builtin) VALUES
+    ('admin', 'Full access', TRUE),
+    ('user', 'Regular user', TRUE),
+    ('support', 'Support staff with read access to users', FALSE);
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, p.name FROM roles r CROSS JOIN permissions p WHERE r.name = 'admin';
+
+-- Regular users may read other users but only change their own account,
+-- which goes through /me.
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, 'users:read' FROM roles r WHERE r.name IN ('user', 'support');
+
+-- Any other role values already stored on users become roles without
+-- permissions so that no assignment is lost.
+INSERT INTO roles (name)
+SELECT DISTINCT role FROM users WHERE role <> ''
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u JOIN roles r ON r.name = u.role;
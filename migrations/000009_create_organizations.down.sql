@@ -0,0 +1,20 @@
+DROP POLICY IF EXISTS users_tenant_isolation ON users;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+
+DELETE FROM role_permissions WHERE permission = 'organizations:manage';
+DELETE FROM permissions WHERE name = 'organizations:manage';
+
+DROP INDEX IF EXISTS idx_audit_events_organization_id;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS organization_id;
+
+DROP INDEX IF EXISTS idx_api_keys_organization_id;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS organization_id;
+
+-- Fails if two organizations share a username or email.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_organization_email_key;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_organization_username_key;
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username);
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+ALTER TABLE users DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organizations;
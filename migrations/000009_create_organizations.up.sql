@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(63) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- The default organization keeps id 1 so existing rows and callers without a
+-- tenant can refer to it through the column defaults below.
+INSERT INTO organizations (id, slug, name) VALUES (1, 'default', 'Default');
+SELECT setval(pg_get_serial_sequence('organizations', 'id'), 1);
+
+ALTER TABLE users ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE users DROP CONSTRAINT users_username_key;
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_organization_username_key UNIQUE (organization_id, username);
+ALTER TABLE users ADD CONSTRAINT users_organization_email_key UNIQUE (organization_id, email);
+
+ALTER TABLE api_keys ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id) ON DELETE CASCADE;
+CREATE INDEX idx_api_keys_organization_id ON api_keys(organization_id);
+
+-- audit_events is append-only, so the column is added without touching rows.
+ALTER TABLE audit_events ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX idx_audit_events_organization_id ON audit_events(organization_id);
+
+INSERT INTO permissions (name, description) VALUES
+    ('organizations:manage', 'Create organizations (default organization only)');
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'organizations:manage' FROM roles WHERE name = 'admin';
+
+-- Optional defence in depth: the repositories already scope every users query,
+-- and transactions set app.tenant_id with SET LOCAL semantics. The policy only
+-- applies to roles that do not own the table, or to all of them after
+-- ALTER TABLE users FORCE ROW LEVEL SECURITY.
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+CREATE POLICY users_tenant_isolation ON users
+    USING (
+        COALESCE(current_setting('app.tenant_id', TRUE), '') = ''
+        OR organization_id = current_setting('app.tenant_id', TRUE)::INTEGER
+    );
@@ -0,0 +1,3 @@
+-- Tokens carry the version they were issued at. Bumping it signs the user out
+-- everywhere.
+ALTER TABLE users ADD COLUMN token_version INT NOT NULL DEFAULT 0;
@@ -0,0 +1,19 @@
+-- Lets API replicas invalidate their users cache when any of them, or
+-- anything else, changes a user. Notifications are delivered on commit.
+CREATE OR REPLACE FUNCTION notify_users_changed() RETURNS TRIGGER AS $$
+DECLARE
+    changed users;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed := OLD;
+    ELSE
+        changed := NEW;
+    END IF;
+    PERFORM pg_notify('users_changed', changed.organization_id || ':' || changed.public_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_changed
+AFTER INSERT OR UPDATE OR DELETE ON users
+FOR EACH ROW EXECUTE FUNCTION notify_users_changed();
@@ -0,0 +1,2 @@
+-- Disabled users can no longer sign in.
+ALTER TABLE users ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
@@ -0,0 +1,15 @@
+-- Suspended users can sign in again once suspended_until has passed.
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS user_status_changes (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    suspended_until TIMESTAMP,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_user_status_changes_user_id ON user_status_changes(user_id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_users_attributes;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS display_name,
+    DROP COLUMN IF EXISTS locale,
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS attributes,
+    DROP COLUMN IF EXISTS avatar_key,
+    DROP COLUMN IF EXISTS avatar_url;
@@ -0,0 +1,10 @@
+ALTER TABLE users
+    ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN locale TEXT NOT NULL DEFAULT '',
+    ADD COLUMN timezone TEXT NOT NULL DEFAULT '',
+    -- App-specific values, checked against the configured JSON Schema.
+    ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN avatar_key TEXT NOT NULL DEFAULT '',
+    ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_users_attributes ON users USING GIN (attributes);